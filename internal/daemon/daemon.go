@@ -26,6 +26,41 @@ type Reporter struct {
 	Skipped func()
 }
 
+// CompositeReporter returns a Reporter invoking the probes of all provided
+// reporters in order. Probes not set on a reporter are skipped.
+func CompositeReporter(reporters ...*Reporter) *Reporter {
+	return &Reporter{
+		Started: func(interval time.Duration) {
+			for _, r := range reporters {
+				if r.Started != nil {
+					r.Started(interval)
+				}
+			}
+		},
+		Stopped: func() {
+			for _, r := range reporters {
+				if r.Stopped != nil {
+					r.Stopped()
+				}
+			}
+		},
+		Ticked: func() {
+			for _, r := range reporters {
+				if r.Ticked != nil {
+					r.Ticked()
+				}
+			}
+		},
+		Skipped: func() {
+			for _, r := range reporters {
+				if r.Skipped != nil {
+					r.Skipped()
+				}
+			}
+		},
+	}
+}
+
 func (c *Configuration) setDefaults() {
 	if c.Reporter == nil {
 		c.Reporter = &Reporter{}
@@ -69,3 +69,32 @@ func TestDaemon_Loop(t *testing.T) {
 	// loop actually loops.
 	assert.InEpsilon(t, expectedTickCount, actualTickCount, 0.2, "tick count %d not as the expected %d", actualTickCount, expectedTickCount)
 }
+
+func TestCompositeReporter(t *testing.T) {
+	var calls []string
+	reporter := daemon.CompositeReporter(
+		&daemon.Reporter{
+			Started: func(time.Duration) {
+				calls = append(calls, "first started")
+			},
+			Ticked: func() {
+				calls = append(calls, "first ticked")
+			},
+		},
+		&daemon.Reporter{
+			Started: func(time.Duration) {
+				calls = append(calls, "second started")
+			},
+			Stopped: func() {
+				calls = append(calls, "second stopped")
+			},
+		},
+	)
+
+	reporter.Started(time.Second)
+	reporter.Ticked()
+	reporter.Skipped()
+	reporter.Stopped()
+
+	assert.Equal(t, []string{"first started", "second started", "first ticked", "second stopped"}, calls, "calls not as expected")
+}
@@ -0,0 +1,17 @@
+package httpchecker
+
+type compositeReporter struct {
+	reporters []Reporter
+}
+
+func (r compositeReporter) ReportHTTPCheck(report Report) {
+	for _, reporter := range r.reporters {
+		reporter.ReportHTTPCheck(report)
+	}
+}
+
+func CompositeReporter(reporters ...Reporter) Reporter {
+	return &compositeReporter{
+		reporters: reporters,
+	}
+}
@@ -0,0 +1,126 @@
+package httpchecker
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"time"
+)
+
+// maxBodySize is the maximum number of bytes of a response body matched
+// against the body expectation.
+const maxBodySize = 1 << 20
+
+// Check requests target and reports the result to reporter. Options that are
+// not set on target use their defaults.
+//
+// Redirects are not followed so the status code of the target itself is
+// checked. A new connection is used for each check so the TLS handshake is
+// measured every time.
+func Check(target Target, reporter Reporter) {
+	target.setDefaults()
+	report := Report{
+		Name:   target.Name,
+		Method: target.Method,
+		URL:    target.URL,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), target.Timeout)
+	defer cancel()
+	var tlsStart time.Time
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		TLSHandshakeStart: func() {
+			tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			report.TLSHandshakeDuration = time.Since(tlsStart)
+		},
+	})
+	req, err := http.NewRequestWithContext(ctx, target.Method, target.URL, nil)
+	if err != nil {
+		report.Status = "Request error"
+		report.Error = err
+		report.Failure = FailureUnknown
+		reporter.ReportHTTPCheck(report)
+		return
+	}
+	client := http.Client{
+		Transport: &http.Transport{
+			DisableKeepAlives: true,
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: target.InsecureSkipVerify,
+			},
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		report.ResponseDuration = time.Since(start)
+		report.Status = "Request error"
+		report.Error = err
+		report.Failure = classifyError(err)
+		reporter.ReportHTTPCheck(report)
+		return
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	report.ResponseDuration = time.Since(start)
+	report.StatusCode = resp.StatusCode
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) != 0 {
+		report.CertificateExpiry = resp.TLS.PeerCertificates[0].NotAfter
+	}
+	switch {
+	case err != nil:
+		report.Status = "Read error"
+		report.Error = err
+		report.Failure = classifyError(err)
+	case !target.expectedStatusCode(resp.StatusCode):
+		report.Status = fmt.Sprintf("Unexpected status code %d", resp.StatusCode)
+		report.Failure = FailureStatusCode
+	case target.Body != nil && !target.Body.Match(body):
+		report.Status = "Unexpected body"
+		report.Failure = FailureBody
+	default:
+		report.Status = resp.Status
+		report.Healthy = true
+	}
+	reporter.ReportHTTPCheck(report)
+}
+
+// classifyError returns the FailureReason of err.
+func classifyError(err error) FailureReason {
+	var dnsError *net.DNSError
+	var opError *net.OpError
+	var recordHeaderError tls.RecordHeaderError
+	var certificateError *tls.CertificateVerificationError
+	var unknownAuthorityError x509.UnknownAuthorityError
+	var hostnameError x509.HostnameError
+	var invalidError x509.CertificateInvalidError
+	var netError net.Error
+	switch {
+	case errors.As(err, &dnsError):
+		return FailureDNS
+	case errors.As(err, &certificateError),
+		errors.As(err, &unknownAuthorityError),
+		errors.As(err, &hostnameError),
+		errors.As(err, &invalidError),
+		errors.As(err, &recordHeaderError):
+		return FailureTLS
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netError) && netError.Timeout():
+		return FailureTimeout
+	case errors.As(err, &opError):
+		return FailureConnection
+	default:
+		return FailureUnknown
+	}
+}
@@ -0,0 +1,168 @@
+package httpchecker
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type reportRecorder struct {
+	reports []Report
+}
+
+func (r *reportRecorder) ReportHTTPCheck(report Report) {
+	r.reports = append(r.reports, report)
+}
+
+func check(t *testing.T, target Target) (Report, bool) {
+	t.Helper()
+	recorder := &reportRecorder{}
+	Check(target, recorder)
+	if !assert.Len(t, recorder.reports, 1, "number of reports not as expected") {
+		return Report{}, false
+	}
+	return recorder.reports[0], true
+}
+
+func TestCheck(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"ok"}`))
+	})
+	mux.HandleFunc("/unavailable", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/health", http.StatusFound)
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	})
+	mux.HandleFunc("/method", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Method))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tt := []struct {
+		name       string
+		target     Target
+		healthy    bool
+		statusCode int
+		failure    FailureReason
+	}{
+		{
+			name:       "healthy",
+			target:     Target{URL: server.URL + "/health"},
+			healthy:    true,
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "expected body",
+			target:     Target{URL: server.URL + "/health", Body: regexp.MustCompile(`"status":"ok"`)},
+			healthy:    true,
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "unexpected body",
+			target:     Target{URL: server.URL + "/health", Body: regexp.MustCompile(`"status":"degraded"`)},
+			statusCode: http.StatusOK,
+			failure:    FailureBody,
+		},
+		{
+			name:       "unexpected status code",
+			target:     Target{URL: server.URL + "/unavailable"},
+			statusCode: http.StatusServiceUnavailable,
+			failure:    FailureStatusCode,
+		},
+		{
+			name:       "expected status code",
+			target:     Target{URL: server.URL + "/unavailable", ExpectedStatusCodes: []int{200, 503}},
+			healthy:    true,
+			statusCode: http.StatusServiceUnavailable,
+		},
+		{
+			name:       "redirects are not followed",
+			target:     Target{URL: server.URL + "/redirect"},
+			statusCode: http.StatusFound,
+			failure:    FailureStatusCode,
+		},
+		{
+			name:    "timeout",
+			target:  Target{URL: server.URL + "/slow", Timeout: 50 * time.Millisecond},
+			failure: FailureTimeout,
+		},
+		{
+			name:       "method",
+			target:     Target{URL: server.URL + "/method", Method: http.MethodPost, Body: regexp.MustCompile("^POST$")},
+			healthy:    true,
+			statusCode: http.StatusOK,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			report, ok := check(t, tc.target)
+			if !ok {
+				return
+			}
+			assert.Equal(t, tc.healthy, report.Healthy, "healthy not as expected")
+			assert.Equal(t, tc.statusCode, report.StatusCode, "status code not as expected")
+			assert.Equal(t, tc.failure, report.Failure, "failure reason not as expected")
+			assert.NotZero(t, report.ResponseDuration, "response duration not set")
+			assert.Zero(t, report.TLSHandshakeDuration, "tls handshake duration set for plain http")
+			assert.True(t, report.CertificateExpiry.IsZero(), "certificate expiry set for plain http")
+		})
+	}
+}
+
+func TestCheck_connectionRefused(t *testing.T) {
+	// reserve a port and close it again to get one nothing listens on
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen on tcp: %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	report, ok := check(t, Target{URL: "http://" + address + "/"})
+	if !ok {
+		return
+	}
+	assert.False(t, report.Healthy, "reported healthy")
+	assert.Equal(t, FailureConnection, report.Failure, "failure reason not as expected")
+}
+
+func TestCheck_tls(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	t.Run("verified", func(t *testing.T) {
+		report, ok := check(t, Target{URL: server.URL})
+		if !ok {
+			return
+		}
+		assert.False(t, report.Healthy, "reported healthy")
+		assert.Equal(t, FailureTLS, report.Failure, "failure reason not as expected")
+		assert.NotZero(t, report.TLSHandshakeDuration, "tls handshake duration not set")
+	})
+
+	t.Run("insecure", func(t *testing.T) {
+		report, ok := check(t, Target{URL: server.URL, InsecureSkipVerify: true})
+		if !ok {
+			return
+		}
+		assert.True(t, report.Healthy, "reported unhealthy")
+		assert.NotZero(t, report.TLSHandshakeDuration, "tls handshake duration not set")
+		assert.Equal(t, server.Certificate().NotAfter, report.CertificateExpiry, "certificate expiry not as expected")
+	})
+}
@@ -0,0 +1,51 @@
+package httpchecker
+
+import (
+	"time"
+
+	"github.com/prometheus/common/log"
+)
+
+type logReporter struct {
+	lastReport  time.Time
+	lastHealthy bool
+	Logger      log.Logger
+}
+
+func (r *logReporter) ReportHTTPCheck(report Report) {
+	l := r.Logger.With("report", report)
+	switch {
+	case report.Healthy && r.lastHealthy:
+		// Endpoint is still healthy - great
+	case !report.Healthy && (r.lastHealthy || r.lastReport == time.Time{}):
+		// Endpoint became unhealthy
+		r.lastReport = time.Now()
+		r.lastHealthy = report.Healthy
+		l.
+			With("status", "unhealthy").
+			With("reason", report.Failure).
+			Infof("HTTP endpoint %s is unhealthy: %s", report.Name, report.Status)
+	case report.Healthy && !r.lastHealthy:
+		// Endpoint became healthy
+		r.lastReport = time.Now()
+		r.lastHealthy = report.Healthy
+		l.
+			With("status", "healthy").
+			Infof("HTTP endpoint %s is healthy", report.Name)
+	case !report.Healthy && !r.lastHealthy:
+		// Endpoint still unhealthy
+		if time.Since(r.lastReport) > 5*time.Minute {
+			r.lastReport = time.Now()
+			l.
+				With("status", "unhealthy").
+				With("reason", report.Failure).
+				Infof("HTTP endpoint %s is still unhealthy: %s", report.Name, report.Status)
+		}
+	default:
+		panic("This should never happen in LogReporter.ReportHTTPCheck")
+	}
+}
+
+func LogReporter(logger log.Logger) Reporter {
+	return &logReporter{Logger: logger}
+}
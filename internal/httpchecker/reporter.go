@@ -0,0 +1,55 @@
+package httpchecker
+
+import (
+	"time"
+)
+
+type Reporter interface {
+	ReportHTTPCheck(report Report)
+}
+
+type Report struct {
+	Name   string
+	Method string
+	URL    string
+	// Healthy is true if a response was received with an expected status code
+	// and body.
+	Healthy    bool
+	StatusCode int
+	Status     string
+	Error      error
+	// Failure is the reason the check failed. It is empty if Healthy is true.
+	Failure FailureReason
+	// ResponseDuration is the time from the request was sent until the full
+	// response was read or the request failed.
+	ResponseDuration time.Duration
+	// TLSHandshakeDuration is the duration of the TLS handshake. It is 0 for
+	// plain HTTP.
+	TLSHandshakeDuration time.Duration
+	// CertificateExpiry is when the leaf certificate of the peer expires. It is
+	// the zero time for plain HTTP.
+	CertificateExpiry time.Time
+}
+
+// FailureReason classifies why a check failed.
+type FailureReason string
+
+const (
+	// FailureConnection is reported when no connection could be established,
+	// eg. when it is refused or the host is unreachable.
+	FailureConnection FailureReason = "connection"
+	// FailureDNS is reported when the host cannot be resolved.
+	FailureDNS FailureReason = "dns"
+	// FailureTimeout is reported when no response is received in time.
+	FailureTimeout FailureReason = "timeout"
+	// FailureTLS is reported when the TLS handshake fails, eg. because of an
+	// invalid certificate.
+	FailureTLS FailureReason = "tls"
+	// FailureStatusCode is reported when the status code is not expected.
+	FailureStatusCode FailureReason = "status_code"
+	// FailureBody is reported when the response body does not match the
+	// expectation.
+	FailureBody FailureReason = "body"
+	// FailureUnknown is reported for all other errors.
+	FailureUnknown FailureReason = "unknown"
+)
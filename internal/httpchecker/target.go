@@ -0,0 +1,189 @@
+package httpchecker
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultInterval is the default interval between checks of a target.
+	DefaultInterval = 5 * time.Second
+	// DefaultTimeout is the default timeout of a full request.
+	DefaultTimeout = 2 * time.Second
+)
+
+// Target is an HTTP endpoint to check along with the expectations of its
+// response.
+type Target struct {
+	Name   string
+	Method string
+	URL    string
+	// ExpectedStatusCodes are the accepted status codes. If empty any 2xx
+	// status code is accepted.
+	ExpectedStatusCodes []int
+	// Body is the pattern the response body is expected to match.
+	Body *regexp.Regexp
+	// Interval is the interval between checks.
+	Interval time.Duration
+	// Timeout is the timeout of the full request including reading the body.
+	Timeout time.Duration
+	// InsecureSkipVerify disables verification of the peer certificate.
+	InsecureSkipVerify bool
+}
+
+func (t *Target) setDefaults() {
+	if t.Name == "" {
+		t.Name = t.URL
+	}
+	if t.Method == "" {
+		t.Method = http.MethodGet
+	}
+	if t.Interval == 0 {
+		t.Interval = DefaultInterval
+	}
+	if t.Timeout == 0 {
+		t.Timeout = DefaultTimeout
+	}
+}
+
+func (t Target) validate() error {
+	u, err := url.Parse(t.URL)
+	if err != nil {
+		return fmt.Errorf("could not parse url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("url scheme must be http or https")
+	}
+	if u.Host == "" {
+		return fmt.Errorf("url host is required")
+	}
+	for _, code := range t.ExpectedStatusCodes {
+		if code < 100 || code > 599 {
+			return fmt.Errorf("status code %d is out of range", code)
+		}
+	}
+	return nil
+}
+
+// expectedStatusCode reports whether code is accepted by t.
+func (t Target) expectedStatusCode(code int) bool {
+	if len(t.ExpectedStatusCodes) == 0 {
+		return code >= 200 && code < 300
+	}
+	for _, expected := range t.ExpectedStatusCodes {
+		if code == expected {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseTarget parses a target of the form <url> or <name>=<url>. The target
+// is checked with a GET request expecting a 2xx status code.
+func ParseTarget(s string) (Target, error) {
+	var t Target
+	name, rawURL, ok := strings.Cut(s, "=")
+	// a URL with a query contains = so only treat the first part as a name if
+	// it is not part of the URL
+	if ok && !strings.ContainsAny(name, ":/?") {
+		t.Name = name
+		t.URL = rawURL
+	} else {
+		t.URL = s
+	}
+	err := t.validate()
+	if err != nil {
+		return Target{}, err
+	}
+	t.setDefaults()
+	return t, nil
+}
+
+// targetsConfig is the format of a targets configuration file.
+type targetsConfig struct {
+	Targets []struct {
+		Name                string `json:"name"`
+		Method              string `json:"method"`
+		URL                 string `json:"url"`
+		ExpectedStatusCodes []int  `json:"expected_status_codes"`
+		Body                string `json:"body"`
+		Interval            string `json:"interval"`
+		Timeout             string `json:"timeout"`
+		InsecureSkipVerify  bool   `json:"insecure_skip_verify"`
+	} `json:"targets"`
+}
+
+// ReadTargets reads targets from a JSON configuration file. Options that are
+// omitted are set to their defaults.
+//
+//	{
+//	  "targets": [
+//	    {"name": "api", "url": "https://10.0.0.1/health", "expected_status_codes": [200], "body": "ok"}
+//	  ]
+//	}
+func ReadTargets(r io.Reader) ([]Target, error) {
+	var config targetsConfig
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&config)
+	if err != nil {
+		return nil, fmt.Errorf("decode targets: %w", err)
+	}
+	var targets []Target
+	for i, c := range config.Targets {
+		t := Target{
+			Name:                c.Name,
+			Method:              strings.ToUpper(c.Method),
+			URL:                 c.URL,
+			ExpectedStatusCodes: c.ExpectedStatusCodes,
+			InsecureSkipVerify:  c.InsecureSkipVerify,
+		}
+		if c.Body != "" {
+			t.Body, err = regexp.Compile(c.Body)
+			if err != nil {
+				return nil, fmt.Errorf("target %d: could not parse body: %w", i, err)
+			}
+		}
+		durations := []struct {
+			key   string
+			value string
+			d     *time.Duration
+		}{
+			{"interval", c.Interval, &t.Interval},
+			{"timeout", c.Timeout, &t.Timeout},
+		}
+		for _, d := range durations {
+			if d.value == "" {
+				continue
+			}
+			*d.d, err = parsePositiveDuration(d.key, d.value)
+			if err != nil {
+				return nil, fmt.Errorf("target %d: %w", i, err)
+			}
+		}
+		err = t.validate()
+		if err != nil {
+			return nil, fmt.Errorf("target %d: %w", i, err)
+		}
+		t.setDefaults()
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
+
+func parsePositiveDuration(key, value string) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("could not parse %s: %w", key, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("%s must be positive", key)
+	}
+	return d, nil
+}
@@ -0,0 +1,136 @@
+package httpchecker
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTarget(t *testing.T) {
+	tt := []struct {
+		name   string
+		input  string
+		target Target
+		err    string
+	}{
+		{
+			name:  "url",
+			input: "https://10.0.0.1/health",
+			target: Target{
+				Name:     "https://10.0.0.1/health",
+				Method:   "GET",
+				URL:      "https://10.0.0.1/health",
+				Interval: DefaultInterval,
+				Timeout:  DefaultTimeout,
+			},
+		},
+		{
+			name:  "name and url with query",
+			input: "api=http://10.0.0.1:8080/health?verbose=true",
+			target: Target{
+				Name:     "api",
+				Method:   "GET",
+				URL:      "http://10.0.0.1:8080/health?verbose=true",
+				Interval: DefaultInterval,
+				Timeout:  DefaultTimeout,
+			},
+		},
+		{
+			name:  "url with query",
+			input: "http://10.0.0.1/health?verbose=true",
+			target: Target{
+				Name:     "http://10.0.0.1/health?verbose=true",
+				Method:   "GET",
+				URL:      "http://10.0.0.1/health?verbose=true",
+				Interval: DefaultInterval,
+				Timeout:  DefaultTimeout,
+			},
+		},
+		{
+			name:  "unsupported scheme",
+			input: "ftp://10.0.0.1/",
+			err:   "url scheme must be http or https",
+		},
+		{
+			name:  "missing host",
+			input: "api=http:///health",
+			err:   "url host is required",
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			target, err := ParseTarget(tc.input)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err, "error not as expected")
+				return
+			}
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tc.target, target, "target not as expected")
+		})
+	}
+}
+
+func TestReadTargets(t *testing.T) {
+	tt := []struct {
+		name    string
+		input   string
+		targets []Target
+		err     string
+	}{
+		{
+			name: "targets with and without options",
+			input: `{"targets": [
+				{"name": "api", "method": "head", "url": "https://10.0.0.1/health", "expected_status_codes": [200, 204], "body": "ok", "interval": "10s", "timeout": "1s", "insecure_skip_verify": true},
+				{"url": "http://10.0.0.2/"}
+			]}`,
+			targets: []Target{
+				{
+					Name:                "api",
+					Method:              "HEAD",
+					URL:                 "https://10.0.0.1/health",
+					ExpectedStatusCodes: []int{200, 204},
+					Body:                regexp.MustCompile("ok"),
+					Interval:            10 * time.Second,
+					Timeout:             time.Second,
+					InsecureSkipVerify:  true,
+				},
+				{
+					Name:     "http://10.0.0.2/",
+					Method:   "GET",
+					URL:      "http://10.0.0.2/",
+					Interval: DefaultInterval,
+					Timeout:  DefaultTimeout,
+				},
+			},
+		},
+		{
+			name:  "invalid status code",
+			input: `{"targets": [{"url": "http://10.0.0.1/", "expected_status_codes": [2000]}]}`,
+			err:   "target 0: status code 2000 is out of range",
+		},
+		{
+			name:  "invalid body",
+			input: `{"targets": [{"url": "http://10.0.0.1/", "body": "("}]}`,
+			err:   "target 0: could not parse body: error parsing regexp: missing closing ): `(`",
+		},
+		{
+			name:  "invalid timeout",
+			input: `{"targets": [{"url": "http://10.0.0.1/", "timeout": "0s"}]}`,
+			err:   "target 0: timeout must be positive",
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			targets, err := ReadTargets(strings.NewReader(tc.input))
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err, "error not as expected")
+				return
+			}
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tc.targets, targets, "targets not as expected")
+		})
+	}
+}
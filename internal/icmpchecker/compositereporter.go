@@ -0,0 +1,17 @@
+package icmpchecker
+
+type compositeReporter struct {
+	reporters []Reporter
+}
+
+func (r compositeReporter) ReportICMPCheck(report Report) {
+	for _, reporter := range r.reporters {
+		reporter.ReportICMPCheck(report)
+	}
+}
+
+func CompositeReporter(reporters ...Reporter) Reporter {
+	return &compositeReporter{
+		reporters: reporters,
+	}
+}
@@ -0,0 +1,150 @@
+package icmpchecker
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	protocolICMP   = 1
+	protocolICMPv6 = 58
+)
+
+// Checker sends ICMP echo requests to a target with unprivileged Linux ping
+// sockets and keeps statistics of the latest probes.
+//
+// The process must be allowed to create ping sockets by its group being within
+// the net.ipv4.ping_group_range sysctl.
+type Checker struct {
+	target Target
+	window *window
+	seq    int
+}
+
+// NewChecker returns a Checker probing target. Options that are not set on
+// target use their defaults.
+func NewChecker(target Target) *Checker {
+	target.setDefaults()
+	return &Checker{
+		target: target,
+		window: newWindow(target.WindowSize),
+	}
+}
+
+// Check sends a single echo request and reports the result along with the
+// statistics of the window to reporter. Check must not be called
+// concurrently.
+func (c *Checker) Check(reporter Reporter) {
+	c.seq = (c.seq + 1) & 0xffff
+	report := Report{
+		Name:    c.target.Name,
+		Address: c.target.Address,
+	}
+	rtt, err := c.ping(c.seq)
+	if err != nil {
+		report.Status = "Echo error"
+		report.Error = err
+		report.Failure = classifyError(err)
+		c.window.add(sample{})
+	} else {
+		report.Status = "Echo reply"
+		report.Reachable = true
+		report.RoundTripTime = rtt
+		c.window.add(sample{received: true, roundTripTime: rtt})
+	}
+	report.Window = c.window.statistics()
+	reporter.ReportICMPCheck(report)
+}
+
+// ping sends an echo request with sequence number seq and waits for the
+// matching reply.
+func (c *Checker) ping(seq int) (time.Duration, error) {
+	addr, err := net.ResolveIPAddr("ip", c.target.Address)
+	if err != nil {
+		return 0, err
+	}
+	network, protocol := "udp4", protocolICMP
+	var requestType, replyType icmp.Type = ipv4.ICMPTypeEcho, ipv4.ICMPTypeEchoReply
+	if addr.IP.To4() == nil {
+		network, protocol = "udp6", protocolICMPv6
+		requestType, replyType = ipv6.ICMPTypeEchoRequest, ipv6.ICMPTypeEchoReply
+	}
+	conn, err := icmp.ListenPacket(network, "")
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	// the kernel sets the echo identifier of ping sockets so replies are
+	// matched on sequence number and a random payload
+	data := make([]byte, 16)
+	_, err = rand.Read(data)
+	if err != nil {
+		return 0, err
+	}
+	request, err := (&icmp.Message{
+		Type: requestType,
+		Body: &icmp.Echo{
+			ID:   os.Getpid() & 0xffff,
+			Seq:  seq,
+			Data: data,
+		},
+	}).Marshal(nil)
+	if err != nil {
+		return 0, err
+	}
+	err = conn.SetDeadline(time.Now().Add(c.target.Timeout))
+	if err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	_, err = conn.WriteTo(request, &net.UDPAddr{IP: addr.IP, Zone: addr.Zone})
+	if err != nil {
+		return 0, err
+	}
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return 0, err
+		}
+		rtt := time.Since(start)
+		reply, err := icmp.ParseMessage(protocol, buf[:n])
+		if err != nil {
+			return 0, fmt.Errorf("parse reply: %w", err)
+		}
+		echo, ok := reply.Body.(*icmp.Echo)
+		if reply.Type != replyType || !ok || echo.Seq != seq || !bytes.Equal(echo.Data, data) {
+			continue
+		}
+		return rtt, nil
+	}
+}
+
+// classifyError returns the FailureReason of err.
+func classifyError(err error) FailureReason {
+	var dnsError *net.DNSError
+	var netError net.Error
+	switch {
+	case errors.As(err, &dnsError):
+		return FailureDNS
+	case errors.Is(err, syscall.EACCES), errors.Is(err, syscall.EPERM):
+		return FailurePermission
+	case errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH), errors.Is(err, syscall.EHOSTDOWN), errors.Is(err, syscall.ENETDOWN):
+		return FailureUnreachable
+	case errors.As(err, &netError) && netError.Timeout():
+		return FailureTimeout
+	default:
+		return FailureUnknown
+	}
+}
@@ -0,0 +1,178 @@
+package icmpchecker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type reportRecorder struct {
+	reports []Report
+}
+
+func (r *reportRecorder) ReportICMPCheck(report Report) {
+	r.reports = append(r.reports, report)
+}
+
+func TestWindow_statistics(t *testing.T) {
+	ms := time.Millisecond
+	tt := []struct {
+		name    string
+		size    int
+		samples []sample
+		stats   WindowStatistics
+	}{
+		{
+			name:  "empty",
+			size:  3,
+			stats: WindowStatistics{},
+		},
+		{
+			name: "all received",
+			size: 3,
+			samples: []sample{
+				{received: true, roundTripTime: 10 * ms},
+				{received: true, roundTripTime: 20 * ms},
+				{received: true, roundTripTime: 15 * ms},
+			},
+			stats: WindowStatistics{
+				Sent:                 3,
+				Received:             3,
+				AverageRoundTripTime: 15 * ms,
+				// (10 + 5) / 2
+				Jitter: 7500 * time.Microsecond,
+			},
+		},
+		{
+			name: "loss",
+			size: 4,
+			samples: []sample{
+				{received: true, roundTripTime: 10 * ms},
+				{},
+				{received: true, roundTripTime: 30 * ms},
+				{},
+			},
+			stats: WindowStatistics{
+				Sent:                 4,
+				Received:             2,
+				LossPercent:          50,
+				AverageRoundTripTime: 20 * ms,
+				Jitter:               20 * ms,
+			},
+		},
+		{
+			name: "oldest samples leave the window",
+			size: 2,
+			samples: []sample{
+				{},
+				{},
+				{received: true, roundTripTime: 10 * ms},
+				{received: true, roundTripTime: 10 * ms},
+			},
+			stats: WindowStatistics{
+				Sent:                 2,
+				Received:             2,
+				AverageRoundTripTime: 10 * ms,
+			},
+		},
+		{
+			name: "all lost",
+			size: 2,
+			samples: []sample{
+				{},
+				{},
+			},
+			stats: WindowStatistics{
+				Sent:        2,
+				LossPercent: 100,
+			},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			w := newWindow(tc.size)
+			for _, s := range tc.samples {
+				w.add(s)
+			}
+			assert.Equal(t, tc.stats, w.statistics(), "statistics not as expected")
+		})
+	}
+}
+
+func TestChecker_loopback(t *testing.T) {
+	recorder := &reportRecorder{}
+	checker := NewChecker(Target{Address: "127.0.0.1", WindowSize: 2})
+	checker.Check(recorder)
+	checker.Check(recorder)
+
+	if !assert.Len(t, recorder.reports, 2, "number of reports not as expected") {
+		return
+	}
+	if recorder.reports[0].Failure == FailurePermission {
+		t.Skipf("ping sockets are not permitted: %v", recorder.reports[0].Error)
+	}
+	report := recorder.reports[1]
+	assert.True(t, report.Reachable, "loopback not reachable: %v", report.Error)
+	assert.NotZero(t, report.RoundTripTime, "round trip time not set")
+	assert.Equal(t, 2, report.Window.Received, "received not as expected")
+	assert.Equal(t, 0.0, report.Window.LossPercent, "loss not as expected")
+}
+
+func TestParseTarget(t *testing.T) {
+	tt := []struct {
+		name   string
+		input  string
+		target Target
+		err    string
+	}{
+		{
+			name:  "address",
+			input: "icmp://10.0.0.1",
+			target: Target{
+				Name:       "10.0.0.1",
+				Address:    "10.0.0.1",
+				Interval:   DefaultInterval,
+				Timeout:    DefaultTimeout,
+				WindowSize: DefaultWindowSize,
+			},
+		},
+		{
+			name:  "name, ipv6 and options",
+			input: "icmp://gw@[fd00::1]?interval=5s&timeout=2s&window=12",
+			target: Target{
+				Name:       "gw",
+				Address:    "fd00::1",
+				Interval:   5 * time.Second,
+				Timeout:    2 * time.Second,
+				WindowSize: 12,
+			},
+		},
+		{
+			name:  "port",
+			input: "icmp://10.0.0.1:22",
+			err:   "unexpected port in icmp://10.0.0.1:22",
+		},
+		{
+			name:  "invalid window",
+			input: "icmp://10.0.0.1?window=0",
+			err:   "window must be a positive integer",
+		},
+		{
+			name:  "missing address",
+			input: "icmp://gw@",
+			err:   "address is required",
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			target, err := ParseTarget(tc.input)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err, "error not as expected")
+				return
+			}
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tc.target, target, "target not as expected")
+		})
+	}
+}
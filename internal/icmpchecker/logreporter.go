@@ -0,0 +1,51 @@
+package icmpchecker
+
+import (
+	"time"
+
+	"github.com/prometheus/common/log"
+)
+
+type logReporter struct {
+	lastReport    time.Time
+	lastReachable bool
+	Logger        log.Logger
+}
+
+func (r *logReporter) ReportICMPCheck(report Report) {
+	l := r.Logger.With("report", report)
+	switch {
+	case report.Reachable && r.lastReachable:
+		// Host still replies - great
+	case !report.Reachable && (r.lastReachable || r.lastReport == time.Time{}):
+		// Host stopped replying
+		r.lastReport = time.Now()
+		r.lastReachable = report.Reachable
+		l.
+			With("status", "unreachable").
+			With("reason", report.Failure).
+			Infof("ICMP echo to %s failed with %.0f%% loss", report.Name, report.Window.LossPercent)
+	case report.Reachable && !r.lastReachable:
+		// Host started replying
+		r.lastReport = time.Now()
+		r.lastReachable = report.Reachable
+		l.
+			With("status", "reachable").
+			Infof("ICMP echo to %s succeeded in %s", report.Name, report.RoundTripTime)
+	case !report.Reachable && !r.lastReachable:
+		// Host still not replying
+		if time.Since(r.lastReport) > 5*time.Minute {
+			r.lastReport = time.Now()
+			l.
+				With("status", "unreachable").
+				With("reason", report.Failure).
+				Infof("ICMP echo to %s is still failing with %.0f%% loss", report.Name, report.Window.LossPercent)
+		}
+	default:
+		panic("This should never happen in LogReporter.ReportICMPCheck")
+	}
+}
+
+func LogReporter(logger log.Logger) Reporter {
+	return &logReporter{Logger: logger}
+}
@@ -0,0 +1,61 @@
+package icmpchecker
+
+import (
+	"time"
+)
+
+type Reporter interface {
+	ReportICMPCheck(report Report)
+}
+
+type Report struct {
+	Name    string
+	Address string
+	// Reachable is true if an echo reply was received for the probe in time.
+	Reachable bool
+	Status    string
+	Error     error
+	// Failure is the reason the probe failed. It is empty if Reachable is
+	// true.
+	Failure FailureReason
+	// RoundTripTime is the round trip time of the probe. It is 0 if no reply
+	// was received.
+	RoundTripTime time.Duration
+
+	// Window holds statistics of the latest probes including this one.
+	Window WindowStatistics
+}
+
+// WindowStatistics are statistics of the probes in a sliding window.
+type WindowStatistics struct {
+	// Sent is the number of probes in the window.
+	Sent int
+	// Received is the number of probes in the window that received a reply.
+	Received int
+	// LossPercent is the percentage of probes in the window without a reply.
+	LossPercent float64
+	// AverageRoundTripTime is the mean round trip time of the replies in the
+	// window.
+	AverageRoundTripTime time.Duration
+	// Jitter is the mean absolute difference between the round trip times of
+	// consecutive replies in the window.
+	Jitter time.Duration
+}
+
+// FailureReason classifies why a probe failed.
+type FailureReason string
+
+const (
+	// FailureTimeout is reported when no reply is received in time.
+	FailureTimeout FailureReason = "timeout"
+	// FailureUnreachable is reported when there is no route to the host or
+	// network.
+	FailureUnreachable FailureReason = "unreachable"
+	// FailureDNS is reported when the address cannot be resolved.
+	FailureDNS FailureReason = "dns"
+	// FailurePermission is reported when the process is not allowed to open
+	// a ping socket. See net.ipv4.ping_group_range in sysctl.
+	FailurePermission FailureReason = "permission"
+	// FailureUnknown is reported for all other errors.
+	FailureUnknown FailureReason = "unknown"
+)
@@ -0,0 +1,107 @@
+package icmpchecker
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	// DefaultInterval is the default interval between probes of a target.
+	DefaultInterval = 1 * time.Second
+	// DefaultTimeout is the default time to wait for an echo reply.
+	DefaultTimeout = 1 * time.Second
+	// DefaultWindowSize is the default number of probes that statistics are
+	// calculated over.
+	DefaultWindowSize = 60
+)
+
+// Target is a host to send ICMP echo requests to.
+type Target struct {
+	Name    string
+	Address string
+	// Interval is the interval between probes.
+	Interval time.Duration
+	// Timeout is the time to wait for an echo reply.
+	Timeout time.Duration
+	// WindowSize is the number of probes that statistics are calculated over.
+	WindowSize int
+}
+
+func (t *Target) setDefaults() {
+	if t.Name == "" {
+		t.Name = t.Address
+	}
+	if t.Interval == 0 {
+		t.Interval = DefaultInterval
+	}
+	if t.Timeout == 0 {
+		t.Timeout = DefaultTimeout
+	}
+	if t.WindowSize == 0 {
+		t.WindowSize = DefaultWindowSize
+	}
+}
+
+// ParseTarget parses a target of the form icmp://[<name>@]<address>. IPv6
+// addresses must be enclosed in square brackets. Options can be set as URL
+// query parameters, eg. icmp://gw@10.0.0.1?interval=5s&window=12.
+//
+// Supported options are interval, timeout and window.
+func ParseTarget(s string) (Target, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return Target{}, fmt.Errorf("could not parse url: %w", err)
+	}
+	if u.Scheme != "icmp" {
+		return Target{}, fmt.Errorf("url scheme must be icmp")
+	}
+	if u.Path != "" || u.Fragment != "" {
+		return Target{}, fmt.Errorf("unexpected path in %s", s)
+	}
+	if u.Port() != "" {
+		return Target{}, fmt.Errorf("unexpected port in %s", s)
+	}
+	t := Target{
+		Address: u.Hostname(),
+	}
+	if t.Address == "" {
+		return Target{}, fmt.Errorf("address is required")
+	}
+	if u.User != nil {
+		t.Name = u.User.Username()
+	}
+	for key, values := range u.Query() {
+		value := values[len(values)-1]
+		switch key {
+		case "interval":
+			t.Interval, err = parsePositiveDuration(key, value)
+		case "timeout":
+			t.Timeout, err = parsePositiveDuration(key, value)
+		case "window":
+			t.WindowSize, err = strconv.Atoi(value)
+			if err != nil || t.WindowSize <= 0 {
+				err = fmt.Errorf("window must be a positive integer")
+			}
+		default:
+			err = fmt.Errorf("unknown option '%s'", key)
+		}
+		if err != nil {
+			return Target{}, err
+		}
+	}
+	t.setDefaults()
+	return t, nil
+}
+
+func parsePositiveDuration(key, value string) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("could not parse %s: %w", key, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("%s must be positive", key)
+	}
+	return d, nil
+}
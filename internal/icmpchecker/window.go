@@ -0,0 +1,68 @@
+package icmpchecker
+
+import (
+	"time"
+)
+
+// window holds the results of the latest probes.
+type window struct {
+	size    int
+	samples []sample
+}
+
+type sample struct {
+	received      bool
+	roundTripTime time.Duration
+}
+
+func newWindow(size int) *window {
+	return &window{
+		size: size,
+	}
+}
+
+// add adds s to the window removing the oldest sample if the window is full.
+func (w *window) add(s sample) {
+	w.samples = append(w.samples, s)
+	if len(w.samples) > w.size {
+		w.samples = w.samples[len(w.samples)-w.size:]
+	}
+}
+
+func (w *window) statistics() WindowStatistics {
+	stats := WindowStatistics{
+		Sent: len(w.samples),
+	}
+	var total, deviation time.Duration
+	var previous *sample
+	var deviations int
+	for i, s := range w.samples {
+		if !s.received {
+			continue
+		}
+		stats.Received++
+		total += s.roundTripTime
+		if previous != nil {
+			deviation += absDuration(s.roundTripTime - previous.roundTripTime)
+			deviations++
+		}
+		previous = &w.samples[i]
+	}
+	if stats.Sent != 0 {
+		stats.LossPercent = float64(stats.Sent-stats.Received) / float64(stats.Sent) * 100
+	}
+	if stats.Received != 0 {
+		stats.AverageRoundTripTime = total / time.Duration(stats.Received)
+	}
+	if deviations != 0 {
+		stats.Jitter = deviation / time.Duration(deviations)
+	}
+	return stats
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
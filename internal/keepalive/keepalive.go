@@ -0,0 +1,461 @@
+package keepalive
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lunarway/strong-duckling/internal/strongswan"
+	"github.com/lunarway/strong-duckling/internal/tunnelchecker"
+	"github.com/lunarway/strong-duckling/internal/vici"
+	"github.com/prometheus/common/log"
+)
+
+var _ strongswan.IKESAStatusReceiver = &Keepaliver{}
+var _ strongswan.CollectionDoneReceiver = &Keepaliver{}
+
+const (
+	// DefaultInterval is the default interval between checks of the use-out of
+	// child SAs.
+	DefaultInterval = 5 * time.Second
+	// DefaultPort is the default port probes are sent to. It is the discard
+	// port so probes are ignored by hosts running the service.
+	DefaultPort = 9
+	// DefaultTimeout is the default time to wait for a TCP connection.
+	DefaultTimeout = 1 * time.Second
+)
+
+// Protocol is the protocol of keepalive probes.
+type Protocol string
+
+const (
+	// ProtocolUDP sends a small datagram.
+	ProtocolUDP Protocol = "udp"
+	// ProtocolTCP opens and closes a TCP connection. The probe is sent even if
+	// the connection is refused or times out.
+	ProtocolTCP Protocol = "tcp"
+)
+
+// payload is the content of UDP probes.
+const payload = "strong-duckling keepalive"
+
+// Rule applies keepalives to child SAs matching name patterns. Patterns use
+// the syntax of path.Match and an empty pattern matches all names.
+type Rule struct {
+	IKESAName   string
+	ChildSAName string
+	// Threshold is the time since the last outbound packet of the child SA
+	// after which a probe is sent.
+	Threshold time.Duration
+	Protocol  Protocol
+	// Host is either an IP address or a host number within the remote traffic
+	// selector, eg. host 1 of 10.2.0.0/16 is 10.2.0.1. If empty the address of
+	// single host selectors and host 1 of subnets is used.
+	Host    string
+	Port    int
+	Timeout time.Duration
+}
+
+func (r Rule) matches(ikeSAName, childSAName string) bool {
+	return matchPattern(r.IKESAName, ikeSAName) && matchPattern(r.ChildSAName, childSAName)
+}
+
+func matchPattern(pattern, name string) bool {
+	if pattern == "" {
+		return true
+	}
+	ok, err := path.Match(pattern, name)
+	return err == nil && ok
+}
+
+func (r *Rule) setDefaults() {
+	if r.Protocol == "" {
+		r.Protocol = ProtocolUDP
+	}
+	if r.Port == 0 {
+		r.Port = DefaultPort
+	}
+	if r.Timeout == 0 {
+		r.Timeout = DefaultTimeout
+	}
+}
+
+// remoteAddress returns the address probes are sent to within prefix.
+func (r Rule) remoteAddress(prefix netip.Prefix) (netip.Addr, bool) {
+	if r.Host != "" {
+		return tunnelchecker.HostAddress(prefix, r.Host)
+	}
+	if prefix.IsSingleIP() {
+		return prefix.Addr(), true
+	}
+	return tunnelchecker.HostAddress(prefix, "1")
+}
+
+// ParseRule parses a rule of the form
+//
+//	<ike sa pattern>[/<child sa pattern>]?threshold=<duration>&protocol=<udp|tcp>&host=<host>&port=<port>&timeout=<duration>
+//
+// eg. partner-*/net-*?threshold=30s&protocol=tcp&port=22. Only threshold is
+// required.
+func ParseRule(s string) (Rule, error) {
+	patterns, query, ok := strings.Cut(s, "?")
+	if !ok {
+		return Rule{}, fmt.Errorf("could not understand keepalive rule %s", s)
+	}
+	var r Rule
+	r.IKESAName, r.ChildSAName, _ = strings.Cut(patterns, "/")
+	for _, pattern := range []string{r.IKESAName, r.ChildSAName} {
+		_, err := path.Match(pattern, "")
+		if err != nil {
+			return Rule{}, fmt.Errorf("invalid pattern '%s': %w", pattern, err)
+		}
+	}
+	options, err := url.ParseQuery(query)
+	if err != nil {
+		return Rule{}, fmt.Errorf("parse options: %w", err)
+	}
+	for key, values := range options {
+		value := values[len(values)-1]
+		switch key {
+		case "threshold":
+			r.Threshold, err = time.ParseDuration(value)
+		case "timeout":
+			r.Timeout, err = time.ParseDuration(value)
+		case "protocol":
+			r.Protocol = Protocol(value)
+			if r.Protocol != ProtocolUDP && r.Protocol != ProtocolTCP {
+				return Rule{}, fmt.Errorf("unknown protocol '%s'", value)
+			}
+		case "host":
+			r.Host = value
+			_, addrErr := netip.ParseAddr(value)
+			_, hostNumberErr := strconv.ParseUint(value, 10, 64)
+			if addrErr != nil && hostNumberErr != nil {
+				return Rule{}, fmt.Errorf("host '%s' is neither an IP address nor a host number", value)
+			}
+		case "port":
+			r.Port, err = strconv.Atoi(value)
+			if err == nil && (r.Port < 1 || r.Port > 65535) {
+				return Rule{}, fmt.Errorf("port %d out of range", r.Port)
+			}
+		default:
+			return Rule{}, fmt.Errorf("unknown option '%s'", key)
+		}
+		if err != nil {
+			return Rule{}, fmt.Errorf("could not parse %s: %w", key, err)
+		}
+	}
+	if r.Threshold <= 0 {
+		return Rule{}, fmt.Errorf("positive threshold is required")
+	}
+	if r.Timeout < 0 {
+		return Rule{}, fmt.Errorf("timeout must not be negative")
+	}
+	r.setDefaults()
+	return r, nil
+}
+
+// Report is the result of sending a keepalive probe through a child SA.
+type Report struct {
+	IKESAName     string
+	ChildSAName   string
+	Protocol      Protocol
+	LocalAddress  string
+	RemoteAddress string
+	Port          int
+	// Silence is the time since the last outbound packet of the child SA when
+	// the probe was sent.
+	Silence time.Duration
+	// Error is set if the probe could not be sent.
+	Error error
+}
+
+// Reporter receives the results of keepalive probes.
+type Reporter interface {
+	ReportKeepalive(report Report)
+}
+
+// Configuration specifies which child SAs a Keepaliver keeps alive.
+type Configuration struct {
+	// Rules are matched in order against the names of installed child SAs and
+	// the first matching rule applies. Child SAs matching no rule are not kept
+	// alive.
+	Rules    []Rule
+	Reporter Reporter
+}
+
+// Keepaliver sends small probes into the remote traffic selector of installed
+// child SAs that have not sent packets for longer than a threshold. This
+// keeps child SAs and NAT mappings alive at peers that remove them when idle.
+//
+// Probes are sent from the local address within the local traffic selector
+// so they are routed through the child SA. Child SAs without such an address
+// on this host are skipped.
+//
+// The use-out of child SAs is received as IKESAStatus and probes are sent by
+// Tick which is meant to be called by a daemon.Daemon. Orphaned IKE SAs and
+// IKE SAs missing from a collection are not kept alive.
+type Keepaliver struct {
+	logger log.Logger
+	config Configuration
+
+	now        func() time.Time
+	localAddrs func() ([]netip.Addr, error)
+	send       func(p probe) error
+
+	mu sync.Mutex
+	// childSAs holds the kept alive child SAs by IKE SA name and child SA
+	// name.
+	childSAs map[string]map[string]*childSA
+	// seen holds the names of IKE SAs seen in the current collection.
+	seen map[string]struct{}
+}
+
+type childSA struct {
+	probe probe
+	// lastPacketOut is the time of the last outbound packet derived from
+	// use-out.
+	lastPacketOut time.Time
+	// lastProbe is the time of the last probe.
+	lastProbe time.Time
+	threshold time.Duration
+}
+
+type probe struct {
+	ikeSAName   string
+	childSAName string
+	protocol    Protocol
+	local       netip.Addr
+	remote      netip.Addr
+	port        int
+	timeout     time.Duration
+}
+
+// New returns a Keepaliver reporting probes to the reporter of config.
+func New(logger log.Logger, config Configuration) *Keepaliver {
+	for i := range config.Rules {
+		config.Rules[i].setDefaults()
+	}
+	return &Keepaliver{
+		logger:     logger,
+		config:     config,
+		now:        time.Now,
+		localAddrs: interfaceAddrs,
+		send:       send,
+		childSAs:   make(map[string]map[string]*childSA),
+		seen:       make(map[string]struct{}),
+	}
+}
+
+func (k *Keepaliver) IKESAStatus(ikeSAStatus strongswan.IKESAStatus) {
+	now := k.now()
+	var localAddrs []netip.Addr
+	current := make(map[string]*childSA)
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	// orphaned IKE SAs are meant to go away and must not be kept alive
+	if ikeSAStatus.Orphaned {
+		delete(k.childSAs, ikeSAStatus.Name)
+		return
+	}
+	k.seen[ikeSAStatus.Name] = struct{}{}
+	previous := k.childSAs[ikeSAStatus.Name]
+	for _, status := range ikeSAStatus.ChildSA {
+		if status.State == nil || status.State.State != vici.ChildSAStateInstalled {
+			continue
+		}
+		rule, ok := k.rule(ikeSAStatus.Name, status.Name)
+		if !ok {
+			continue
+		}
+		lastPacketOut, ok := lastPacketOut(now, status.State)
+		if !ok {
+			continue
+		}
+		if localAddrs == nil {
+			var err error
+			localAddrs, err = k.localAddrs()
+			if err != nil {
+				k.logger.Errorf("Could not list local addresses for keepalives: %v", err)
+				return
+			}
+		}
+		p, ok := newProbe(rule, status.State, localAddrs)
+		if !ok {
+			k.logger.Debugf("Skipping keepalives of child SA %s.%s without a local address in its local traffic selectors", ikeSAStatus.Name, status.Name)
+			continue
+		}
+		p.ikeSAName = ikeSAStatus.Name
+		p.childSAName = status.Name
+		c := &childSA{
+			probe:         p,
+			lastPacketOut: lastPacketOut,
+			threshold:     rule.Threshold,
+		}
+		if previous, ok := previous[status.Name]; ok {
+			c.lastProbe = previous.lastProbe
+		}
+		current[status.Name] = c
+	}
+	if len(current) == 0 {
+		delete(k.childSAs, ikeSAStatus.Name)
+		return
+	}
+	k.childSAs[ikeSAStatus.Name] = current
+}
+
+// CollectionDone stops keeping alive the child SAs of IKE SAs that are gone,
+// eg. after their configuration is unloaded.
+func (k *Keepaliver) CollectionDone() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	for ikeSAName := range k.childSAs {
+		if _, ok := k.seen[ikeSAName]; !ok {
+			delete(k.childSAs, ikeSAName)
+		}
+	}
+	k.seen = make(map[string]struct{})
+}
+
+// Tick sends probes through the child SAs that have not sent packets for
+// longer than their threshold.
+func (k *Keepaliver) Tick() {
+	type due struct {
+		probe   probe
+		silence time.Duration
+	}
+	now := k.now()
+	var probes []due
+	k.mu.Lock()
+	for _, childSAs := range k.childSAs {
+		for _, c := range childSAs {
+			last := c.lastPacketOut
+			if c.lastProbe.After(last) {
+				last = c.lastProbe
+			}
+			silence := now.Sub(last)
+			if silence < c.threshold {
+				continue
+			}
+			c.lastProbe = now
+			probes = append(probes, due{probe: c.probe, silence: now.Sub(c.lastPacketOut)})
+		}
+	}
+	k.mu.Unlock()
+
+	for _, d := range probes {
+		p := d.probe
+		err := k.send(p)
+		if err != nil {
+			k.logger.Infof("Could not send %s keepalive through child SA %s.%s from %s to %s: %v", p.protocol, p.ikeSAName, p.childSAName, p.local, net.JoinHostPort(p.remote.String(), strconv.Itoa(p.port)), err)
+		} else {
+			k.logger.Debugf("Sent %s keepalive through child SA %s.%s from %s to %s after %s without outbound packets", p.protocol, p.ikeSAName, p.childSAName, p.local, net.JoinHostPort(p.remote.String(), strconv.Itoa(p.port)), d.silence)
+		}
+		if k.config.Reporter != nil {
+			k.config.Reporter.ReportKeepalive(Report{
+				IKESAName:     p.ikeSAName,
+				ChildSAName:   p.childSAName,
+				Protocol:      p.protocol,
+				LocalAddress:  p.local.String(),
+				RemoteAddress: p.remote.String(),
+				Port:          p.port,
+				Silence:       d.silence,
+				Error:         err,
+			})
+		}
+	}
+}
+
+func (k *Keepaliver) rule(ikeSAName, childSAName string) (Rule, bool) {
+	for _, rule := range k.config.Rules {
+		if rule.matches(ikeSAName, childSAName) {
+			return rule, true
+		}
+	}
+	return Rule{}, false
+}
+
+// lastPacketOut returns the time of the last outbound packet of childSA. If
+// it has sent no packets the time it was installed is used.
+func lastPacketOut(now time.Time, childSA *vici.ChildSA) (time.Time, bool) {
+	seconds := childSA.LastPacketOutSeconds
+	if seconds == "" {
+		seconds = childSA.InstallTimeSeconds
+	}
+	s, err := strconv.ParseUint(seconds, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return now.Add(-time.Duration(s) * time.Second), true
+}
+
+// newProbe returns the probe of rule from a local address within the local
+// traffic selectors of childSA to the remote traffic selectors of the same
+// address family.
+func newProbe(rule Rule, childSA *vici.ChildSA, localAddrs []netip.Addr) (probe, bool) {
+	for _, localTrafficSelector := range childSA.LocalTrafficSelectors {
+		localPrefix, ok := tunnelchecker.ParseTrafficSelector(localTrafficSelector)
+		if !ok {
+			continue
+		}
+		local, ok := localAddress(localPrefix, localAddrs)
+		if !ok {
+			continue
+		}
+		for _, remoteTrafficSelector := range childSA.RemoteTrafficSelectors {
+			remotePrefix, ok := tunnelchecker.ParseTrafficSelector(remoteTrafficSelector)
+			if !ok || remotePrefix.Addr().Is4() != local.Is4() {
+				continue
+			}
+			remote, ok := rule.remoteAddress(remotePrefix)
+			if !ok {
+				continue
+			}
+			return probe{
+				protocol: rule.Protocol,
+				local:    local,
+				remote:   remote,
+				port:     rule.Port,
+				timeout:  rule.Timeout,
+			}, true
+		}
+	}
+	return probe{}, false
+}
+
+func localAddress(prefix netip.Prefix, localAddrs []netip.Addr) (netip.Addr, bool) {
+	for _, addr := range localAddrs {
+		if prefix.Contains(addr) {
+			return addr, true
+		}
+	}
+	return netip.Addr{}, false
+}
+
+// interfaceAddrs returns the addresses of the network interfaces of the host.
+func interfaceAddrs() ([]netip.Addr, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, err
+	}
+	var result []netip.Addr
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		a, ok := netip.AddrFromSlice(ipNet.IP)
+		if !ok {
+			continue
+		}
+		result = append(result, a.Unmap())
+	}
+	return result, nil
+}
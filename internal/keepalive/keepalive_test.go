@@ -0,0 +1,241 @@
+package keepalive
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/lunarway/strong-duckling/internal/strongswan"
+	"github.com/lunarway/strong-duckling/internal/test"
+	"github.com/lunarway/strong-duckling/internal/vici"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRule(t *testing.T) {
+	tt := []struct {
+		name  string
+		input string
+		rule  Rule
+		err   string
+	}{
+		{
+			name:  "defaults",
+			input: "partner-*/net-*?threshold=30s",
+			rule:  Rule{IKESAName: "partner-*", ChildSAName: "net-*", Threshold: 30 * time.Second, Protocol: ProtocolUDP, Port: DefaultPort, Timeout: DefaultTimeout},
+		},
+		{
+			name:  "all options",
+			input: "?threshold=1m&protocol=tcp&host=10.2.0.10&port=22&timeout=2s",
+			rule:  Rule{Threshold: time.Minute, Protocol: ProtocolTCP, Host: "10.2.0.10", Port: 22, Timeout: 2 * time.Second},
+		},
+		{
+			name:  "missing options",
+			input: "partner1",
+			err:   "could not understand keepalive rule partner1",
+		},
+		{
+			name:  "missing threshold",
+			input: "partner1?port=22",
+			err:   "positive threshold is required",
+		},
+		{
+			name:  "invalid threshold",
+			input: "partner1?threshold=30",
+			err:   `could not parse threshold: time: missing unit in duration "30"`,
+		},
+		{
+			name:  "unknown protocol",
+			input: "partner1?threshold=30s&protocol=icmp",
+			err:   "unknown protocol 'icmp'",
+		},
+		{
+			name:  "invalid host",
+			input: "partner1?threshold=30s&host=gateway",
+			err:   "host 'gateway' is neither an IP address nor a host number",
+		},
+		{
+			name:  "port out of range",
+			input: "partner1?threshold=30s&port=70000",
+			err:   "port 70000 out of range",
+		},
+		{
+			name:  "unknown option",
+			input: "partner1?threshold=30s&interval=5s",
+			err:   "unknown option 'interval'",
+		},
+		{
+			name:  "invalid pattern",
+			input: "partner[?threshold=30s",
+			err:   "invalid pattern 'partner[': syntax error in pattern",
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			rule, err := ParseRule(tc.input)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err, "error")
+				return
+			}
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tc.rule, rule, "rule")
+		})
+	}
+}
+
+type reports []Report
+
+func (r *reports) ReportKeepalive(report Report) {
+	*r = append(*r, report)
+}
+
+func keepaliveStatus(childSAs ...strongswan.ChildSAStatus) strongswan.IKESAStatus {
+	return strongswan.IKESAStatus{
+		Name:    "partner1",
+		ChildSA: childSAs,
+	}
+}
+
+func keepaliveChildSA(name, useOut, localTS, remoteTS string) strongswan.ChildSAStatus {
+	return strongswan.ChildSAStatus{
+		Name: name,
+		State: &vici.ChildSA{
+			Name:                   name,
+			State:                  vici.ChildSAStateInstalled,
+			InstallTimeSeconds:     "3600",
+			LastPacketOutSeconds:   useOut,
+			LocalTrafficSelectors:  []string{localTS},
+			RemoteTrafficSelectors: []string{remoteTS},
+		},
+	}
+}
+
+func TestKeepaliver(t *testing.T) {
+	var r reports
+	k := New(test.NewLogger(t), Configuration{
+		Rules: []Rule{
+			{IKESAName: "partner1", ChildSAName: "net-1", Threshold: time.Minute},
+			{IKESAName: "partner1", ChildSAName: "net-2", Threshold: time.Minute, Protocol: ProtocolTCP, Host: "10", Port: 22},
+		},
+		Reporter: &r,
+	})
+	now := time.Now()
+	k.now = func() time.Time {
+		return now
+	}
+	k.localAddrs = func() ([]netip.Addr, error) {
+		return []netip.Addr{netip.MustParseAddr("127.0.0.1"), netip.MustParseAddr("10.1.0.5")}, nil
+	}
+	var sent []string
+	k.send = func(p probe) error {
+		sent = append(sent, p.childSAName)
+		if p.childSAName == "net-2" {
+			return errors.New("network unreachable")
+		}
+		return nil
+	}
+
+	k.IKESAStatus(keepaliveStatus(
+		keepaliveChildSA("net-1", "50", "10.1.0.0/16", "10.2.0.1/32"),
+		keepaliveChildSA("net-2", "", "10.1.0.0/16[tcp]", "10.3.0.0/16"),
+		keepaliveChildSA("net-3", "120", "10.1.0.0/16", "10.4.0.0/16"),
+		// no local address within the local traffic selector
+		keepaliveChildSA("net-1", "120", "10.9.0.0/16", "10.2.0.1/32"),
+	))
+	k.Tick()
+	// net-1 was kept alive by its own traffic before it exceeded the threshold
+	assert.Equal(t, []string{"net-2"}, sent, "probes not as expected")
+	assert.Equal(t, reports{
+		{IKESAName: "partner1", ChildSAName: "net-2", Protocol: ProtocolTCP, LocalAddress: "10.1.0.5", RemoteAddress: "10.3.0.10", Port: 22, Silence: time.Hour, Error: errors.New("network unreachable")},
+	}, r, "reports not as expected")
+
+	// net-2 is not probed again until its threshold passed since the last probe
+	sent, r = nil, nil
+	now = now.Add(5 * time.Second)
+	k.Tick()
+	assert.Empty(t, sent, "probes not as expected")
+
+	sent, r = nil, nil
+	now = now.Add(55 * time.Second)
+	k.Tick()
+	sort.Strings(sent)
+	assert.Equal(t, []string{"net-1", "net-2"}, sent, "probes not as expected")
+
+	// net-1 sends traffic and net-2 is no longer installed
+	sent, r = nil, nil
+	now = now.Add(time.Minute)
+	k.IKESAStatus(keepaliveStatus(
+		keepaliveChildSA("net-1", "5", "10.1.0.0/16", "10.2.0.1/32"),
+		strongswan.ChildSAStatus{Name: "net-2"},
+	))
+	k.Tick()
+	assert.Empty(t, sent, "probes not as expected")
+}
+
+func TestSend(t *testing.T) {
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen on udp: %v", err)
+	}
+	defer udp.Close()
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen on tcp: %v", err)
+	}
+	closedPort := tcp.Addr().(*net.TCPAddr).Port
+	tcp.Close()
+
+	localhost := netip.MustParseAddr("127.0.0.1")
+	err = send(probe{protocol: ProtocolUDP, local: localhost, remote: localhost, port: udp.LocalAddr().(*net.UDPAddr).Port, timeout: time.Second})
+	if !assert.NoError(t, err, "udp probe") {
+		return
+	}
+	buf := make([]byte, 1024)
+	udp.SetReadDeadline(time.Now().Add(time.Second))
+	n, addr, err := udp.ReadFrom(buf)
+	if assert.NoError(t, err, "receive udp probe") {
+		assert.Equal(t, payload, string(buf[:n]), "payload")
+		assert.Equal(t, "127.0.0.1", addr.(*net.UDPAddr).IP.String(), "source address")
+	}
+
+	err = send(probe{protocol: ProtocolTCP, local: localhost, remote: localhost, port: closedPort, timeout: time.Second})
+	assert.NoError(t, err, "refused tcp probe")
+
+	err = send(probe{protocol: ProtocolTCP, local: netip.MustParseAddr("192.0.2.1"), remote: localhost, port: closedPort, timeout: time.Second})
+	assert.Error(t, err, "tcp probe from address not on the host")
+}
+
+func TestKeepaliver_gone(t *testing.T) {
+	k := New(test.NewLogger(t), Configuration{
+		Rules: []Rule{
+			{Threshold: time.Minute},
+		},
+	})
+	k.localAddrs = func() ([]netip.Addr, error) {
+		return []netip.Addr{netip.MustParseAddr("10.1.0.5")}, nil
+	}
+	var sent []string
+	k.send = func(p probe) error {
+		sent = append(sent, p.ikeSAName)
+		return nil
+	}
+	status := func(name string, orphaned bool) strongswan.IKESAStatus {
+		s := keepaliveStatus(keepaliveChildSA("net-1", "120", "10.1.0.0/16", "10.2.0.1/32"))
+		s.Name = name
+		s.Orphaned = orphaned
+		return s
+	}
+
+	k.IKESAStatus(status("partner1", false))
+	k.IKESAStatus(status("partner2", false))
+	k.CollectionDone()
+	// partner1 is unloaded and torn down and partner2 is orphaned
+	k.IKESAStatus(status("partner2", true))
+	k.IKESAStatus(status("partner3", false))
+	k.CollectionDone()
+	k.Tick()
+
+	assert.Equal(t, []string{"partner3"}, sent, "probes not as expected")
+}
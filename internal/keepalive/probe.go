@@ -0,0 +1,56 @@
+package keepalive
+
+import (
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// send sends p from its local address. TCP probes are sent once the SYN
+// leaves the host so a refused or timed out connection is not an error.
+func send(p probe) error {
+	remote := net.JoinHostPort(p.remote.String(), strconv.Itoa(p.port))
+	switch p.protocol {
+	case ProtocolTCP:
+		dialer := net.Dialer{
+			LocalAddr: &net.TCPAddr{IP: p.local.AsSlice()},
+			Timeout:   p.timeout,
+		}
+		conn, err := dialer.Dial("tcp", remote)
+		if err != nil {
+			if sent(err) {
+				return nil
+			}
+			return err
+		}
+		return conn.Close()
+	default:
+		dialer := net.Dialer{
+			LocalAddr: &net.UDPAddr{IP: p.local.AsSlice()},
+		}
+		conn, err := dialer.Dial("udp", remote)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		err = conn.SetWriteDeadline(time.Now().Add(p.timeout))
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(conn, payload)
+		return err
+	}
+}
+
+// sent returns whether a failed TCP connection still sent a packet to the
+// peer.
+func sent(err error) bool {
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"github.com/lunarway/strong-duckling/internal/strongswan"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	subSystemDryRun = "dry_run"
+)
+
+// dryRun reports actions decided by components in dry-run mode.
+type dryRun struct {
+	decisionsTotal *prometheus.CounterVec
+}
+
+func newDryRun() *dryRun {
+	return &dryRun{
+		decisionsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subSystemDryRun,
+			Name:      "decisions_total",
+			Help:      "Total number of actions decided but not sent to charon in dry-run mode",
+		}, []string{"component", "ike_sa_name", "child_sa_name", "action"}),
+	}
+}
+
+func (d *dryRun) getCollectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		d.decisionsTotal,
+	}
+}
+
+func (d *dryRun) ReportDecision(decision strongswan.Decision) {
+	d.decisionsTotal.WithLabelValues(decision.Component, decision.IKESAName, decision.ChildSAName, decision.Action).Inc()
+}
@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"github.com/lunarway/strong-duckling/internal/strongswan"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	subSystemDuplicates = "duplicates"
+)
+
+// duplicates reports on stale duplicate child SAs terminated by a
+// strongswan.DuplicateTerminator.
+type duplicates struct {
+	staleChildSAs     *prometheus.GaugeVec
+	terminationsTotal *prometheus.CounterVec
+}
+
+func newDuplicates() *duplicates {
+	return &duplicates{
+		staleChildSAs: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subSystemDuplicates,
+			Name:      "stale_child_sas",
+			Help:      "Number of installed child SAs with the same name and traffic selectors as a newer child SA",
+		}, []string{"ike_sa_name", "child_sa_name"}),
+		terminationsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subSystemDuplicates,
+			Name:      "terminations_total",
+			Help:      "Total number of terminations of stale duplicate child SAs",
+		}, []string{"ike_sa_name", "child_sa_name", "result"}),
+	}
+}
+
+func (d *duplicates) getCollectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		d.staleChildSAs,
+		d.terminationsTotal,
+	}
+}
+
+func (d *duplicates) ReportStale(ikeSAName, childSAName string, count int) {
+	d.staleChildSAs.WithLabelValues(ikeSAName, childSAName).Set(float64(count))
+}
+
+func (d *duplicates) ReportTermination(termination strongswan.DuplicateTermination) {
+	result := "success"
+	if termination.Error != nil {
+		result = "failure"
+	}
+	d.terminationsTotal.WithLabelValues(termination.IKESAName, termination.ChildSAName, result).Inc()
+}
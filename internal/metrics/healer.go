@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"github.com/lunarway/strong-duckling/internal/strongswan"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	subSystemHealer = "healer"
+)
+
+// healer reports actions taken on child SAs that traffic does not pass
+// through.
+type healer struct {
+	actionsTotal *prometheus.CounterVec
+}
+
+func newHealer() *healer {
+	return &healer{
+		actionsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subSystemHealer,
+			Name:      "actions_total",
+			Help:      "Total number of actions taken on child SAs after consecutive failed checks",
+		}, []string{"ike_sa_name", "child_sa_name", "action", "result"}),
+	}
+}
+
+func (h *healer) getCollectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		h.actionsTotal,
+	}
+}
+
+func (h *healer) ReportHeal(report strongswan.HealReport) {
+	result := "success"
+	if report.Error != nil {
+		result = "failure"
+	}
+	h.actionsTotal.WithLabelValues(report.IKESAName, report.ChildSAName, string(report.Action), result).Inc()
+}
@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"strconv"
+
+	"github.com/lunarway/strong-duckling/internal/httpchecker"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	subSystemHttpChecker = "http_checker"
+)
+
+type httpChecker struct {
+	checks               *prometheus.CounterVec
+	healthy              *prometheus.GaugeVec
+	statusCode           *prometheus.GaugeVec
+	failuresTotal        *prometheus.CounterVec
+	responseDuration     *prometheus.HistogramVec
+	tlsHandshakeDuration *prometheus.HistogramVec
+	certificateExpiry    *prometheus.GaugeVec
+}
+
+func newHttpChecker() *httpChecker {
+	return &httpChecker{
+		checks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subSystemHttpChecker,
+			Name:      "checked_total",
+			Help:      "Total number of times the endpoint has been checked",
+		}, []string{"name", "method", "url", "healthy"}),
+		healthy: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subSystemHttpChecker,
+			Name:      "healthy_info",
+			Help:      "Is the endpoint responding as expected 1 otherwise 0",
+		}, []string{"name", "method", "url"}),
+		statusCode: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subSystemHttpChecker,
+			Name:      "status_code",
+			Help:      "Status code of the last response or 0 if no response was received",
+		}, []string{"name", "method", "url"}),
+		failuresTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subSystemHttpChecker,
+			Name:      "failures_total",
+			Help:      "Total number of failed checks by reason",
+		}, []string{"name", "method", "url", "reason"}),
+		responseDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subSystemHttpChecker,
+			Name:      "response_duration_seconds",
+			Help:      "Duration from a request is sent until the full response is read including failed requests",
+			Buckets:   prometheus.ExponentialBuckets(0.005, 2, 11),
+		}, []string{"name", "method", "url"}),
+		tlsHandshakeDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subSystemHttpChecker,
+			Name:      "tls_handshake_duration_seconds",
+			Help:      "Duration of TLS handshakes",
+			Buckets:   prometheus.ExponentialBuckets(0.005, 2, 11),
+		}, []string{"name", "method", "url"}),
+		certificateExpiry: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subSystemHttpChecker,
+			Name:      "certificate_expiry_timestamp_seconds",
+			Help:      "Unix timestamp of the expiry of the peer certificate",
+		}, []string{"name", "method", "url"}),
+	}
+}
+
+func (hc *httpChecker) getCollectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		hc.checks,
+		hc.healthy,
+		hc.statusCode,
+		hc.failuresTotal,
+		hc.responseDuration,
+		hc.tlsHandshakeDuration,
+		hc.certificateExpiry,
+	}
+}
+
+func (hc *httpChecker) ReportHTTPCheck(report httpchecker.Report) {
+	labelValues := []string{report.Name, report.Method, report.URL}
+	hc.checks.WithLabelValues(append(labelValues, strconv.FormatBool(report.Healthy))...).Inc()
+	if report.Healthy {
+		hc.healthy.WithLabelValues(labelValues...).Set(1)
+	} else {
+		hc.healthy.WithLabelValues(labelValues...).Set(0)
+		hc.failuresTotal.WithLabelValues(append(labelValues, string(report.Failure))...).Inc()
+	}
+	hc.statusCode.WithLabelValues(labelValues...).Set(float64(report.StatusCode))
+	hc.responseDuration.WithLabelValues(labelValues...).Observe(report.ResponseDuration.Seconds())
+	if report.TLSHandshakeDuration > 0 {
+		hc.tlsHandshakeDuration.WithLabelValues(labelValues...).Observe(report.TLSHandshakeDuration.Seconds())
+	}
+	if !report.CertificateExpiry.IsZero() {
+		hc.certificateExpiry.WithLabelValues(labelValues...).Set(float64(report.CertificateExpiry.Unix()))
+	}
+}
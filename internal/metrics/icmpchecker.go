@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"github.com/lunarway/strong-duckling/internal/icmpchecker"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	subSystemIcmpChecker = "icmp_checker"
+)
+
+type icmpChecker struct {
+	sentTotal            *prometheus.CounterVec
+	receivedTotal        *prometheus.CounterVec
+	reachable            *prometheus.GaugeVec
+	failuresTotal        *prometheus.CounterVec
+	roundTripTime        *prometheus.HistogramVec
+	lossPercent          *prometheus.GaugeVec
+	averageRoundTripTime *prometheus.GaugeVec
+	jitter               *prometheus.GaugeVec
+}
+
+func newIcmpChecker() *icmpChecker {
+	return &icmpChecker{
+		sentTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subSystemIcmpChecker,
+			Name:      "sent_total",
+			Help:      "Total number of echo requests sent",
+		}, []string{"name", "address"}),
+		receivedTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subSystemIcmpChecker,
+			Name:      "received_total",
+			Help:      "Total number of echo replies received",
+		}, []string{"name", "address"}),
+		reachable: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subSystemIcmpChecker,
+			Name:      "reachable_info",
+			Help:      "Did the last echo request receive a reply 1 otherwise 0",
+		}, []string{"name", "address"}),
+		failuresTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subSystemIcmpChecker,
+			Name:      "failures_total",
+			Help:      "Total number of failed echo requests by reason",
+		}, []string{"name", "address", "reason"}),
+		roundTripTime: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subSystemIcmpChecker,
+			Name:      "round_trip_seconds",
+			Help:      "Round trip time of echo replies",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 11),
+		}, []string{"name", "address"}),
+		lossPercent: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subSystemIcmpChecker,
+			Name:      "loss_percent",
+			Help:      "Percentage of echo requests without a reply over the window",
+		}, []string{"name", "address"}),
+		averageRoundTripTime: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subSystemIcmpChecker,
+			Name:      "round_trip_average_seconds",
+			Help:      "Mean round trip time of echo replies over the window",
+		}, []string{"name", "address"}),
+		jitter: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subSystemIcmpChecker,
+			Name:      "jitter_seconds",
+			Help:      "Mean difference between round trip times of consecutive echo replies over the window",
+		}, []string{"name", "address"}),
+	}
+}
+
+func (ic *icmpChecker) getCollectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		ic.sentTotal,
+		ic.receivedTotal,
+		ic.reachable,
+		ic.failuresTotal,
+		ic.roundTripTime,
+		ic.lossPercent,
+		ic.averageRoundTripTime,
+		ic.jitter,
+	}
+}
+
+func (ic *icmpChecker) ReportICMPCheck(report icmpchecker.Report) {
+	labelValues := []string{report.Name, report.Address}
+	ic.sentTotal.WithLabelValues(labelValues...).Inc()
+	if report.Reachable {
+		ic.receivedTotal.WithLabelValues(labelValues...).Inc()
+		ic.reachable.WithLabelValues(labelValues...).Set(1)
+		ic.roundTripTime.WithLabelValues(labelValues...).Observe(report.RoundTripTime.Seconds())
+	} else {
+		ic.reachable.WithLabelValues(labelValues...).Set(0)
+		ic.failuresTotal.WithLabelValues(append(labelValues, string(report.Failure))...).Inc()
+	}
+	ic.lossPercent.WithLabelValues(labelValues...).Set(report.Window.LossPercent)
+	ic.averageRoundTripTime.WithLabelValues(labelValues...).Set(report.Window.AverageRoundTripTime.Seconds())
+	ic.jitter.WithLabelValues(labelValues...).Set(report.Window.Jitter.Seconds())
+}
@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"github.com/lunarway/strong-duckling/internal/strongswan"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	subSystemIdle = "idle"
+)
+
+var _ strongswan.IdleStatusReceiver = &idle{}
+
+// idle reports idle child SAs detected by a strongswan.IdleDetector.
+type idle struct {
+	childSA          *prometheus.GaugeVec
+	transitionsTotal *prometheus.CounterVec
+}
+
+func newIdle() *idle {
+	return &idle{
+		childSA: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subSystemIdle,
+			Name:      "child_sa",
+			Help:      "1 if the child SA has seen no packets in the direction for longer than its threshold, otherwise 0",
+		}, []string{"ike_sa_name", "child_sa_name", "direction"}),
+		transitionsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subSystemIdle,
+			Name:      "transitions_total",
+			Help:      "Total number of times the child SA became idle in the direction",
+		}, []string{"ike_sa_name", "child_sa_name", "direction"}),
+	}
+}
+
+func (i *idle) getCollectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		i.childSA,
+		i.transitionsTotal,
+	}
+}
+
+func (i *idle) IdleStatus(status strongswan.IdleStatus) {
+	labelValues := []string{status.IKESAName, status.ChildSAName, string(status.Direction)}
+	if status.Removed {
+		i.childSA.DeleteLabelValues(labelValues...)
+		return
+	}
+	value := 0.0
+	if status.Idle {
+		value = 1
+		if status.Changed {
+			i.transitionsTotal.WithLabelValues(labelValues...).Inc()
+		}
+	}
+	i.childSA.WithLabelValues(labelValues...).Set(value)
+}
@@ -3,6 +3,7 @@ package metrics
 import (
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/lunarway/strong-duckling/internal/strongswan"
 	"github.com/lunarway/strong-duckling/internal/vici"
@@ -14,6 +15,8 @@ const (
 	subSystemIKE = "ike_sa"
 )
 
+var _ strongswan.CollectionDoneReceiver = &ikeSA{}
+
 type ikeSA struct {
 	logger log.Logger
 	helper *helper
@@ -28,8 +31,26 @@ type ikeSA struct {
 	installs             *prometheus.CounterVec
 	rekeySeconds         *prometheus.HistogramVec
 	lifeTimeSeconds      *prometheus.HistogramVec
-	state                *prometheus.GaugeVec
-	childSAState         *prometheus.GaugeVec
+	state                *stateVec
+	childSAState         *stateVec
+	info                 *infoVec
+	childSAInfo          *infoVec
+
+	rekeyRemainingSeconds       *prometheus.GaugeVec
+	rekeyBytesConsumedPercent   *prometheus.GaugeVec
+	rekeyPacketsConsumedPercent *prometheus.GaugeVec
+	rekeyBytesForecastSeconds   *prometheus.GaugeVec
+	rekeyPacketsForecastSeconds *prometheus.GaugeVec
+
+	// now returns the current time. It is used to calculate traffic rates.
+	now func() time.Time
+	// rekeyForecasters holds a forecaster for each IKE SA name.
+	rekeyForecasters map[string]*rekeyForecaster
+	// rekeySeries holds the label values of the rekey series of child SAs by
+	// IKE SA name and child SA key.
+	rekeySeries map[string]map[string][]string
+	// seen holds the names of IKE SAs seen in the current collection.
+	seen map[string]struct{}
 }
 
 type ikeSALabels struct {
@@ -125,18 +146,64 @@ func newIkeSA(logger log.Logger) *ikeSA {
 			Help:      "Duration of each IKE session",
 			Buckets:   prometheus.ExponentialBuckets(15, 2, 14),
 		}, childSALabels{}.names()),
-		state: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		state: newStateVec(prometheus.NewGaugeVec(prometheus.GaugeOpts{
 			Namespace: namespace,
 			Subsystem: subSystemIKE,
 			Name:      "state_info",
-			Help:      "Current state of the SA",
-		}, ikeSALabels{}.names()),
-		childSAState: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Help:      "Current state of the SA. The series of the current state is 1 otherwise 0",
+		}, append(ikeSALabels{}.names(), "state")), vici.IKESAStates),
+		childSAState: newStateVec(prometheus.NewGaugeVec(prometheus.GaugeOpts{
 			Namespace: namespace,
 			Subsystem: subSystemIKE,
 			Name:      "child_state_info",
-			Help:      "Current state of the child SA",
+			Help:      "Current state of the child SA. The series of the current state is 1 otherwise 0",
+		}, append(childSALabels{}.names(), "state")), vici.ChildSAStates),
+		info: newInfoVec(prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subSystemIKE,
+			Name:      "info",
+			Help:      "Negotiated parameters of the SA",
+		}, append(ikeSALabels{}.names(), ikeSAInfoLabelNames...))),
+		childSAInfo: newInfoVec(prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subSystemIKE,
+			Name:      "child_info",
+			Help:      "Negotiated parameters of the child SA",
+		}, append(childSALabels{}.names(), childSAInfoLabelNames...))),
+		rekeyRemainingSeconds: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subSystemIKE,
+			Name:      "rekey_remaining_seconds",
+			Help:      "Number of seconds until the child SA is rekeyed by time",
+		}, childSALabels{}.names()),
+		rekeyBytesConsumedPercent: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subSystemIKE,
+			Name:      "rekey_bytes_consumed_percent",
+			Help:      "Percentage of the rekey_bytes limit consumed by the child SA",
+		}, childSALabels{}.names()),
+		rekeyPacketsConsumedPercent: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subSystemIKE,
+			Name:      "rekey_packets_consumed_percent",
+			Help:      "Percentage of the rekey_packets limit consumed by the child SA",
+		}, childSALabels{}.names()),
+		rekeyBytesForecastSeconds: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subSystemIKE,
+			Name:      "rekey_bytes_forecast_seconds",
+			Help:      "Estimated number of seconds until the rekey_bytes limit is reached at the current rate",
 		}, childSALabels{}.names()),
+		rekeyPacketsForecastSeconds: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subSystemIKE,
+			Name:      "rekey_packets_forecast_seconds",
+			Help:      "Estimated number of seconds until the rekey_packets limit is reached at the current rate",
+		}, childSALabels{}.names()),
+		now:              time.Now,
+		rekeyForecasters: make(map[string]*rekeyForecaster),
+		rekeySeries:      make(map[string]map[string][]string),
+		seen:             make(map[string]struct{}),
 	}
 }
 
@@ -152,14 +219,23 @@ func (i *ikeSA) getCollectors() []prometheus.Collector {
 		i.installs,
 		i.rekeySeconds,
 		i.lifeTimeSeconds,
-		i.state,
-		i.childSAState,
+		i.state.gauge,
+		i.childSAState.gauge,
+		i.info.gauge,
+		i.childSAInfo.gauge,
+		i.rekeyRemainingSeconds,
+		i.rekeyBytesConsumedPercent,
+		i.rekeyPacketsConsumedPercent,
+		i.rekeyBytesForecastSeconds,
+		i.rekeyPacketsForecastSeconds,
 	}
 }
 
 func (p *ikeSA) IKESAStatus(ikeSAStatus strongswan.IKESAStatus) {
+	p.seen[ikeSAStatus.Name] = struct{}{}
 	if ikeSAStatus.State == nil {
 		p.logger.Errorf("No SA for connection configuration: %#v", ikeSAStatus.Configuration)
+		p.forget(ikeSAStatus.Name)
 		return
 	}
 	ikeSALabels := ikeSALabels{
@@ -169,7 +245,16 @@ func (p *ikeSA) IKESAStatus(ikeSAStatus strongswan.IKESAStatus) {
 	}
 	p.helper.setGaugeByMax(p.establishedSeconds, ikeSAStatus.State.EstablishedSeconds, "EstablishedSeconds", ikeSALabels)
 	p.logger.Infof("prometheusReporter: IKESAStatus: IKE_SA state: %v", ikeSAStatus.State.State)
-	for _, child := range ikeSAStatus.State.ChildSAs {
+	p.state.set(ikeSAStatus.Name, "", ikeSALabels.values(), ikeSAStatus.State.State)
+	p.info.set(ikeSAStatus.Name, "", append(ikeSALabels.values(), ikeSAInfoLabelValues(ikeSAStatus.State)...))
+	forecaster, ok := p.rekeyForecasters[ikeSAStatus.Name]
+	if !ok {
+		forecaster = newRekeyForecaster()
+		p.rekeyForecasters[ikeSAStatus.Name] = forecaster
+	}
+	now := p.now()
+	seenChildSAs := make(map[string]struct{})
+	for childKey, child := range ikeSAStatus.State.ChildSAs {
 		labels := childSALabels{
 			ikeSALabels:   ikeSALabels,
 			childSAName:   child.Name,
@@ -177,6 +262,8 @@ func (p *ikeSA) IKESAStatus(ikeSAStatus strongswan.IKESAStatus) {
 			remoteIPRange: strings.Join(child.RemoteTrafficSelectors, ","),
 		}
 		p.logger.Infof("prometheusReporter: IKESAStatus: IKE_SA child state: %v", child.State)
+		p.childSAState.set(ikeSAStatus.Name, childKey, labels.values(), child.State)
+		p.childSAInfo.set(ikeSAStatus.Name, childKey, append(labels.values(), childSAInfoLabelValues(child)...))
 		p.helper.setCounterByMax(p.installs, child.InstallTimeSeconds, "InstallTimeSeconds", labels)
 		p.helper.setGauge(p.packetsIn, child.PacketsIn, "PacketsIn", labels)
 		p.helper.setGauge(p.packetsOut, child.PacketsOut, "PacketsOut", labels)
@@ -186,8 +273,202 @@ func (p *ikeSA) IKESAStatus(ikeSAStatus strongswan.IKESAStatus) {
 		p.helper.setHistogramByMax(p.lastPacketOutSeconds, child.LastPacketOutSeconds, "LastPacketOutSeconds", labels)
 		p.helper.setHistogramByMin(p.rekeySeconds, child.RekeyTimeSeconds, "RekeyTimeSeconds", labels)
 		p.helper.setHistogramByMax(p.lifeTimeSeconds, child.LifeTimeSeconds, "LifeTimeSeconds", labels)
-		p.setRekeySeconds(ikeSAStatus.Configuration, child, labels)
+		// orphaned IKE SAs have no configured rekey time
+		if !ikeSAStatus.Orphaned {
+			p.setRekeySeconds(ikeSAStatus.Configuration, child, labels)
+		}
+		p.setRekeyForecast(forecaster, childKey, now, ikeSAStatus.Configuration.Children[child.Name], child, labels)
+		seenChildSAs[childKey] = struct{}{}
+	}
+	forecaster.forget(seenChildSAs)
+	p.retainRekeySeries(ikeSAStatus.Name, seenChildSAs)
+	p.childSAInfo.retain(ikeSAStatus.Name, seenChildSAs)
+	p.childSAState.retain(ikeSAStatus.Name, seenChildSAs)
+}
+
+// CollectionDone forgets IKE SAs that are gone, eg. after their configuration
+// is unloaded and they are torn down.
+func (p *ikeSA) CollectionDone() {
+	gone := make(map[string]struct{})
+	for name := range p.rekeyForecasters {
+		gone[name] = struct{}{}
+	}
+	for name := range p.rekeySeries {
+		gone[name] = struct{}{}
+	}
+	for _, series := range []map[string]map[string][]string{p.info.series, p.childSAInfo.series} {
+		for name := range series {
+			gone[name] = struct{}{}
+		}
+	}
+	for _, series := range []map[string]map[string][][]string{p.state.series, p.childSAState.series} {
+		for name := range series {
+			gone[name] = struct{}{}
+		}
+	}
+	for name := range gone {
+		if _, ok := p.seen[name]; !ok {
+			p.forget(name)
+		}
+	}
+	p.seen = make(map[string]struct{})
+}
+
+// forget removes the info, state and rekey series and the rekey forecaster of
+// the IKE SA name.
+func (p *ikeSA) forget(name string) {
+	p.info.retain(name, nil)
+	p.childSAInfo.retain(name, nil)
+	p.state.retain(name, nil)
+	p.childSAState.retain(name, nil)
+	p.retainRekeySeries(name, nil)
+	delete(p.rekeyForecasters, name)
+}
+
+// rekeyGauges returns the gauges of the rekey series of child SAs.
+func (p *ikeSA) rekeyGauges() []*prometheus.GaugeVec {
+	return []*prometheus.GaugeVec{
+		p.rekeyRemainingSeconds,
+		p.rekeyBytesConsumedPercent,
+		p.rekeyPacketsConsumedPercent,
+		p.rekeyBytesForecastSeconds,
+		p.rekeyPacketsForecastSeconds,
+	}
+}
+
+// setRekeySeries records the label values of the rekey series of the child SA
+// childKey of the IKE SA name. Series of previous label values of the child SA
+// are removed.
+func (p *ikeSA) setRekeySeries(name, childKey string, labelValues []string) {
+	keys, ok := p.rekeySeries[name]
+	if !ok {
+		keys = make(map[string][]string)
+		p.rekeySeries[name] = keys
+	}
+	if previous, ok := keys[childKey]; ok && !equalLabelValues(previous, labelValues) {
+		for _, g := range p.rekeyGauges() {
+			g.DeleteLabelValues(previous...)
+		}
+	}
+	keys[childKey] = labelValues
+}
+
+// retainRekeySeries removes the rekey series of child SAs of the IKE SA name
+// that are not in keys. Series with the same label values as a retained child
+// SA, eg. of a rekeyed child SA, are kept.
+func (p *ikeSA) retainRekeySeries(name string, keys map[string]struct{}) {
+	var retained [][]string
+	for key, labelValues := range p.rekeySeries[name] {
+		if _, ok := keys[key]; ok {
+			retained = append(retained, labelValues)
+		}
+	}
+	for key, labelValues := range p.rekeySeries[name] {
+		if _, ok := keys[key]; ok {
+			continue
+		}
+		if !containsLabelValues(retained, labelValues) {
+			for _, g := range p.rekeyGauges() {
+				g.DeleteLabelValues(labelValues...)
+			}
+		}
+		delete(p.rekeySeries[name], key)
+	}
+	if len(p.rekeySeries[name]) == 0 {
+		delete(p.rekeySeries, name)
+	}
+}
+
+var ikeSAInfoLabelNames = []string{
+	"unique_id",
+	"ike_version",
+	"initiator",
+	"nat_local",
+	"nat_remote",
+	"initiator_spi",
+	"responder_spi",
+	"encr_alg",
+	"encr_keysize",
+	"integ_alg",
+	"integ_keysize",
+	"prf_alg",
+	"dh_group",
+}
+
+func ikeSAInfoLabelValues(sa *vici.IkeSa) []string {
+	return []string{
+		sa.UniqueID,
+		sa.IKEVersion,
+		sa.Initiator,
+		sa.NATLocal,
+		sa.NATRemote,
+		sa.InitiatorSPI,
+		sa.ResponderSPI,
+		sa.EncryptionAlgorithm,
+		sa.EncryptionKeySize,
+		sa.IntegrityAlgorithm,
+		sa.IntegrityKeySize,
+		sa.PRFAlgorithm,
+		sa.DHGroup,
+	}
+}
+
+var childSAInfoLabelNames = []string{
+	"unique_id",
+	"reqid",
+	"mode",
+	"protocol",
+	"encap",
+	"spi_in",
+	"spi_out",
+	"encr_alg",
+	"encr_keysize",
+	"integ_alg",
+	"integ_keysize",
+	"dh_group",
+	"esn",
+}
+
+func childSAInfoLabelValues(child vici.ChildSA) []string {
+	return []string{
+		child.UniqueID,
+		child.ReqID,
+		child.IPsecMode,
+		child.IPsecProtocol,
+		child.UDPEncapsulation,
+		child.SPIIn,
+		child.SPIOut,
+		child.EncryptionAlgorithm,
+		child.EncryptionKeySize,
+		child.IntegrityAlgorithm,
+		child.IntegrityKeySize,
+		child.DHGroup,
+		child.ExtendedSequenceNumber,
+	}
+}
+
+func (p *ikeSA) setRekeyForecast(forecaster *rekeyForecaster, childKey string, now time.Time, conf vici.ChildSAConf, child vici.ChildSA, labels childSALabels) {
+	var rekeyRemaining *float64
+	if seconds, err := strconv.ParseFloat(child.RekeyTimeSeconds, 64); err == nil {
+		rekeyRemaining = &seconds
+	}
+	p.setRekeySeries(labels.name, childKey, labels.values())
+	setOrDeleteGauge(p.rekeyRemainingSeconds, rekeyRemaining, labels)
+	forecast := forecaster.observe(childKey, now, conf, child)
+	setOrDeleteGauge(p.rekeyBytesConsumedPercent, forecast.bytesConsumed, labels)
+	setOrDeleteGauge(p.rekeyPacketsConsumedPercent, forecast.packetsConsumed, labels)
+	setOrDeleteGauge(p.rekeyBytesForecastSeconds, forecast.bytesForecast, labels)
+	setOrDeleteGauge(p.rekeyPacketsForecastSeconds, forecast.packetsForecast, labels)
+}
+
+// setOrDeleteGauge sets the gauge to value if it is set and otherwise removes
+// the series to avoid exposing stale values.
+func setOrDeleteGauge(g *prometheus.GaugeVec, value *float64, labels childSALabels) {
+	if value == nil {
+		g.DeleteLabelValues(labels.values()...)
+		return
 	}
+	g.WithLabelValues(labels.values()...).Set(*value)
 }
 
 func (p *ikeSA) setRekeySeconds(conn vici.IKEConf, child vici.ChildSA, labels childSALabels) {
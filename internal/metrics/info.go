@@ -0,0 +1,160 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// infoVec tracks the label values of info style gauges to remove series that
+// are no longer valid, eg. when SPIs change after a rekey.
+//
+// Series are grouped, eg. by IKE SA name, so all series of a group can be
+// retained when a full set of current keys is known.
+type infoVec struct {
+	gauge  *prometheus.GaugeVec
+	series map[string]map[string][]string
+}
+
+func newInfoVec(gauge *prometheus.GaugeVec) *infoVec {
+	return &infoVec{
+		gauge:  gauge,
+		series: make(map[string]map[string][]string),
+	}
+}
+
+// set sets the series identified by group and key with labelValues to 1. Any
+// previous series for the same key with other label values is removed.
+func (v *infoVec) set(group, key string, labelValues []string) {
+	v.setValue(group, key, labelValues, 1)
+}
+
+// setValue sets the series identified by group and key with labelValues to
+// value. Any previous series for the same key with other label values is
+// removed.
+func (v *infoVec) setValue(group, key string, labelValues []string, value float64) {
+	keys, ok := v.series[group]
+	if !ok {
+		keys = make(map[string][]string)
+		v.series[group] = keys
+	}
+	previous, ok := keys[key]
+	if ok && !equalLabelValues(previous, labelValues) {
+		v.gauge.DeleteLabelValues(previous...)
+	}
+	keys[key] = labelValues
+	v.gauge.WithLabelValues(labelValues...).Set(value)
+}
+
+// retain removes all series in group that are not in keys.
+func (v *infoVec) retain(group string, keys map[string]struct{}) {
+	for key, labelValues := range v.series[group] {
+		if _, ok := keys[key]; ok {
+			continue
+		}
+		v.gauge.DeleteLabelValues(labelValues...)
+		delete(v.series[group], key)
+	}
+	if len(v.series[group]) == 0 {
+		delete(v.series, group)
+	}
+}
+
+func equalLabelValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// stateVec tracks the series of state style gauges with a series per state to
+// remove the series of SAs that are gone.
+//
+// Series are grouped and keyed like infoVec.
+type stateVec struct {
+	gauge  *prometheus.GaugeVec
+	states []string
+	series map[string]map[string][][]string
+}
+
+func newStateVec(gauge *prometheus.GaugeVec, states []string) *stateVec {
+	return &stateVec{
+		gauge:  gauge,
+		states: states,
+		series: make(map[string]map[string][][]string),
+	}
+}
+
+// set sets a series for each of the known states where the series of state is
+// 1 and all others are 0. If state is not one of the known states, it is added
+// as an additional series. The state label value is appended to labelValues.
+// Any previous series for the same key that is not set is removed.
+func (v *stateVec) set(group, key string, labelValues []string, state string) {
+	var current [][]string
+	known := false
+	for _, s := range v.states {
+		value := 0.0
+		if s == state {
+			value = 1
+			known = true
+		}
+		current = append(current, v.setState(labelValues, s, value))
+	}
+	if !known && state != "" {
+		current = append(current, v.setState(labelValues, state, 1))
+	}
+	keys, ok := v.series[group]
+	if !ok {
+		keys = make(map[string][][]string)
+		v.series[group] = keys
+	}
+	for _, previous := range keys[key] {
+		if !containsLabelValues(current, previous) {
+			v.gauge.DeleteLabelValues(previous...)
+		}
+	}
+	keys[key] = current
+}
+
+func (v *stateVec) setState(labelValues []string, state string, value float64) []string {
+	stateLabelValues := append(append([]string(nil), labelValues...), state)
+	v.gauge.WithLabelValues(stateLabelValues...).Set(value)
+	return stateLabelValues
+}
+
+// retain removes all series in group that are not in keys. Series with the
+// same label values as a retained key, eg. of a rekeyed child SA, are kept.
+func (v *stateVec) retain(group string, keys map[string]struct{}) {
+	var retained [][]string
+	for key, series := range v.series[group] {
+		if _, ok := keys[key]; ok {
+			retained = append(retained, series...)
+		}
+	}
+	for key, series := range v.series[group] {
+		if _, ok := keys[key]; ok {
+			continue
+		}
+		for _, labelValues := range series {
+			if !containsLabelValues(retained, labelValues) {
+				v.gauge.DeleteLabelValues(labelValues...)
+			}
+		}
+		delete(v.series[group], key)
+	}
+	if len(v.series[group]) == 0 {
+		delete(v.series, group)
+	}
+}
+
+func containsLabelValues(series [][]string, labelValues []string) bool {
+	for _, s := range series {
+		if equalLabelValues(s, labelValues) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"github.com/lunarway/strong-duckling/internal/keepalive"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	subSystemKeepalive = "keepalive"
+)
+
+var _ keepalive.Reporter = &keepaliveReporter{}
+
+// keepaliveReporter reports keepalive probes sent through child SAs.
+type keepaliveReporter struct {
+	sentTotal     *prometheus.CounterVec
+	failuresTotal *prometheus.CounterVec
+}
+
+func newKeepalive() *keepaliveReporter {
+	return &keepaliveReporter{
+		sentTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subSystemKeepalive,
+			Name:      "sent_total",
+			Help:      "Total number of keepalive probes sent through the child SA",
+		}, []string{"ike_sa_name", "child_sa_name", "protocol"}),
+		failuresTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subSystemKeepalive,
+			Name:      "failures_total",
+			Help:      "Total number of keepalive probes that could not be sent through the child SA",
+		}, []string{"ike_sa_name", "child_sa_name", "protocol"}),
+	}
+}
+
+func (k *keepaliveReporter) getCollectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		k.sentTotal,
+		k.failuresTotal,
+	}
+}
+
+func (k *keepaliveReporter) ReportKeepalive(report keepalive.Report) {
+	labelValues := []string{report.IKESAName, report.ChildSAName, string(report.Protocol)}
+	if report.Error != nil {
+		k.failuresTotal.WithLabelValues(labelValues...).Inc()
+		return
+	}
+	k.sentTotal.WithLabelValues(labelValues...).Inc()
+}
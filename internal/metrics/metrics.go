@@ -2,61 +2,196 @@ package metrics
 
 import (
 	"net/http"
+	"time"
 
 	daemonpkg "github.com/lunarway/strong-duckling/internal/daemon"
+	"github.com/lunarway/strong-duckling/internal/httpchecker"
+	"github.com/lunarway/strong-duckling/internal/icmpchecker"
+	"github.com/lunarway/strong-duckling/internal/keepalive"
 	"github.com/lunarway/strong-duckling/internal/strongswan"
 	"github.com/lunarway/strong-duckling/internal/tcpchecker"
+	"github.com/lunarway/strong-duckling/internal/tunnelchecker"
+	"github.com/lunarway/strong-duckling/internal/udpchecker"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/prometheus/common/log"
 )
 
-func Register(serveMux *http.ServeMux) {
-	serveMux.Handle("/metrics", promhttp.InstrumentMetricHandler(
-		prometheus.DefaultRegisterer, http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
-			promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{}).ServeHTTP(rw, r)
-		}),
-	))
-}
-
 const (
 	namespace = "strong_duckling"
 )
 
+// Configuration specifies how a PrometheusReporter is set up.
+type Configuration struct {
+	// ConstLabels are added to all metrics of the reporter. Use them to
+	// distinguish multiple reporters in the same process.
+	ConstLabels prometheus.Labels
+	// ProcessCollector enables the standard process metrics, eg. CPU and memory
+	// usage and open file descriptors.
+	ProcessCollector bool
+	// GoCollector enables the standard Go runtime metrics.
+	GoCollector bool
+	// TcpCheckerFlapWindow is the sliding window over which the flap rate of
+	// TCP checker targets is calculated. Defaults to DefaultFlapWindow.
+	TcpCheckerFlapWindow time.Duration
+	// RemoteAccessMaxIdentities is the maximum number of distinct identities
+	// exposed as labels by remote access metrics. If 0 there is no limit.
+	RemoteAccessMaxIdentities int
+}
+
+// PrometheusReporter reports metrics to its own Prometheus registry. Use
+// Handler to expose them.
 type PrometheusReporter struct {
-	registry prometheus.Registerer
+	registry *prometheus.Registry
 	logger   log.Logger
 
-	version    *prometheus.GaugeVec
-	tcpChecker *tcpChecker
-	ikeSA      *ikeSA
-	daemon     *daemon
+	version       *prometheus.GaugeVec
+	tcpChecker    *tcpChecker
+	httpChecker   *httpChecker
+	udpChecker    *udpChecker
+	icmpChecker   *icmpChecker
+	tunnelChecker *tunnelChecker
+	ikeSA         *ikeSA
+	remoteAccess  *remoteAccess
+	healer        *healer
+	reinitiator   *reinitiator
+	duplicates    *duplicates
+	watchdog      *watchdog
+	orphans       *orphans
+	idle          *idle
+	keepalive     *keepaliveReporter
+	dryRun        *dryRun
+	daemon        *daemon
 }
 
 func (pr *PrometheusReporter) TcpChecker() tcpchecker.Reporter {
 	return pr.tcpChecker
 }
 
+func (pr *PrometheusReporter) HttpChecker() httpchecker.Reporter {
+	return pr.httpChecker
+}
+
+func (pr *PrometheusReporter) UdpChecker() udpchecker.Reporter {
+	return pr.udpChecker
+}
+
+func (pr *PrometheusReporter) IcmpChecker() icmpchecker.Reporter {
+	return pr.icmpChecker
+}
+
+// TunnelChecker returns a reporter of checks through the child SA childSAName
+// of the IKE SA ikeSAName.
+func (pr *PrometheusReporter) TunnelChecker(ikeSAName, childSAName string) tcpchecker.Reporter {
+	return &tunnelCheckerReporter{
+		tunnelChecker: pr.tunnelChecker,
+		ikeSAName:     ikeSAName,
+		childSAName:   childSAName,
+	}
+}
+
+// DeleteTunnelChecker removes the metrics of target. Use it when the target is
+// no longer checked.
+func (pr *PrometheusReporter) DeleteTunnelChecker(target tunnelchecker.Target) {
+	pr.tunnelChecker.delete(target)
+}
+
 func (pr *PrometheusReporter) StrongSwan() strongswan.IKESAStatusReceiver {
 	return pr.ikeSA
 }
 
+// RemoteAccess returns a receiver reporting metrics on individual sessions of
+// remote access connections. At most Configuration.RemoteAccessMaxIdentities
+// distinct identities are exposed as labels.
+func (pr *PrometheusReporter) RemoteAccess() strongswan.IKESAStatusReceiver {
+	return pr.remoteAccess
+}
+
+// Healer returns a reporter of actions taken on child SAs by a
+// strongswan.Healer.
+func (pr *PrometheusReporter) Healer() strongswan.HealReporter {
+	return pr.healer
+}
+
+// Reinitiator returns a reporter of initiations by a strongswan.Reinitiator.
+func (pr *PrometheusReporter) Reinitiator() strongswan.ReinitiatorReporter {
+	return pr.reinitiator
+}
+
+// Duplicates returns a reporter of stale duplicate child SAs terminated by a
+// strongswan.DuplicateTerminator.
+func (pr *PrometheusReporter) Duplicates() strongswan.DuplicatesReporter {
+	return pr.duplicates
+}
+
+// Watchdog returns a reporter of stuck IKE SAs terminated by a
+// strongswan.Watchdog.
+func (pr *PrometheusReporter) Watchdog() strongswan.WatchdogReporter {
+	return pr.watchdog
+}
+
+// OrphanedIKESAs returns a receiver reporting the number of IKE SAs without a
+// configuration.
+func (pr *PrometheusReporter) OrphanedIKESAs() strongswan.IKESAStatusReceiver {
+	return pr.orphans
+}
+
+// Orphans returns a reporter of terminations of IKE SAs without a
+// configuration by a strongswan.OrphanTerminator.
+func (pr *PrometheusReporter) Orphans() strongswan.OrphanReporter {
+	return pr.orphans
+}
+
+// Idle returns a receiver of idle statuses of child SAs detected by a
+// strongswan.IdleDetector.
+func (pr *PrometheusReporter) Idle() strongswan.IdleStatusReceiver {
+	return pr.idle
+}
+
+// Keepalive returns a reporter of keepalive probes sent through child SAs.
+func (pr *PrometheusReporter) Keepalive() keepalive.Reporter {
+	return pr.keepalive
+}
+
+// DryRun returns a reporter of actions decided by components in dry-run mode.
+func (pr *PrometheusReporter) DryRun() strongswan.DecisionReporter {
+	return pr.dryRun
+}
+
 func (pr *PrometheusReporter) Daemon(logger log.Logger, name string) *daemonpkg.Reporter {
 	return pr.daemon.DefaultDaemonReporter(logger, name)
 }
 
-func NewPrometheusReporter(reg prometheus.Registerer, logger log.Logger) (*PrometheusReporter, error) {
+// Handler returns an http.Handler serving the metrics of the reporter.
+func (pr *PrometheusReporter) Handler() http.Handler {
+	return promhttp.InstrumentMetricHandler(pr.registry, promhttp.HandlerFor(pr.registry, promhttp.HandlerOpts{}))
+}
+
+func NewPrometheusReporter(logger log.Logger, config Configuration) (*PrometheusReporter, error) {
 	r := PrometheusReporter{
-		registry: reg,
+		registry: prometheus.NewRegistry(),
 		logger:   logger,
 		version: prometheus.NewGaugeVec(prometheus.GaugeOpts{
 			Namespace: namespace,
 			Name:      "info",
 			Help:      "Version info of strong_duckling",
 		}, []string{"version"}),
-		tcpChecker: newTcpChecker(),
-		ikeSA:      newIkeSA(logger),
-		daemon:     newDaemon(),
+		tcpChecker:    newTcpChecker(config.TcpCheckerFlapWindow),
+		httpChecker:   newHttpChecker(),
+		udpChecker:    newUdpChecker(),
+		icmpChecker:   newIcmpChecker(),
+		tunnelChecker: newTunnelChecker(),
+		ikeSA:         newIkeSA(logger),
+		remoteAccess:  newRemoteAccess(logger, config.RemoteAccessMaxIdentities),
+		healer:        newHealer(),
+		reinitiator:   newReinitiator(),
+		duplicates:    newDuplicates(),
+		watchdog:      newWatchdog(),
+		orphans:       newOrphans(),
+		idle:          newIdle(),
+		keepalive:     newKeepalive(),
+		dryRun:        newDryRun(),
+		daemon:        newDaemon(),
 	}
 
 	collectors := []prometheus.Collector{
@@ -64,10 +199,29 @@ func NewPrometheusReporter(reg prometheus.Registerer, logger log.Logger) (*Prome
 	}
 
 	collectors = append(collectors, r.tcpChecker.getCollectors()...)
+	collectors = append(collectors, r.httpChecker.getCollectors()...)
+	collectors = append(collectors, r.udpChecker.getCollectors()...)
+	collectors = append(collectors, r.icmpChecker.getCollectors()...)
+	collectors = append(collectors, r.tunnelChecker.getCollectors()...)
 	collectors = append(collectors, r.ikeSA.getCollectors()...)
+	collectors = append(collectors, r.remoteAccess.getCollectors()...)
+	collectors = append(collectors, r.healer.getCollectors()...)
+	collectors = append(collectors, r.reinitiator.getCollectors()...)
+	collectors = append(collectors, r.duplicates.getCollectors()...)
+	collectors = append(collectors, r.watchdog.getCollectors()...)
+	collectors = append(collectors, r.orphans.getCollectors()...)
+	collectors = append(collectors, r.idle.getCollectors()...)
+	collectors = append(collectors, r.keepalive.getCollectors()...)
+	collectors = append(collectors, r.dryRun.getCollectors()...)
 	collectors = append(collectors, r.daemon.getCollectors()...)
+	if config.ProcessCollector {
+		collectors = append(collectors, prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
+	}
+	if config.GoCollector {
+		collectors = append(collectors, prometheus.NewGoCollector())
+	}
 
-	err := register(r.registry, collectors...)
+	err := register(prometheus.WrapRegistererWith(config.ConstLabels, r.registry), collectors...)
 	if err != nil {
 		return nil, err
 	}
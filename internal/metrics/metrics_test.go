@@ -1,12 +1,22 @@
 package metrics
 
 import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/lunarway/strong-duckling/internal/httpchecker"
+	"github.com/lunarway/strong-duckling/internal/icmpchecker"
+	"github.com/lunarway/strong-duckling/internal/keepalive"
 	"github.com/lunarway/strong-duckling/internal/strongswan"
 	"github.com/lunarway/strong-duckling/internal/tcpchecker"
 	"github.com/lunarway/strong-duckling/internal/test"
+	"github.com/lunarway/strong-duckling/internal/tunnelchecker"
+	"github.com/lunarway/strong-duckling/internal/udpchecker"
 	"github.com/lunarway/strong-duckling/internal/vici"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
@@ -14,6 +24,10 @@ import (
 )
 
 var _ tcpchecker.Reporter = (&PrometheusReporter{}).TcpChecker()
+var _ httpchecker.Reporter = (&PrometheusReporter{}).HttpChecker()
+var _ udpchecker.Reporter = (&PrometheusReporter{}).UdpChecker()
+var _ icmpchecker.Reporter = (&PrometheusReporter{}).IcmpChecker()
+var _ tcpchecker.Reporter = (&PrometheusReporter{}).TunnelChecker("", "")
 
 func TestIKESAStatus_gauges(t *testing.T) {
 	tt := []struct {
@@ -43,9 +57,8 @@ func TestIKESAStatus_gauges(t *testing.T) {
 	}
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
-			reg := prometheus.NewRegistry()
 			logger := test.NewLogger(t)
-			p, err := NewPrometheusReporter(reg, logger)
+			p, err := NewPrometheusReporter(logger, Configuration{})
 			if !assert.NoError(t, err, "unexpected initialization error") {
 				return
 			}
@@ -88,9 +101,8 @@ func TestIKESAStatus_installs(t *testing.T) {
 	}
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
-			reg := prometheus.NewRegistry()
 			logger := test.NewLogger(t)
-			p, err := NewPrometheusReporter(reg, logger)
+			p, err := NewPrometheusReporter(logger, Configuration{})
 			if !assert.NoError(t, err, "unexpected initialization error") {
 				return
 			}
@@ -178,9 +190,8 @@ strong_duckling_ike_sa_rekey_seconds_count{child_sa_name="",ike_sa_name="",local
 	}
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
-			reg := prometheus.NewRegistry()
 			logger := test.NewLogger(t)
-			p, err := NewPrometheusReporter(reg, logger)
+			p, err := NewPrometheusReporter(logger, Configuration{})
 			if !assert.NoError(t, err, "unexpected initialization error") {
 				return
 			}
@@ -200,7 +211,7 @@ strong_duckling_ike_sa_rekey_seconds_count{child_sa_name="",ike_sa_name="",local
 					},
 				})
 			}
-			err = testutil.GatherAndCompare(reg, strings.NewReader(tc.histogram), "strong_duckling_ike_sa_rekey_seconds")
+			err = testutil.GatherAndCompare(p.registry, strings.NewReader(tc.histogram), "strong_duckling_ike_sa_rekey_seconds")
 			assert.NoError(t, err, "unexpected error from gathering metrics")
 		})
 	}
@@ -252,9 +263,8 @@ func TestPrometheusReporter_maxValue(t *testing.T) {
 	}
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
-			reg := prometheus.NewRegistry()
 			logger := test.NewLogger(t)
-			p, err := NewPrometheusReporter(reg, logger)
+			p, err := NewPrometheusReporter(logger, Configuration{})
 			if !assert.NoError(t, err, "unexpected initialization error") {
 				return
 			}
@@ -316,9 +326,8 @@ func TestPrometheusReporter_minValue(t *testing.T) {
 	}
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
-			reg := prometheus.NewRegistry()
 			logger := test.NewLogger(t)
-			p, err := NewPrometheusReporter(reg, logger)
+			p, err := NewPrometheusReporter(logger, Configuration{})
 			if !assert.NoError(t, err, "unexpected initialization error") {
 				return
 			}
@@ -360,7 +369,26 @@ func TestIKESAStatus_labels(t *testing.T) {
 					},
 				},
 			},
-			output: `# HELP strong_duckling_ike_sa_installs_total Total number of SA installs
+			output: `# HELP strong_duckling_ike_sa_child_info Negotiated parameters of the child SA
+# TYPE strong_duckling_ike_sa_child_info gauge
+strong_duckling_ike_sa_child_info{child_sa_name="net-1",dh_group="",encap="",encr_alg="",encr_keysize="",esn="",ike_sa_name="gw-gw",integ_alg="",integ_keysize="",local_ip_range="local1,local2",local_peer_ip="localhost",mode="",protocol="",remote_ip_range="remote1,remote2",remote_peer_ip="remotehost",reqid="",spi_in="",spi_out="",unique_id=""} 1
+# HELP strong_duckling_ike_sa_child_state_info Current state of the child SA. The series of the current state is 1 otherwise 0
+# TYPE strong_duckling_ike_sa_child_state_info gauge
+strong_duckling_ike_sa_child_state_info{child_sa_name="net-1",ike_sa_name="gw-gw",local_ip_range="local1,local2",local_peer_ip="localhost",remote_ip_range="remote1,remote2",remote_peer_ip="remotehost",state="CREATED"} 0
+strong_duckling_ike_sa_child_state_info{child_sa_name="net-1",ike_sa_name="gw-gw",local_ip_range="local1,local2",local_peer_ip="localhost",remote_ip_range="remote1,remote2",remote_peer_ip="remotehost",state="DELETED"} 0
+strong_duckling_ike_sa_child_state_info{child_sa_name="net-1",ike_sa_name="gw-gw",local_ip_range="local1,local2",local_peer_ip="localhost",remote_ip_range="remote1,remote2",remote_peer_ip="remotehost",state="DELETING"} 0
+strong_duckling_ike_sa_child_state_info{child_sa_name="net-1",ike_sa_name="gw-gw",local_ip_range="local1,local2",local_peer_ip="localhost",remote_ip_range="remote1,remote2",remote_peer_ip="remotehost",state="DESTROYING"} 0
+strong_duckling_ike_sa_child_state_info{child_sa_name="net-1",ike_sa_name="gw-gw",local_ip_range="local1,local2",local_peer_ip="localhost",remote_ip_range="remote1,remote2",remote_peer_ip="remotehost",state="INSTALLED"} 0
+strong_duckling_ike_sa_child_state_info{child_sa_name="net-1",ike_sa_name="gw-gw",local_ip_range="local1,local2",local_peer_ip="localhost",remote_ip_range="remote1,remote2",remote_peer_ip="remotehost",state="INSTALLING"} 0
+strong_duckling_ike_sa_child_state_info{child_sa_name="net-1",ike_sa_name="gw-gw",local_ip_range="local1,local2",local_peer_ip="localhost",remote_ip_range="remote1,remote2",remote_peer_ip="remotehost",state="REKEYED"} 0
+strong_duckling_ike_sa_child_state_info{child_sa_name="net-1",ike_sa_name="gw-gw",local_ip_range="local1,local2",local_peer_ip="localhost",remote_ip_range="remote1,remote2",remote_peer_ip="remotehost",state="REKEYING"} 0
+strong_duckling_ike_sa_child_state_info{child_sa_name="net-1",ike_sa_name="gw-gw",local_ip_range="local1,local2",local_peer_ip="localhost",remote_ip_range="remote1,remote2",remote_peer_ip="remotehost",state="RETRYING"} 0
+strong_duckling_ike_sa_child_state_info{child_sa_name="net-1",ike_sa_name="gw-gw",local_ip_range="local1,local2",local_peer_ip="localhost",remote_ip_range="remote1,remote2",remote_peer_ip="remotehost",state="ROUTED"} 0
+strong_duckling_ike_sa_child_state_info{child_sa_name="net-1",ike_sa_name="gw-gw",local_ip_range="local1,local2",local_peer_ip="localhost",remote_ip_range="remote1,remote2",remote_peer_ip="remotehost",state="UPDATING"} 0
+# HELP strong_duckling_ike_sa_info Negotiated parameters of the SA
+# TYPE strong_duckling_ike_sa_info gauge
+strong_duckling_ike_sa_info{dh_group="",encr_alg="",encr_keysize="",ike_sa_name="gw-gw",ike_version="",initiator="",initiator_spi="",integ_alg="",integ_keysize="",local_peer_ip="localhost",nat_local="",nat_remote="",prf_alg="",remote_peer_ip="remotehost",responder_spi="",unique_id=""} 1
+# HELP strong_duckling_ike_sa_installs_total Total number of SA installs
 # TYPE strong_duckling_ike_sa_installs_total counter
 strong_duckling_ike_sa_installs_total{child_sa_name="net-1",ike_sa_name="gw-gw",local_ip_range="local1,local2",local_peer_ip="localhost",remote_ip_range="remote1,remote2",remote_peer_ip="remotehost"} 1
 # HELP strong_duckling_ike_sa_packets_in_total Total number of received packets
@@ -369,14 +397,23 @@ strong_duckling_ike_sa_packets_in_total{child_sa_name="net-1",ike_sa_name="gw-gw
 # HELP strong_duckling_ike_sa_packets_out_total Total number of transmitted packets
 # TYPE strong_duckling_ike_sa_packets_out_total gauge
 strong_duckling_ike_sa_packets_out_total{child_sa_name="net-1",ike_sa_name="gw-gw",local_ip_range="local1,local2",local_peer_ip="localhost",remote_ip_range="remote1,remote2",remote_peer_ip="remotehost"} 321
+# HELP strong_duckling_ike_sa_state_info Current state of the SA. The series of the current state is 1 otherwise 0
+# TYPE strong_duckling_ike_sa_state_info gauge
+strong_duckling_ike_sa_state_info{ike_sa_name="gw-gw",local_peer_ip="localhost",remote_peer_ip="remotehost",state="CONNECTING"} 0
+strong_duckling_ike_sa_state_info{ike_sa_name="gw-gw",local_peer_ip="localhost",remote_peer_ip="remotehost",state="CREATED"} 0
+strong_duckling_ike_sa_state_info{ike_sa_name="gw-gw",local_peer_ip="localhost",remote_peer_ip="remotehost",state="DELETING"} 0
+strong_duckling_ike_sa_state_info{ike_sa_name="gw-gw",local_peer_ip="localhost",remote_peer_ip="remotehost",state="DESTROYING"} 0
+strong_duckling_ike_sa_state_info{ike_sa_name="gw-gw",local_peer_ip="localhost",remote_peer_ip="remotehost",state="ESTABLISHED"} 0
+strong_duckling_ike_sa_state_info{ike_sa_name="gw-gw",local_peer_ip="localhost",remote_peer_ip="remotehost",state="PASSIVE"} 0
+strong_duckling_ike_sa_state_info{ike_sa_name="gw-gw",local_peer_ip="localhost",remote_peer_ip="remotehost",state="REKEYED"} 0
+strong_duckling_ike_sa_state_info{ike_sa_name="gw-gw",local_peer_ip="localhost",remote_peer_ip="remotehost",state="REKEYING"} 0
 `,
 		},
 	}
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
-			reg := prometheus.NewRegistry()
 			logger := test.NewLogger(t)
-			p, err := NewPrometheusReporter(reg, logger)
+			p, err := NewPrometheusReporter(logger, Configuration{})
 			if !assert.NoError(t, err, "unexpected initialization error") {
 				return
 			}
@@ -385,8 +422,827 @@ strong_duckling_ike_sa_packets_out_total{child_sa_name="net-1",ike_sa_name="gw-g
 				Configuration: tc.conf,
 				State:         tc.sa,
 			})
-			err = testutil.GatherAndCompare(reg, strings.NewReader(tc.output))
+			err = testutil.GatherAndCompare(p.registry, strings.NewReader(tc.output))
 			assert.NoError(t, err, "registered metrics not as expected")
 		})
 	}
 }
+
+func TestIKESAStatus_rekeyForecast(t *testing.T) {
+	type sample struct {
+		uniqueID, bytesIn, bytesOut, packetsIn, packetsOut string
+	}
+	tt := []struct {
+		name            string
+		conf            vici.ChildSAConf
+		samples         []sample
+		bytesConsumed   float64
+		packetsConsumed float64
+		bytesForecast   float64
+		packetsForecast float64
+		forecastSet     bool
+	}{
+		{
+			name: "single sample",
+			conf: vici.ChildSAConf{RekeyBytes: "1000", RekeyPackets: "100"},
+			samples: []sample{
+				{uniqueID: "1", bytesIn: "100", bytesOut: "200", packetsIn: "10", packetsOut: "5"},
+			},
+			bytesConsumed:   20,
+			packetsConsumed: 10,
+			forecastSet:     false,
+		},
+		{
+			name: "increasing traffic",
+			conf: vici.ChildSAConf{RekeyBytes: "1000", RekeyPackets: "100"},
+			samples: []sample{
+				{uniqueID: "1", bytesIn: "100", bytesOut: "200", packetsIn: "10", packetsOut: "5"},
+				{uniqueID: "1", bytesIn: "100", bytesOut: "400", packetsIn: "20", packetsOut: "5"},
+			},
+			bytesConsumed:   40,
+			packetsConsumed: 20,
+			// 200 bytes and 10 packets per 10 seconds
+			bytesForecast:   30,
+			packetsForecast: 80,
+			forecastSet:     true,
+		},
+		{
+			name: "rekeyed child sa",
+			conf: vici.ChildSAConf{RekeyBytes: "1000", RekeyPackets: "100"},
+			samples: []sample{
+				{uniqueID: "1", bytesIn: "100", bytesOut: "900", packetsIn: "10", packetsOut: "90"},
+				{uniqueID: "2", bytesIn: "100", bytesOut: "100", packetsIn: "10", packetsOut: "10"},
+			},
+			bytesConsumed:   10,
+			packetsConsumed: 10,
+			forecastSet:     false,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			logger := test.NewLogger(t)
+			p, err := NewPrometheusReporter(logger, Configuration{})
+			if !assert.NoError(t, err, "unexpected initialization error") {
+				return
+			}
+			now := time.Unix(0, 0)
+			p.ikeSA.now = func() time.Time {
+				return now
+			}
+
+			for _, s := range tc.samples {
+				p.StrongSwan().IKESAStatus(strongswan.IKESAStatus{
+					Name: "gw-gw",
+					Configuration: vici.IKEConf{
+						Children: map[string]vici.ChildSAConf{
+							"net-0": tc.conf,
+						},
+					},
+					State: &vici.IkeSa{
+						ChildSAs: map[string]vici.ChildSA{
+							"net-0": {
+								Name:       "net-0",
+								UniqueID:   s.uniqueID,
+								BytesIn:    s.bytesIn,
+								BytesOut:   s.bytesOut,
+								PacketsIn:  s.packetsIn,
+								PacketsOut: s.packetsOut,
+							},
+						},
+					},
+				})
+				now = now.Add(10 * time.Second)
+			}
+
+			assert.Equal(t, tc.bytesConsumed, testutil.ToFloat64(p.ikeSA.rekeyBytesConsumedPercent), "bytes consumed not as expected")
+			assert.Equal(t, tc.packetsConsumed, testutil.ToFloat64(p.ikeSA.rekeyPacketsConsumedPercent), "packets consumed not as expected")
+			if !tc.forecastSet {
+				assert.Equal(t, 0, testutil.CollectAndCount(p.ikeSA.rekeyBytesForecastSeconds), "bytes forecast should not be set")
+				assert.Equal(t, 0, testutil.CollectAndCount(p.ikeSA.rekeyPacketsForecastSeconds), "packets forecast should not be set")
+				return
+			}
+			assert.Equal(t, tc.bytesForecast, testutil.ToFloat64(p.ikeSA.rekeyBytesForecastSeconds), "bytes forecast not as expected")
+			assert.Equal(t, tc.packetsForecast, testutil.ToFloat64(p.ikeSA.rekeyPacketsForecastSeconds), "packets forecast not as expected")
+		})
+	}
+}
+
+func TestIKESAStatus_gone(t *testing.T) {
+	logger := test.NewLogger(t)
+	p, err := NewPrometheusReporter(logger, Configuration{})
+	if !assert.NoError(t, err, "unexpected initialization error") {
+		return
+	}
+	now := time.Now()
+	p.ikeSA.now = func() time.Time {
+		return now
+	}
+	consumed := 100
+	status := func(rekeyTime string, childSAs ...string) strongswan.IKESAStatus {
+		status := strongswan.IKESAStatus{
+			Name: "gw-gw",
+			Configuration: vici.IKEConf{
+				Children: map[string]vici.ChildSAConf{
+					"net-0": {RekeyBytes: "1000", RekeyPackets: "100"},
+					"net-1": {RekeyBytes: "1000", RekeyPackets: "100"},
+				},
+			},
+			State: &vici.IkeSa{
+				State:    vici.IKESAStateEstablished,
+				ChildSAs: map[string]vici.ChildSA{},
+			},
+		}
+		for n, name := range childSAs {
+			status.State.ChildSAs[name+"-1"] = vici.ChildSA{Name: name, UniqueID: strconv.Itoa(n + 1), State: vici.ChildSAStateInstalled, RekeyTimeSeconds: rekeyTime, BytesIn: strconv.Itoa(consumed), PacketsIn: strconv.Itoa(consumed / 10)}
+		}
+		return status
+	}
+	rekeyGauges := p.ikeSA.rekeyGauges()
+	countRekeySeries := func() int {
+		count := 0
+		for _, g := range rekeyGauges {
+			count += testutil.CollectAndCount(g)
+		}
+		return count
+	}
+
+	p.StrongSwan().IKESAStatus(status("100", "net-0", "net-1"))
+	p.ikeSA.CollectionDone()
+	now = now.Add(time.Minute)
+	consumed += 100
+	p.StrongSwan().IKESAStatus(status("100", "net-0", "net-1"))
+	p.ikeSA.CollectionDone()
+	assert.Equal(t, 10, countRekeySeries(), "rekey series not as expected")
+
+	// net-1 is gone
+	now = now.Add(time.Minute)
+	consumed += 100
+	p.StrongSwan().IKESAStatus(status("100", "net-0"))
+	p.ikeSA.CollectionDone()
+	assert.Equal(t, 5, countRekeySeries(), "rekey series of gone child SA not removed")
+	assert.Equal(t, 100.0, testutil.ToFloat64(p.ikeSA.rekeyRemainingSeconds), "rekey remaining not as expected")
+
+	// rekey time is no longer available, eg. while the child SA is rekeyed
+	p.StrongSwan().IKESAStatus(status("", "net-0"))
+	p.ikeSA.CollectionDone()
+	assert.Equal(t, 0, testutil.CollectAndCount(p.ikeSA.rekeyRemainingSeconds), "stale rekey remaining not removed")
+	assert.Len(t, p.ikeSA.rekeyForecasters, 1, "rekey forecaster not kept")
+
+	// the IKE SA goes down
+	p.StrongSwan().IKESAStatus(strongswan.IKESAStatus{Name: "gw-gw"})
+	p.ikeSA.CollectionDone()
+	assert.Equal(t, 0, countRekeySeries(), "rekey series of down IKE SA not removed")
+
+	// the connection is unloaded and its IKE SA torn down
+	p.StrongSwan().IKESAStatus(status("100", "net-0"))
+	p.ikeSA.CollectionDone()
+	p.ikeSA.CollectionDone()
+	assert.Empty(t, p.ikeSA.rekeyForecasters, "rekey forecaster of gone IKE SA not removed")
+	assert.Empty(t, p.ikeSA.rekeySeries, "rekey series of gone IKE SA not forgotten")
+	assert.Equal(t, 0, countRekeySeries(), "rekey series of gone IKE SA not removed")
+	assert.Equal(t, 0, testutil.CollectAndCount(p.ikeSA.info.gauge), "info of gone IKE SA not removed")
+	assert.Equal(t, 0, testutil.CollectAndCount(p.ikeSA.state.gauge), "states of gone IKE SA not removed")
+}
+
+func TestIKESAStatus_info(t *testing.T) {
+	logger := test.NewLogger(t)
+	p, err := NewPrometheusReporter(logger, Configuration{})
+	if !assert.NoError(t, err, "unexpected initialization error") {
+		return
+	}
+
+	// a rekey replaces the child SA with a new one with another key and SPIs
+	for _, child := range []struct{ key, spiIn, spiOut string }{
+		{key: "net-0-1", spiIn: "c1", spiOut: "c2"},
+		{key: "net-0-2", spiIn: "c3", spiOut: "c4"},
+	} {
+		p.StrongSwan().IKESAStatus(strongswan.IKESAStatus{
+			Name: "gw-gw",
+			State: &vici.IkeSa{
+				State:               vici.IKESAStateEstablished,
+				IKEVersion:          "2",
+				EncryptionAlgorithm: "AES_GCM_16",
+				ChildSAs: map[string]vici.ChildSA{
+					child.key: {
+						Name:          "net-0",
+						State:         vici.ChildSAStateInstalled,
+						IPsecMode:     "TUNNEL",
+						IPsecProtocol: "ESP",
+						SPIIn:         child.spiIn,
+						SPIOut:        child.spiOut,
+					},
+				},
+			},
+		})
+	}
+
+	err = testutil.GatherAndCompare(p.registry, strings.NewReader(`# HELP strong_duckling_ike_sa_info Negotiated parameters of the SA
+# TYPE strong_duckling_ike_sa_info gauge
+strong_duckling_ike_sa_info{dh_group="",encr_alg="AES_GCM_16",encr_keysize="",ike_sa_name="gw-gw",ike_version="2",initiator="",initiator_spi="",integ_alg="",integ_keysize="",local_peer_ip="",nat_local="",nat_remote="",prf_alg="",remote_peer_ip="",responder_spi="",unique_id=""} 1
+# HELP strong_duckling_ike_sa_child_info Negotiated parameters of the child SA
+# TYPE strong_duckling_ike_sa_child_info gauge
+strong_duckling_ike_sa_child_info{child_sa_name="net-0",dh_group="",encap="",encr_alg="",encr_keysize="",esn="",ike_sa_name="gw-gw",integ_alg="",integ_keysize="",local_ip_range="",local_peer_ip="",mode="TUNNEL",protocol="ESP",remote_ip_range="",remote_peer_ip="",reqid="",spi_in="c3",spi_out="c4",unique_id=""} 1
+`), "strong_duckling_ike_sa_info", "strong_duckling_ike_sa_child_info")
+	assert.NoError(t, err, "info metrics not as expected")
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(p.ikeSA.state.gauge.WithLabelValues("gw-gw", "", "", vici.IKESAStateEstablished)), "established state not set")
+	assert.Equal(t, 0.0, testutil.ToFloat64(p.ikeSA.state.gauge.WithLabelValues("gw-gw", "", "", vici.IKESAStateConnecting)), "connecting state set")
+	assert.Equal(t, 1.0, testutil.ToFloat64(p.ikeSA.childSAState.gauge.WithLabelValues("gw-gw", "", "", "", "", "net-0", vici.ChildSAStateInstalled)), "installed state not set")
+}
+
+func TestIKESAStatus_statesRemoved(t *testing.T) {
+	logger := test.NewLogger(t)
+	p, err := NewPrometheusReporter(logger, Configuration{})
+	if !assert.NoError(t, err, "unexpected initialization error") {
+		return
+	}
+	status := func(state string, childSAs map[string]vici.ChildSA) strongswan.IKESAStatus {
+		return strongswan.IKESAStatus{
+			Name: "gw-gw",
+			State: &vici.IkeSa{
+				State:    state,
+				ChildSAs: childSAs,
+			},
+		}
+	}
+	installed := vici.ChildSA{Name: "net-0", State: vici.ChildSAStateInstalled}
+
+	p.StrongSwan().IKESAStatus(status("UNKNOWN_STATE", map[string]vici.ChildSA{"net-0-1": installed, "net-1-2": {Name: "net-1", State: vici.ChildSAStateInstalled}}))
+	p.StrongSwan().IKESAStatus(status(vici.IKESAStateEstablished, map[string]vici.ChildSA{"net-0-1": installed}))
+	assert.Equal(t, len(vici.IKESAStates), testutil.CollectAndCount(p.ikeSA.state.gauge), "unknown state not removed")
+	assert.Equal(t, len(vici.ChildSAStates), testutil.CollectAndCount(p.ikeSA.childSAState.gauge), "states of removed child SA not removed")
+
+	// the IKE SA is torn down
+	p.StrongSwan().IKESAStatus(strongswan.IKESAStatus{Name: "gw-gw"})
+	assert.Equal(t, 0, testutil.CollectAndCount(p.ikeSA.state.gauge), "states of torn down IKE SA not removed")
+	assert.Equal(t, 0, testutil.CollectAndCount(p.ikeSA.childSAState.gauge), "states of torn down child SA not removed")
+}
+
+func TestRemoteAccess(t *testing.T) {
+	logger := test.NewLogger(t)
+	p, err := NewPrometheusReporter(logger, Configuration{
+		RemoteAccessMaxIdentities: 2,
+	})
+	if !assert.NoError(t, err, "unexpected initialization error") {
+		return
+	}
+	receiver := p.RemoteAccess()
+	conf := vici.IKEConf{
+		RemoteAuthSection: map[string]vici.AuthConf{
+			"remote-1": {Groups: []string{"employees"}},
+		},
+	}
+
+	receiver.IKESAStatus(strongswan.IKESAStatus{
+		Name:          "rw",
+		Configuration: conf,
+		Sessions: []vici.IkeSa{
+			{
+				UniqueID:           "1",
+				RemoteEAPID:        "alice",
+				EstablishedSeconds: "100",
+				RemoteVIPs:         []string{"10.0.0.1"},
+				ChildSAs: map[string]vici.ChildSA{
+					"net-1": {BytesIn: "10", BytesOut: "20"},
+				},
+			},
+			{UniqueID: "2", RemoteEAPID: "alice", EstablishedSeconds: "50"},
+			{UniqueID: "3", RemoteXAuthID: "bob", EstablishedSeconds: "10"},
+			{UniqueID: "4", RemoteID: "carol", EstablishedSeconds: "10"},
+		},
+	})
+	// alice disconnects one session and transfers more data on the other
+	receiver.IKESAStatus(strongswan.IKESAStatus{
+		Name:          "rw",
+		Configuration: conf,
+		Sessions: []vici.IkeSa{
+			{
+				UniqueID:           "1",
+				RemoteEAPID:        "alice",
+				EstablishedSeconds: "102",
+				RemoteVIPs:         []string{"10.0.0.1"},
+				ChildSAs: map[string]vici.ChildSA{
+					"net-1": {BytesIn: "15", BytesOut: "30"},
+				},
+			},
+			{UniqueID: "3", RemoteXAuthID: "bob", EstablishedSeconds: "12"},
+			{UniqueID: "4", RemoteID: "carol", EstablishedSeconds: "12"},
+		},
+	})
+
+	err = testutil.GatherAndCompare(p.registry, strings.NewReader(`# HELP strong_duckling_remote_access_sessions Number of active sessions per identity
+# TYPE strong_duckling_remote_access_sessions gauge
+strong_duckling_remote_access_sessions{group="employees",identity="alice",ike_sa_name="rw"} 1
+strong_duckling_remote_access_sessions{group="employees",identity="bob",ike_sa_name="rw"} 1
+strong_duckling_remote_access_sessions{group="employees",identity="other",ike_sa_name="rw"} 1
+# HELP strong_duckling_remote_access_virtual_ip_info Virtual IPs assigned to active sessions
+# TYPE strong_duckling_remote_access_virtual_ip_info gauge
+strong_duckling_remote_access_virtual_ip_info{identity="alice",ike_sa_name="rw",virtual_ip="10.0.0.1"} 1
+# HELP strong_duckling_remote_access_bytes_in_total Total number of bytes received from an identity
+# TYPE strong_duckling_remote_access_bytes_in_total counter
+strong_duckling_remote_access_bytes_in_total{identity="alice",ike_sa_name="rw"} 15
+# HELP strong_duckling_remote_access_bytes_out_total Total number of bytes transmitted to an identity
+# TYPE strong_duckling_remote_access_bytes_out_total counter
+strong_duckling_remote_access_bytes_out_total{identity="alice",ike_sa_name="rw"} 30
+`), "strong_duckling_remote_access_sessions", "strong_duckling_remote_access_virtual_ip_info", "strong_duckling_remote_access_bytes_in_total", "strong_duckling_remote_access_bytes_out_total")
+	assert.NoError(t, err, "remote access metrics not as expected")
+
+	assert.Equal(t, 1, testutil.CollectAndCount(p.remoteAccess.sessionDuration), "session duration not observed")
+}
+
+func TestNewPrometheusReporter_isolated(t *testing.T) {
+	logger := test.NewLogger(t)
+	reporterA, err := NewPrometheusReporter(logger, Configuration{
+		ConstLabels: prometheus.Labels{"gateway": "a"},
+	})
+	if !assert.NoError(t, err, "unexpected initialization error") {
+		return
+	}
+	reporterB, err := NewPrometheusReporter(logger, Configuration{
+		ConstLabels:      prometheus.Labels{"gateway": "b"},
+		ProcessCollector: true,
+		GoCollector:      true,
+	})
+	if !assert.NoError(t, err, "unexpected initialization error") {
+		return
+	}
+
+	reporterA.Info("1.0.0")
+	reporterB.Info("2.0.0")
+
+	bodyA := scrape(t, reporterA.Handler())
+	bodyB := scrape(t, reporterB.Handler())
+
+	assert.Contains(t, bodyA, `strong_duckling_info{gateway="a",version="1.0.0"} 1`, "reporter a info metric not as expected")
+	assert.NotContains(t, bodyA, `gateway="b"`, "reporter a exposes metrics of reporter b")
+	assert.NotContains(t, bodyA, "go_goroutines", "reporter a exposes go metrics")
+	assert.Contains(t, bodyB, `strong_duckling_info{gateway="b",version="2.0.0"} 1`, "reporter b info metric not as expected")
+	assert.NotContains(t, bodyB, `gateway="a"`, "reporter b exposes metrics of reporter a")
+	assert.Contains(t, bodyB, "go_goroutines", "reporter b does not expose go metrics")
+}
+
+func scrape(t *testing.T, handler http.Handler) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code, "status code not as expected")
+	return rec.Body.String()
+}
+
+func TestTcpChecker_perTarget(t *testing.T) {
+	logger := test.NewLogger(t)
+	p, err := NewPrometheusReporter(logger, Configuration{
+		TcpCheckerFlapWindow: time.Minute,
+	})
+	if !assert.NoError(t, err, "unexpected initialization error") {
+		return
+	}
+	now := time.Unix(1000, 0)
+	p.tcpChecker.now = func() time.Time {
+		return now
+	}
+	report := func(name string, open bool) {
+		p.TcpChecker().ReportPortCheck(tcpchecker.Report{
+			Name:    name,
+			Address: "1.2.3.4",
+			Port:    22,
+			Open:    open,
+		})
+		now = now.Add(10 * time.Second)
+	}
+
+	// interleaved reports of two targets must not be compared with each other
+	report("a", true)
+	report("b", false)
+	report("a", true)
+	report("b", false)
+	report("a", false)
+	report("a", true)
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(p.tcpChecker.connectedTotal.WithLabelValues("a", "1.2.3.4", "22")), "connected of a not as expected")
+	assert.Equal(t, 1.0, testutil.ToFloat64(p.tcpChecker.disconnectedTotal.WithLabelValues("a", "1.2.3.4", "22")), "disconnected of a not as expected")
+	assert.Equal(t, 0.0, testutil.ToFloat64(p.tcpChecker.connectedTotal.WithLabelValues("b", "1.2.3.4", "22")), "connected of b not as expected")
+	assert.Equal(t, 1.0, testutil.ToFloat64(p.tcpChecker.disconnectedTotal.WithLabelValues("b", "1.2.3.4", "22")), "disconnected of b not as expected")
+	assert.Equal(t, 2.0, testutil.ToFloat64(p.tcpChecker.flapRate.WithLabelValues("a", "1.2.3.4", "22")), "flap rate of a not as expected")
+	assert.Equal(t, 0.0, testutil.ToFloat64(p.tcpChecker.flapRate.WithLabelValues("b", "1.2.3.4", "22")), "flap rate of b not as expected")
+	assert.Equal(t, 1050.0, testutil.ToFloat64(p.tcpChecker.lastStateChange.WithLabelValues("a", "1.2.3.4", "22")), "last state change of a not as expected")
+	assert.Equal(t, 1010.0, testutil.ToFloat64(p.tcpChecker.lastStateChange.WithLabelValues("b", "1.2.3.4", "22")), "last state change of b not as expected")
+
+	// state changes leave the flap window
+	now = now.Add(time.Minute)
+	report("a", true)
+	assert.Equal(t, 0.0, testutil.ToFloat64(p.tcpChecker.flapRate.WithLabelValues("a", "1.2.3.4", "22")), "flap rate of a after window not as expected")
+	assert.Equal(t, 1050.0, testutil.ToFloat64(p.tcpChecker.lastStateChange.WithLabelValues("a", "1.2.3.4", "22")), "last state change of a after window not as expected")
+}
+
+func TestTcpChecker_failures(t *testing.T) {
+	logger := test.NewLogger(t)
+	p, err := NewPrometheusReporter(logger, Configuration{})
+	if !assert.NoError(t, err, "unexpected initialization error") {
+		return
+	}
+	report := tcpchecker.Report{
+		Name:              "partner1",
+		Address:           "1.2.3.4",
+		Port:              22,
+		Open:              true,
+		ConnectDuration:   5 * time.Millisecond,
+		FirstByteDuration: 20 * time.Millisecond,
+	}
+	p.TcpChecker().ReportPortCheck(report)
+	report.Open = false
+	report.Failure = tcpchecker.FailureTimeout
+	report.ConnectDuration = time.Second
+	report.FirstByteDuration = 0
+	p.TcpChecker().ReportPortCheck(report)
+	report.Failure = tcpchecker.FailureRefused
+	report.ConnectDuration = time.Millisecond
+	p.TcpChecker().ReportPortCheck(report)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(p.tcpChecker.failuresTotal.WithLabelValues("partner1", "1.2.3.4", "22", "timeout")), "timeout failures not as expected")
+	assert.Equal(t, 1.0, testutil.ToFloat64(p.tcpChecker.failuresTotal.WithLabelValues("partner1", "1.2.3.4", "22", "refused")), "refused failures not as expected")
+	err = testutil.GatherAndCompare(p.registry, strings.NewReader(`# HELP strong_duckling_tcp_checker_first_byte_duration_seconds Duration from a connection is established until the first byte is received
+# TYPE strong_duckling_tcp_checker_first_byte_duration_seconds histogram
+strong_duckling_tcp_checker_first_byte_duration_seconds_bucket{address="1.2.3.4",name="partner1",port="22",le="0.001"} 0
+strong_duckling_tcp_checker_first_byte_duration_seconds_bucket{address="1.2.3.4",name="partner1",port="22",le="0.002"} 0
+strong_duckling_tcp_checker_first_byte_duration_seconds_bucket{address="1.2.3.4",name="partner1",port="22",le="0.004"} 0
+strong_duckling_tcp_checker_first_byte_duration_seconds_bucket{address="1.2.3.4",name="partner1",port="22",le="0.008"} 0
+strong_duckling_tcp_checker_first_byte_duration_seconds_bucket{address="1.2.3.4",name="partner1",port="22",le="0.016"} 0
+strong_duckling_tcp_checker_first_byte_duration_seconds_bucket{address="1.2.3.4",name="partner1",port="22",le="0.032"} 1
+strong_duckling_tcp_checker_first_byte_duration_seconds_bucket{address="1.2.3.4",name="partner1",port="22",le="0.064"} 1
+strong_duckling_tcp_checker_first_byte_duration_seconds_bucket{address="1.2.3.4",name="partner1",port="22",le="0.128"} 1
+strong_duckling_tcp_checker_first_byte_duration_seconds_bucket{address="1.2.3.4",name="partner1",port="22",le="0.256"} 1
+strong_duckling_tcp_checker_first_byte_duration_seconds_bucket{address="1.2.3.4",name="partner1",port="22",le="0.512"} 1
+strong_duckling_tcp_checker_first_byte_duration_seconds_bucket{address="1.2.3.4",name="partner1",port="22",le="1.024"} 1
+strong_duckling_tcp_checker_first_byte_duration_seconds_bucket{address="1.2.3.4",name="partner1",port="22",le="+Inf"} 1
+strong_duckling_tcp_checker_first_byte_duration_seconds_sum{address="1.2.3.4",name="partner1",port="22"} 0.02
+strong_duckling_tcp_checker_first_byte_duration_seconds_count{address="1.2.3.4",name="partner1",port="22"} 1
+`), "strong_duckling_tcp_checker_first_byte_duration_seconds")
+	assert.NoError(t, err, "first byte duration not as expected")
+	assert.Equal(t, 1, testutil.CollectAndCount(p.tcpChecker.connectDuration), "connect duration series not as expected")
+}
+
+func TestTcpChecker_unexpectedResponse(t *testing.T) {
+	logger := test.NewLogger(t)
+	p, err := NewPrometheusReporter(logger, Configuration{})
+	if !assert.NoError(t, err, "unexpected initialization error") {
+		return
+	}
+	report := tcpchecker.Report{
+		Name:    "ssh",
+		Address: "1.2.3.4",
+		Port:    22,
+		Open:    true,
+		Failure: tcpchecker.FailureUnexpectedResponse,
+	}
+	p.TcpChecker().ReportPortCheck(report)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(p.tcpChecker.open.WithLabelValues("ssh", "1.2.3.4", "22")), "open not as expected")
+	assert.Equal(t, 1.0, testutil.ToFloat64(p.tcpChecker.unexpectedResponse.WithLabelValues("ssh", "1.2.3.4", "22")), "unexpected response not as expected")
+	assert.Equal(t, 1.0, testutil.ToFloat64(p.tcpChecker.failuresTotal.WithLabelValues("ssh", "1.2.3.4", "22", "unexpected_response")), "failures not as expected")
+
+	report.Failure = ""
+	p.TcpChecker().ReportPortCheck(report)
+	assert.Equal(t, 0.0, testutil.ToFloat64(p.tcpChecker.unexpectedResponse.WithLabelValues("ssh", "1.2.3.4", "22")), "unexpected response not reset")
+}
+
+func TestHttpChecker(t *testing.T) {
+	logger := test.NewLogger(t)
+	p, err := NewPrometheusReporter(logger, Configuration{})
+	if !assert.NoError(t, err, "unexpected initialization error") {
+		return
+	}
+	report := httpchecker.Report{
+		Name:                 "api",
+		Method:               "GET",
+		URL:                  "https://10.0.0.1/health",
+		Healthy:              true,
+		StatusCode:           200,
+		ResponseDuration:     20 * time.Millisecond,
+		TLSHandshakeDuration: 10 * time.Millisecond,
+		CertificateExpiry:    time.Unix(1700000000, 0),
+	}
+	p.HttpChecker().ReportHTTPCheck(report)
+	report.Healthy = false
+	report.StatusCode = 503
+	report.Failure = httpchecker.FailureStatusCode
+	p.HttpChecker().ReportHTTPCheck(report)
+
+	labelValues := []string{"api", "GET", "https://10.0.0.1/health"}
+	assert.Equal(t, 0.0, testutil.ToFloat64(p.httpChecker.healthy.WithLabelValues(labelValues...)), "healthy not as expected")
+	assert.Equal(t, 503.0, testutil.ToFloat64(p.httpChecker.statusCode.WithLabelValues(labelValues...)), "status code not as expected")
+	assert.Equal(t, 1.0, testutil.ToFloat64(p.httpChecker.failuresTotal.WithLabelValues(append(labelValues, "status_code")...)), "failures not as expected")
+	assert.Equal(t, 1700000000.0, testutil.ToFloat64(p.httpChecker.certificateExpiry.WithLabelValues(labelValues...)), "certificate expiry not as expected")
+	assert.Equal(t, 1, testutil.CollectAndCount(p.httpChecker.tlsHandshakeDuration), "tls handshake duration series not as expected")
+	assert.Equal(t, 2, testutil.CollectAndCount(p.httpChecker.checks), "checked series not as expected")
+}
+
+func TestUdpChecker(t *testing.T) {
+	logger := test.NewLogger(t)
+	p, err := NewPrometheusReporter(logger, Configuration{})
+	if !assert.NoError(t, err, "unexpected initialization error") {
+		return
+	}
+	report := udpchecker.Report{
+		Name:          "dns",
+		Address:       "10.0.0.53",
+		Port:          53,
+		Reachable:     true,
+		RoundTripTime: 5 * time.Millisecond,
+	}
+	p.UdpChecker().ReportUDPCheck(report)
+	report.Reachable = false
+	report.RoundTripTime = 0
+	report.Failure = udpchecker.FailureTimeout
+	p.UdpChecker().ReportUDPCheck(report)
+
+	labelValues := []string{"dns", "10.0.0.53", "53"}
+	assert.Equal(t, 0.0, testutil.ToFloat64(p.udpChecker.reachable.WithLabelValues(labelValues...)), "reachable not as expected")
+	assert.Equal(t, 1.0, testutil.ToFloat64(p.udpChecker.failuresTotal.WithLabelValues(append(labelValues, "timeout")...)), "failures not as expected")
+	assert.Equal(t, 1, testutil.CollectAndCount(p.udpChecker.roundTripTime), "round trip time series not as expected")
+}
+
+func TestIcmpChecker(t *testing.T) {
+	logger := test.NewLogger(t)
+	p, err := NewPrometheusReporter(logger, Configuration{})
+	if !assert.NoError(t, err, "unexpected initialization error") {
+		return
+	}
+	p.IcmpChecker().ReportICMPCheck(icmpchecker.Report{
+		Name:          "gw",
+		Address:       "10.0.0.1",
+		Reachable:     true,
+		RoundTripTime: 10 * time.Millisecond,
+		Window: icmpchecker.WindowStatistics{
+			Sent:                 1,
+			Received:             1,
+			AverageRoundTripTime: 10 * time.Millisecond,
+		},
+	})
+	p.IcmpChecker().ReportICMPCheck(icmpchecker.Report{
+		Name:    "gw",
+		Address: "10.0.0.1",
+		Failure: icmpchecker.FailureTimeout,
+		Window: icmpchecker.WindowStatistics{
+			Sent:                 2,
+			Received:             1,
+			LossPercent:          50,
+			AverageRoundTripTime: 10 * time.Millisecond,
+		},
+	})
+
+	labelValues := []string{"gw", "10.0.0.1"}
+	assert.Equal(t, 2.0, testutil.ToFloat64(p.icmpChecker.sentTotal.WithLabelValues(labelValues...)), "sent not as expected")
+	assert.Equal(t, 1.0, testutil.ToFloat64(p.icmpChecker.receivedTotal.WithLabelValues(labelValues...)), "received not as expected")
+	assert.Equal(t, 0.0, testutil.ToFloat64(p.icmpChecker.reachable.WithLabelValues(labelValues...)), "reachable not as expected")
+	assert.Equal(t, 1.0, testutil.ToFloat64(p.icmpChecker.failuresTotal.WithLabelValues(append(labelValues, "timeout")...)), "failures not as expected")
+	assert.Equal(t, 50.0, testutil.ToFloat64(p.icmpChecker.lossPercent.WithLabelValues(labelValues...)), "loss not as expected")
+	assert.Equal(t, 0.01, testutil.ToFloat64(p.icmpChecker.averageRoundTripTime.WithLabelValues(labelValues...)), "average round trip time not as expected")
+}
+
+func TestTunnelChecker(t *testing.T) {
+	logger := test.NewLogger(t)
+	p, err := NewPrometheusReporter(logger, Configuration{})
+	if !assert.NoError(t, err, "unexpected initialization error") {
+		return
+	}
+	report := tcpchecker.Report{
+		Name:    "ssh",
+		Address: "10.2.0.1",
+		Port:    22,
+		Open:    false,
+		Failure: tcpchecker.FailureTimeout,
+	}
+	p.TunnelChecker("partner1", "net-1").ReportPortCheck(report)
+
+	labelValues := []string{"partner1", "net-1", "ssh", "10.2.0.1", "22"}
+	assert.Equal(t, 0.0, testutil.ToFloat64(p.tunnelChecker.open.WithLabelValues(labelValues...)), "open not as expected")
+	assert.Equal(t, 1.0, testutil.ToFloat64(p.tunnelChecker.failuresTotal.WithLabelValues(append(labelValues, "timeout")...)), "failures not as expected")
+
+	p.DeleteTunnelChecker(tunnelchecker.Target{
+		Target: tcpchecker.Target{
+			Name:        "ssh",
+			Address:     "10.2.0.1",
+			Port:        22,
+			IKESAName:   "partner1",
+			ChildSAName: "net-1",
+		},
+	})
+	for _, c := range p.tunnelChecker.getCollectors() {
+		assert.Equal(t, 0, testutil.CollectAndCount(c), "series not deleted")
+	}
+}
+
+func TestHealer(t *testing.T) {
+	logger := test.NewLogger(t)
+	p, err := NewPrometheusReporter(logger, Configuration{})
+	if !assert.NoError(t, err, "unexpected initialization error") {
+		return
+	}
+	report := strongswan.HealReport{
+		IKESAName:   "partner1",
+		ChildSAName: "net-1",
+		Action:      strongswan.HealActionRekey,
+		Failures:    3,
+	}
+	p.Healer().ReportHeal(report)
+	report.Error = errors.New("rekey unsuccessful")
+	p.Healer().ReportHeal(report)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(p.healer.actionsTotal.WithLabelValues("partner1", "net-1", "rekey", "success")), "successful actions not as expected")
+	assert.Equal(t, 1.0, testutil.ToFloat64(p.healer.actionsTotal.WithLabelValues("partner1", "net-1", "rekey", "failure")), "failed actions not as expected")
+}
+
+func TestDuplicates(t *testing.T) {
+	logger := test.NewLogger(t)
+	p, err := NewPrometheusReporter(logger, Configuration{})
+	if !assert.NoError(t, err, "unexpected initialization error") {
+		return
+	}
+	p.Duplicates().ReportStale("partner1", "net-1", 2)
+	termination := strongswan.DuplicateTermination{
+		IKESAName:   "partner1",
+		ChildSAName: "net-1",
+		UniqueID:    "7",
+	}
+	p.Duplicates().ReportTermination(termination)
+	termination.Error = errors.New("terminate unsuccessful")
+	p.Duplicates().ReportTermination(termination)
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(p.duplicates.staleChildSAs.WithLabelValues("partner1", "net-1")), "stale child SAs not as expected")
+	assert.Equal(t, 1.0, testutil.ToFloat64(p.duplicates.terminationsTotal.WithLabelValues("partner1", "net-1", "success")), "successful terminations not as expected")
+	assert.Equal(t, 1.0, testutil.ToFloat64(p.duplicates.terminationsTotal.WithLabelValues("partner1", "net-1", "failure")), "failed terminations not as expected")
+}
+
+func TestWatchdog(t *testing.T) {
+	logger := test.NewLogger(t)
+	p, err := NewPrometheusReporter(logger, Configuration{})
+	if !assert.NoError(t, err, "unexpected initialization error") {
+		return
+	}
+	stuck := strongswan.StuckIKESA{
+		IKESAName: "partner1",
+		UniqueID:  "3",
+		State:     vici.IKESAStateConnecting,
+		Duration:  6 * time.Minute,
+	}
+	p.Watchdog().ReportStuck(stuck)
+	stuck.Error = errors.New("terminate unsuccessful")
+	p.Watchdog().ReportStuck(stuck)
+	stuck.Error = nil
+	stuck.DryRun = true
+	p.Watchdog().ReportStuck(stuck)
+
+	for _, result := range []string{"success", "failure", "dry_run"} {
+		assert.Equal(t, 1.0, testutil.ToFloat64(p.watchdog.stuckTotal.WithLabelValues("partner1", vici.IKESAStateConnecting, result)), "%s stuck IKE SAs not as expected", result)
+	}
+}
+
+func TestOrphans(t *testing.T) {
+	logger := test.NewLogger(t)
+	p, err := NewPrometheusReporter(logger, Configuration{})
+	if !assert.NoError(t, err, "unexpected initialization error") {
+		return
+	}
+	receiver := p.OrphanedIKESAs()
+	collectionDone := receiver.(strongswan.CollectionDoneReceiver)
+
+	receiver.IKESAStatus(strongswan.IKESAStatus{
+		Name:     "partner1",
+		Sessions: []vici.IkeSa{{UniqueID: "3"}, {UniqueID: "4"}},
+		Orphaned: true,
+	})
+	receiver.IKESAStatus(strongswan.IKESAStatus{
+		Name:     "partner2",
+		Sessions: []vici.IkeSa{{UniqueID: "5"}},
+	})
+	collectionDone.CollectionDone()
+	assert.Equal(t, 1, testutil.CollectAndCount(p.orphans.ikeSAs), "series not as expected")
+	assert.Equal(t, 2.0, testutil.ToFloat64(p.orphans.ikeSAs.WithLabelValues("partner1")), "orphaned IKE SAs not as expected")
+
+	// the orphaned IKE SAs are gone
+	collectionDone.CollectionDone()
+	assert.Equal(t, 0, testutil.CollectAndCount(p.orphans.ikeSAs), "series not deleted")
+
+	termination := strongswan.OrphanTermination{
+		IKESAName: "partner1",
+		UniqueID:  "3",
+	}
+	p.Orphans().ReportOrphanTermination(termination)
+	termination.Error = errors.New("terminate unsuccessful")
+	p.Orphans().ReportOrphanTermination(termination)
+	assert.Equal(t, 1.0, testutil.ToFloat64(p.orphans.terminationsTotal.WithLabelValues("partner1", "success")), "successful terminations not as expected")
+	assert.Equal(t, 1.0, testutil.ToFloat64(p.orphans.terminationsTotal.WithLabelValues("partner1", "failure")), "failed terminations not as expected")
+}
+
+func TestIdle(t *testing.T) {
+	logger := test.NewLogger(t)
+	p, err := NewPrometheusReporter(logger, Configuration{})
+	if !assert.NoError(t, err, "unexpected initialization error") {
+		return
+	}
+	status := strongswan.IdleStatus{
+		IKESAName:   "partner1",
+		ChildSAName: "net-1",
+		Direction:   strongswan.IdleDirectionIn,
+		Idle:        true,
+		Changed:     true,
+		Silence:     2 * time.Minute,
+		Threshold:   time.Minute,
+	}
+	p.Idle().IdleStatus(status)
+	status.Changed = false
+	p.Idle().IdleStatus(status)
+	assert.Equal(t, 1.0, testutil.ToFloat64(p.idle.childSA.WithLabelValues("partner1", "net-1", "in")), "idle not as expected")
+	assert.Equal(t, 1.0, testutil.ToFloat64(p.idle.transitionsTotal.WithLabelValues("partner1", "net-1", "in")), "transitions not as expected")
+
+	status.Idle = false
+	status.Changed = true
+	p.Idle().IdleStatus(status)
+	assert.Equal(t, 0.0, testutil.ToFloat64(p.idle.childSA.WithLabelValues("partner1", "net-1", "in")), "idle not reset")
+	assert.Equal(t, 1.0, testutil.ToFloat64(p.idle.transitionsTotal.WithLabelValues("partner1", "net-1", "in")), "transitions not as expected")
+
+	status.Changed = false
+	status.Removed = true
+	p.Idle().IdleStatus(status)
+	assert.Equal(t, 0, testutil.CollectAndCount(p.idle.childSA), "idle series not removed")
+}
+
+func TestKeepalive(t *testing.T) {
+	logger := test.NewLogger(t)
+	p, err := NewPrometheusReporter(logger, Configuration{})
+	if !assert.NoError(t, err, "unexpected initialization error") {
+		return
+	}
+	report := keepalive.Report{
+		IKESAName:     "partner1",
+		ChildSAName:   "net-1",
+		Protocol:      keepalive.ProtocolUDP,
+		LocalAddress:  "10.1.0.5",
+		RemoteAddress: "10.2.0.1",
+		Port:          9,
+	}
+	p.Keepalive().ReportKeepalive(report)
+	p.Keepalive().ReportKeepalive(report)
+	report.Error = errors.New("network unreachable")
+	p.Keepalive().ReportKeepalive(report)
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(p.keepalive.sentTotal.WithLabelValues("partner1", "net-1", "udp")), "sent not as expected")
+	assert.Equal(t, 1.0, testutil.ToFloat64(p.keepalive.failuresTotal.WithLabelValues("partner1", "net-1", "udp")), "failures not as expected")
+}
+
+func TestDryRun(t *testing.T) {
+	logger := test.NewLogger(t)
+	p, err := NewPrometheusReporter(logger, Configuration{})
+	if !assert.NoError(t, err, "unexpected initialization error") {
+		return
+	}
+	decision := strongswan.Decision{
+		Component:   "healer",
+		IKESAName:   "partner1",
+		ChildSAName: "net-1",
+		Action:      "rekey",
+		Reason:      "3 consecutive checks through installed Child SA partner1.net-1[7] failed",
+	}
+	p.DryRun().ReportDecision(decision)
+	p.DryRun().ReportDecision(decision)
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(p.dryRun.decisionsTotal.WithLabelValues("healer", "partner1", "net-1", "rekey")), "decisions not as expected")
+}
+
+func TestReinitiator(t *testing.T) {
+	logger := test.NewLogger(t)
+	p, err := NewPrometheusReporter(logger, Configuration{})
+	if !assert.NoError(t, err, "unexpected initialization error") {
+		return
+	}
+	p.Reinitiator().ReportBackoff("partner1", "net-1", 20*time.Second)
+	assert.Equal(t, 20.0, testutil.ToFloat64(p.reinitiator.backoff.WithLabelValues("partner1", "net-1")), "backoff not as expected")
+
+	p.Reinitiator().ReportBackoff("partner1", "net-1", 0)
+	assert.Equal(t, 0.0, testutil.ToFloat64(p.reinitiator.backoff.WithLabelValues("partner1", "net-1")), "backoff not reset")
+}
+
+func TestReinitiator_initiations(t *testing.T) {
+	logger := test.NewLogger(t)
+	p, err := NewPrometheusReporter(logger, Configuration{})
+	if !assert.NoError(t, err, "unexpected initialization error") {
+		return
+	}
+	initiation := strongswan.Initiation{
+		IKESAName:   "partner1",
+		ChildSAName: "net-1",
+		Duration:    2 * time.Second,
+	}
+	p.Reinitiator().ReportInitiation(initiation)
+	initiation.Error = errors.New("initiate unsuccessful")
+	p.Reinitiator().ReportInitiation(initiation)
+	p.Reinitiator().ReportSkipped("partner1", "net-1", strongswan.SkipReasonBackoff)
+
+	labelValues := []string{"partner1", "net-1"}
+	assert.Equal(t, 2.0, testutil.ToFloat64(p.reinitiator.attemptsTotal.WithLabelValues(labelValues...)), "attempts not as expected")
+	assert.Equal(t, 1.0, testutil.ToFloat64(p.reinitiator.successTotal.WithLabelValues(labelValues...)), "successes not as expected")
+	assert.Equal(t, 1.0, testutil.ToFloat64(p.reinitiator.failuresTotal.WithLabelValues(labelValues...)), "failures not as expected")
+	assert.Equal(t, 1.0, testutil.ToFloat64(p.reinitiator.skippedTotal.WithLabelValues("partner1", "net-1", "backoff")), "skipped not as expected")
+	assert.Equal(t, 1, testutil.CollectAndCount(p.reinitiator.duration), "duration series not as expected")
+}
@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"github.com/lunarway/strong-duckling/internal/strongswan"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	subSystemOrphaned = "orphaned"
+)
+
+var _ strongswan.CollectionDoneReceiver = &orphans{}
+
+// orphans reports on IKE SAs without a configuration and their terminations
+// by a strongswan.OrphanTerminator.
+type orphans struct {
+	ikeSAs            *prometheus.GaugeVec
+	terminationsTotal *prometheus.CounterVec
+
+	// reported holds the IKE SA names with orphaned IKE SAs in the gauge.
+	reported map[string]struct{}
+	// seen holds the number of orphaned IKE SAs by IKE SA name of the current
+	// collection.
+	seen map[string]int
+}
+
+func newOrphans() *orphans {
+	return &orphans{
+		ikeSAs: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subSystemOrphaned,
+			Name:      "ike_sas",
+			Help:      "Number of IKE SAs without a configuration, e.g. after unload-conn or a configuration reload",
+		}, []string{"ike_sa_name"}),
+		terminationsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subSystemOrphaned,
+			Name:      "terminations_total",
+			Help:      "Total number of terminations of IKE SAs without a configuration",
+		}, []string{"ike_sa_name", "result"}),
+		reported: make(map[string]struct{}),
+		seen:     make(map[string]int),
+	}
+}
+
+func (o *orphans) getCollectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		o.ikeSAs,
+		o.terminationsTotal,
+	}
+}
+
+func (o *orphans) IKESAStatus(ikeSAStatus strongswan.IKESAStatus) {
+	if !ikeSAStatus.Orphaned {
+		return
+	}
+	o.seen[ikeSAStatus.Name] = len(ikeSAStatus.Sessions)
+}
+
+// CollectionDone sets the gauge to the orphaned IKE SAs of the collection and
+// deletes the series of IKE SAs that are gone.
+func (o *orphans) CollectionDone() {
+	for ikeSAName, count := range o.seen {
+		o.ikeSAs.WithLabelValues(ikeSAName).Set(float64(count))
+	}
+	for ikeSAName := range o.reported {
+		if _, ok := o.seen[ikeSAName]; !ok {
+			o.ikeSAs.DeleteLabelValues(ikeSAName)
+		}
+	}
+	o.reported = make(map[string]struct{})
+	for ikeSAName := range o.seen {
+		o.reported[ikeSAName] = struct{}{}
+	}
+	o.seen = make(map[string]int)
+}
+
+func (o *orphans) ReportOrphanTermination(termination strongswan.OrphanTermination) {
+	result := "success"
+	if termination.Error != nil {
+		result = "failure"
+	}
+	o.terminationsTotal.WithLabelValues(termination.IKESAName, result).Inc()
+}
@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/lunarway/strong-duckling/internal/strongswan"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	subSystemReinitiator = "reinitiator"
+)
+
+// reinitiator reports on the initiations of missing child SAs by a
+// strongswan.Reinitiator.
+type reinitiator struct {
+	backoff       *prometheus.GaugeVec
+	attemptsTotal *prometheus.CounterVec
+	successTotal  *prometheus.CounterVec
+	failuresTotal *prometheus.CounterVec
+	skippedTotal  *prometheus.CounterVec
+	duration      *prometheus.HistogramVec
+}
+
+func newReinitiator() *reinitiator {
+	return &reinitiator{
+		backoff: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subSystemReinitiator,
+			Name:      "backoff_seconds",
+			Help:      "Delay before the next initiation of the child SA after failed initiations. 0 if it is not backed off",
+		}, []string{"ike_sa_name", "child_sa_name"}),
+		attemptsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subSystemReinitiator,
+			Name:      "attempts_total",
+			Help:      "Total number of initiations of the child SA",
+		}, []string{"ike_sa_name", "child_sa_name"}),
+		successTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subSystemReinitiator,
+			Name:      "successes_total",
+			Help:      "Total number of successful initiations of the child SA",
+		}, []string{"ike_sa_name", "child_sa_name"}),
+		failuresTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subSystemReinitiator,
+			Name:      "failures_total",
+			Help:      "Total number of failed initiations of the child SA",
+		}, []string{"ike_sa_name", "child_sa_name"}),
+		skippedTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subSystemReinitiator,
+			Name:      "skipped_total",
+			Help:      "Total number of times the missing child SA was not initiated by reason",
+		}, []string{"ike_sa_name", "child_sa_name", "reason"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subSystemReinitiator,
+			Name:      "duration_seconds",
+			Help:      "Duration of initiations of the child SA",
+			Buckets:   prometheus.ExponentialBuckets(0.1, 2, 12),
+		}, []string{"ike_sa_name", "child_sa_name"}),
+	}
+}
+
+func (r *reinitiator) getCollectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		r.backoff,
+		r.attemptsTotal,
+		r.successTotal,
+		r.failuresTotal,
+		r.skippedTotal,
+		r.duration,
+	}
+}
+
+func (r *reinitiator) ReportBackoff(ikeSAName, childSAName string, delay time.Duration) {
+	r.backoff.WithLabelValues(ikeSAName, childSAName).Set(delay.Seconds())
+}
+
+func (r *reinitiator) ReportInitiation(initiation strongswan.Initiation) {
+	labelValues := []string{initiation.IKESAName, initiation.ChildSAName}
+	r.attemptsTotal.WithLabelValues(labelValues...).Inc()
+	if initiation.Error != nil {
+		r.failuresTotal.WithLabelValues(labelValues...).Inc()
+	} else {
+		r.successTotal.WithLabelValues(labelValues...).Inc()
+	}
+	r.duration.WithLabelValues(labelValues...).Observe(initiation.Duration.Seconds())
+}
+
+func (r *reinitiator) ReportSkipped(ikeSAName, childSAName string, reason strongswan.SkipReason) {
+	r.skippedTotal.WithLabelValues(ikeSAName, childSAName, string(reason)).Inc()
+}
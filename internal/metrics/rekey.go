@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/lunarway/strong-duckling/internal/vici"
+)
+
+// rekeyForecaster keeps track of the byte and packet counters of child SAs to
+// estimate the current traffic rate and from that when a volume based rekey
+// will be triggered.
+type rekeyForecaster struct {
+	samples map[string]rekeySample
+}
+
+type rekeySample struct {
+	uniqueID string
+	time     time.Time
+	bytes    float64
+	packets  float64
+}
+
+// rekeyForecast is the result of a single observation of a child SA.
+type rekeyForecast struct {
+	// bytesConsumed and packetsConsumed are the percentage of the configured
+	// rekey_bytes and rekey_packets limits that are used. They are only set if
+	// the limit is configured.
+	bytesConsumed, packetsConsumed *float64
+	// bytesForecast and packetsForecast are the estimated number of seconds
+	// until the volume limit is reached based on the current rate. They are only
+	// set if the limit is configured and traffic is flowing.
+	bytesForecast, packetsForecast *float64
+}
+
+func newRekeyForecaster() *rekeyForecaster {
+	return &rekeyForecaster{
+		samples: make(map[string]rekeySample),
+	}
+}
+
+// observe records the counters of child and returns the volume rekey forecast
+// based on the rekey limits in conf.
+//
+// The kernel applies the volume limits to the inbound and outbound SA
+// individually so the direction with the most traffic is used.
+func (f *rekeyForecaster) observe(key string, now time.Time, conf vici.ChildSAConf, child vici.ChildSA) rekeyForecast {
+	current := rekeySample{
+		uniqueID: child.UniqueID,
+		time:     now,
+		bytes:    math.Max(parseCounter(child.BytesIn), parseCounter(child.BytesOut)),
+		packets:  math.Max(parseCounter(child.PacketsIn), parseCounter(child.PacketsOut)),
+	}
+	previous, ok := f.samples[key]
+	f.samples[key] = current
+
+	// a rekey replaces the SA and resets its counters so rates can only be
+	// calculated between samples of the same SA.
+	rateKnown := ok && previous.uniqueID == current.uniqueID && current.time.After(previous.time)
+
+	var forecast rekeyForecast
+	if limit := parseCounter(conf.RekeyBytes); limit > 0 {
+		forecast.bytesConsumed = percentage(current.bytes, limit)
+		if rateKnown {
+			forecast.bytesForecast = secondsUntil(previous.bytes, current.bytes, limit, current.time.Sub(previous.time))
+		}
+	}
+	if limit := parseCounter(conf.RekeyPackets); limit > 0 {
+		forecast.packetsConsumed = percentage(current.packets, limit)
+		if rateKnown {
+			forecast.packetsForecast = secondsUntil(previous.packets, current.packets, limit, current.time.Sub(previous.time))
+		}
+	}
+	return forecast
+}
+
+// forget removes all samples not in the keys set.
+func (f *rekeyForecaster) forget(keys map[string]struct{}) {
+	for key := range f.samples {
+		if _, ok := keys[key]; !ok {
+			delete(f.samples, key)
+		}
+	}
+}
+
+func percentage(value, limit float64) *float64 {
+	p := value / limit * 100
+	return &p
+}
+
+// secondsUntil returns the number of seconds before current reaches limit if
+// it keeps increasing with the rate from previous over elapsed. If the value
+// is not increasing nil is returned.
+func secondsUntil(previous, current, limit float64, elapsed time.Duration) *float64 {
+	if current <= previous {
+		return nil
+	}
+	rate := (current - previous) / elapsed.Seconds()
+	seconds := math.Max(limit-current, 0) / rate
+	return &seconds
+}
+
+// parseCounter parses a vici counter value. Empty and invalid values are
+// reported as 0.
+func parseCounter(value string) float64 {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0
+	}
+	return f
+}
@@ -0,0 +1,209 @@
+package metrics
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lunarway/strong-duckling/internal/strongswan"
+	"github.com/lunarway/strong-duckling/internal/vici"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+)
+
+const (
+	subSystemRemoteAccess = "remote_access"
+
+	// otherIdentity is the identity label value used for all identities seen
+	// after the maximum number of identities is reached.
+	otherIdentity = "other"
+)
+
+// remoteAccess reports metrics on individual sessions of remote access
+// connections, ie. connections where multiple peers share the same
+// configuration and are identified by their EAP, XAuth or IKE identity.
+type remoteAccess struct {
+	logger log.Logger
+
+	// maxIdentities is the maximum number of distinct identities exposed as
+	// label values. Additional identities are reported as otherIdentity. If 0
+	// there is no limit.
+	maxIdentities int
+	identities    map[string]struct{}
+
+	sessions        *infoVec
+	virtualIPs      *infoVec
+	sessionDuration *prometheus.HistogramVec
+	bytesIn         *prometheus.CounterVec
+	bytesOut        *prometheus.CounterVec
+
+	// activeSessions holds the sessions of each IKE SA name keyed by their
+	// unique ID.
+	activeSessions map[string]map[string]remoteAccessSession
+	// childSABytes holds the last seen byte counters of child SAs keyed by IKE
+	// SA name and a session unique child SA key.
+	childSABytes map[string]map[string]childSABytes
+}
+
+type remoteAccessSession struct {
+	identity           string
+	group              string
+	establishedSeconds float64
+}
+
+type childSABytes struct {
+	in, out float64
+}
+
+func newRemoteAccess(logger log.Logger, maxIdentities int) *remoteAccess {
+	return &remoteAccess{
+		logger:        logger,
+		maxIdentities: maxIdentities,
+		identities:    make(map[string]struct{}),
+		sessions: newInfoVec(prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subSystemRemoteAccess,
+			Name:      "sessions",
+			Help:      "Number of active sessions per identity",
+		}, []string{"ike_sa_name", "identity", "group"})),
+		virtualIPs: newInfoVec(prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subSystemRemoteAccess,
+			Name:      "virtual_ip_info",
+			Help:      "Virtual IPs assigned to active sessions",
+		}, []string{"ike_sa_name", "identity", "virtual_ip"})),
+		sessionDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subSystemRemoteAccess,
+			Name:      "session_duration_seconds",
+			Help:      "Duration of ended sessions",
+			Buckets:   prometheus.ExponentialBuckets(60, 2, 12),
+		}, []string{"ike_sa_name", "group"}),
+		bytesIn: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subSystemRemoteAccess,
+			Name:      "bytes_in_total",
+			Help:      "Total number of bytes received from an identity",
+		}, []string{"ike_sa_name", "identity"}),
+		bytesOut: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subSystemRemoteAccess,
+			Name:      "bytes_out_total",
+			Help:      "Total number of bytes transmitted to an identity",
+		}, []string{"ike_sa_name", "identity"}),
+		activeSessions: make(map[string]map[string]remoteAccessSession),
+		childSABytes:   make(map[string]map[string]childSABytes),
+	}
+}
+
+func (r *remoteAccess) getCollectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		r.sessions.gauge,
+		r.virtualIPs.gauge,
+		r.sessionDuration,
+		r.bytesIn,
+		r.bytesOut,
+	}
+}
+
+func (r *remoteAccess) IKESAStatus(ikeSAStatus strongswan.IKESAStatus) {
+	name := ikeSAStatus.Name
+	group := remoteGroups(ikeSAStatus.Configuration)
+
+	sessions := make(map[string]remoteAccessSession)
+	sessionCounts := make(map[string]float64)
+	seenVirtualIPs := make(map[string]struct{})
+	previousChildSABytes := r.childSABytes[name]
+	currentChildSABytes := make(map[string]childSABytes)
+	for _, sa := range ikeSAStatus.Sessions {
+		identity := r.identityLabel(remoteIdentity(sa))
+		established, _ := strconv.ParseFloat(sa.EstablishedSeconds, 64)
+		sessions[sa.UniqueID] = remoteAccessSession{
+			identity:           identity,
+			group:              group,
+			establishedSeconds: established,
+		}
+		sessionCounts[identity]++
+
+		for _, vip := range sa.RemoteVIPs {
+			key := sa.UniqueID + "/" + vip
+			r.virtualIPs.set(name, key, []string{name, identity, vip})
+			seenVirtualIPs[key] = struct{}{}
+		}
+
+		for childKey, child := range sa.ChildSAs {
+			key := sa.UniqueID + "/" + childKey
+			current := childSABytes{
+				in:  parseCounter(child.BytesIn),
+				out: parseCounter(child.BytesOut),
+			}
+			currentChildSABytes[key] = current
+			previous := previousChildSABytes[key]
+			r.bytesIn.WithLabelValues(name, identity).Add(counterDelta(previous.in, current.in))
+			r.bytesOut.WithLabelValues(name, identity).Add(counterDelta(previous.out, current.out))
+		}
+	}
+
+	for uniqueID, session := range r.activeSessions[name] {
+		if _, ok := sessions[uniqueID]; ok {
+			continue
+		}
+		r.logger.With("identity", session.identity).Debugf("Remote access session %s for %s ended after %v seconds", uniqueID, name, session.establishedSeconds)
+		r.sessionDuration.WithLabelValues(name, session.group).Observe(session.establishedSeconds)
+	}
+	r.activeSessions[name] = sessions
+	r.childSABytes[name] = currentChildSABytes
+
+	seenIdentities := make(map[string]struct{})
+	for identity, count := range sessionCounts {
+		r.sessions.setValue(name, identity, []string{name, identity, group}, count)
+		seenIdentities[identity] = struct{}{}
+	}
+	r.sessions.retain(name, seenIdentities)
+	r.virtualIPs.retain(name, seenVirtualIPs)
+}
+
+// identityLabel returns the label value to use for identity ensuring that at
+// most maxIdentities distinct values are used.
+func (r *remoteAccess) identityLabel(identity string) string {
+	if _, ok := r.identities[identity]; ok {
+		return identity
+	}
+	if r.maxIdentities > 0 && len(r.identities) >= r.maxIdentities {
+		return otherIdentity
+	}
+	r.identities[identity] = struct{}{}
+	return identity
+}
+
+// remoteIdentity returns the most specific identity of the remote peer.
+func remoteIdentity(sa vici.IkeSa) string {
+	switch {
+	case sa.RemoteEAPID != "":
+		return sa.RemoteEAPID
+	case sa.RemoteXAuthID != "":
+		return sa.RemoteXAuthID
+	default:
+		return sa.RemoteID
+	}
+}
+
+// remoteGroups returns the groups required by the remote authentication rounds
+// of conf.
+func remoteGroups(conf vici.IKEConf) string {
+	var groups []string
+	for _, auth := range conf.RemoteAuthSection {
+		groups = append(groups, auth.Groups...)
+	}
+	sort.Strings(groups)
+	return strings.Join(groups, ",")
+}
+
+// counterDelta returns the increase from previous to current. If current is
+// lower than previous the counter is assumed to be reset.
+func counterDelta(previous, current float64) float64 {
+	if current < previous {
+		return current
+	}
+	return current - previous
+}
@@ -2,6 +2,8 @@ package metrics
 
 import (
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/lunarway/strong-duckling/internal/tcpchecker"
 	"github.com/prometheus/client_golang/prometheus"
@@ -9,18 +11,46 @@ import (
 
 const (
 	subSystemTcpChecker = "tcp_checker"
+
+	// DefaultFlapWindow is the default sliding window over which the flap rate
+	// of TCP checker targets is calculated.
+	DefaultFlapWindow = 10 * time.Minute
 )
 
 type tcpChecker struct {
-	checks           *prometheus.CounterVec
-	open             *prometheus.GaugeVec
-	connectedTotal   *prometheus.CounterVec
-	disconectedTotal *prometheus.CounterVec
+	checks            *prometheus.CounterVec
+	open              *prometheus.GaugeVec
+	connectedTotal    *prometheus.CounterVec
+	disconnectedTotal *prometheus.CounterVec
+	flapRate          *prometheus.GaugeVec
+	lastStateChange   *prometheus.GaugeVec
+	connectDuration   *prometheus.HistogramVec
+	firstByteDuration *prometheus.HistogramVec
+	failuresTotal     *prometheus.CounterVec
+	// unexpectedResponse is 1 if the port is open but another service than
+	// expected responds.
+	unexpectedResponse *prometheus.GaugeVec
+
+	// now returns the current time. It is used to timestamp state changes.
+	now        func() time.Time
+	flapWindow time.Duration
 
-	previousOpenState *bool
+	// targets holds the state of each checked target keyed by its label
+	// values. Checks of multiple targets are reported concurrently.
+	mu      sync.Mutex
+	targets map[string]*tcpCheckerTarget
 }
 
-func newTcpChecker() *tcpChecker {
+type tcpCheckerTarget struct {
+	open bool
+	// stateChanges holds the times of state changes within the flap window.
+	stateChanges []time.Time
+}
+
+func newTcpChecker(flapWindow time.Duration) *tcpChecker {
+	if flapWindow == 0 {
+		flapWindow = DefaultFlapWindow
+	}
 	return &tcpChecker{
 		checks: prometheus.NewCounterVec(prometheus.CounterOpts{
 			Namespace: namespace,
@@ -40,12 +70,53 @@ func newTcpChecker() *tcpChecker {
 			Name:      "connected_total",
 			Help:      "Total number of times connection to TCP address:port was established",
 		}, []string{"name", "address", "port"}),
-		disconectedTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+		disconnectedTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
 			Namespace: namespace,
 			Subsystem: subSystemTcpChecker,
 			Name:      "disconnected_total",
 			Help:      "Total number of times connection to TCP address:port was lost",
 		}, []string{"name", "address", "port"}),
+		flapRate: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subSystemTcpChecker,
+			Name:      "flap_rate",
+			Help:      "Number of open state changes per minute over the flap window",
+		}, []string{"name", "address", "port"}),
+		lastStateChange: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subSystemTcpChecker,
+			Name:      "last_state_change_timestamp_seconds",
+			Help:      "Unix timestamp of the last open state change",
+		}, []string{"name", "address", "port"}),
+		connectDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subSystemTcpChecker,
+			Name:      "connect_duration_seconds",
+			Help:      "Duration of establishing TCP connections including failed attempts",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 11),
+		}, []string{"name", "address", "port"}),
+		firstByteDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subSystemTcpChecker,
+			Name:      "first_byte_duration_seconds",
+			Help:      "Duration from a connection is established until the first byte is received",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 11),
+		}, []string{"name", "address", "port"}),
+		failuresTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subSystemTcpChecker,
+			Name:      "failures_total",
+			Help:      "Total number of failed checks by reason",
+		}, []string{"name", "address", "port", "reason"}),
+		unexpectedResponse: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subSystemTcpChecker,
+			Name:      "unexpected_response_info",
+			Help:      "Is 1 if TCP is open but the response does not match the expectation otherwise 0",
+		}, []string{"name", "address", "port"}),
+		now:        time.Now,
+		flapWindow: flapWindow,
+		targets:    make(map[string]*tcpCheckerTarget),
 	}
 }
 
@@ -54,7 +125,13 @@ func (tc *tcpChecker) getCollectors() []prometheus.Collector {
 		tc.open,
 		tc.checks,
 		tc.connectedTotal,
-		tc.disconectedTotal,
+		tc.disconnectedTotal,
+		tc.flapRate,
+		tc.lastStateChange,
+		tc.connectDuration,
+		tc.firstByteDuration,
+		tc.failuresTotal,
+		tc.unexpectedResponse,
 	}
 }
 
@@ -63,15 +140,57 @@ func (r *tcpChecker) ReportPortCheck(report tcpchecker.Report) {
 	if report.Open {
 		r.checks.WithLabelValues(append(labelValues, "true")...).Inc()
 		r.open.WithLabelValues(labelValues...).Set(1)
-		if r.previousOpenState == nil || *r.previousOpenState != report.Open {
-			r.connectedTotal.WithLabelValues(labelValues...).Add(1)
-		}
 	} else {
 		r.checks.WithLabelValues(append(labelValues, "false")...).Inc()
 		r.open.WithLabelValues(labelValues...).Set(0)
-		if r.previousOpenState == nil || *r.previousOpenState != report.Open {
-			r.disconectedTotal.WithLabelValues(labelValues...).Add(0)
+	}
+	if reason := report.Reason(); reason != "" {
+		r.failuresTotal.WithLabelValues(append(labelValues, string(reason))...).Inc()
+	}
+	if report.UnexpectedResponse() {
+		r.unexpectedResponse.WithLabelValues(labelValues...).Set(1)
+	} else {
+		r.unexpectedResponse.WithLabelValues(labelValues...).Set(0)
+	}
+	r.connectDuration.WithLabelValues(labelValues...).Observe(report.ConnectDuration.Seconds())
+	if report.FirstByteDuration > 0 {
+		r.firstByteDuration.WithLabelValues(labelValues...).Observe(report.FirstByteDuration.Seconds())
+	}
+
+	now := r.now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := fmt.Sprintf("%q", labelValues)
+	target, seen := r.targets[key]
+	if !seen {
+		target = &tcpCheckerTarget{}
+		r.targets[key] = target
+	}
+	changed := !seen || target.open != report.Open
+	target.open = report.Open
+	if changed {
+		if report.Open {
+			r.connectedTotal.WithLabelValues(labelValues...).Inc()
+		} else {
+			r.disconnectedTotal.WithLabelValues(labelValues...).Inc()
+		}
+		r.lastStateChange.WithLabelValues(labelValues...).Set(float64(now.UnixNano()) / float64(time.Second))
+		// the first report of a target is its initial state and not a flap
+		if seen {
+			target.stateChanges = append(target.stateChanges, now)
+		}
+	}
+	target.stateChanges = withinWindow(target.stateChanges, now.Add(-r.flapWindow))
+	r.flapRate.WithLabelValues(labelValues...).Set(float64(len(target.stateChanges)) / r.flapWindow.Minutes())
+}
+
+// withinWindow returns the times in times that are after start. times must be
+// sorted in ascending order.
+func withinWindow(times []time.Time, start time.Time) []time.Time {
+	for i, t := range times {
+		if t.After(start) {
+			return times[i:]
 		}
 	}
-	r.previousOpenState = &report.Open
+	return nil
 }
@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/lunarway/strong-duckling/internal/tcpchecker"
+	"github.com/lunarway/strong-duckling/internal/tunnelchecker"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	subSystemTunnelChecker = "tunnel_checker"
+)
+
+// tunnelChecker reports TCP checks derived from child SA traffic selectors
+// labeled with the owning IKE and child SA.
+type tunnelChecker struct {
+	checks          *prometheus.CounterVec
+	open            *prometheus.GaugeVec
+	connectDuration *prometheus.HistogramVec
+	failuresTotal   *prometheus.CounterVec
+}
+
+func newTunnelChecker() *tunnelChecker {
+	return &tunnelChecker{
+		checks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subSystemTunnelChecker,
+			Name:      "checked_total",
+			Help:      "Total number of times the connection through the child SA has been checked",
+		}, []string{"ike_sa_name", "child_sa_name", "name", "address", "port", "open"}),
+		open: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subSystemTunnelChecker,
+			Name:      "open_info",
+			Help:      "Is TCP open through the child SA is 1 otherwise 0",
+		}, []string{"ike_sa_name", "child_sa_name", "name", "address", "port"}),
+		connectDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subSystemTunnelChecker,
+			Name:      "connect_duration_seconds",
+			Help:      "Duration of establishing TCP connections through the child SA including failed attempts",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 11),
+		}, []string{"ike_sa_name", "child_sa_name", "name", "address", "port"}),
+		failuresTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subSystemTunnelChecker,
+			Name:      "failures_total",
+			Help:      "Total number of failed checks through the child SA by reason",
+		}, []string{"ike_sa_name", "child_sa_name", "name", "address", "port", "reason"}),
+	}
+}
+
+func (tc *tunnelChecker) getCollectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		tc.checks,
+		tc.open,
+		tc.connectDuration,
+		tc.failuresTotal,
+	}
+}
+
+func (tc *tunnelChecker) delete(target tunnelchecker.Target) {
+	labelValues := []string{target.IKESAName, target.ChildSAName, target.Name, target.Address, fmt.Sprintf("%d", target.Port)}
+	tc.open.DeleteLabelValues(labelValues...)
+	tc.connectDuration.DeleteLabelValues(labelValues...)
+	for _, open := range []bool{true, false} {
+		tc.checks.DeleteLabelValues(append(labelValues, strconv.FormatBool(open))...)
+	}
+	for _, reason := range tcpchecker.FailureReasons {
+		tc.failuresTotal.DeleteLabelValues(append(labelValues, string(reason))...)
+	}
+}
+
+// tunnelCheckerReporter reports tcp checks of a single child SA.
+type tunnelCheckerReporter struct {
+	tunnelChecker *tunnelChecker
+	ikeSAName     string
+	childSAName   string
+}
+
+func (r *tunnelCheckerReporter) ReportPortCheck(report tcpchecker.Report) {
+	tc := r.tunnelChecker
+	labelValues := []string{r.ikeSAName, r.childSAName, report.Name, report.Address, fmt.Sprintf("%d", report.Port)}
+	tc.checks.WithLabelValues(append(labelValues, strconv.FormatBool(report.Open))...).Inc()
+	if report.Open {
+		tc.open.WithLabelValues(labelValues...).Set(1)
+	} else {
+		tc.open.WithLabelValues(labelValues...).Set(0)
+	}
+	if reason := report.Reason(); reason != "" {
+		tc.failuresTotal.WithLabelValues(append(labelValues, string(reason))...).Inc()
+	}
+	tc.connectDuration.WithLabelValues(labelValues...).Observe(report.ConnectDuration.Seconds())
+}
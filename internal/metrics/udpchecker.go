@@ -0,0 +1,73 @@
+package metrics
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/lunarway/strong-duckling/internal/udpchecker"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	subSystemUdpChecker = "udp_checker"
+)
+
+type udpChecker struct {
+	checks        *prometheus.CounterVec
+	reachable     *prometheus.GaugeVec
+	failuresTotal *prometheus.CounterVec
+	roundTripTime *prometheus.HistogramVec
+}
+
+func newUdpChecker() *udpChecker {
+	return &udpChecker{
+		checks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subSystemUdpChecker,
+			Name:      "checked_total",
+			Help:      "Total number of times the peer has been checked",
+		}, []string{"name", "address", "port", "reachable"}),
+		reachable: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subSystemUdpChecker,
+			Name:      "reachable_info",
+			Help:      "Is the peer replying as expected 1 otherwise 0",
+		}, []string{"name", "address", "port"}),
+		failuresTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subSystemUdpChecker,
+			Name:      "failures_total",
+			Help:      "Total number of failed checks by reason",
+		}, []string{"name", "address", "port", "reason"}),
+		roundTripTime: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subSystemUdpChecker,
+			Name:      "round_trip_seconds",
+			Help:      "Round trip time of replies",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 11),
+		}, []string{"name", "address", "port"}),
+	}
+}
+
+func (uc *udpChecker) getCollectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		uc.checks,
+		uc.reachable,
+		uc.failuresTotal,
+		uc.roundTripTime,
+	}
+}
+
+func (uc *udpChecker) ReportUDPCheck(report udpchecker.Report) {
+	labelValues := []string{report.Name, report.Address, fmt.Sprintf("%d", report.Port)}
+	uc.checks.WithLabelValues(append(labelValues, strconv.FormatBool(report.Reachable))...).Inc()
+	if report.Reachable {
+		uc.reachable.WithLabelValues(labelValues...).Set(1)
+	} else {
+		uc.reachable.WithLabelValues(labelValues...).Set(0)
+		uc.failuresTotal.WithLabelValues(append(labelValues, string(report.Failure))...).Inc()
+	}
+	if report.RoundTripTime > 0 {
+		uc.roundTripTime.WithLabelValues(labelValues...).Observe(report.RoundTripTime.Seconds())
+	}
+}
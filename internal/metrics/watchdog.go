@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"github.com/lunarway/strong-duckling/internal/strongswan"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// watchdog reports IKE SAs found stuck in non-final states by a
+// strongswan.Watchdog.
+type watchdog struct {
+	stuckTotal *prometheus.CounterVec
+}
+
+func newWatchdog() *watchdog {
+	return &watchdog{
+		stuckTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "stuck_ike_sa_total",
+			Help:      "Total number of IKE SAs terminated after staying in non-final states for longer than the threshold by their last state. result is dry_run if they were not terminated",
+		}, []string{"ike_sa_name", "state", "result"}),
+	}
+}
+
+func (w *watchdog) getCollectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		w.stuckTotal,
+	}
+}
+
+func (w *watchdog) ReportStuck(stuck strongswan.StuckIKESA) {
+	result := "success"
+	switch {
+	case stuck.DryRun:
+		result = "dry_run"
+	case stuck.Error != nil:
+		result = "failure"
+	}
+	w.stuckTotal.WithLabelValues(stuck.IKESAName, stuck.State, result).Inc()
+}
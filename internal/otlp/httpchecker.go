@@ -0,0 +1,78 @@
+package otlp
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lunarway/strong-duckling/internal/httpchecker"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+type httpChecker struct {
+	checks               metric.Int64Counter
+	healthy              metric.Int64Gauge
+	statusCode           metric.Int64Gauge
+	failures             metric.Int64Counter
+	responseDuration     metric.Float64Histogram
+	tlsHandshakeDuration metric.Float64Histogram
+	certificateExpiry    metric.Int64Gauge
+}
+
+func newHttpChecker(meter metric.Meter) (*httpChecker, error) {
+	var hc httpChecker
+	var err error
+	hc.checks, err = meter.Int64Counter(prefix+"http_checker.checked", metric.WithDescription("Total number of times the endpoint has been checked"))
+	if err != nil {
+		return nil, fmt.Errorf("create http checker instrument: %w", err)
+	}
+	hc.healthy, err = meter.Int64Gauge(prefix+"http_checker.healthy", metric.WithDescription("Is the endpoint responding as expected 1 otherwise 0"))
+	if err != nil {
+		return nil, fmt.Errorf("create http checker instrument: %w", err)
+	}
+	hc.statusCode, err = meter.Int64Gauge(prefix+"http_checker.status_code", metric.WithDescription("Status code of the last response or 0 if no response was received"))
+	if err != nil {
+		return nil, fmt.Errorf("create http checker instrument: %w", err)
+	}
+	hc.failures, err = meter.Int64Counter(prefix+"http_checker.failures", metric.WithDescription("Total number of failed checks by reason"))
+	if err != nil {
+		return nil, fmt.Errorf("create http checker instrument: %w", err)
+	}
+	hc.responseDuration, err = meter.Float64Histogram(prefix+"http_checker.response_duration", metric.WithDescription("Duration from a request is sent until the full response is read including failed requests"), metric.WithUnit("s"))
+	if err != nil {
+		return nil, fmt.Errorf("create http checker instrument: %w", err)
+	}
+	hc.tlsHandshakeDuration, err = meter.Float64Histogram(prefix+"http_checker.tls_handshake_duration", metric.WithDescription("Duration of TLS handshakes"), metric.WithUnit("s"))
+	if err != nil {
+		return nil, fmt.Errorf("create http checker instrument: %w", err)
+	}
+	hc.certificateExpiry, err = meter.Int64Gauge(prefix+"http_checker.certificate_expiry_timestamp_seconds", metric.WithDescription("Unix timestamp of the expiry of the peer certificate"), metric.WithUnit("s"))
+	if err != nil {
+		return nil, fmt.Errorf("create http checker instrument: %w", err)
+	}
+	return &hc, nil
+}
+
+func (hc *httpChecker) ReportHTTPCheck(report httpchecker.Report) {
+	ctx := context.Background()
+	attributes := []attribute.KeyValue{
+		attribute.String("name", report.Name),
+		attribute.String("method", report.Method),
+		attribute.String("url", report.URL),
+	}
+	hc.checks.Add(ctx, 1, metric.WithAttributes(append(attributes, attribute.Bool("healthy", report.Healthy))...))
+	if report.Healthy {
+		hc.healthy.Record(ctx, 1, metric.WithAttributes(attributes...))
+	} else {
+		hc.healthy.Record(ctx, 0, metric.WithAttributes(attributes...))
+		hc.failures.Add(ctx, 1, metric.WithAttributes(append(attributes, attribute.String("reason", string(report.Failure)))...))
+	}
+	hc.statusCode.Record(ctx, int64(report.StatusCode), metric.WithAttributes(attributes...))
+	hc.responseDuration.Record(ctx, report.ResponseDuration.Seconds(), metric.WithAttributes(attributes...))
+	if report.TLSHandshakeDuration > 0 {
+		hc.tlsHandshakeDuration.Record(ctx, report.TLSHandshakeDuration.Seconds(), metric.WithAttributes(attributes...))
+	}
+	if !report.CertificateExpiry.IsZero() {
+		hc.certificateExpiry.Record(ctx, report.CertificateExpiry.Unix(), metric.WithAttributes(attributes...))
+	}
+}
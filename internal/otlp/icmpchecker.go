@@ -0,0 +1,83 @@
+package otlp
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lunarway/strong-duckling/internal/icmpchecker"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+type icmpChecker struct {
+	sent                 metric.Int64Counter
+	received             metric.Int64Counter
+	reachable            metric.Int64Gauge
+	failures             metric.Int64Counter
+	roundTripTime        metric.Float64Histogram
+	lossPercent          metric.Float64Gauge
+	averageRoundTripTime metric.Float64Gauge
+	jitter               metric.Float64Gauge
+}
+
+func newIcmpChecker(meter metric.Meter) (*icmpChecker, error) {
+	var ic icmpChecker
+	var err error
+	ic.sent, err = meter.Int64Counter(prefix+"icmp_checker.sent", metric.WithDescription("Total number of echo requests sent"))
+	if err != nil {
+		return nil, fmt.Errorf("create icmp checker instrument: %w", err)
+	}
+	ic.received, err = meter.Int64Counter(prefix+"icmp_checker.received", metric.WithDescription("Total number of echo replies received"))
+	if err != nil {
+		return nil, fmt.Errorf("create icmp checker instrument: %w", err)
+	}
+	ic.reachable, err = meter.Int64Gauge(prefix+"icmp_checker.reachable", metric.WithDescription("Did the last echo request receive a reply 1 otherwise 0"))
+	if err != nil {
+		return nil, fmt.Errorf("create icmp checker instrument: %w", err)
+	}
+	ic.failures, err = meter.Int64Counter(prefix+"icmp_checker.failures", metric.WithDescription("Total number of failed echo requests by reason"))
+	if err != nil {
+		return nil, fmt.Errorf("create icmp checker instrument: %w", err)
+	}
+	ic.roundTripTime, err = meter.Float64Histogram(prefix+"icmp_checker.round_trip", metric.WithDescription("Round trip time of echo replies"), metric.WithUnit("s"))
+	if err != nil {
+		return nil, fmt.Errorf("create icmp checker instrument: %w", err)
+	}
+	ic.lossPercent, err = meter.Float64Gauge(prefix+"icmp_checker.loss_percent", metric.WithDescription("Percentage of echo requests without a reply over the window"), metric.WithUnit("%"))
+	if err != nil {
+		return nil, fmt.Errorf("create icmp checker instrument: %w", err)
+	}
+	ic.averageRoundTripTime, err = meter.Float64Gauge(prefix+"icmp_checker.round_trip_average_seconds", metric.WithDescription("Mean round trip time of echo replies over the window"), metric.WithUnit("s"))
+	if err != nil {
+		return nil, fmt.Errorf("create icmp checker instrument: %w", err)
+	}
+	ic.jitter, err = meter.Float64Gauge(prefix+"icmp_checker.jitter_seconds", metric.WithDescription("Mean difference between round trip times of consecutive echo replies over the window"), metric.WithUnit("s"))
+	if err != nil {
+		return nil, fmt.Errorf("create icmp checker instrument: %w", err)
+	}
+	return &ic, nil
+}
+
+func (ic *icmpChecker) ReportICMPCheck(report icmpchecker.Report) {
+	ctx := context.Background()
+	attributes := metric.WithAttributes(
+		attribute.String("name", report.Name),
+		attribute.String("address", report.Address),
+	)
+	ic.sent.Add(ctx, 1, attributes)
+	if report.Reachable {
+		ic.received.Add(ctx, 1, attributes)
+		ic.reachable.Record(ctx, 1, attributes)
+		ic.roundTripTime.Record(ctx, report.RoundTripTime.Seconds(), attributes)
+	} else {
+		ic.reachable.Record(ctx, 0, attributes)
+		ic.failures.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("name", report.Name),
+			attribute.String("address", report.Address),
+			attribute.String("reason", string(report.Failure)),
+		))
+	}
+	ic.lossPercent.Record(ctx, report.Window.LossPercent, attributes)
+	ic.averageRoundTripTime.Record(ctx, report.Window.AverageRoundTripTime.Seconds(), attributes)
+	ic.jitter.Record(ctx, report.Window.Jitter.Seconds(), attributes)
+}
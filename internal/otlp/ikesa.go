@@ -0,0 +1,246 @@
+package otlp
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/lunarway/strong-duckling/internal/strongswan"
+	"github.com/lunarway/strong-duckling/internal/vici"
+	"github.com/prometheus/common/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var _ strongswan.CollectionDoneReceiver = &ikeSA{}
+
+type ikeSA struct {
+	logger log.Logger
+
+	establishedSeconds    metric.Float64Gauge
+	packetsIn             metric.Float64Gauge
+	packetsOut            metric.Float64Gauge
+	bytesIn               metric.Float64Gauge
+	bytesOut              metric.Float64Gauge
+	lastPacketInSeconds   metric.Float64Gauge
+	lastPacketOutSeconds  metric.Float64Gauge
+	rekeyRemainingSeconds metric.Float64Gauge
+	state                 metric.Int64Gauge
+	childSAState          metric.Int64Gauge
+	installs              metric.Int64Counter
+
+	mu sync.Mutex
+	// installedChildSAs holds the unique ID of the last seen child SA by IKE SA
+	// and child SA name. A new unique ID indicates a new install.
+	installedChildSAs map[string]map[string]string
+	// ikeSAStates holds the last recorded state of each IKE SA name.
+	ikeSAStates map[string]recordedState
+	// childSAStates holds the last recorded states of child SAs by IKE SA name
+	// and attributes.
+	childSAStates map[string]map[attribute.Distinct]recordedState
+	// seen holds the IKE SA names received in the current collection.
+	seen map[string]struct{}
+}
+
+// recordedState is a state recorded with attributes. Its values are reset to 0
+// when the SA is gone as gauges keep the last recorded value.
+type recordedState struct {
+	attributes []attribute.KeyValue
+	state      string
+}
+
+func newIkeSA(meter metric.Meter, logger log.Logger) (*ikeSA, error) {
+	i := ikeSA{
+		logger:            logger,
+		installedChildSAs: make(map[string]map[string]string),
+		ikeSAStates:       make(map[string]recordedState),
+		childSAStates:     make(map[string]map[attribute.Distinct]recordedState),
+		seen:              make(map[string]struct{}),
+	}
+	gauges := []struct {
+		gauge      *metric.Float64Gauge
+		name, help string
+		unit       string
+	}{
+		{&i.establishedSeconds, "ike_sa.established", "Number of seconds the SA has been established", "s"},
+		{&i.packetsIn, "ike_sa.packets_in", "Total number of received packets", "{packet}"},
+		{&i.packetsOut, "ike_sa.packets_out", "Total number of transmitted packets", "{packet}"},
+		{&i.bytesIn, "ike_sa.bytes_in", "Total number of received bytes", "By"},
+		{&i.bytesOut, "ike_sa.bytes_out", "Total number of transmitted bytes", "By"},
+		{&i.lastPacketInSeconds, "ike_sa.packets_in_silence", "Number of seconds since the last received packet", "s"},
+		{&i.lastPacketOutSeconds, "ike_sa.packets_out_silence", "Number of seconds since the last transmitted packet", "s"},
+		{&i.rekeyRemainingSeconds, "ike_sa.rekey_remaining", "Number of seconds until the child SA is rekeyed by time", "s"},
+	}
+	for _, g := range gauges {
+		gauge, err := meter.Float64Gauge(prefix+g.name, metric.WithDescription(g.help), metric.WithUnit(g.unit))
+		if err != nil {
+			return nil, fmt.Errorf("create ike sa instrument: %w", err)
+		}
+		*g.gauge = gauge
+	}
+	var err error
+	i.state, err = meter.Int64Gauge(prefix+"ike_sa.state", metric.WithDescription("Current state of the SA"))
+	if err != nil {
+		return nil, fmt.Errorf("create ike sa instrument: %w", err)
+	}
+	i.childSAState, err = meter.Int64Gauge(prefix+"ike_sa.child_state", metric.WithDescription("Current state of the child SA"))
+	if err != nil {
+		return nil, fmt.Errorf("create ike sa instrument: %w", err)
+	}
+	i.installs, err = meter.Int64Counter(prefix+"ike_sa.installs", metric.WithDescription("Total number of SA installs"))
+	if err != nil {
+		return nil, fmt.Errorf("create ike sa instrument: %w", err)
+	}
+	return &i, nil
+}
+
+func (i *ikeSA) IKESAStatus(ikeSAStatus strongswan.IKESAStatus) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	ctx := context.Background()
+	i.seen[ikeSAStatus.Name] = struct{}{}
+	if ikeSAStatus.State == nil {
+		i.logger.Errorf("No SA for connection configuration: %#v", ikeSAStatus.Configuration)
+		i.forget(ctx, ikeSAStatus.Name)
+		return
+	}
+	ikeSAAttributes := []attribute.KeyValue{
+		attribute.String("ike_sa_name", ikeSAStatus.Name),
+		attribute.String("local_peer_ip", ikeSAStatus.State.LocalHost),
+		attribute.String("remote_peer_ip", ikeSAStatus.State.RemoteHost),
+	}
+	i.record(i.establishedSeconds, ikeSAStatus.State.EstablishedSeconds, ikeSAAttributes)
+	recordStates(ctx, i.state, ikeSAAttributes, vici.IKESAStates, ikeSAStatus.State.State)
+	if previous, ok := i.ikeSAStates[ikeSAStatus.Name]; ok && equivalent(previous.attributes) != equivalent(ikeSAAttributes) {
+		resetStates(ctx, i.state, vici.IKESAStates, previous)
+	}
+	i.ikeSAStates[ikeSAStatus.Name] = recordedState{attributes: ikeSAAttributes, state: ikeSAStatus.State.State}
+
+	installed, ok := i.installedChildSAs[ikeSAStatus.Name]
+	if !ok {
+		installed = make(map[string]string)
+		i.installedChildSAs[ikeSAStatus.Name] = installed
+	}
+	childSAStates := make(map[attribute.Distinct]recordedState)
+	seenChildSAs := make(map[string]struct{})
+	for _, child := range ikeSAStatus.State.ChildSAs {
+		attributes := append(append([]attribute.KeyValue{}, ikeSAAttributes...),
+			attribute.String("local_ip_range", strings.Join(child.LocalTrafficSelectors, ",")),
+			attribute.String("remote_ip_range", strings.Join(child.RemoteTrafficSelectors, ",")),
+			attribute.String("child_sa_name", child.Name),
+		)
+		recordStates(ctx, i.childSAState, attributes, vici.ChildSAStates, child.State)
+		childSAStates[equivalent(attributes)] = recordedState{attributes: attributes, state: child.State}
+		i.record(i.packetsIn, child.PacketsIn, attributes)
+		i.record(i.packetsOut, child.PacketsOut, attributes)
+		i.record(i.bytesIn, child.BytesIn, attributes)
+		i.record(i.bytesOut, child.BytesOut, attributes)
+		i.record(i.lastPacketInSeconds, child.LastPacketInSeconds, attributes)
+		i.record(i.lastPacketOutSeconds, child.LastPacketOutSeconds, attributes)
+		i.record(i.rekeyRemainingSeconds, child.RekeyTimeSeconds, attributes)
+
+		seenChildSAs[child.Name] = struct{}{}
+		previousUniqueID, ok := installed[child.Name]
+		installed[child.Name] = child.UniqueID
+		if !ok || previousUniqueID != child.UniqueID {
+			i.installs.Add(ctx, 1, metric.WithAttributes(attributes...))
+		}
+	}
+	for key, previous := range i.childSAStates[ikeSAStatus.Name] {
+		if _, ok := childSAStates[key]; !ok {
+			resetStates(ctx, i.childSAState, vici.ChildSAStates, previous)
+		}
+	}
+	i.childSAStates[ikeSAStatus.Name] = childSAStates
+	for name := range installed {
+		if _, ok := seenChildSAs[name]; !ok {
+			delete(installed, name)
+		}
+	}
+}
+
+// CollectionDone resets the states of IKE SAs that were not received in the
+// collection, eg. after their configuration was unloaded.
+func (i *ikeSA) CollectionDone() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	ctx := context.Background()
+	for name := range i.ikeSAStates {
+		if _, ok := i.seen[name]; !ok {
+			i.forget(ctx, name)
+		}
+	}
+	for name := range i.installedChildSAs {
+		if _, ok := i.seen[name]; !ok {
+			i.forget(ctx, name)
+		}
+	}
+	i.seen = make(map[string]struct{})
+}
+
+// forget resets the states of the IKE SA name and its child SAs and forgets
+// its installed child SAs. i.mu must be held.
+func (i *ikeSA) forget(ctx context.Context, name string) {
+	if previous, ok := i.ikeSAStates[name]; ok {
+		resetStates(ctx, i.state, vici.IKESAStates, previous)
+	}
+	for _, previous := range i.childSAStates[name] {
+		resetStates(ctx, i.childSAState, vici.ChildSAStates, previous)
+	}
+	delete(i.ikeSAStates, name)
+	delete(i.childSAStates, name)
+	delete(i.installedChildSAs, name)
+}
+
+// recordStates records a value for each of states where the value of state is
+// 1 and all others are 0 so the previous state is reset. If state is not one of
+// the known states, it is recorded as an additional value.
+func recordStates(ctx context.Context, gauge metric.Int64Gauge, attributes []attribute.KeyValue, states []string, state string) {
+	known := false
+	for _, s := range states {
+		var value int64
+		if s == state {
+			value = 1
+			known = true
+		}
+		gauge.Record(ctx, value, metric.WithAttributes(append(attributes, attribute.String("state", s))...))
+	}
+	if !known && state != "" {
+		gauge.Record(ctx, 1, metric.WithAttributes(append(attributes, attribute.String("state", state))...))
+	}
+}
+
+// resetStates records 0 for each of states and the recorded state.
+func resetStates(ctx context.Context, gauge metric.Int64Gauge, states []string, recorded recordedState) {
+	recordStates(ctx, gauge, recorded.attributes, states, "")
+	for _, s := range states {
+		if s == recorded.state {
+			return
+		}
+	}
+	if recorded.state != "" {
+		gauge.Record(ctx, 0, metric.WithAttributes(append(recorded.attributes, attribute.String("state", recorded.state))...))
+	}
+}
+
+// equivalent returns a comparable value of the set of attributes.
+func equivalent(attributes []attribute.KeyValue) attribute.Distinct {
+	set := attribute.NewSet(attributes...)
+	return set.Equivalent()
+}
+
+// record records value on gauge if it can be parsed as a number. Empty values
+// are ignored as charon omits fields that are not applicable.
+func (i *ikeSA) record(gauge metric.Float64Gauge, value string, attributes []attribute.KeyValue) {
+	if value == "" {
+		return
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		i.logger.Errorf("otlp: failed to convert '%s' to float64: %v", value, err)
+		return
+	}
+	gauge.Record(context.Background(), f, metric.WithAttributes(attributes...))
+}
@@ -0,0 +1,156 @@
+// Package otlp implements exporting of metrics and traces with the
+// OpenTelemetry protocol (OTLP).
+package otlp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/common/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// ProtocolGRPC exports with OTLP over gRPC.
+	ProtocolGRPC = "grpc"
+	// ProtocolHTTP exports with OTLP over HTTP with protobuf payloads.
+	ProtocolHTTP = "http"
+
+	// DefaultExportInterval is the default interval between metric exports.
+	DefaultExportInterval = 10 * time.Second
+
+	instrumentationName = "github.com/lunarway/strong-duckling"
+)
+
+// Configuration specifies where and how an Exporter exports.
+//
+// Default values are set for all fields except Endpoint so they can be
+// omitted.
+type Configuration struct {
+	// Endpoint is the host and port of the OTLP receiver, eg. localhost:4317.
+	Endpoint string
+	// Protocol is the OTLP transport to use. Either ProtocolGRPC or
+	// ProtocolHTTP.
+	Protocol string
+	// Insecure disables TLS towards the receiver.
+	Insecure bool
+	// ExportInterval is the interval between metric exports.
+	ExportInterval time.Duration
+	// ServiceVersion is reported as the service.version resource attribute.
+	ServiceVersion string
+}
+
+func (c *Configuration) setDefaults() {
+	if c.Protocol == "" {
+		c.Protocol = ProtocolGRPC
+	}
+	if c.ExportInterval == 0 {
+		c.ExportInterval = DefaultExportInterval
+	}
+}
+
+// Exporter exports metrics reported through its Reporter and spans created by
+// its Tracer to an OTLP receiver.
+type Exporter struct {
+	meterProvider  *sdkmetric.MeterProvider
+	tracerProvider *sdktrace.TracerProvider
+	reporter       *Reporter
+}
+
+// New returns an Exporter exporting to the configured endpoint. Call Shutdown
+// to flush pending data before exiting.
+func New(ctx context.Context, logger log.Logger, config Configuration) (*Exporter, error) {
+	config.setDefaults()
+	metricExporter, traceExporter, err := exporters(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	res := resource.NewSchemaless(
+		attribute.String("service.name", "strong-duckling"),
+		attribute.String("service.version", config.ServiceVersion),
+	)
+	meterProvider := sdkmetric.NewMeterProvider(
+		sdkmetric.WithResource(res),
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter, sdkmetric.WithInterval(config.ExportInterval))),
+	)
+	tracerProvider := sdktrace.NewTracerProvider(
+		sdktrace.WithResource(res),
+		sdktrace.WithBatcher(traceExporter),
+	)
+	reporter, err := NewReporter(meterProvider.Meter(instrumentationName), logger)
+	if err != nil {
+		return nil, err
+	}
+	return &Exporter{
+		meterProvider:  meterProvider,
+		tracerProvider: tracerProvider,
+		reporter:       reporter,
+	}, nil
+}
+
+func exporters(ctx context.Context, config Configuration) (sdkmetric.Exporter, sdktrace.SpanExporter, error) {
+	switch config.Protocol {
+	case ProtocolGRPC:
+		metricOptions := []otlpmetricgrpc.Option{otlpmetricgrpc.WithEndpoint(config.Endpoint)}
+		traceOptions := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(config.Endpoint)}
+		if config.Insecure {
+			metricOptions = append(metricOptions, otlpmetricgrpc.WithInsecure())
+			traceOptions = append(traceOptions, otlptracegrpc.WithInsecure())
+		}
+		metricExporter, err := otlpmetricgrpc.New(ctx, metricOptions...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("create grpc metric exporter: %w", err)
+		}
+		traceExporter, err := otlptracegrpc.New(ctx, traceOptions...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("create grpc trace exporter: %w", err)
+		}
+		return metricExporter, traceExporter, nil
+	case ProtocolHTTP:
+		metricOptions := []otlpmetrichttp.Option{otlpmetrichttp.WithEndpoint(config.Endpoint)}
+		traceOptions := []otlptracehttp.Option{otlptracehttp.WithEndpoint(config.Endpoint)}
+		if config.Insecure {
+			metricOptions = append(metricOptions, otlpmetrichttp.WithInsecure())
+			traceOptions = append(traceOptions, otlptracehttp.WithInsecure())
+		}
+		metricExporter, err := otlpmetrichttp.New(ctx, metricOptions...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("create http metric exporter: %w", err)
+		}
+		traceExporter, err := otlptracehttp.New(ctx, traceOptions...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("create http trace exporter: %w", err)
+		}
+		return metricExporter, traceExporter, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown protocol '%s'", config.Protocol)
+	}
+}
+
+// Reporter returns the Reporter of measurements exported by e.
+func (e *Exporter) Reporter() *Reporter {
+	return e.reporter
+}
+
+// Tracer returns a tracer creating spans exported by e.
+func (e *Exporter) Tracer(name string) trace.Tracer {
+	return e.tracerProvider.Tracer(name)
+}
+
+// Shutdown flushes all pending metrics and spans and stops the exporter.
+func (e *Exporter) Shutdown(ctx context.Context) error {
+	return errors.Join(
+		e.meterProvider.Shutdown(ctx),
+		e.tracerProvider.Shutdown(ctx),
+	)
+}
@@ -0,0 +1,413 @@
+package otlp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lunarway/strong-duckling/internal/httpchecker"
+	"github.com/lunarway/strong-duckling/internal/icmpchecker"
+	"github.com/lunarway/strong-duckling/internal/strongswan"
+	"github.com/lunarway/strong-duckling/internal/tcpchecker"
+	"github.com/lunarway/strong-duckling/internal/test"
+	"github.com/lunarway/strong-duckling/internal/udpchecker"
+	"github.com/lunarway/strong-duckling/internal/vici"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	collectormetrics "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+var _ tcpchecker.Reporter = (&Reporter{}).TcpChecker()
+var _ tcpchecker.Reporter = (&Reporter{}).TunnelChecker("", "")
+var _ httpchecker.Reporter = (&Reporter{}).HttpChecker()
+var _ udpchecker.Reporter = (&Reporter{}).UdpChecker()
+var _ icmpchecker.Reporter = (&Reporter{}).IcmpChecker()
+var _ strongswan.IKESAStatusReceiver = (&Reporter{}).StrongSwan()
+var _ strongswan.CollectionDoneReceiver = (&Reporter{}).StrongSwan().(strongswan.CollectionDoneReceiver)
+
+// collect returns all metrics recorded with reader keyed by name.
+func collect(t *testing.T, reader sdkmetric.Reader) map[string]metricdata.Aggregation {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	err := reader.Collect(context.Background(), &rm)
+	if err != nil {
+		t.Fatalf("collect metrics: %v", err)
+	}
+	metrics := make(map[string]metricdata.Aggregation)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			metrics[m.Name] = m.Data
+		}
+	}
+	return metrics
+}
+
+func TestReporter_TcpChecker(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	r, err := NewReporter(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test"), test.NewLogger(t))
+	if !assert.NoError(t, err, "unexpected initialization error") {
+		return
+	}
+
+	report := tcpchecker.Report{
+		Name:    "partner1",
+		Address: "1.2.3.4",
+		Port:    4500,
+		Open:    true,
+	}
+	r.TcpChecker().ReportPortCheck(report)
+	r.TcpChecker().ReportPortCheck(report)
+	report.Open = false
+	r.TcpChecker().ReportPortCheck(report)
+
+	attributes := attribute.NewSet(
+		attribute.String("name", "partner1"),
+		attribute.String("address", "1.2.3.4"),
+		attribute.String("port", "4500"),
+	)
+	metrics := collect(t, reader)
+	assert.Equal(t, []metricdata.DataPoint[int64]{{Attributes: attributes, Value: 0}}, withoutTime(metrics["strong_duckling.tcp_checker.open"].(metricdata.Gauge[int64]).DataPoints), "open not as expected")
+	assert.Equal(t, []metricdata.DataPoint[int64]{{Attributes: attributes, Value: 1}}, withoutTime(metrics["strong_duckling.tcp_checker.connected"].(metricdata.Sum[int64]).DataPoints), "connected not as expected")
+	assert.Equal(t, []metricdata.DataPoint[int64]{{Attributes: attributes, Value: 1}}, withoutTime(metrics["strong_duckling.tcp_checker.disconnected"].(metricdata.Sum[int64]).DataPoints), "disconnected not as expected")
+	assert.Len(t, metrics["strong_duckling.tcp_checker.checked"].(metricdata.Sum[int64]).DataPoints, 2, "checked series not as expected")
+}
+
+func TestReporter_TunnelChecker(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	r, err := NewReporter(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test"), test.NewLogger(t))
+	if !assert.NoError(t, err, "unexpected initialization error") {
+		return
+	}
+
+	r.TunnelChecker("partner1", "net-1").ReportPortCheck(tcpchecker.Report{
+		Name:    "ssh",
+		Address: "10.2.0.1",
+		Port:    22,
+		Failure: tcpchecker.FailureTimeout,
+	})
+
+	attributes := []attribute.KeyValue{
+		attribute.String("ike_sa_name", "partner1"),
+		attribute.String("child_sa_name", "net-1"),
+		attribute.String("name", "ssh"),
+		attribute.String("address", "10.2.0.1"),
+		attribute.String("port", "22"),
+	}
+	metrics := collect(t, reader)
+	assert.Equal(t, []metricdata.DataPoint[int64]{{Attributes: attribute.NewSet(attributes...), Value: 0}}, withoutTime(metrics["strong_duckling.tunnel_checker.open"].(metricdata.Gauge[int64]).DataPoints), "open not as expected")
+	assert.Equal(t, []metricdata.DataPoint[int64]{{Attributes: attribute.NewSet(append(attributes, attribute.String("reason", "timeout"))...), Value: 1}}, withoutTime(metrics["strong_duckling.tunnel_checker.failures"].(metricdata.Sum[int64]).DataPoints), "failures not as expected")
+}
+
+func TestReporter_HttpChecker(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	r, err := NewReporter(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test"), test.NewLogger(t))
+	if !assert.NoError(t, err, "unexpected initialization error") {
+		return
+	}
+
+	expiry := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	r.HttpChecker().ReportHTTPCheck(httpchecker.Report{
+		Name:              "api",
+		Method:            "GET",
+		URL:               "https://10.0.0.1/health",
+		Healthy:           true,
+		StatusCode:        200,
+		CertificateExpiry: expiry,
+	})
+
+	attributes := attribute.NewSet(
+		attribute.String("name", "api"),
+		attribute.String("method", "GET"),
+		attribute.String("url", "https://10.0.0.1/health"),
+	)
+	metrics := collect(t, reader)
+	assert.Equal(t, []metricdata.DataPoint[int64]{{Attributes: attributes, Value: 1}}, withoutTime(metrics["strong_duckling.http_checker.healthy"].(metricdata.Gauge[int64]).DataPoints), "healthy not as expected")
+	assert.Equal(t, []metricdata.DataPoint[int64]{{Attributes: attributes, Value: 200}}, withoutTime(metrics["strong_duckling.http_checker.status_code"].(metricdata.Gauge[int64]).DataPoints), "status code not as expected")
+	assert.Equal(t, []metricdata.DataPoint[int64]{{Attributes: attributes, Value: expiry.Unix()}}, withoutTime(metrics["strong_duckling.http_checker.certificate_expiry_timestamp_seconds"].(metricdata.Gauge[int64]).DataPoints), "certificate expiry not as expected")
+}
+
+func TestReporter_UdpChecker(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	r, err := NewReporter(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test"), test.NewLogger(t))
+	if !assert.NoError(t, err, "unexpected initialization error") {
+		return
+	}
+
+	report := udpchecker.Report{
+		Name:      "ike",
+		Address:   "1.2.3.4",
+		Port:      500,
+		Reachable: true,
+	}
+	r.UdpChecker().ReportUDPCheck(report)
+	report.Reachable = false
+	report.Failure = udpchecker.FailureTimeout
+	r.UdpChecker().ReportUDPCheck(report)
+
+	attributes := []attribute.KeyValue{
+		attribute.String("name", "ike"),
+		attribute.String("address", "1.2.3.4"),
+		attribute.String("port", "500"),
+	}
+	metrics := collect(t, reader)
+	assert.Equal(t, []metricdata.DataPoint[int64]{{Attributes: attribute.NewSet(attributes...), Value: 0}}, withoutTime(metrics["strong_duckling.udp_checker.reachable"].(metricdata.Gauge[int64]).DataPoints), "reachable not as expected")
+	assert.Equal(t, []metricdata.DataPoint[int64]{{Attributes: attribute.NewSet(append(attributes, attribute.String("reason", "timeout"))...), Value: 1}}, withoutTime(metrics["strong_duckling.udp_checker.failures"].(metricdata.Sum[int64]).DataPoints), "failures not as expected")
+	assert.Len(t, metrics["strong_duckling.udp_checker.checked"].(metricdata.Sum[int64]).DataPoints, 2, "checked series not as expected")
+}
+
+func TestReporter_IcmpChecker(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	r, err := NewReporter(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test"), test.NewLogger(t))
+	if !assert.NoError(t, err, "unexpected initialization error") {
+		return
+	}
+
+	r.IcmpChecker().ReportICMPCheck(icmpchecker.Report{
+		Name:          "gw",
+		Address:       "1.2.3.4",
+		Reachable:     true,
+		RoundTripTime: 4 * time.Millisecond,
+	})
+	r.IcmpChecker().ReportICMPCheck(icmpchecker.Report{
+		Name:    "gw",
+		Address: "1.2.3.4",
+		Failure: icmpchecker.FailureTimeout,
+		Window: icmpchecker.WindowStatistics{
+			Sent:        2,
+			Received:    1,
+			LossPercent: 50,
+		},
+	})
+
+	attributes := attribute.NewSet(
+		attribute.String("name", "gw"),
+		attribute.String("address", "1.2.3.4"),
+	)
+	metrics := collect(t, reader)
+	assert.Equal(t, []metricdata.DataPoint[int64]{{Attributes: attributes, Value: 2}}, withoutTime(metrics["strong_duckling.icmp_checker.sent"].(metricdata.Sum[int64]).DataPoints), "sent not as expected")
+	assert.Equal(t, []metricdata.DataPoint[int64]{{Attributes: attributes, Value: 1}}, withoutTime(metrics["strong_duckling.icmp_checker.received"].(metricdata.Sum[int64]).DataPoints), "received not as expected")
+	assert.Equal(t, []metricdata.DataPoint[int64]{{Attributes: attributes, Value: 0}}, withoutTime(metrics["strong_duckling.icmp_checker.reachable"].(metricdata.Gauge[int64]).DataPoints), "reachable not as expected")
+	assert.Equal(t, []metricdata.DataPoint[float64]{{Attributes: attributes, Value: 50}}, withoutTime(metrics["strong_duckling.icmp_checker.loss_percent"].(metricdata.Gauge[float64]).DataPoints), "loss not as expected")
+}
+
+func TestReporter_IKESAStatus(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	r, err := NewReporter(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test"), test.NewLogger(t))
+	if !assert.NoError(t, err, "unexpected initialization error") {
+		return
+	}
+
+	status := strongswan.IKESAStatus{
+		Name: "gw-gw",
+		State: &vici.IkeSa{
+			State:              "ESTABLISHED",
+			LocalHost:          "10.0.0.1",
+			RemoteHost:         "10.0.0.2",
+			EstablishedSeconds: "42",
+			ChildSAs: map[string]vici.ChildSA{
+				"net-0-1": {
+					Name:                   "net-0",
+					UniqueID:               "1",
+					State:                  "INSTALLED",
+					LocalTrafficSelectors:  []string{"10.1.0.0/16"},
+					RemoteTrafficSelectors: []string{"10.3.0.0/16"},
+					BytesIn:                "100",
+				},
+			},
+		},
+	}
+	connecting := status
+	connecting.State = &vici.IkeSa{
+		State:      "CONNECTING",
+		LocalHost:  "10.0.0.1",
+		RemoteHost: "10.0.0.2",
+	}
+	r.StrongSwan().IKESAStatus(connecting)
+	r.StrongSwan().IKESAStatus(status)
+	r.StrongSwan().IKESAStatus(status)
+
+	ikeAttributes := []attribute.KeyValue{
+		attribute.String("ike_sa_name", "gw-gw"),
+		attribute.String("local_peer_ip", "10.0.0.1"),
+		attribute.String("remote_peer_ip", "10.0.0.2"),
+	}
+	childAttributes := attribute.NewSet(append(ikeAttributes,
+		attribute.String("local_ip_range", "10.1.0.0/16"),
+		attribute.String("remote_ip_range", "10.3.0.0/16"),
+		attribute.String("child_sa_name", "net-0"),
+	)...)
+	metrics := collect(t, reader)
+	assert.Equal(t, []metricdata.DataPoint[float64]{{Attributes: attribute.NewSet(ikeAttributes...), Value: 42}}, withoutTime(metrics["strong_duckling.ike_sa.established"].(metricdata.Gauge[float64]).DataPoints), "established not as expected")
+	assert.Equal(t, []metricdata.DataPoint[float64]{{Attributes: childAttributes, Value: 100}}, withoutTime(metrics["strong_duckling.ike_sa.bytes_in"].(metricdata.Gauge[float64]).DataPoints), "bytes in not as expected")
+	assert.Equal(t, []metricdata.DataPoint[int64]{{Attributes: childAttributes, Value: 1}}, withoutTime(metrics["strong_duckling.ike_sa.installs"].(metricdata.Sum[int64]).DataPoints), "installs not as expected")
+	assert.NotContains(t, metrics, "strong_duckling.ike_sa.packets_in", "unset counters must not be recorded")
+
+	states := make(map[string]int64)
+	for _, dp := range metrics["strong_duckling.ike_sa.state"].(metricdata.Gauge[int64]).DataPoints {
+		state, _ := dp.Attributes.Value("state")
+		states[state.AsString()] = dp.Value
+	}
+	assert.Len(t, states, len(vici.IKESAStates), "states not as expected")
+	assert.Equal(t, int64(1), states["ESTABLISHED"], "current state not 1")
+	assert.Equal(t, int64(0), states["CONNECTING"], "previous state not reset")
+}
+
+func TestReporter_IKESAStatus_gone(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	r, err := NewReporter(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test"), test.NewLogger(t))
+	if !assert.NoError(t, err, "unexpected initialization error") {
+		return
+	}
+	receiver := r.StrongSwan()
+	status := strongswan.IKESAStatus{
+		Name: "gw-gw",
+		State: &vici.IkeSa{
+			State:      "ESTABLISHED",
+			LocalHost:  "10.0.0.1",
+			RemoteHost: "10.0.0.2",
+			ChildSAs: map[string]vici.ChildSA{
+				"net-0-1": {Name: "net-0", UniqueID: "1", State: "INSTALLED"},
+			},
+		},
+	}
+	// states returns the sum of the values of the states of name.
+	states := func(name string) int64 {
+		var sum int64
+		for _, dp := range collect(t, reader)[name].(metricdata.Gauge[int64]).DataPoints {
+			sum += dp.Value
+		}
+		return sum
+	}
+
+	receiver.IKESAStatus(status)
+	receiver.(strongswan.CollectionDoneReceiver).CollectionDone()
+	assert.Equal(t, int64(1), states("strong_duckling.ike_sa.state"), "IKE SA state not recorded")
+	assert.Equal(t, int64(1), states("strong_duckling.ike_sa.child_state"), "child SA state not recorded")
+
+	// the IKE SA goes down
+	receiver.IKESAStatus(strongswan.IKESAStatus{Name: "gw-gw"})
+	receiver.(strongswan.CollectionDoneReceiver).CollectionDone()
+	assert.Equal(t, int64(0), states("strong_duckling.ike_sa.state"), "state of down IKE SA not reset")
+	assert.Equal(t, int64(0), states("strong_duckling.ike_sa.child_state"), "state of child SA of down IKE SA not reset")
+
+	// the child SA is installed again after the IKE SA came up
+	receiver.IKESAStatus(status)
+	receiver.(strongswan.CollectionDoneReceiver).CollectionDone()
+	installs := collect(t, reader)["strong_duckling.ike_sa.installs"].(metricdata.Sum[int64]).DataPoints
+	if assert.Len(t, installs, 1, "installs not as expected") {
+		assert.Equal(t, int64(2), installs[0].Value, "install not counted")
+	}
+
+	// the connection is unloaded and its IKE SA torn down
+	receiver.(strongswan.CollectionDoneReceiver).CollectionDone()
+	assert.Equal(t, int64(0), states("strong_duckling.ike_sa.state"), "state of gone IKE SA not reset")
+	assert.Equal(t, int64(0), states("strong_duckling.ike_sa.child_state"), "state of child SA of gone IKE SA not reset")
+}
+
+func withoutTime[N int64 | float64](dataPoints []metricdata.DataPoint[N]) []metricdata.DataPoint[N] {
+	var result []metricdata.DataPoint[N]
+	for _, dp := range dataPoints {
+		result = append(result, metricdata.DataPoint[N]{
+			Attributes: dp.Attributes,
+			Value:      dp.Value,
+		})
+	}
+	return result
+}
+
+// receiver is an in-memory OTLP/HTTP receiver recording exported metric names
+// and span names.
+type receiver struct {
+	mu      sync.Mutex
+	metrics []string
+	spans   []string
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	switch r.URL.Path {
+	case "/v1/metrics":
+		var req collectormetrics.ExportMetricsServiceRequest
+		if err := proto.Unmarshal(body, &req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		for _, rm := range req.ResourceMetrics {
+			for _, sm := range rm.ScopeMetrics {
+				for _, m := range sm.Metrics {
+					rc.metrics = append(rc.metrics, m.Name)
+				}
+			}
+		}
+		resp, _ := proto.Marshal(&collectormetrics.ExportMetricsServiceResponse{})
+		w.Write(resp)
+	case "/v1/traces":
+		var req collectortrace.ExportTraceServiceRequest
+		if err := proto.Unmarshal(body, &req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		for _, rs := range req.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				for _, s := range ss.Spans {
+					rc.spans = append(rc.spans, s.Name)
+				}
+			}
+		}
+		resp, _ := proto.Marshal(&collectortrace.ExportTraceServiceResponse{})
+		w.Write(resp)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestExporter_http(t *testing.T) {
+	rc := &receiver{}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	e, err := New(context.Background(), test.NewLogger(t), Configuration{
+		Endpoint:       strings.TrimPrefix(server.URL, "http://"),
+		Protocol:       ProtocolHTTP,
+		Insecure:       true,
+		ServiceVersion: "1.0.0",
+	})
+	if !assert.NoError(t, err, "unexpected initialization error") {
+		return
+	}
+	e.Reporter().Info("1.0.0")
+	_, span := e.Tracer("test").Start(context.Background(), "initiate")
+	span.End()
+
+	err = e.Shutdown(context.Background())
+	assert.NoError(t, err, "unexpected shutdown error")
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	assert.Contains(t, rc.metrics, "strong_duckling.info", "exported metrics not as expected")
+	assert.Equal(t, []string{"initiate"}, rc.spans, "exported spans not as expected")
+}
+
+func TestNew_unknownProtocol(t *testing.T) {
+	_, err := New(context.Background(), test.NewLogger(t), Configuration{
+		Endpoint: "localhost:4317",
+		Protocol: "udp",
+	})
+	assert.EqualError(t, err, "unknown protocol 'udp'", "error not as expected")
+}
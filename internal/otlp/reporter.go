@@ -0,0 +1,169 @@
+package otlp
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	daemonpkg "github.com/lunarway/strong-duckling/internal/daemon"
+	"github.com/lunarway/strong-duckling/internal/httpchecker"
+	"github.com/lunarway/strong-duckling/internal/icmpchecker"
+	"github.com/lunarway/strong-duckling/internal/strongswan"
+	"github.com/lunarway/strong-duckling/internal/tcpchecker"
+	"github.com/lunarway/strong-duckling/internal/udpchecker"
+	"github.com/prometheus/common/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	prefix = "strong_duckling."
+)
+
+// Reporter records measurements as OpenTelemetry metric instruments.
+type Reporter struct {
+	logger log.Logger
+
+	version       metric.Int64Gauge
+	tcpChecker    *tcpChecker
+	tunnelChecker *tunnelChecker
+	httpChecker   *httpChecker
+	udpChecker    *udpChecker
+	icmpChecker   *icmpChecker
+	ikeSA         *ikeSA
+	daemon        *daemon
+}
+
+// NewReporter returns a Reporter creating its instruments with meter.
+func NewReporter(meter metric.Meter, logger log.Logger) (*Reporter, error) {
+	version, err := meter.Int64Gauge(prefix+"info", metric.WithDescription("Version info of strong_duckling"))
+	if err != nil {
+		return nil, fmt.Errorf("create info instrument: %w", err)
+	}
+	tcpChecker, err := newTcpChecker(meter)
+	if err != nil {
+		return nil, err
+	}
+	tunnelChecker, err := newTunnelChecker(meter)
+	if err != nil {
+		return nil, err
+	}
+	httpChecker, err := newHttpChecker(meter)
+	if err != nil {
+		return nil, err
+	}
+	udpChecker, err := newUdpChecker(meter)
+	if err != nil {
+		return nil, err
+	}
+	icmpChecker, err := newIcmpChecker(meter)
+	if err != nil {
+		return nil, err
+	}
+	ikeSA, err := newIkeSA(meter, logger)
+	if err != nil {
+		return nil, err
+	}
+	daemon, err := newDaemon(meter)
+	if err != nil {
+		return nil, err
+	}
+	return &Reporter{
+		logger:        logger,
+		version:       version,
+		tcpChecker:    tcpChecker,
+		tunnelChecker: tunnelChecker,
+		httpChecker:   httpChecker,
+		udpChecker:    udpChecker,
+		icmpChecker:   icmpChecker,
+		ikeSA:         ikeSA,
+		daemon:        daemon,
+	}, nil
+}
+
+func (r *Reporter) TcpChecker() tcpchecker.Reporter {
+	return r.tcpChecker
+}
+
+// TunnelChecker returns a reporter of checks through the child SA childSAName
+// of the IKE SA ikeSAName.
+func (r *Reporter) TunnelChecker(ikeSAName, childSAName string) tcpchecker.Reporter {
+	return &tunnelCheckerReporter{
+		tunnelChecker: r.tunnelChecker,
+		ikeSAName:     ikeSAName,
+		childSAName:   childSAName,
+	}
+}
+
+func (r *Reporter) HttpChecker() httpchecker.Reporter {
+	return r.httpChecker
+}
+
+func (r *Reporter) UdpChecker() udpchecker.Reporter {
+	return r.udpChecker
+}
+
+func (r *Reporter) IcmpChecker() icmpchecker.Reporter {
+	return r.icmpChecker
+}
+
+func (r *Reporter) StrongSwan() strongswan.IKESAStatusReceiver {
+	return r.ikeSA
+}
+
+// Daemon returns a daemon reporter counting life cycle events. It does not log
+// the events so it can be combined with other reporters that do.
+func (r *Reporter) Daemon(name string) *daemonpkg.Reporter {
+	return r.daemon.reporter(name)
+}
+
+func (r *Reporter) Info(strongDucklingVersion string) {
+	r.version.Record(context.Background(), 1, metric.WithAttributes(attribute.String("version", strongDucklingVersion)))
+}
+
+type daemon struct {
+	started metric.Int64Counter
+	stopped metric.Int64Counter
+	skipped metric.Int64Counter
+	ticked  metric.Int64Counter
+}
+
+func newDaemon(meter metric.Meter) (*daemon, error) {
+	var d daemon
+	var err error
+	d.started, err = meter.Int64Counter(prefix+"daemon.starts", metric.WithDescription("Total number of times started"))
+	if err != nil {
+		return nil, fmt.Errorf("create daemon instrument: %w", err)
+	}
+	d.stopped, err = meter.Int64Counter(prefix+"daemon.stops", metric.WithDescription("Total number of times stopped"))
+	if err != nil {
+		return nil, fmt.Errorf("create daemon instrument: %w", err)
+	}
+	d.skipped, err = meter.Int64Counter(prefix+"daemon.skips", metric.WithDescription("Total number of times tick was skipped"))
+	if err != nil {
+		return nil, fmt.Errorf("create daemon instrument: %w", err)
+	}
+	d.ticked, err = meter.Int64Counter(prefix+"daemon.ticks", metric.WithDescription("Total number of times tick was invoked"))
+	if err != nil {
+		return nil, fmt.Errorf("create daemon instrument: %w", err)
+	}
+	return &d, nil
+}
+
+func (d *daemon) reporter(name string) *daemonpkg.Reporter {
+	nameAttribute := metric.WithAttributes(attribute.String("name", name))
+	return &daemonpkg.Reporter{
+		Started: func(duration time.Duration) {
+			d.started.Add(context.Background(), 1, metric.WithAttributes(attribute.String("name", name), attribute.String("interval", duration.String())))
+		},
+		Stopped: func() {
+			d.stopped.Add(context.Background(), 1, nameAttribute)
+		},
+		Skipped: func() {
+			d.skipped.Add(context.Background(), 1, nameAttribute)
+		},
+		Ticked: func() {
+			d.ticked.Add(context.Background(), 1, nameAttribute)
+		},
+	}
+}
@@ -0,0 +1,98 @@
+package otlp
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/lunarway/strong-duckling/internal/tcpchecker"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+type tcpChecker struct {
+	checks       metric.Int64Counter
+	open         metric.Int64Gauge
+	connected    metric.Int64Counter
+	disconnected metric.Int64Counter
+	failures     metric.Int64Counter
+
+	connectDuration   metric.Float64Histogram
+	firstByteDuration metric.Float64Histogram
+
+	mu sync.Mutex
+	// previousOpenState holds the last open state of each checked target keyed
+	// by name.
+	previousOpenState map[string]bool
+}
+
+func newTcpChecker(meter metric.Meter) (*tcpChecker, error) {
+	tc := tcpChecker{
+		previousOpenState: make(map[string]bool),
+	}
+	var err error
+	tc.checks, err = meter.Int64Counter(prefix+"tcp_checker.checked", metric.WithDescription("Total number of times the connection has been checked"))
+	if err != nil {
+		return nil, fmt.Errorf("create tcp checker instrument: %w", err)
+	}
+	tc.open, err = meter.Int64Gauge(prefix+"tcp_checker.open", metric.WithDescription("Is TCP open is 1 otherwise 0"))
+	if err != nil {
+		return nil, fmt.Errorf("create tcp checker instrument: %w", err)
+	}
+	tc.connected, err = meter.Int64Counter(prefix+"tcp_checker.connected", metric.WithDescription("Total number of times connection to TCP address:port was established"))
+	if err != nil {
+		return nil, fmt.Errorf("create tcp checker instrument: %w", err)
+	}
+	tc.disconnected, err = meter.Int64Counter(prefix+"tcp_checker.disconnected", metric.WithDescription("Total number of times connection to TCP address:port was lost"))
+	if err != nil {
+		return nil, fmt.Errorf("create tcp checker instrument: %w", err)
+	}
+	tc.failures, err = meter.Int64Counter(prefix+"tcp_checker.failures", metric.WithDescription("Total number of failed checks by reason"))
+	if err != nil {
+		return nil, fmt.Errorf("create tcp checker instrument: %w", err)
+	}
+	tc.connectDuration, err = meter.Float64Histogram(prefix+"tcp_checker.connect_duration", metric.WithDescription("Duration of establishing TCP connections including failed attempts"), metric.WithUnit("s"))
+	if err != nil {
+		return nil, fmt.Errorf("create tcp checker instrument: %w", err)
+	}
+	tc.firstByteDuration, err = meter.Float64Histogram(prefix+"tcp_checker.first_byte_duration", metric.WithDescription("Duration from a connection is established until the first byte is received"), metric.WithUnit("s"))
+	if err != nil {
+		return nil, fmt.Errorf("create tcp checker instrument: %w", err)
+	}
+	return &tc, nil
+}
+
+func (tc *tcpChecker) ReportPortCheck(report tcpchecker.Report) {
+	ctx := context.Background()
+	attributes := []attribute.KeyValue{
+		attribute.String("name", report.Name),
+		attribute.String("address", report.Address),
+		attribute.String("port", fmt.Sprintf("%d", report.Port)),
+	}
+	tc.checks.Add(ctx, 1, metric.WithAttributes(append(attributes, attribute.Bool("open", report.Open))...))
+	var open int64
+	if report.Open {
+		open = 1
+	}
+	tc.open.Record(ctx, open, metric.WithAttributes(attributes...))
+	tc.connectDuration.Record(ctx, report.ConnectDuration.Seconds(), metric.WithAttributes(attributes...))
+	if report.FirstByteDuration > 0 {
+		tc.firstByteDuration.Record(ctx, report.FirstByteDuration.Seconds(), metric.WithAttributes(attributes...))
+	}
+	if reason := report.Reason(); reason != "" {
+		tc.failures.Add(ctx, 1, metric.WithAttributes(append(attributes, attribute.String("reason", string(reason)))...))
+	}
+
+	tc.mu.Lock()
+	previousOpen, ok := tc.previousOpenState[report.Name]
+	tc.previousOpenState[report.Name] = report.Open
+	tc.mu.Unlock()
+	if ok && previousOpen == report.Open {
+		return
+	}
+	if report.Open {
+		tc.connected.Add(ctx, 1, metric.WithAttributes(attributes...))
+	} else {
+		tc.disconnected.Add(ctx, 1, metric.WithAttributes(attributes...))
+	}
+}
@@ -0,0 +1,70 @@
+package otlp
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lunarway/strong-duckling/internal/tcpchecker"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// tunnelChecker records TCP checks derived from child SA traffic selectors
+// with the owning IKE and child SA as attributes.
+type tunnelChecker struct {
+	checks          metric.Int64Counter
+	open            metric.Int64Gauge
+	failures        metric.Int64Counter
+	connectDuration metric.Float64Histogram
+}
+
+func newTunnelChecker(meter metric.Meter) (*tunnelChecker, error) {
+	var tc tunnelChecker
+	var err error
+	tc.checks, err = meter.Int64Counter(prefix+"tunnel_checker.checked", metric.WithDescription("Total number of times the connection through the child SA has been checked"))
+	if err != nil {
+		return nil, fmt.Errorf("create tunnel checker instrument: %w", err)
+	}
+	tc.open, err = meter.Int64Gauge(prefix+"tunnel_checker.open", metric.WithDescription("Is TCP open through the child SA is 1 otherwise 0"))
+	if err != nil {
+		return nil, fmt.Errorf("create tunnel checker instrument: %w", err)
+	}
+	tc.failures, err = meter.Int64Counter(prefix+"tunnel_checker.failures", metric.WithDescription("Total number of failed checks through the child SA by reason"))
+	if err != nil {
+		return nil, fmt.Errorf("create tunnel checker instrument: %w", err)
+	}
+	tc.connectDuration, err = meter.Float64Histogram(prefix+"tunnel_checker.connect_duration", metric.WithDescription("Duration of establishing TCP connections through the child SA including failed attempts"), metric.WithUnit("s"))
+	if err != nil {
+		return nil, fmt.Errorf("create tunnel checker instrument: %w", err)
+	}
+	return &tc, nil
+}
+
+// tunnelCheckerReporter records tcp checks of a single child SA.
+type tunnelCheckerReporter struct {
+	tunnelChecker *tunnelChecker
+	ikeSAName     string
+	childSAName   string
+}
+
+func (r *tunnelCheckerReporter) ReportPortCheck(report tcpchecker.Report) {
+	ctx := context.Background()
+	tc := r.tunnelChecker
+	attributes := []attribute.KeyValue{
+		attribute.String("ike_sa_name", r.ikeSAName),
+		attribute.String("child_sa_name", r.childSAName),
+		attribute.String("name", report.Name),
+		attribute.String("address", report.Address),
+		attribute.String("port", fmt.Sprintf("%d", report.Port)),
+	}
+	tc.checks.Add(ctx, 1, metric.WithAttributes(append(attributes, attribute.Bool("open", report.Open))...))
+	var open int64
+	if report.Open {
+		open = 1
+	}
+	tc.open.Record(ctx, open, metric.WithAttributes(attributes...))
+	tc.connectDuration.Record(ctx, report.ConnectDuration.Seconds(), metric.WithAttributes(attributes...))
+	if reason := report.Reason(); reason != "" {
+		tc.failures.Add(ctx, 1, metric.WithAttributes(append(attributes, attribute.String("reason", string(reason)))...))
+	}
+}
@@ -0,0 +1,58 @@
+package otlp
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lunarway/strong-duckling/internal/udpchecker"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+type udpChecker struct {
+	checks        metric.Int64Counter
+	reachable     metric.Int64Gauge
+	failures      metric.Int64Counter
+	roundTripTime metric.Float64Histogram
+}
+
+func newUdpChecker(meter metric.Meter) (*udpChecker, error) {
+	var uc udpChecker
+	var err error
+	uc.checks, err = meter.Int64Counter(prefix+"udp_checker.checked", metric.WithDescription("Total number of times the peer has been checked"))
+	if err != nil {
+		return nil, fmt.Errorf("create udp checker instrument: %w", err)
+	}
+	uc.reachable, err = meter.Int64Gauge(prefix+"udp_checker.reachable", metric.WithDescription("Is the peer replying as expected 1 otherwise 0"))
+	if err != nil {
+		return nil, fmt.Errorf("create udp checker instrument: %w", err)
+	}
+	uc.failures, err = meter.Int64Counter(prefix+"udp_checker.failures", metric.WithDescription("Total number of failed checks by reason"))
+	if err != nil {
+		return nil, fmt.Errorf("create udp checker instrument: %w", err)
+	}
+	uc.roundTripTime, err = meter.Float64Histogram(prefix+"udp_checker.round_trip", metric.WithDescription("Round trip time of replies"), metric.WithUnit("s"))
+	if err != nil {
+		return nil, fmt.Errorf("create udp checker instrument: %w", err)
+	}
+	return &uc, nil
+}
+
+func (uc *udpChecker) ReportUDPCheck(report udpchecker.Report) {
+	ctx := context.Background()
+	attributes := []attribute.KeyValue{
+		attribute.String("name", report.Name),
+		attribute.String("address", report.Address),
+		attribute.String("port", fmt.Sprintf("%d", report.Port)),
+	}
+	uc.checks.Add(ctx, 1, metric.WithAttributes(append(attributes, attribute.Bool("reachable", report.Reachable))...))
+	if report.Reachable {
+		uc.reachable.Record(ctx, 1, metric.WithAttributes(attributes...))
+	} else {
+		uc.reachable.Record(ctx, 0, metric.WithAttributes(attributes...))
+		uc.failures.Add(ctx, 1, metric.WithAttributes(append(attributes, attribute.String("reason", string(report.Failure)))...))
+	}
+	if report.RoundTripTime > 0 {
+		uc.roundTripTime.Record(ctx, report.RoundTripTime.Seconds(), metric.WithAttributes(attributes...))
+	}
+}
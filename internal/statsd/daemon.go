@@ -0,0 +1,27 @@
+package statsd
+
+import (
+	"time"
+
+	daemonpkg "github.com/lunarway/strong-duckling/internal/daemon"
+)
+
+// newDaemonReporter returns a daemon reporter counting life cycle events. It
+// does not log the events so it can be combined with other reporters that do.
+func newDaemonReporter(r *Reporter, name string) *daemonpkg.Reporter {
+	nameTag := tag("name", name)
+	return &daemonpkg.Reporter{
+		Started: func(duration time.Duration) {
+			r.count("daemon.starts", 1, nameTag, tag("interval", duration.String()))
+		},
+		Stopped: func() {
+			r.count("daemon.stops", 1, nameTag)
+		},
+		Skipped: func() {
+			r.count("daemon.skips", 1, nameTag)
+		},
+		Ticked: func() {
+			r.count("daemon.ticks", 1, nameTag)
+		},
+	}
+}
@@ -0,0 +1,34 @@
+package statsd
+
+import (
+	"strconv"
+
+	"github.com/lunarway/strong-duckling/internal/httpchecker"
+)
+
+type httpChecker struct {
+	reporter *Reporter
+}
+
+func (hc *httpChecker) ReportHTTPCheck(report httpchecker.Report) {
+	tags := []string{
+		tag("name", report.Name),
+		tag("method", report.Method),
+		tag("url", report.URL),
+	}
+	hc.reporter.count("http_checker.checked", 1, append(tags, tag("healthy", strconv.FormatBool(report.Healthy)))...)
+	if report.Healthy {
+		hc.reporter.gauge("http_checker.healthy", 1, tags...)
+	} else {
+		hc.reporter.gauge("http_checker.healthy", 0, tags...)
+		hc.reporter.count("http_checker.failures", 1, append(tags, tag("reason", string(report.Failure)))...)
+	}
+	hc.reporter.gauge("http_checker.status_code", float64(report.StatusCode), tags...)
+	hc.reporter.timing("http_checker.response_duration", report.ResponseDuration, tags...)
+	if report.TLSHandshakeDuration > 0 {
+		hc.reporter.timing("http_checker.tls_handshake_duration", report.TLSHandshakeDuration, tags...)
+	}
+	if !report.CertificateExpiry.IsZero() {
+		hc.reporter.gauge("http_checker.certificate_expiry_timestamp_seconds", float64(report.CertificateExpiry.Unix()), tags...)
+	}
+}
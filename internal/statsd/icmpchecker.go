@@ -0,0 +1,28 @@
+package statsd
+
+import (
+	"github.com/lunarway/strong-duckling/internal/icmpchecker"
+)
+
+type icmpChecker struct {
+	reporter *Reporter
+}
+
+func (ic *icmpChecker) ReportICMPCheck(report icmpchecker.Report) {
+	tags := []string{
+		tag("name", report.Name),
+		tag("address", report.Address),
+	}
+	ic.reporter.count("icmp_checker.sent", 1, tags...)
+	if report.Reachable {
+		ic.reporter.count("icmp_checker.received", 1, tags...)
+		ic.reporter.gauge("icmp_checker.reachable", 1, tags...)
+		ic.reporter.timing("icmp_checker.round_trip", report.RoundTripTime, tags...)
+	} else {
+		ic.reporter.gauge("icmp_checker.reachable", 0, tags...)
+		ic.reporter.count("icmp_checker.failures", 1, append(tags, tag("reason", string(report.Failure)))...)
+	}
+	ic.reporter.gauge("icmp_checker.loss_percent", report.Window.LossPercent, tags...)
+	ic.reporter.gauge("icmp_checker.round_trip_average_seconds", report.Window.AverageRoundTripTime.Seconds(), tags...)
+	ic.reporter.gauge("icmp_checker.jitter_seconds", report.Window.Jitter.Seconds(), tags...)
+}
@@ -0,0 +1,192 @@
+package statsd
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/lunarway/strong-duckling/internal/strongswan"
+	"github.com/lunarway/strong-duckling/internal/vici"
+	"github.com/prometheus/common/log"
+)
+
+var _ strongswan.CollectionDoneReceiver = &ikeSA{}
+
+type ikeSA struct {
+	reporter *Reporter
+	logger   log.Logger
+
+	mu sync.Mutex
+	// installedChildSAs holds the unique ID of the last seen child SA by IKE SA
+	// and child SA name. A new unique ID indicates a new install.
+	installedChildSAs map[string]map[string]string
+	// ikeSAStates holds the last reported state of each IKE SA name.
+	ikeSAStates map[string]reportedState
+	// childSAStates holds the last reported states of child SAs by IKE SA name
+	// and joined tags.
+	childSAStates map[string]map[string]reportedState
+	// seen holds the IKE SA names received in the current collection.
+	seen map[string]struct{}
+}
+
+// reportedState is a state reported with tags. Its gauges are reset to 0 when
+// the SA is gone as StatsD backends keep the last value of a gauge.
+type reportedState struct {
+	tags  []string
+	state string
+}
+
+func newIkeSA(r *Reporter, logger log.Logger) *ikeSA {
+	return &ikeSA{
+		reporter:          r,
+		logger:            logger,
+		installedChildSAs: make(map[string]map[string]string),
+		ikeSAStates:       make(map[string]reportedState),
+		childSAStates:     make(map[string]map[string]reportedState),
+		seen:              make(map[string]struct{}),
+	}
+}
+
+func (i *ikeSA) IKESAStatus(ikeSAStatus strongswan.IKESAStatus) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.seen[ikeSAStatus.Name] = struct{}{}
+	if ikeSAStatus.State == nil {
+		i.logger.Errorf("No SA for connection configuration: %#v", ikeSAStatus.Configuration)
+		i.forget(ikeSAStatus.Name)
+		return
+	}
+	ikeSATags := []string{
+		tag("ike_sa_name", ikeSAStatus.Name),
+		tag("local_peer_ip", ikeSAStatus.State.LocalHost),
+		tag("remote_peer_ip", ikeSAStatus.State.RemoteHost),
+	}
+	i.gauge("ike_sa.established_seconds", ikeSAStatus.State.EstablishedSeconds, ikeSATags)
+	i.states("ike_sa.state", ikeSATags, vici.IKESAStates, ikeSAStatus.State.State)
+	if previous, ok := i.ikeSAStates[ikeSAStatus.Name]; ok && strings.Join(previous.tags, ",") != strings.Join(ikeSATags, ",") {
+		i.resetStates("ike_sa.state", vici.IKESAStates, previous)
+	}
+	i.ikeSAStates[ikeSAStatus.Name] = reportedState{tags: ikeSATags, state: ikeSAStatus.State.State}
+
+	installed, ok := i.installedChildSAs[ikeSAStatus.Name]
+	if !ok {
+		installed = make(map[string]string)
+		i.installedChildSAs[ikeSAStatus.Name] = installed
+	}
+	childSAStates := make(map[string]reportedState)
+	seenChildSAs := make(map[string]struct{})
+	for _, child := range ikeSAStatus.State.ChildSAs {
+		tags := append(append([]string{}, ikeSATags...),
+			tag("local_ip_range", strings.Join(child.LocalTrafficSelectors, ",")),
+			tag("remote_ip_range", strings.Join(child.RemoteTrafficSelectors, ",")),
+			tag("child_sa_name", child.Name),
+		)
+		i.states("ike_sa.child_state", tags, vici.ChildSAStates, child.State)
+		childSAStates[strings.Join(tags, ",")] = reportedState{tags: tags, state: child.State}
+		i.gauge("ike_sa.packets_in_total", child.PacketsIn, tags)
+		i.gauge("ike_sa.packets_out_total", child.PacketsOut, tags)
+		i.gauge("ike_sa.bytes_in_total", child.BytesIn, tags)
+		i.gauge("ike_sa.bytes_out_total", child.BytesOut, tags)
+		i.gauge("ike_sa.packets_in_silence_seconds", child.LastPacketInSeconds, tags)
+		i.gauge("ike_sa.packets_out_silence_seconds", child.LastPacketOutSeconds, tags)
+		i.gauge("ike_sa.rekey_remaining_seconds", child.RekeyTimeSeconds, tags)
+		i.gauge("ike_sa.lifetime_remaining_seconds", child.LifeTimeSeconds, tags)
+		i.gauge("ike_sa.install_seconds", child.InstallTimeSeconds, tags)
+
+		seenChildSAs[child.Name] = struct{}{}
+		previousUniqueID, ok := installed[child.Name]
+		installed[child.Name] = child.UniqueID
+		if !ok || previousUniqueID != child.UniqueID {
+			i.reporter.count("ike_sa.installs", 1, tags...)
+		}
+	}
+	for key, previous := range i.childSAStates[ikeSAStatus.Name] {
+		if _, ok := childSAStates[key]; !ok {
+			i.resetStates("ike_sa.child_state", vici.ChildSAStates, previous)
+		}
+	}
+	i.childSAStates[ikeSAStatus.Name] = childSAStates
+	for name := range installed {
+		if _, ok := seenChildSAs[name]; !ok {
+			delete(installed, name)
+		}
+	}
+}
+
+// CollectionDone resets the states of IKE SAs that were not received in the
+// collection, eg. after their configuration was unloaded.
+func (i *ikeSA) CollectionDone() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for name := range i.ikeSAStates {
+		if _, ok := i.seen[name]; !ok {
+			i.forget(name)
+		}
+	}
+	for name := range i.installedChildSAs {
+		if _, ok := i.seen[name]; !ok {
+			i.forget(name)
+		}
+	}
+	i.seen = make(map[string]struct{})
+}
+
+// forget resets the states of the IKE SA name and its child SAs and forgets
+// its installed child SAs. i.mu must be held.
+func (i *ikeSA) forget(name string) {
+	if previous, ok := i.ikeSAStates[name]; ok {
+		i.resetStates("ike_sa.state", vici.IKESAStates, previous)
+	}
+	for _, previous := range i.childSAStates[name] {
+		i.resetStates("ike_sa.child_state", vici.ChildSAStates, previous)
+	}
+	delete(i.ikeSAStates, name)
+	delete(i.childSAStates, name)
+	delete(i.installedChildSAs, name)
+}
+
+// states reports a gauge for each of states where the gauge of state is 1 and
+// all others are 0 so the previous state is reset. If state is not one of the
+// known states, it is reported as an additional gauge.
+func (i *ikeSA) states(name string, tags []string, states []string, state string) {
+	known := false
+	for _, s := range states {
+		value := 0.0
+		if s == state {
+			value = 1
+			known = true
+		}
+		i.reporter.gauge(name, value, append(tags, tag("state", s))...)
+	}
+	if !known && state != "" {
+		i.reporter.gauge(name, 1, append(tags, tag("state", state))...)
+	}
+}
+
+// resetStates reports 0 for each of states and the reported state.
+func (i *ikeSA) resetStates(name string, states []string, reported reportedState) {
+	i.states(name, reported.tags, states, "")
+	known := false
+	for _, s := range states {
+		if s == reported.state {
+			known = true
+		}
+	}
+	if !known && reported.state != "" {
+		i.reporter.gauge(name, 0, append(reported.tags, tag("state", reported.state))...)
+	}
+}
+
+// gauge reports value as a gauge if it can be parsed as a number. Empty values
+// are ignored as charon omits fields that are not applicable.
+func (i *ikeSA) gauge(name, value string, tags []string) {
+	if value == "" {
+		return
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		i.logger.Errorf("statsd: failed to convert %s '%s' to float64: %v", name, value, err)
+		return
+	}
+	i.reporter.gauge(name, f, tags...)
+}
@@ -0,0 +1,215 @@
+// Package statsd implements a reporter pushing metrics over UDP in the StatsD
+// format with DogStatsD tags.
+package statsd
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	daemonpkg "github.com/lunarway/strong-duckling/internal/daemon"
+	"github.com/lunarway/strong-duckling/internal/httpchecker"
+	"github.com/lunarway/strong-duckling/internal/icmpchecker"
+	"github.com/lunarway/strong-duckling/internal/strongswan"
+	"github.com/lunarway/strong-duckling/internal/tcpchecker"
+	"github.com/lunarway/strong-duckling/internal/udpchecker"
+	"github.com/prometheus/common/log"
+)
+
+const (
+	// DefaultPrefix is prepended to all metric names.
+	DefaultPrefix = "strong_duckling."
+	// DefaultFlushInterval is the default interval between flushes of buffered
+	// metrics.
+	DefaultFlushInterval = 1 * time.Second
+	// DefaultMaxPacketSize is the default maximum size of a UDP packet. It fits
+	// within the common Ethernet MTU after IP and UDP headers.
+	DefaultMaxPacketSize = 1432
+)
+
+// Configuration specifies how a Reporter sends metrics.
+//
+// Default values are set for all fields except Address so they can be
+// omitted.
+type Configuration struct {
+	// Address is the UDP address of the StatsD server, eg. localhost:8125.
+	Address string
+	// Prefix is prepended to all metric names.
+	Prefix string
+	// Tags are added to all metrics. Supports <name>:<value> and <name>.
+	Tags []string
+	// FlushInterval is the interval between flushes of buffered metrics.
+	FlushInterval time.Duration
+	// MaxPacketSize is the maximum number of bytes sent in a single packet.
+	MaxPacketSize int
+}
+
+func (c *Configuration) setDefaults() {
+	if c.Prefix == "" {
+		c.Prefix = DefaultPrefix
+	}
+	if c.FlushInterval == 0 {
+		c.FlushInterval = DefaultFlushInterval
+	}
+	if c.MaxPacketSize == 0 {
+		c.MaxPacketSize = DefaultMaxPacketSize
+	}
+}
+
+// Reporter buffers metrics and sends them in batches to a StatsD server. Call
+// Flush to send buffered metrics, eg. from a daemon.Daemon with the interval
+// returned by FlushInterval.
+type Reporter struct {
+	config Configuration
+	logger log.Logger
+	conn   net.Conn
+
+	mu     sync.Mutex
+	buffer bytes.Buffer
+
+	tcpChecker  *tcpChecker
+	httpChecker *httpChecker
+	udpChecker  *udpChecker
+	icmpChecker *icmpChecker
+	ikeSA       *ikeSA
+}
+
+// New returns a Reporter sending metrics to the configured address.
+func New(logger log.Logger, config Configuration) (*Reporter, error) {
+	config.setDefaults()
+	conn, err := net.Dial("udp", config.Address)
+	if err != nil {
+		return nil, fmt.Errorf("dial statsd address: %w", err)
+	}
+	r := &Reporter{
+		config: config,
+		logger: logger,
+		conn:   conn,
+	}
+	r.tcpChecker = newTcpChecker(r)
+	r.httpChecker = &httpChecker{reporter: r}
+	r.udpChecker = &udpChecker{reporter: r}
+	r.icmpChecker = &icmpChecker{reporter: r}
+	r.ikeSA = newIkeSA(r, logger)
+	return r, nil
+}
+
+func (r *Reporter) TcpChecker() tcpchecker.Reporter {
+	return r.tcpChecker
+}
+
+// TunnelChecker returns a reporter of checks through the child SA childSAName
+// of the IKE SA ikeSAName.
+func (r *Reporter) TunnelChecker(ikeSAName, childSAName string) tcpchecker.Reporter {
+	return &tunnelCheckerReporter{
+		reporter:    r,
+		ikeSAName:   ikeSAName,
+		childSAName: childSAName,
+	}
+}
+
+func (r *Reporter) HttpChecker() httpchecker.Reporter {
+	return r.httpChecker
+}
+
+func (r *Reporter) UdpChecker() udpchecker.Reporter {
+	return r.udpChecker
+}
+
+func (r *Reporter) IcmpChecker() icmpchecker.Reporter {
+	return r.icmpChecker
+}
+
+func (r *Reporter) StrongSwan() strongswan.IKESAStatusReceiver {
+	return r.ikeSA
+}
+
+func (r *Reporter) Daemon(name string) *daemonpkg.Reporter {
+	return newDaemonReporter(r, name)
+}
+
+// Info reports the version of strong-duckling.
+func (r *Reporter) Info(strongDucklingVersion string) {
+	r.gauge("info", 1, tag("version", strongDucklingVersion))
+}
+
+// FlushInterval returns the configured interval between flushes.
+func (r *Reporter) FlushInterval() time.Duration {
+	return r.config.FlushInterval
+}
+
+// Flush sends all buffered metrics.
+func (r *Reporter) Flush() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.flush()
+}
+
+// Close flushes any buffered metrics and closes the underlying connection.
+func (r *Reporter) Close() error {
+	r.Flush()
+	return r.conn.Close()
+}
+
+// flush writes the buffer to the connection. The caller must hold r.mu.
+func (r *Reporter) flush() {
+	if r.buffer.Len() == 0 {
+		return
+	}
+	_, err := r.conn.Write(r.buffer.Bytes())
+	if err != nil {
+		r.logger.Errorf("statsd: failed to send metrics: %v", err)
+	}
+	r.buffer.Reset()
+}
+
+func (r *Reporter) count(name string, value int64, tags ...string) {
+	r.send(name, fmt.Sprintf("%d", value), "c", tags)
+}
+
+func (r *Reporter) gauge(name string, value float64, tags ...string) {
+	r.send(name, fmt.Sprintf("%g", value), "g", tags)
+}
+
+// timing reports a duration in milliseconds.
+func (r *Reporter) timing(name string, value time.Duration, tags ...string) {
+	r.send(name, fmt.Sprintf("%g", float64(value)/float64(time.Millisecond)), "ms", tags)
+}
+
+// send appends a metric line to the buffer. If the line does not fit within
+// the maximum packet size the buffer is flushed first.
+func (r *Reporter) send(name, value, metricType string, tags []string) {
+	line := r.format(name, value, metricType, tags)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.buffer.Len() != 0 && r.buffer.Len()+1+len(line) > r.config.MaxPacketSize {
+		r.flush()
+	}
+	if r.buffer.Len() != 0 {
+		r.buffer.WriteByte('\n')
+	}
+	r.buffer.WriteString(line)
+}
+
+func (r *Reporter) format(name, value, metricType string, tags []string) string {
+	var line strings.Builder
+	fmt.Fprintf(&line, "%s%s:%s|%s", r.config.Prefix, name, value, metricType)
+	allTags := append(append([]string{}, r.config.Tags...), tags...)
+	if len(allTags) != 0 {
+		fmt.Fprintf(&line, "|#%s", strings.Join(allTags, ","))
+	}
+	return line.String()
+}
+
+// tagValueReplacer replaces characters with a special meaning in the DogStatsD
+// format.
+var tagValueReplacer = strings.NewReplacer(",", "_", "|", "_", "#", "_", "\n", "_")
+
+// tag returns a DogStatsD tag with name and value.
+func tag(name, value string) string {
+	return fmt.Sprintf("%s:%s", name, tagValueReplacer.Replace(value))
+}
@@ -0,0 +1,355 @@
+package statsd
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lunarway/strong-duckling/internal/httpchecker"
+	"github.com/lunarway/strong-duckling/internal/icmpchecker"
+	"github.com/lunarway/strong-duckling/internal/strongswan"
+	"github.com/lunarway/strong-duckling/internal/tcpchecker"
+	"github.com/lunarway/strong-duckling/internal/test"
+	"github.com/lunarway/strong-duckling/internal/udpchecker"
+	"github.com/lunarway/strong-duckling/internal/vici"
+	"github.com/stretchr/testify/assert"
+)
+
+var _ tcpchecker.Reporter = (&Reporter{}).TcpChecker()
+var _ tcpchecker.Reporter = (&Reporter{}).TunnelChecker("", "")
+var _ httpchecker.Reporter = (&Reporter{}).HttpChecker()
+var _ udpchecker.Reporter = (&Reporter{}).UdpChecker()
+var _ icmpchecker.Reporter = (&Reporter{}).IcmpChecker()
+var _ strongswan.IKESAStatusReceiver = (&Reporter{}).StrongSwan()
+var _ strongswan.CollectionDoneReceiver = (&Reporter{}).StrongSwan().(strongswan.CollectionDoneReceiver)
+
+// listen starts a local UDP listener and returns its address along with a
+// function reading a single packet from it.
+func listen(t *testing.T) (string, func() string) {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen on udp: %v", err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+	})
+	return conn.LocalAddr().String(), func() string {
+		t.Helper()
+		buf := make([]byte, 65536)
+		err := conn.SetReadDeadline(time.Now().Add(time.Second))
+		if err != nil {
+			t.Fatalf("set read deadline: %v", err)
+		}
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("read packet: %v", err)
+		}
+		return string(buf[:n])
+	}
+}
+
+func TestReporter_TcpChecker(t *testing.T) {
+	address, read := listen(t)
+	r, err := New(test.NewLogger(t), Configuration{
+		Address: address,
+		Tags:    []string{"env:test"},
+	})
+	if !assert.NoError(t, err, "unexpected initialization error") {
+		return
+	}
+	defer r.Close()
+
+	report := tcpchecker.Report{
+		Name:            "partner1",
+		Address:         "1.2.3.4",
+		Port:            4500,
+		Open:            true,
+		ConnectDuration: 5 * time.Millisecond,
+	}
+	r.TcpChecker().ReportPortCheck(report)
+	r.TcpChecker().ReportPortCheck(report)
+	report.Open = false
+	report.Failure = tcpchecker.FailureRefused
+	r.TcpChecker().ReportPortCheck(report)
+	r.Flush()
+
+	assert.Equal(t, strings.Join([]string{
+		"strong_duckling.tcp_checker.checked:1|c|#env:test,name:partner1,address:1.2.3.4,port:4500,open:true",
+		"strong_duckling.tcp_checker.open:1|g|#env:test,name:partner1,address:1.2.3.4,port:4500",
+		"strong_duckling.tcp_checker.connect_duration:5|ms|#env:test,name:partner1,address:1.2.3.4,port:4500",
+		"strong_duckling.tcp_checker.connected:1|c|#env:test,name:partner1,address:1.2.3.4,port:4500",
+		"strong_duckling.tcp_checker.checked:1|c|#env:test,name:partner1,address:1.2.3.4,port:4500,open:true",
+		"strong_duckling.tcp_checker.open:1|g|#env:test,name:partner1,address:1.2.3.4,port:4500",
+		"strong_duckling.tcp_checker.connect_duration:5|ms|#env:test,name:partner1,address:1.2.3.4,port:4500",
+		"strong_duckling.tcp_checker.checked:1|c|#env:test,name:partner1,address:1.2.3.4,port:4500,open:false",
+		"strong_duckling.tcp_checker.open:0|g|#env:test,name:partner1,address:1.2.3.4,port:4500",
+		"strong_duckling.tcp_checker.connect_duration:5|ms|#env:test,name:partner1,address:1.2.3.4,port:4500",
+		"strong_duckling.tcp_checker.failures:1|c|#env:test,name:partner1,address:1.2.3.4,port:4500,reason:refused",
+		"strong_duckling.tcp_checker.disconnected:1|c|#env:test,name:partner1,address:1.2.3.4,port:4500",
+	}, "\n"), read(), "packet not as expected")
+}
+
+func TestReporter_TunnelChecker(t *testing.T) {
+	address, read := listen(t)
+	r, err := New(test.NewLogger(t), Configuration{
+		Address: address,
+	})
+	if !assert.NoError(t, err, "unexpected initialization error") {
+		return
+	}
+	defer r.Close()
+
+	r.TunnelChecker("partner1", "net-1").ReportPortCheck(tcpchecker.Report{
+		Name:            "ssh",
+		Address:         "10.2.0.1",
+		Port:            22,
+		ConnectDuration: 5 * time.Millisecond,
+		Failure:         tcpchecker.FailureTimeout,
+	})
+	r.Flush()
+
+	tags := "ike_sa_name:partner1,child_sa_name:net-1,name:ssh,address:10.2.0.1,port:22"
+	assert.Equal(t, strings.Join([]string{
+		"strong_duckling.tunnel_checker.checked:1|c|#" + tags + ",open:false",
+		"strong_duckling.tunnel_checker.open:0|g|#" + tags,
+		"strong_duckling.tunnel_checker.connect_duration:5|ms|#" + tags,
+		"strong_duckling.tunnel_checker.failures:1|c|#" + tags + ",reason:timeout",
+	}, "\n"), read(), "packet not as expected")
+}
+
+func TestReporter_HttpChecker(t *testing.T) {
+	address, read := listen(t)
+	r, err := New(test.NewLogger(t), Configuration{
+		Address: address,
+	})
+	if !assert.NoError(t, err, "unexpected initialization error") {
+		return
+	}
+	defer r.Close()
+
+	r.HttpChecker().ReportHTTPCheck(httpchecker.Report{
+		Name:             "api",
+		Method:           "GET",
+		URL:              "http://10.0.0.1/health",
+		StatusCode:       503,
+		Failure:          httpchecker.FailureStatusCode,
+		ResponseDuration: 5 * time.Millisecond,
+	})
+	r.Flush()
+
+	tags := "name:api,method:GET,url:http://10.0.0.1/health"
+	assert.Equal(t, strings.Join([]string{
+		"strong_duckling.http_checker.checked:1|c|#" + tags + ",healthy:false",
+		"strong_duckling.http_checker.healthy:0|g|#" + tags,
+		"strong_duckling.http_checker.failures:1|c|#" + tags + ",reason:status_code",
+		"strong_duckling.http_checker.status_code:503|g|#" + tags,
+		"strong_duckling.http_checker.response_duration:5|ms|#" + tags,
+	}, "\n"), read(), "packet not as expected")
+}
+
+func TestReporter_UdpChecker(t *testing.T) {
+	address, read := listen(t)
+	r, err := New(test.NewLogger(t), Configuration{
+		Address: address,
+	})
+	if !assert.NoError(t, err, "unexpected initialization error") {
+		return
+	}
+	defer r.Close()
+
+	r.UdpChecker().ReportUDPCheck(udpchecker.Report{
+		Name:          "ike",
+		Address:       "1.2.3.4",
+		Port:          500,
+		Reachable:     true,
+		RoundTripTime: 5 * time.Millisecond,
+	})
+	r.UdpChecker().ReportUDPCheck(udpchecker.Report{
+		Name:    "ike",
+		Address: "1.2.3.4",
+		Port:    500,
+		Failure: udpchecker.FailureTimeout,
+	})
+	r.Flush()
+
+	assert.Equal(t, strings.Join([]string{
+		"strong_duckling.udp_checker.checked:1|c|#name:ike,address:1.2.3.4,port:500,reachable:true",
+		"strong_duckling.udp_checker.reachable:1|g|#name:ike,address:1.2.3.4,port:500",
+		"strong_duckling.udp_checker.round_trip:5|ms|#name:ike,address:1.2.3.4,port:500",
+		"strong_duckling.udp_checker.checked:1|c|#name:ike,address:1.2.3.4,port:500,reachable:false",
+		"strong_duckling.udp_checker.reachable:0|g|#name:ike,address:1.2.3.4,port:500",
+		"strong_duckling.udp_checker.failures:1|c|#name:ike,address:1.2.3.4,port:500,reason:timeout",
+	}, "\n"), read(), "packet not as expected")
+}
+
+func TestReporter_IcmpChecker(t *testing.T) {
+	address, read := listen(t)
+	r, err := New(test.NewLogger(t), Configuration{
+		Address: address,
+	})
+	if !assert.NoError(t, err, "unexpected initialization error") {
+		return
+	}
+	defer r.Close()
+
+	r.IcmpChecker().ReportICMPCheck(icmpchecker.Report{
+		Name:    "gw",
+		Address: "1.2.3.4",
+		Failure: icmpchecker.FailureTimeout,
+		Window: icmpchecker.WindowStatistics{
+			Sent:                 2,
+			Received:             1,
+			LossPercent:          50,
+			AverageRoundTripTime: 4 * time.Millisecond,
+		},
+	})
+	r.Flush()
+
+	assert.Equal(t, strings.Join([]string{
+		"strong_duckling.icmp_checker.sent:1|c|#name:gw,address:1.2.3.4",
+		"strong_duckling.icmp_checker.reachable:0|g|#name:gw,address:1.2.3.4",
+		"strong_duckling.icmp_checker.failures:1|c|#name:gw,address:1.2.3.4,reason:timeout",
+		"strong_duckling.icmp_checker.loss_percent:50|g|#name:gw,address:1.2.3.4",
+		"strong_duckling.icmp_checker.round_trip_average_seconds:0.004|g|#name:gw,address:1.2.3.4",
+		"strong_duckling.icmp_checker.jitter_seconds:0|g|#name:gw,address:1.2.3.4",
+	}, "\n"), read(), "packet not as expected")
+}
+
+func TestReporter_IKESAStatus(t *testing.T) {
+	address, read := listen(t)
+	r, err := New(test.NewLogger(t), Configuration{
+		Address: address,
+		Prefix:  "sd.",
+		// all lines fit in a single packet
+		MaxPacketSize: 65000,
+	})
+	if !assert.NoError(t, err, "unexpected initialization error") {
+		return
+	}
+	defer r.Close()
+
+	r.StrongSwan().IKESAStatus(strongswan.IKESAStatus{
+		Name: "gw-gw",
+		State: &vici.IkeSa{
+			State:              "ESTABLISHED",
+			LocalHost:          "10.0.0.1",
+			RemoteHost:         "10.0.0.2",
+			EstablishedSeconds: "42",
+			ChildSAs: map[string]vici.ChildSA{
+				"net-0-1": {
+					Name:                   "net-0",
+					UniqueID:               "1",
+					State:                  "INSTALLED",
+					LocalTrafficSelectors:  []string{"10.1.0.0/16", "10.2.0.0/16"},
+					RemoteTrafficSelectors: []string{"10.3.0.0/16"},
+					BytesIn:                "100",
+				},
+			},
+		},
+	})
+	r.Flush()
+
+	ikeTags := "ike_sa_name:gw-gw,local_peer_ip:10.0.0.1,remote_peer_ip:10.0.0.2"
+	childTags := ikeTags + ",local_ip_range:10.1.0.0/16_10.2.0.0/16,remote_ip_range:10.3.0.0/16,child_sa_name:net-0"
+	states := func(name, tags string, states []string, state string) []string {
+		var lines []string
+		for _, s := range states {
+			value := "0"
+			if s == state {
+				value = "1"
+			}
+			lines = append(lines, "sd.ike_sa."+name+":"+value+"|g|#"+tags+",state:"+s)
+		}
+		return lines
+	}
+	var lines []string
+	lines = append(lines, "sd.ike_sa.established_seconds:42|g|#"+ikeTags)
+	lines = append(lines, states("state", ikeTags, vici.IKESAStates, "ESTABLISHED")...)
+	lines = append(lines, states("child_state", childTags, vici.ChildSAStates, "INSTALLED")...)
+	lines = append(lines,
+		"sd.ike_sa.bytes_in_total:100|g|#"+childTags,
+		"sd.ike_sa.installs:1|c|#"+childTags,
+	)
+	assert.Equal(t, strings.Join(lines, "\n"), read(), "packet not as expected")
+}
+
+func TestReporter_IKESAStatus_gone(t *testing.T) {
+	address, read := listen(t)
+	r, err := New(test.NewLogger(t), Configuration{
+		Address:       address,
+		Prefix:        "sd.",
+		MaxPacketSize: 65000,
+	})
+	if !assert.NoError(t, err, "unexpected initialization error") {
+		return
+	}
+	defer r.Close()
+	receiver := r.StrongSwan()
+	status := strongswan.IKESAStatus{
+		Name: "gw-gw",
+		State: &vici.IkeSa{
+			State:      "ESTABLISHED",
+			LocalHost:  "10.0.0.1",
+			RemoteHost: "10.0.0.2",
+			ChildSAs: map[string]vici.ChildSA{
+				"net-0-1": {Name: "net-0", UniqueID: "1", State: "INSTALLED"},
+			},
+		},
+	}
+
+	ikeTags := "ike_sa_name:gw-gw,local_peer_ip:10.0.0.1,remote_peer_ip:10.0.0.2"
+	childTags := ikeTags + ",local_ip_range:,remote_ip_range:,child_sa_name:net-0"
+	reset := func(name, tags string, states []string) []string {
+		var lines []string
+		for _, s := range states {
+			lines = append(lines, "sd.ike_sa."+name+":0|g|#"+tags+",state:"+s)
+		}
+		return lines
+	}
+	var lines []string
+	lines = append(lines, reset("state", ikeTags, vici.IKESAStates)...)
+	lines = append(lines, reset("child_state", childTags, vici.ChildSAStates)...)
+	resetLines := strings.Join(lines, "\n")
+
+	// the IKE SA goes down
+	receiver.IKESAStatus(status)
+	r.Flush()
+	read()
+	receiver.IKESAStatus(strongswan.IKESAStatus{Name: "gw-gw"})
+	receiver.(strongswan.CollectionDoneReceiver).CollectionDone()
+	r.Flush()
+	assert.Equal(t, resetLines, read(), "states of down IKE SA not reset")
+
+	// the child SA is installed again after the IKE SA came up
+	receiver.IKESAStatus(status)
+	receiver.(strongswan.CollectionDoneReceiver).CollectionDone()
+	r.Flush()
+	assert.Contains(t, read(), "sd.ike_sa.installs:1|c|#"+childTags, "install not counted")
+
+	// the connection is unloaded and its IKE SA torn down
+	receiver.(strongswan.CollectionDoneReceiver).CollectionDone()
+	r.Flush()
+	assert.Equal(t, resetLines, read(), "states of gone IKE SA not reset")
+}
+
+func TestReporter_batching(t *testing.T) {
+	address, read := listen(t)
+	r, err := New(test.NewLogger(t), Configuration{
+		Address:       address,
+		MaxPacketSize: 60,
+	})
+	if !assert.NoError(t, err, "unexpected initialization error") {
+		return
+	}
+	defer r.Close()
+
+	// each line is 39 bytes so only one fits in a packet
+	r.Info("1.0.0")
+	r.Info("2.0.0")
+	r.Flush()
+
+	assert.Equal(t, "strong_duckling.info:1|g|#version:1.0.0", read(), "first packet not as expected")
+	assert.Equal(t, "strong_duckling.info:1|g|#version:2.0.0", read(), "second packet not as expected")
+}
@@ -0,0 +1,59 @@
+package statsd
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/lunarway/strong-duckling/internal/tcpchecker"
+)
+
+type tcpChecker struct {
+	reporter *Reporter
+
+	mu sync.Mutex
+	// previousOpenState holds the last open state of each checked target keyed
+	// by name.
+	previousOpenState map[string]bool
+}
+
+func newTcpChecker(r *Reporter) *tcpChecker {
+	return &tcpChecker{
+		reporter:          r,
+		previousOpenState: make(map[string]bool),
+	}
+}
+
+func (tc *tcpChecker) ReportPortCheck(report tcpchecker.Report) {
+	tags := []string{
+		tag("name", report.Name),
+		tag("address", report.Address),
+		tag("port", fmt.Sprintf("%d", report.Port)),
+	}
+	tc.reporter.count("tcp_checker.checked", 1, append(tags, tag("open", strconv.FormatBool(report.Open)))...)
+	open := 0.0
+	if report.Open {
+		open = 1
+	}
+	tc.reporter.gauge("tcp_checker.open", open, tags...)
+	tc.reporter.timing("tcp_checker.connect_duration", report.ConnectDuration, tags...)
+	if report.FirstByteDuration > 0 {
+		tc.reporter.timing("tcp_checker.first_byte_duration", report.FirstByteDuration, tags...)
+	}
+	if reason := report.Reason(); reason != "" {
+		tc.reporter.count("tcp_checker.failures", 1, append(tags, tag("reason", string(reason)))...)
+	}
+
+	tc.mu.Lock()
+	previousOpen, ok := tc.previousOpenState[report.Name]
+	tc.previousOpenState[report.Name] = report.Open
+	tc.mu.Unlock()
+	if ok && previousOpen == report.Open {
+		return
+	}
+	if report.Open {
+		tc.reporter.count("tcp_checker.connected", 1, tags...)
+	} else {
+		tc.reporter.count("tcp_checker.disconnected", 1, tags...)
+	}
+}
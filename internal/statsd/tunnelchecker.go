@@ -0,0 +1,36 @@
+package statsd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/lunarway/strong-duckling/internal/tcpchecker"
+)
+
+// tunnelCheckerReporter reports TCP checks derived from child SA traffic
+// selectors tagged with the owning IKE and child SA.
+type tunnelCheckerReporter struct {
+	reporter    *Reporter
+	ikeSAName   string
+	childSAName string
+}
+
+func (r *tunnelCheckerReporter) ReportPortCheck(report tcpchecker.Report) {
+	tags := []string{
+		tag("ike_sa_name", r.ikeSAName),
+		tag("child_sa_name", r.childSAName),
+		tag("name", report.Name),
+		tag("address", report.Address),
+		tag("port", fmt.Sprintf("%d", report.Port)),
+	}
+	r.reporter.count("tunnel_checker.checked", 1, append(tags, tag("open", strconv.FormatBool(report.Open)))...)
+	open := 0.0
+	if report.Open {
+		open = 1
+	}
+	r.reporter.gauge("tunnel_checker.open", open, tags...)
+	r.reporter.timing("tunnel_checker.connect_duration", report.ConnectDuration, tags...)
+	if reason := report.Reason(); reason != "" {
+		r.reporter.count("tunnel_checker.failures", 1, append(tags, tag("reason", string(reason)))...)
+	}
+}
@@ -0,0 +1,30 @@
+package statsd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/lunarway/strong-duckling/internal/udpchecker"
+)
+
+type udpChecker struct {
+	reporter *Reporter
+}
+
+func (uc *udpChecker) ReportUDPCheck(report udpchecker.Report) {
+	tags := []string{
+		tag("name", report.Name),
+		tag("address", report.Address),
+		tag("port", fmt.Sprintf("%d", report.Port)),
+	}
+	uc.reporter.count("udp_checker.checked", 1, append(tags, tag("reachable", strconv.FormatBool(report.Reachable)))...)
+	if report.Reachable {
+		uc.reporter.gauge("udp_checker.reachable", 1, tags...)
+	} else {
+		uc.reporter.gauge("udp_checker.reachable", 0, tags...)
+		uc.reporter.count("udp_checker.failures", 1, append(tags, tag("reason", string(report.Failure)))...)
+	}
+	if report.RoundTripTime > 0 {
+		uc.reporter.timing("udp_checker.round_trip", report.RoundTripTime, tags...)
+	}
+}
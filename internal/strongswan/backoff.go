@@ -0,0 +1,112 @@
+package strongswan
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// DefaultBackoffInitialDelay is the default delay after the first failed
+	// initiation.
+	DefaultBackoffInitialDelay = 5 * time.Second
+	// DefaultBackoffMaxDelay is the default maximum delay between initiations.
+	DefaultBackoffMaxDelay = 5 * time.Minute
+	// DefaultBackoffMultiplier is the default factor the delay is multiplied by
+	// after each failed initiation.
+	DefaultBackoffMultiplier = 2
+	// DefaultBackoffJitter is the recommended fraction of the delay that is
+	// randomly subtracted from it.
+	DefaultBackoffJitter = 0.2
+)
+
+// BackoffConfiguration specifies the delays between failed initiations of a
+// child SA.
+type BackoffConfiguration struct {
+	// InitialDelay is the delay after the first failed initiation. Defaults to
+	// DefaultBackoffInitialDelay.
+	InitialDelay time.Duration
+	// MaxDelay is the maximum delay between initiations. Defaults to
+	// DefaultBackoffMaxDelay.
+	MaxDelay time.Duration
+	// Multiplier is the factor the delay is multiplied by after each failed
+	// initiation. Defaults to DefaultBackoffMultiplier.
+	Multiplier float64
+	// Jitter is the fraction of the delay, between 0 and 1, that is randomly
+	// subtracted from it to spread out initiations. If 0 there is no jitter.
+	Jitter float64
+	// MaxAttempts is the number of consecutive failed initiations after which
+	// initiation is given up until the child SA is established by other means.
+	// If 0 initiation is never given up.
+	MaxAttempts int
+}
+
+func (c *BackoffConfiguration) setDefaults() {
+	if c.InitialDelay == 0 {
+		c.InitialDelay = DefaultBackoffInitialDelay
+	}
+	if c.MaxDelay == 0 {
+		c.MaxDelay = DefaultBackoffMaxDelay
+	}
+	if c.Multiplier == 0 {
+		c.Multiplier = DefaultBackoffMultiplier
+	}
+}
+
+// Validate reports whether the configuration is usable.
+func (c BackoffConfiguration) Validate() error {
+	if c.InitialDelay < 0 {
+		return fmt.Errorf("initial delay must not be negative")
+	}
+	if c.MaxDelay != 0 && c.MaxDelay < c.InitialDelay {
+		return fmt.Errorf("max delay must not be less than initial delay")
+	}
+	if c.Multiplier != 0 && c.Multiplier < 1 {
+		return fmt.Errorf("multiplier must be at least 1")
+	}
+	if c.Jitter < 0 || c.Jitter > 1 {
+		return fmt.Errorf("jitter must be between 0 and 1")
+	}
+	if c.MaxAttempts < 0 {
+		return fmt.Errorf("max attempts must not be negative")
+	}
+	return nil
+}
+
+// backoff holds the backoff state of a child SA.
+type backoff struct {
+	// failures is the number of consecutive failed initiations.
+	failures int
+	// delay is the current delay before the next initiation.
+	delay time.Duration
+	// next is the earliest time of the next initiation.
+	next time.Time
+}
+
+// ready reports whether the child SA may be initiated at now.
+func (b *backoff) ready(config BackoffConfiguration, now time.Time) bool {
+	if b.givenUp(config) {
+		return false
+	}
+	return !now.Before(b.next)
+}
+
+// givenUp reports whether initiation is given up.
+func (b *backoff) givenUp(config BackoffConfiguration) bool {
+	return config.MaxAttempts != 0 && b.failures >= config.MaxAttempts
+}
+
+// failure records a failed initiation at now. random must return a number in
+// [0, 1) and is used for jitter.
+func (b *backoff) failure(config BackoffConfiguration, now time.Time, random float64) {
+	b.failures++
+	delay := float64(config.InitialDelay)
+	for i := 1; i < b.failures && delay < float64(config.MaxDelay); i++ {
+		delay *= config.Multiplier
+	}
+	if delay > float64(config.MaxDelay) {
+		delay = float64(config.MaxDelay)
+	}
+	delay -= delay * config.Jitter * random
+	b.delay = time.Duration(delay)
+	b.next = now.Add(b.delay)
+}
@@ -0,0 +1,113 @@
+package strongswan
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff_failure(t *testing.T) {
+	tt := []struct {
+		name   string
+		config BackoffConfiguration
+		random float64
+		delays []time.Duration
+	}{
+		{
+			name: "exponential",
+			config: BackoffConfiguration{
+				InitialDelay: time.Second,
+				MaxDelay:     time.Minute,
+				Multiplier:   2,
+			},
+			delays: []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second},
+		},
+		{
+			name: "max delay",
+			config: BackoffConfiguration{
+				InitialDelay: 10 * time.Second,
+				MaxDelay:     30 * time.Second,
+				Multiplier:   3,
+			},
+			delays: []time.Duration{10 * time.Second, 30 * time.Second, 30 * time.Second},
+		},
+		{
+			name: "jitter",
+			config: BackoffConfiguration{
+				InitialDelay: 10 * time.Second,
+				MaxDelay:     time.Minute,
+				Multiplier:   2,
+				Jitter:       0.2,
+			},
+			random: 0.5,
+			delays: []time.Duration{9 * time.Second, 18 * time.Second},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			now := time.Now()
+			var b backoff
+			for _, delay := range tc.delays {
+				b.failure(tc.config, now, tc.random)
+				assert.Equal(t, delay, b.delay, "delay not as expected")
+				assert.False(t, b.ready(tc.config, now), "ready before delay")
+				assert.True(t, b.ready(tc.config, now.Add(delay)), "not ready after delay")
+			}
+		})
+	}
+}
+
+func TestBackoff_givenUp(t *testing.T) {
+	config := BackoffConfiguration{
+		InitialDelay: time.Second,
+		MaxDelay:     time.Minute,
+		Multiplier:   2,
+		MaxAttempts:  2,
+	}
+	now := time.Now()
+	var b backoff
+	b.failure(config, now, 0)
+	assert.False(t, b.givenUp(config), "given up after first failure")
+	b.failure(config, now, 0)
+	assert.True(t, b.givenUp(config), "not given up after max attempts")
+	assert.False(t, b.ready(config, now.Add(time.Hour)), "ready after giving up")
+}
+
+func TestBackoffConfiguration_Validate(t *testing.T) {
+	tt := []struct {
+		name   string
+		config BackoffConfiguration
+		err    string
+	}{
+		{
+			name:   "defaults",
+			config: BackoffConfiguration{},
+		},
+		{
+			name:   "max delay less than initial delay",
+			config: BackoffConfiguration{InitialDelay: time.Minute, MaxDelay: time.Second},
+			err:    "max delay must not be less than initial delay",
+		},
+		{
+			name:   "multiplier less than 1",
+			config: BackoffConfiguration{Multiplier: 0.5},
+			err:    "multiplier must be at least 1",
+		},
+		{
+			name:   "jitter out of range",
+			config: BackoffConfiguration{Jitter: 1.5},
+			err:    "jitter must be between 0 and 1",
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.config.Validate()
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err, "error not as expected")
+				return
+			}
+			assert.NoError(t, err, "unexpected error")
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package strongswan
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/prometheus/common/log"
+)
+
+// Decision is a corrective action decided by a component in dry-run mode.
+// The action is logged and reported but never sent to charon.
+type Decision struct {
+	// Component is the name of the deciding component, eg. reinitiator.
+	Component string
+	IKESAName string
+	// ChildSAName is empty for actions on the IKE SA.
+	ChildSAName string
+	// Action is the action that would have been taken.
+	Action string
+	// Reason describes why the action was decided.
+	Reason string
+	// IKESAStatus is the status of the IKE SA that triggered the decision.
+	IKESAStatus IKESAStatus
+}
+
+// DecisionReporter receives decisions of components in dry-run mode.
+type DecisionReporter interface {
+	ReportDecision(decision Decision)
+}
+
+// reportDecision logs decision along with its triggering IKESAStatus and
+// reports it to reporter if set.
+func reportDecision(logger log.Logger, reporter DecisionReporter, decision Decision) {
+	status, err := json.Marshal(decision.IKESAStatus)
+	if err != nil {
+		status = []byte(err.Error())
+	}
+	sa := fmt.Sprintf("IKE SA %s", decision.IKESAName)
+	if decision.ChildSAName != "" {
+		sa = fmt.Sprintf("Child SA %s.%s", decision.IKESAName, decision.ChildSAName)
+	}
+	logger.
+		With("component", decision.Component).
+		With("ike_sa_name", decision.IKESAName).
+		With("child_sa_name", decision.ChildSAName).
+		With("action", decision.Action).
+		With("ike_sa_status", string(status)).
+		Infof("Dry run: not taking action %s on %s: %s", decision.Action, sa, decision.Reason)
+	if reporter != nil {
+		reporter.ReportDecision(decision)
+	}
+}
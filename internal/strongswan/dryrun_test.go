@@ -0,0 +1,111 @@
+package strongswan
+
+import (
+	"testing"
+
+	"github.com/lunarway/strong-duckling/internal/tcpchecker"
+	"github.com/lunarway/strong-duckling/internal/test"
+	"github.com/lunarway/strong-duckling/internal/vici"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+type decisionReports chan Decision
+
+func (r decisionReports) ReportDecision(decision Decision) {
+	r <- decision
+}
+
+func TestReinitiator_dryRun(t *testing.T) {
+	client := &fakeController{}
+	decisions := make(decisionReports, 1)
+	reports := &reinitiatorReports{}
+	i := NewReinitiator(nil, test.NewLogger(t), noop.NewTracerProvider().Tracer(""), ReinitiatorConfiguration{
+		Policies: []PolicyRule{
+			{IKESAName: "partner1", Policy: ReinitiationPolicy{Action: ReinitiationTerminateInitiate, Backoff: BackoffConfiguration{MaxAttempts: 2}}},
+		},
+		Reporter:         reports,
+		DryRun:           true,
+		DecisionReporter: decisions,
+	})
+	status := IKESAStatus{
+		Name: "partner1",
+		State: &vici.IkeSa{
+			UniqueID: "3",
+		},
+		ChildSA: []ChildSAStatus{
+			{Name: "net-1"},
+		},
+	}
+
+	// decisions are repeated beyond the maximum number of attempts
+	for n := 0; n < 3; n++ {
+		i.IKESAStatus(status)
+		if !assert.Len(t, i.queue, 1, "decision %d not queued", n) {
+			return
+		}
+		i.process(test.NewLogger(t), client, i.dequeue())
+
+		select {
+		case decision := <-decisions:
+			assert.Equal(t, Decision{
+				Component:   "reinitiator",
+				IKESAName:   "partner1",
+				ChildSAName: "net-1",
+				Action:      "terminate_initiate",
+				Reason:      "Child SA partner1.net-1 is missing and policy terminate_initiate terminates IKE SA partner1[3] before initiating it",
+				IKESAStatus: status,
+			}, decision, "decision not as expected")
+		default:
+			t.Fatalf("no decision %d reported", n)
+		}
+	}
+	assert.Empty(t, client.requests, "requests sent in dry run")
+	assert.Empty(t, reports.backoffs, "backoffs reported in dry run")
+	assert.Empty(t, reports.skipped, "skips reported in dry run")
+}
+
+func TestHealer_dryRun(t *testing.T) {
+	client := &fakeController{}
+	decisions := make(decisionReports, 1)
+	var reports healReports
+	healer := NewHealer(client, test.NewLogger(t), noop.NewTracerProvider().Tracer(""), HealerConfiguration{
+		Action:           HealActionReinitiate,
+		Threshold:        2,
+		Reporter:         &reports,
+		DryRun:           true,
+		DecisionReporter: decisions,
+	})
+	status := IKESAStatus{
+		Name: "partner1",
+		ChildSA: []ChildSAStatus{
+			{
+				Name: "net-1",
+				State: &vici.ChildSA{
+					UniqueID: "7",
+					State:    "INSTALLED",
+				},
+			},
+		},
+	}
+	healer.IKESAStatus(status)
+	reporter := healer.Reporter("partner1", "net-1")
+	for n := 0; n < 2; n++ {
+		reporter.ReportPortCheck(tcpchecker.Report{
+			Open: false,
+		})
+	}
+
+	if assert.Len(t, decisions, 1, "decisions not as expected") {
+		assert.Equal(t, Decision{
+			Component:   "healer",
+			IKESAName:   "partner1",
+			ChildSAName: "net-1",
+			Action:      "reinitiate",
+			Reason:      "2 consecutive checks through installed Child SA partner1.net-1[7] failed",
+			IKESAStatus: status,
+		}, <-decisions, "decision not as expected")
+	}
+	assert.Empty(t, client.requests, "requests sent in dry run")
+	assert.Empty(t, reports, "actions reported in dry run")
+}
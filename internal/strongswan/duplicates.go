@@ -0,0 +1,239 @@
+package strongswan
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lunarway/strong-duckling/internal/vici"
+	"github.com/prometheus/common/log"
+)
+
+var _ IKESAStatusReceiver = &DuplicateTerminator{}
+var _ CollectionDoneReceiver = &DuplicateTerminator{}
+
+const (
+	// DefaultDuplicateGracePeriod is the default time a stale duplicate child
+	// SA is left for charon to remove before it is terminated.
+	DefaultDuplicateGracePeriod = time.Minute
+)
+
+// DuplicateTermination is a report of a termination of a stale duplicate
+// child SA.
+type DuplicateTermination struct {
+	IKESAName   string
+	ChildSAName string
+	// UniqueID is the unique ID of the terminated child SA.
+	UniqueID string
+	// Error is the error of the termination if it failed.
+	Error error
+}
+
+// DuplicatesReporter receives reports on duplicate child SAs.
+type DuplicatesReporter interface {
+	// ReportStale reports the number of stale duplicates of a child SA.
+	ReportStale(ikeSAName, childSAName string, count int)
+	ReportTermination(termination DuplicateTermination)
+}
+
+// DuplicatesConfiguration specifies how a DuplicateTerminator terminates stale
+// duplicate child SAs.
+type DuplicatesConfiguration struct {
+	// GracePeriod is the time a stale duplicate is left for charon to remove
+	// before it is terminated. Defaults to DefaultDuplicateGracePeriod.
+	GracePeriod time.Duration
+	Reporter    DuplicatesReporter
+	// DryRun logs and reports the decided terminations to DecisionReporter
+	// instead of sending them to charon.
+	DryRun           bool
+	DecisionReporter DecisionReporter
+}
+
+func (c *DuplicatesConfiguration) setDefaults() {
+	if c.GracePeriod == 0 {
+		c.GracePeriod = DefaultDuplicateGracePeriod
+	}
+}
+
+// DuplicateTerminator terminates stale duplicates of installed child SAs.
+// Child SAs with the same name and traffic selectors are duplicates, eg. after
+// rekey collisions, and all but the newest by install time are stale.
+//
+// Terminations are sent synchronously so the DuplicateTerminator should have
+// its own client.
+type DuplicateTerminator struct {
+	client Controller
+	logger log.Logger
+	config DuplicatesConfiguration
+	now    func() time.Time
+
+	// stale holds the stale duplicates seen by their unique ID.
+	stale map[string]*staleChildSA
+	// seen holds the IKE SA names received in the current collection.
+	seen map[string]struct{}
+}
+
+type staleChildSA struct {
+	ikeSAName string
+	// firstSeen is the time the child SA was first seen as stale.
+	firstSeen time.Time
+}
+
+// NewDuplicateTerminator returns a DuplicateTerminator terminating stale
+// duplicate child SAs through client.
+func NewDuplicateTerminator(client Controller, logger log.Logger, config DuplicatesConfiguration) *DuplicateTerminator {
+	config.setDefaults()
+	return &DuplicateTerminator{
+		client: client,
+		logger: logger,
+		config: config,
+		now:    time.Now,
+		stale:  make(map[string]*staleChildSA),
+		seen:   make(map[string]struct{}),
+	}
+}
+
+func (d *DuplicateTerminator) IKESAStatus(ikeSAStatus IKESAStatus) {
+	now := d.now()
+	d.seen[ikeSAStatus.Name] = struct{}{}
+	seen := make(map[string]struct{})
+	for _, childSA := range ikeSAStatus.ChildSA {
+		var stale []vici.ChildSA
+		for _, duplicates := range duplicateChildSAs(ikeSAStatus.Sessions, childSA.Name) {
+			newest := duplicates[0]
+			for _, duplicate := range duplicates[1:] {
+				seen[duplicate.UniqueID] = struct{}{}
+				stale = append(stale, duplicate)
+				d.check(ikeSAStatus, newest, duplicate, now)
+			}
+		}
+		if d.config.Reporter != nil {
+			d.config.Reporter.ReportStale(ikeSAStatus.Name, childSA.Name, len(stale))
+		}
+	}
+	for uniqueID, stale := range d.stale {
+		if _, ok := seen[uniqueID]; stale.ikeSAName == ikeSAStatus.Name && !ok {
+			delete(d.stale, uniqueID)
+		}
+	}
+}
+
+// CollectionDone forgets the stale duplicates of IKE SAs that were not
+// received in the collection, eg. after their configuration was unloaded.
+func (d *DuplicateTerminator) CollectionDone() {
+	for uniqueID, stale := range d.stale {
+		if _, ok := d.seen[stale.ikeSAName]; !ok {
+			delete(d.stale, uniqueID)
+		}
+	}
+	d.seen = make(map[string]struct{})
+}
+
+// check terminates the stale duplicate childSA of newest if it has been stale
+// for the grace period.
+func (d *DuplicateTerminator) check(ikeSAStatus IKESAStatus, newest, childSA vici.ChildSA, now time.Time) {
+	stale, ok := d.stale[childSA.UniqueID]
+	if !ok {
+		d.logger.Infof("Child SA %s.%s[%s] installed %ss ago is a stale duplicate of Child SA %s[%s] installed %ss ago", ikeSAStatus.Name, childSA.Name, childSA.UniqueID, childSA.InstallTimeSeconds, newest.Name, newest.UniqueID, newest.InstallTimeSeconds)
+		d.stale[childSA.UniqueID] = &staleChildSA{
+			ikeSAName: ikeSAStatus.Name,
+			firstSeen: now,
+		}
+		return
+	}
+	if now.Sub(stale.firstSeen) < d.config.GracePeriod {
+		return
+	}
+	// the child SA is seen as new if it is still there after the termination
+	delete(d.stale, childSA.UniqueID)
+
+	if d.config.DryRun {
+		reportDecision(d.logger, d.config.DecisionReporter, Decision{
+			Component:   "duplicates",
+			IKESAName:   ikeSAStatus.Name,
+			ChildSAName: childSA.Name,
+			Action:      "terminate",
+			Reason:      fmt.Sprintf("Child SA %s[%s] installed %ss ago has been a stale duplicate of Child SA %s[%s] installed %ss ago for %s", childSA.Name, childSA.UniqueID, childSA.InstallTimeSeconds, newest.Name, newest.UniqueID, newest.InstallTimeSeconds, now.Sub(stale.firstSeen)),
+			IKESAStatus: ikeSAStatus,
+		})
+		return
+	}
+	d.logger.Infof("Terminating stale duplicate Child SA %s.%s[%s] after %s", ikeSAStatus.Name, childSA.Name, childSA.UniqueID, now.Sub(stale.firstSeen))
+	err := d.client.Terminate(&vici.TerminateRequest{
+		Child_id: childSA.UniqueID,
+	})
+	if err != nil {
+		d.logger.Errorf("Failed to terminate stale duplicate Child SA %s.%s[%s]: %v", ikeSAStatus.Name, childSA.Name, childSA.UniqueID, err)
+	}
+	if d.config.Reporter != nil {
+		d.config.Reporter.ReportTermination(DuplicateTermination{
+			IKESAName:   ikeSAStatus.Name,
+			ChildSAName: childSA.Name,
+			UniqueID:    childSA.UniqueID,
+			Error:       err,
+		})
+	}
+}
+
+// duplicateChildSAs returns the groups of installed child SAs named
+// childSAName with the same traffic selectors across ikeSAs. Each group holds
+// at least two child SAs with the newest first.
+func duplicateChildSAs(ikeSAs []vici.IkeSa, childSAName string) [][]vici.ChildSA {
+	groups := make(map[string][]vici.ChildSA)
+	var keys []string
+	for _, ikeSA := range ikeSAs {
+		for _, childSA := range ikeSA.ChildSAs {
+			if childSA.Name != childSAName || childSA.State != vici.ChildSAStateInstalled {
+				continue
+			}
+			if _, err := strconv.ParseUint(childSA.InstallTimeSeconds, 10, 64); err != nil {
+				continue
+			}
+			key := trafficSelectorsKey(childSA)
+			if _, ok := groups[key]; !ok {
+				keys = append(keys, key)
+			}
+			groups[key] = append(groups[key], childSA)
+		}
+	}
+	sort.Strings(keys)
+	var duplicates [][]vici.ChildSA
+	for _, key := range keys {
+		group := groups[key]
+		if len(group) < 2 {
+			continue
+		}
+		sort.Slice(group, func(i, j int) bool {
+			return newerChildSA(group[i], group[j])
+		})
+		duplicates = append(duplicates, group)
+	}
+	return duplicates
+}
+
+func trafficSelectorsKey(childSA vici.ChildSA) string {
+	local := append([]string(nil), childSA.LocalTrafficSelectors...)
+	remote := append([]string(nil), childSA.RemoteTrafficSelectors...)
+	sort.Strings(local)
+	sort.Strings(remote)
+	return strings.Join(local, ",") + " === " + strings.Join(remote, ",")
+}
+
+// newerChildSA reports whether a is installed after b. Child SAs installed at
+// the same time are ordered by their unique ID which increases over time.
+func newerChildSA(a, b vici.ChildSA) bool {
+	// install times are validated by duplicateChildSAs
+	installA, _ := strconv.ParseUint(a.InstallTimeSeconds, 10, 64)
+	installB, _ := strconv.ParseUint(b.InstallTimeSeconds, 10, 64)
+	if installA != installB {
+		return installA < installB
+	}
+	idA, errA := strconv.ParseUint(a.UniqueID, 10, 64)
+	idB, errB := strconv.ParseUint(b.UniqueID, 10, 64)
+	if errA != nil || errB != nil {
+		return a.UniqueID > b.UniqueID
+	}
+	return idA > idB
+}
@@ -0,0 +1,226 @@
+package strongswan
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lunarway/strong-duckling/internal/test"
+	"github.com/lunarway/strong-duckling/internal/vici"
+	"github.com/stretchr/testify/assert"
+)
+
+type duplicatesReports struct {
+	stale        map[string]int
+	terminations []DuplicateTermination
+}
+
+func (r *duplicatesReports) ReportStale(ikeSAName, childSAName string, count int) {
+	if r.stale == nil {
+		r.stale = map[string]int{}
+	}
+	r.stale[ikeSAName+"."+childSAName] = count
+}
+
+func (r *duplicatesReports) ReportTermination(termination DuplicateTermination) {
+	r.terminations = append(r.terminations, termination)
+}
+
+func installedChildSA(uniqueID, installTime string, remoteTrafficSelectors ...string) vici.ChildSA {
+	return vici.ChildSA{
+		Name:                   "net-1",
+		UniqueID:               uniqueID,
+		State:                  "INSTALLED",
+		InstallTimeSeconds:     installTime,
+		LocalTrafficSelectors:  []string{"10.1.0.0/16"},
+		RemoteTrafficSelectors: remoteTrafficSelectors,
+	}
+}
+
+func duplicatesStatus(childSAs ...vici.ChildSA) IKESAStatus {
+	ikeSA := vici.IkeSa{
+		UniqueID: "3",
+		ChildSAs: map[string]vici.ChildSA{},
+	}
+	for _, childSA := range childSAs {
+		ikeSA.ChildSAs[childSA.Name+"-"+childSA.UniqueID] = childSA
+	}
+	return IKESAStatus{
+		Name:  "partner1",
+		State: &ikeSA,
+		ChildSA: []ChildSAStatus{
+			{Name: "net-1"},
+		},
+		Sessions: []vici.IkeSa{ikeSA},
+	}
+}
+
+func TestDuplicateTerminator(t *testing.T) {
+	tt := []struct {
+		name     string
+		status   IKESAStatus
+		after    time.Duration
+		err      error
+		requests []string
+		stale    int
+	}{
+		{
+			name: "within grace period",
+			status: duplicatesStatus(
+				installedChildSA("7", "300", "10.2.0.0/16"),
+				installedChildSA("8", "10", "10.2.0.0/16"),
+			),
+			after: 30 * time.Second,
+			stale: 1,
+		},
+		{
+			name: "after grace period",
+			status: duplicatesStatus(
+				installedChildSA("7", "300", "10.2.0.0/16"),
+				installedChildSA("8", "10", "10.2.0.0/16"),
+			),
+			after:    time.Minute,
+			requests: []string{"terminate 7"},
+			stale:    1,
+		},
+		{
+			name: "same install time",
+			status: duplicatesStatus(
+				installedChildSA("9", "10", "10.2.0.0/16"),
+				installedChildSA("10", "10", "10.2.0.0/16"),
+				installedChildSA("8", "10", "10.2.0.0/16"),
+			),
+			after:    time.Minute,
+			requests: []string{"terminate 9", "terminate 8"},
+			stale:    2,
+		},
+		{
+			name: "different traffic selectors",
+			status: duplicatesStatus(
+				installedChildSA("7", "300", "10.2.0.0/16"),
+				installedChildSA("8", "10", "10.3.0.0/16"),
+			),
+			after: time.Minute,
+		},
+		{
+			name: "not installed",
+			status: duplicatesStatus(
+				installedChildSA("7", "300", "10.2.0.0/16"),
+				vici.ChildSA{Name: "net-1", UniqueID: "8", State: "REKEYING", InstallTimeSeconds: "10", RemoteTrafficSelectors: []string{"10.2.0.0/16"}},
+			),
+			after: time.Minute,
+		},
+		{
+			name: "failed termination",
+			status: duplicatesStatus(
+				installedChildSA("7", "300", "10.2.0.0/16"),
+				installedChildSA("8", "10", "10.2.0.0/16"),
+			),
+			after:    time.Minute,
+			err:      errors.New("terminate unsuccessful"),
+			requests: []string{"terminate 7"},
+			stale:    1,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &fakeController{
+				terminateErr: tc.err,
+			}
+			reports := &duplicatesReports{}
+			d := NewDuplicateTerminator(client, test.NewLogger(t), DuplicatesConfiguration{
+				Reporter: reports,
+			})
+			now := time.Now()
+			d.now = func() time.Time {
+				return now
+			}
+
+			d.IKESAStatus(tc.status)
+			now = now.Add(tc.after)
+			d.IKESAStatus(tc.status)
+
+			assert.Equal(t, tc.requests, client.requests, "requests not as expected")
+			assert.Equal(t, map[string]int{"partner1.net-1": tc.stale}, reports.stale, "stale not as expected")
+			if !assert.Len(t, reports.terminations, len(tc.requests), "terminations not as expected") {
+				return
+			}
+			for _, termination := range reports.terminations {
+				assert.Equal(t, tc.err, termination.Error, "error not as expected")
+			}
+		})
+	}
+}
+
+func TestDuplicateTerminator_resolved(t *testing.T) {
+	client := &fakeController{}
+	d := NewDuplicateTerminator(client, test.NewLogger(t), DuplicatesConfiguration{})
+	now := time.Now()
+	d.now = func() time.Time {
+		return now
+	}
+	duplicated := duplicatesStatus(
+		installedChildSA("7", "300", "10.2.0.0/16"),
+		installedChildSA("8", "10", "10.2.0.0/16"),
+	)
+
+	d.IKESAStatus(duplicated)
+	// charon removes the duplicate itself
+	now = now.Add(30 * time.Second)
+	d.IKESAStatus(duplicatesStatus(installedChildSA("8", "40", "10.2.0.0/16")))
+	// a duplicate showing up again gets a new grace period
+	now = now.Add(30 * time.Second)
+	d.IKESAStatus(duplicated)
+	now = now.Add(30 * time.Second)
+	d.IKESAStatus(duplicated)
+
+	assert.Empty(t, client.requests, "requests not as expected")
+}
+
+func TestDuplicateTerminator_gone(t *testing.T) {
+	d := NewDuplicateTerminator(&fakeController{}, test.NewLogger(t), DuplicatesConfiguration{})
+	d.IKESAStatus(duplicatesStatus(
+		installedChildSA("7", "300", "10.2.0.0/16"),
+		installedChildSA("8", "10", "10.2.0.0/16"),
+	))
+	d.CollectionDone()
+	assert.Len(t, d.stale, 1, "stale duplicate not tracked")
+
+	// the connection is unloaded and its IKE SA torn down
+	d.CollectionDone()
+	assert.Empty(t, d.stale, "stale duplicate of gone IKE SA not forgotten")
+}
+
+func TestDuplicateTerminator_dryRun(t *testing.T) {
+	client := &fakeController{}
+	decisions := make(decisionReports, 1)
+	d := NewDuplicateTerminator(client, test.NewLogger(t), DuplicatesConfiguration{
+		GracePeriod:      time.Second,
+		DryRun:           true,
+		DecisionReporter: decisions,
+	})
+	now := time.Now()
+	d.now = func() time.Time {
+		return now
+	}
+	status := duplicatesStatus(
+		installedChildSA("7", "300", "10.2.0.0/16"),
+		installedChildSA("8", "10", "10.2.0.0/16"),
+	)
+
+	d.IKESAStatus(status)
+	now = now.Add(time.Second)
+	d.IKESAStatus(status)
+
+	assert.Empty(t, client.requests, "requests sent in dry run")
+	if assert.Len(t, decisions, 1, "decisions not as expected") {
+		assert.Equal(t, Decision{
+			Component:   "duplicates",
+			IKESAName:   "partner1",
+			ChildSAName: "net-1",
+			Action:      "terminate",
+			Reason:      "Child SA net-1[7] installed 300s ago has been a stale duplicate of Child SA net-1[8] installed 10s ago for 1s",
+			IKESAStatus: status,
+		}, <-decisions, "decision not as expected")
+	}
+}
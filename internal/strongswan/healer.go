@@ -0,0 +1,370 @@
+package strongswan
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lunarway/strong-duckling/internal/tcpchecker"
+	"github.com/lunarway/strong-duckling/internal/vici"
+	"github.com/prometheus/common/log"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var _ IKESAStatusReceiver = &Healer{}
+var _ CollectionDoneReceiver = &Healer{}
+var _ Controller = &vici.ClientConn{}
+
+// Controller controls SAs. It is implemented by *vici.ClientConn.
+type Controller interface {
+	Initiator
+	Terminate(r *vici.TerminateRequest) error
+	Rekey(r *vici.RekeyRequest) error
+}
+
+// HealAction is the action taken on a child SA when checks through it fail.
+type HealAction string
+
+const (
+	// HealActionRekey rekeys the child SA.
+	HealActionRekey HealAction = "rekey"
+	// HealActionReinitiate terminates the child SA and initiates it again.
+	HealActionReinitiate HealAction = "reinitiate"
+
+	// DefaultHealThreshold is the default number of consecutive failed checks
+	// before a child SA is healed.
+	DefaultHealThreshold = 3
+	// DefaultHealCooldown is the default minimum time between actions on the
+	// same child SA.
+	DefaultHealCooldown = 5 * time.Minute
+)
+
+// HealReport is a report of an action taken on a child SA.
+type HealReport struct {
+	IKESAName   string
+	ChildSAName string
+	Action      HealAction
+	// Failures is the number of consecutive failed checks that triggered the
+	// action.
+	Failures int
+	// Error is the error of the action if it failed.
+	Error error
+}
+
+// HealReporter receives reports of actions taken by a Healer.
+type HealReporter interface {
+	ReportHeal(report HealReport)
+}
+
+// HealerConfiguration specifies how a Healer heals child SAs.
+type HealerConfiguration struct {
+	Action HealAction
+	// Threshold is the number of consecutive failed checks before the child
+	// SA is healed. Defaults to DefaultHealThreshold.
+	Threshold int
+	// Cooldown is the minimum time between actions on the same child SA.
+	// Defaults to DefaultHealCooldown.
+	Cooldown time.Duration
+	Reporter HealReporter
+	// DryRun logs and reports the decided actions to DecisionReporter instead
+	// of sending them to charon.
+	DryRun           bool
+	DecisionReporter DecisionReporter
+}
+
+func (c *HealerConfiguration) setDefaults() {
+	if c.Threshold == 0 {
+		c.Threshold = DefaultHealThreshold
+	}
+	if c.Cooldown == 0 {
+		c.Cooldown = DefaultHealCooldown
+	}
+}
+
+// Healer heals child SAs that are installed but traffic does not pass through
+// them. Checks of targets behind a child SA are reported to the
+// tcpchecker.Reporter returned by Reporter and the states of child SAs are
+// received as a IKESAStatusReceiver. Heals are queued and run by a worker so
+// checks are not blocked while a child SA is healed.
+type Healer struct {
+	client Controller
+	logger log.Logger
+	tracer trace.Tracer
+	config HealerConfiguration
+	now    func() time.Time
+
+	// mu guards the fields below which are shared with the worker. cond is
+	// signaled when the queue or a heal in progress changes.
+	mu   sync.Mutex
+	cond *sync.Cond
+	// queue holds the heals waiting to be run in order of arrival.
+	queue []healRequest
+	// children holds the health of child SAs by IKE SA name and child SA
+	// name.
+	children map[string]map[string]*childSAHealth
+	// seen holds the IKE SA names received in the current collection.
+	seen map[string]struct{}
+}
+
+type childSAHealth struct {
+	// installed is true if the child SA is in state INSTALLED.
+	installed bool
+	// uniqueID is the unique ID of the installed child SA.
+	uniqueID string
+	// failures is the number of consecutive failed checks.
+	failures   int
+	lastAction time.Time
+	// healing is true while a heal of the child SA is queued or running.
+	healing bool
+	// status is the latest status of the IKE SA of the child SA.
+	status IKESAStatus
+}
+
+// healRequest is a queued heal of an installed child SA.
+type healRequest struct {
+	ikeSAName   string
+	childSAName string
+	uniqueID    string
+	failures    int
+}
+
+// NewHealer returns a Healer taking actions on child SAs through client. A
+// worker running the heals is started. Initiations are recorded as spans by
+// tracer.
+func NewHealer(client Controller, logger log.Logger, tracer trace.Tracer, config HealerConfiguration) *Healer {
+	config.setDefaults()
+	h := &Healer{
+		client:   client,
+		logger:   logger,
+		tracer:   tracer,
+		config:   config,
+		now:      time.Now,
+		children: make(map[string]map[string]*childSAHealth),
+		seen:     make(map[string]struct{}),
+	}
+	h.cond = sync.NewCond(&h.mu)
+	go h.healWorker()
+	return h
+}
+
+func (h *Healer) child(ikeSAName, childSAName string) *childSAHealth {
+	children, ok := h.children[ikeSAName]
+	if !ok {
+		children = make(map[string]*childSAHealth)
+		h.children[ikeSAName] = children
+	}
+	child, ok := children[childSAName]
+	if !ok {
+		child = &childSAHealth{}
+		children[childSAName] = child
+	}
+	return child
+}
+
+func (h *Healer) IKESAStatus(ikeSAStatus IKESAStatus) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.seen[ikeSAStatus.Name] = struct{}{}
+	childSAs := make(map[string]struct{})
+	for _, childSA := range ikeSAStatus.ChildSA {
+		childSAs[childSA.Name] = struct{}{}
+		child := h.child(ikeSAStatus.Name, childSA.Name)
+		child.status = ikeSAStatus
+		installed := childSA.State != nil && childSA.State.State == vici.ChildSAStateInstalled
+		uniqueID := ""
+		if installed {
+			uniqueID = childSA.State.UniqueID
+		}
+		// failures through a previous child SA do not count towards a heal of
+		// a new one
+		if !installed || uniqueID != child.uniqueID {
+			child.failures = 0
+		}
+		child.installed = installed
+		child.uniqueID = uniqueID
+	}
+	for name, child := range h.children[ikeSAStatus.Name] {
+		if _, ok := childSAs[name]; !ok && !child.healing {
+			delete(h.children[ikeSAStatus.Name], name)
+		}
+	}
+}
+
+// CollectionDone forgets the child SAs of IKE SAs that were not received in
+// the collection unless they are being healed.
+func (h *Healer) CollectionDone() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ikeSAName, children := range h.children {
+		if _, ok := h.seen[ikeSAName]; ok {
+			continue
+		}
+		for name, child := range children {
+			if !child.healing {
+				delete(children, name)
+			}
+		}
+		if len(children) == 0 {
+			delete(h.children, ikeSAName)
+		}
+	}
+	h.seen = make(map[string]struct{})
+}
+
+// Healing returns true if a heal of the child SA childSAName of the IKE SA
+// ikeSAName is queued or running.
+func (h *Healer) Healing(ikeSAName, childSAName string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	child, ok := h.children[ikeSAName][childSAName]
+	return ok && child.healing
+}
+
+// Reporter returns a tcpchecker.Reporter of checks through the child SA
+// childSAName of the IKE SA ikeSAName.
+func (h *Healer) Reporter(ikeSAName, childSAName string) tcpchecker.Reporter {
+	return &healerReporter{
+		healer:      h,
+		ikeSAName:   ikeSAName,
+		childSAName: childSAName,
+	}
+}
+
+type healerReporter struct {
+	healer      *Healer
+	ikeSAName   string
+	childSAName string
+}
+
+func (r *healerReporter) ReportPortCheck(report tcpchecker.Report) {
+	r.healer.check(r.ikeSAName, r.childSAName, report.Open)
+}
+
+// check records the result of a check through a child SA and heals it if the
+// threshold of consecutive failures is reached.
+func (h *Healer) check(ikeSAName, childSAName string, open bool) {
+	h.mu.Lock()
+	child := h.child(ikeSAName, childSAName)
+	if open {
+		child.failures = 0
+		h.mu.Unlock()
+		return
+	}
+	// checks through a child SA being healed are expected to fail
+	if child.healing {
+		h.mu.Unlock()
+		return
+	}
+	// missing child SAs are left to the Reinitiator and failures through them
+	// do not count towards a heal of the next installed child SA
+	if !child.installed {
+		child.failures = 0
+		h.mu.Unlock()
+		return
+	}
+	child.failures++
+	failures := child.failures
+	if failures < h.config.Threshold {
+		h.mu.Unlock()
+		return
+	}
+	now := h.now()
+	if lastAction := child.lastAction; !lastAction.IsZero() && now.Sub(lastAction) < h.config.Cooldown {
+		h.mu.Unlock()
+		h.logger.Debugf("Skip %s of Child SA %s.%s after %d failed checks as last action was %s ago", h.config.Action, ikeSAName, childSAName, failures, now.Sub(lastAction))
+		return
+	}
+	child.lastAction = now
+	child.failures = 0
+
+	if h.config.DryRun {
+		uniqueID := child.uniqueID
+		status := child.status
+		h.mu.Unlock()
+		reportDecision(h.logger, h.config.DecisionReporter, Decision{
+			Component:   "healer",
+			IKESAName:   ikeSAName,
+			ChildSAName: childSAName,
+			Action:      string(h.config.Action),
+			Reason:      fmt.Sprintf("%d consecutive checks through installed Child SA %s.%s[%s] failed", failures, ikeSAName, childSAName, uniqueID),
+			IKESAStatus: status,
+		})
+		return
+	}
+
+	child.healing = true
+	h.queue = append(h.queue, healRequest{
+		ikeSAName:   ikeSAName,
+		childSAName: childSAName,
+		uniqueID:    child.uniqueID,
+		failures:    failures,
+	})
+	h.cond.Signal()
+	h.mu.Unlock()
+}
+
+// healWorker runs the queued heals one at a time.
+func (h *Healer) healWorker() {
+	for {
+		h.process(h.dequeue())
+	}
+}
+
+// dequeue blocks until a heal is queued and returns it.
+func (h *Healer) dequeue() healRequest {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for len(h.queue) == 0 {
+		h.cond.Wait()
+	}
+	request := h.queue[0]
+	h.queue = h.queue[1:]
+	return request
+}
+
+// process heals a child SA, reports the result and marks the heal as done.
+func (h *Healer) process(request healRequest) {
+	h.logger.Infof("Healing Child SA %s.%s with %s after %d failed checks", request.ikeSAName, request.childSAName, h.config.Action, request.failures)
+	err := h.heal(request.ikeSAName, request.childSAName, request.uniqueID)
+	if err != nil {
+		h.logger.Errorf("Failed to %s Child SA %s.%s: %v", h.config.Action, request.ikeSAName, request.childSAName, err)
+	} else {
+		h.logger.Infof("Healed Child SA %s.%s with %s", request.ikeSAName, request.childSAName, h.config.Action)
+	}
+	if h.config.Reporter != nil {
+		h.config.Reporter.ReportHeal(HealReport{
+			IKESAName:   request.ikeSAName,
+			ChildSAName: request.childSAName,
+			Action:      h.config.Action,
+			Failures:    request.failures,
+			Error:       err,
+		})
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.child(request.ikeSAName, request.childSAName).healing = false
+	h.cond.Broadcast()
+}
+
+func (h *Healer) heal(ikeSAName, childSAName, uniqueID string) error {
+	switch h.config.Action {
+	case HealActionRekey:
+		return h.client.Rekey(&vici.RekeyRequest{
+			Child_id: uniqueID,
+		})
+	case HealActionReinitiate:
+		err := h.client.Terminate(&vici.TerminateRequest{
+			Child_id: uniqueID,
+		})
+		if err != nil {
+			return fmt.Errorf("terminate: %w", err)
+		}
+		return initiate(h.logger, h.client, h.tracer, initiateData{
+			IKEName:   ikeSAName,
+			ChildName: childSAName,
+		}).Error
+	default:
+		return fmt.Errorf("unknown heal action '%s'", h.config.Action)
+	}
+}
@@ -0,0 +1,383 @@
+package strongswan
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lunarway/strong-duckling/internal/tcpchecker"
+	"github.com/lunarway/strong-duckling/internal/test"
+	"github.com/lunarway/strong-duckling/internal/vici"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+type fakeController struct {
+	fakeInitiator
+	terminateErr error
+	requests     []string
+}
+
+func (f *fakeController) Initiate(child string, ike string, logger func(fields map[string]interface{})) error {
+	f.requests = append(f.requests, "initiate "+ike+"."+child)
+	return f.fakeInitiator.Initiate(child, ike, logger)
+}
+
+func (f *fakeController) Terminate(r *vici.TerminateRequest) error {
+	f.requests = append(f.requests, "terminate "+firstNonEmpty(r.Child_id, r.Ike_id, r.Ike))
+	return f.terminateErr
+}
+
+func (f *fakeController) Rekey(r *vici.RekeyRequest) error {
+	f.requests = append(f.requests, "rekey "+firstNonEmpty(r.Child_id, r.Ike_id))
+	return f.err
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// waitHealed blocks until no heal of healer is queued or running.
+func waitHealed(healer *Healer) {
+	healer.mu.Lock()
+	defer healer.mu.Unlock()
+	for healing(healer) {
+		healer.cond.Wait()
+	}
+}
+
+func healing(healer *Healer) bool {
+	for _, children := range healer.children {
+		for _, child := range children {
+			if child.healing {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type healReports []HealReport
+
+func (r *healReports) ReportHeal(report HealReport) {
+	*r = append(*r, report)
+}
+
+func TestHealer(t *testing.T) {
+	installed := IKESAStatus{
+		Name: "partner1",
+		ChildSA: []ChildSAStatus{
+			{
+				Name: "net-1",
+				State: &vici.ChildSA{
+					UniqueID: "7",
+					State:    "INSTALLED",
+				},
+			},
+		},
+	}
+	missing := IKESAStatus{
+		Name: "partner1",
+		ChildSA: []ChildSAStatus{
+			{
+				Name: "net-1",
+			},
+		},
+	}
+	tt := []struct {
+		name     string
+		action   HealAction
+		status   IKESAStatus
+		checks   []bool
+		err      error
+		requests []string
+		reports  int
+	}{
+		{
+			name:     "rekey after threshold",
+			action:   HealActionRekey,
+			status:   installed,
+			checks:   []bool{false, false, false},
+			requests: []string{"rekey 7"},
+			reports:  1,
+		},
+		{
+			name:     "reinitiate after threshold",
+			action:   HealActionReinitiate,
+			status:   installed,
+			checks:   []bool{false, false, false},
+			requests: []string{"terminate 7", "initiate partner1.net-1"},
+			reports:  1,
+		},
+		{
+			name:   "below threshold",
+			action: HealActionRekey,
+			status: installed,
+			checks: []bool{false, false},
+		},
+		{
+			name:   "successful check resets failures",
+			action: HealActionRekey,
+			status: installed,
+			checks: []bool{false, false, true, false, false},
+		},
+		{
+			name:   "missing child sa",
+			action: HealActionRekey,
+			status: missing,
+			checks: []bool{false, false, false},
+		},
+		{
+			name:     "cooldown",
+			action:   HealActionRekey,
+			status:   installed,
+			checks:   []bool{false, false, false, false, false, false},
+			requests: []string{"rekey 7"},
+			reports:  1,
+		},
+		{
+			name:     "failed action",
+			action:   HealActionRekey,
+			status:   installed,
+			checks:   []bool{false, false, false},
+			err:      errors.New("rekeying CHILD_SA failed"),
+			requests: []string{"rekey 7"},
+			reports:  1,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &fakeController{
+				fakeInitiator: fakeInitiator{
+					err: tc.err,
+				},
+			}
+			var reports healReports
+			healer := NewHealer(client, test.NewLogger(t), noop.NewTracerProvider().Tracer(""), HealerConfiguration{
+				Action:    tc.action,
+				Threshold: 3,
+				Cooldown:  time.Minute,
+				Reporter:  &reports,
+			})
+			healer.IKESAStatus(tc.status)
+			reporter := healer.Reporter("partner1", "net-1")
+			for _, open := range tc.checks {
+				reporter.ReportPortCheck(tcpchecker.Report{
+					Open: open,
+				})
+			}
+			waitHealed(healer)
+
+			assert.Equal(t, tc.requests, client.requests, "requests not as expected")
+			if !assert.Len(t, reports, tc.reports, "reports not as expected") {
+				return
+			}
+			for _, report := range reports {
+				assert.Equal(t, tc.action, report.Action, "action not as expected")
+				assert.Equal(t, 3, report.Failures, "failures not as expected")
+				assert.Equal(t, tc.err, report.Error, "error not as expected")
+			}
+		})
+	}
+}
+
+func TestHealer_cooldownExpired(t *testing.T) {
+	client := &fakeController{}
+	healer := NewHealer(client, test.NewLogger(t), noop.NewTracerProvider().Tracer(""), HealerConfiguration{
+		Action:    HealActionRekey,
+		Threshold: 1,
+		Cooldown:  time.Minute,
+	})
+	now := time.Now()
+	healer.now = func() time.Time {
+		return now
+	}
+	healer.IKESAStatus(IKESAStatus{
+		Name: "partner1",
+		ChildSA: []ChildSAStatus{
+			{
+				Name: "net-1",
+				State: &vici.ChildSA{
+					UniqueID: "7",
+					State:    "INSTALLED",
+				},
+			},
+		},
+	})
+	reporter := healer.Reporter("partner1", "net-1")
+
+	reporter.ReportPortCheck(tcpchecker.Report{})
+	waitHealed(healer)
+	now = now.Add(30 * time.Second)
+	reporter.ReportPortCheck(tcpchecker.Report{})
+	now = now.Add(31 * time.Second)
+	reporter.ReportPortCheck(tcpchecker.Report{})
+	waitHealed(healer)
+
+	assert.Equal(t, []string{"rekey 7", "rekey 7"}, client.requests, "requests not as expected")
+}
+
+func TestHealer_failuresReset(t *testing.T) {
+	installed := func(uniqueID string) IKESAStatus {
+		return IKESAStatus{
+			Name: "partner1",
+			ChildSA: []ChildSAStatus{
+				{
+					Name: "net-1",
+					State: &vici.ChildSA{
+						UniqueID: uniqueID,
+						State:    "INSTALLED",
+					},
+				},
+			},
+		}
+	}
+	missing := IKESAStatus{
+		Name: "partner1",
+		ChildSA: []ChildSAStatus{
+			{
+				Name: "net-1",
+			},
+		},
+	}
+	tt := []struct {
+		name     string
+		statuses []IKESAStatus
+	}{
+		{
+			name:     "missing child sa",
+			statuses: []IKESAStatus{missing, installed("8")},
+		},
+		{
+			name:     "new child sa",
+			statuses: []IKESAStatus{installed("7"), installed("8")},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &fakeController{}
+			healer := NewHealer(client, test.NewLogger(t), noop.NewTracerProvider().Tracer(""), HealerConfiguration{
+				Action:    HealActionRekey,
+				Threshold: 3,
+				Cooldown:  time.Minute,
+			})
+			reporter := healer.Reporter("partner1", "net-1")
+
+			// failures through the previous child SA do not count towards a
+			// heal of the next one
+			for _, status := range tc.statuses {
+				healer.IKESAStatus(status)
+				reporter.ReportPortCheck(tcpchecker.Report{})
+				reporter.ReportPortCheck(tcpchecker.Report{})
+			}
+			waitHealed(healer)
+			assert.Empty(t, client.requests, "requests not as expected")
+
+			reporter.ReportPortCheck(tcpchecker.Report{})
+			waitHealed(healer)
+			assert.Equal(t, []string{"rekey 8"}, client.requests, "requests not as expected")
+		})
+	}
+}
+
+// blockingTerminator blocks terminations until release is closed.
+type blockingTerminator struct {
+	fakeController
+	release chan struct{}
+}
+
+func (b *blockingTerminator) Terminate(r *vici.TerminateRequest) error {
+	<-b.release
+	return b.fakeController.Terminate(r)
+}
+
+func TestHealer_healing(t *testing.T) {
+	client := &blockingTerminator{
+		release: make(chan struct{}),
+	}
+	healer := NewHealer(client, test.NewLogger(t), noop.NewTracerProvider().Tracer(""), HealerConfiguration{
+		Action:    HealActionReinitiate,
+		Threshold: 1,
+	})
+	var reports reinitiatorReports
+	reinitiator := NewReinitiator(nil, test.NewLogger(t), noop.NewTracerProvider().Tracer(""), ReinitiatorConfiguration{
+		Reporter: &reports,
+		Healer:   healer,
+	})
+	healer.IKESAStatus(IKESAStatus{
+		Name: "partner1",
+		ChildSA: []ChildSAStatus{
+			{
+				Name: "net-1",
+				State: &vici.ChildSA{
+					UniqueID: "7",
+					State:    "INSTALLED",
+				},
+			},
+		},
+	})
+
+	// checks are not blocked by the heal
+	reporter := healer.Reporter("partner1", "net-1")
+	reporter.ReportPortCheck(tcpchecker.Report{})
+	reporter.ReportPortCheck(tcpchecker.Report{})
+	assert.True(t, healer.Healing("partner1", "net-1"), "child SA not healing")
+
+	// the terminated child SA is left to the heal
+	reinitiator.IKESAStatus(IKESAStatus{
+		Name: "partner1",
+		State: &vici.IkeSa{
+			UniqueID: "3",
+		},
+		ChildSA: []ChildSAStatus{
+			{
+				Name: "net-1",
+			},
+		},
+	})
+	assert.Equal(t, map[SkipReason]int{SkipReasonHealing: 1}, reports.skipped, "skipped not as expected")
+	assert.Empty(t, reinitiator.queue, "healing child SA queued")
+
+	close(client.release)
+	waitHealed(healer)
+	assert.False(t, healer.Healing("partner1", "net-1"), "child SA still healing")
+	assert.Equal(t, []string{"terminate 7", "initiate partner1.net-1"}, client.requests, "requests not as expected")
+}
+
+func TestHealer_pruned(t *testing.T) {
+	healer := NewHealer(&fakeController{}, test.NewLogger(t), noop.NewTracerProvider().Tracer(""), HealerConfiguration{
+		Action: HealActionRekey,
+	})
+	healer.IKESAStatus(IKESAStatus{
+		Name: "partner1",
+		ChildSA: []ChildSAStatus{
+			{Name: "net-1"},
+			{Name: "net-2"},
+		},
+	})
+	healer.IKESAStatus(IKESAStatus{
+		Name: "partner2",
+		ChildSA: []ChildSAStatus{
+			{Name: "net-1"},
+		},
+	})
+	healer.CollectionDone()
+
+	// net-2 is removed from partner1 and partner2 is gone
+	healer.IKESAStatus(IKESAStatus{
+		Name: "partner1",
+		ChildSA: []ChildSAStatus{
+			{Name: "net-1"},
+		},
+	})
+	healer.CollectionDone()
+
+	assert.Len(t, healer.children, 1, "IKE SAs not as expected")
+	assert.Len(t, healer.children["partner1"], 1, "child SAs not as expected")
+	assert.Contains(t, healer.children["partner1"], "net-1", "child SA not kept")
+}
@@ -0,0 +1,108 @@
+package strongswan
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/common/log"
+)
+
+const (
+	// DefaultHistorySize is the default number of initiations kept in an
+	// InitiationHistory.
+	DefaultHistorySize = 100
+)
+
+// InitiationHistory keeps the latest initiations in memory.
+type InitiationHistory struct {
+	size int
+
+	mu sync.Mutex
+	// initiations holds the initiations in order of completion.
+	initiations []Initiation
+}
+
+// NewInitiationHistory returns an InitiationHistory keeping the latest size
+// initiations. If size is 0 DefaultHistorySize is used.
+func NewInitiationHistory(size int) *InitiationHistory {
+	if size == 0 {
+		size = DefaultHistorySize
+	}
+	return &InitiationHistory{
+		size: size,
+	}
+}
+
+func (h *InitiationHistory) add(initiation Initiation) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.initiations = append(h.initiations, initiation)
+	if len(h.initiations) > h.size {
+		h.initiations = h.initiations[len(h.initiations)-h.size:]
+	}
+}
+
+// Initiations returns the kept initiations with the latest first.
+func (h *InitiationHistory) Initiations() []Initiation {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	initiations := make([]Initiation, len(h.initiations))
+	for i, initiation := range h.initiations {
+		initiations[len(initiations)-1-i] = initiation
+	}
+	return initiations
+}
+
+type historyResponse struct {
+	Initiations []historyInitiation `json:"initiations"`
+}
+
+type historyInitiation struct {
+	IKESAName       string    `json:"ike_sa_name"`
+	ChildSAName     string    `json:"child_sa_name"`
+	Action          string    `json:"action"`
+	Start           time.Time `json:"start"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	Success         bool      `json:"success"`
+	Error           string    `json:"error,omitempty"`
+	ControlLog      []string  `json:"control_log"`
+}
+
+// RegisterHandler serves the kept initiations as JSON on /reinitiator/history
+// of serveMux.
+func (h *InitiationHistory) RegisterHandler(serveMux *http.ServeMux) {
+	serveMux.HandleFunc("/reinitiator/history", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "only get allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		response := historyResponse{
+			Initiations: []historyInitiation{},
+		}
+		for _, initiation := range h.Initiations() {
+			i := historyInitiation{
+				IKESAName:       initiation.IKESAName,
+				ChildSAName:     initiation.ChildSAName,
+				Action:          string(initiation.Action),
+				Start:           initiation.Start,
+				DurationSeconds: initiation.Duration.Seconds(),
+				Success:         initiation.Error == nil,
+				ControlLog:      initiation.ControlLog,
+			}
+			if initiation.Error != nil {
+				i.Error = initiation.Error.Error()
+			}
+			if i.ControlLog == nil {
+				i.ControlLog = []string{}
+			}
+			response.Initiations = append(response.Initiations, i)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(response)
+		if err != nil {
+			log.Debugf("Failed to write initiation history: %v", err)
+		}
+	})
+}
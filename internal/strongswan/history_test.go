@@ -0,0 +1,72 @@
+package strongswan
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInitiationHistory_size(t *testing.T) {
+	h := NewInitiationHistory(2)
+	h.add(Initiation{ChildSAName: "net-1"})
+	h.add(Initiation{ChildSAName: "net-2"})
+	h.add(Initiation{ChildSAName: "net-3"})
+
+	assert.Equal(t, []Initiation{{ChildSAName: "net-3"}, {ChildSAName: "net-2"}}, h.Initiations(), "initiations not as expected")
+}
+
+func TestInitiationHistory_RegisterHandler(t *testing.T) {
+	h := NewInitiationHistory(0)
+	start := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	h.add(Initiation{
+		IKESAName:   "gw-gw",
+		ChildSAName: "net-0",
+		Action:      ReinitiationInitiateChild,
+		Start:       start,
+		Duration:    1500 * time.Millisecond,
+		ControlLog:  []string{"initiating IKE_SA gw-gw[3] to 10.0.0.2", "establishing CHILD_SA net-0 failed"},
+		Error:       errors.New("initiate unsuccessful: establishing CHILD_SA 'net-0' failed"),
+	})
+	h.add(Initiation{
+		IKESAName:   "gw-gw",
+		ChildSAName: "net-1",
+		Action:      ReinitiationTerminateInitiate,
+		Start:       start.Add(time.Minute),
+		Duration:    time.Second,
+	})
+	serveMux := http.NewServeMux()
+	h.RegisterHandler(serveMux)
+
+	recorder := httptest.NewRecorder()
+	serveMux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/reinitiator/history", nil))
+
+	assert.Equal(t, http.StatusOK, recorder.Code, "status code not as expected")
+	assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"), "content type not as expected")
+	assert.JSONEq(t, `{
+		"initiations": [
+			{
+				"ike_sa_name": "gw-gw",
+				"child_sa_name": "net-1",
+				"action": "terminate_initiate",
+				"start": "2020-01-02T03:05:05Z",
+				"duration_seconds": 1,
+				"success": true,
+				"control_log": []
+			},
+			{
+				"ike_sa_name": "gw-gw",
+				"child_sa_name": "net-0",
+				"action": "initiate_child",
+				"start": "2020-01-02T03:04:05Z",
+				"duration_seconds": 1.5,
+				"success": false,
+				"error": "initiate unsuccessful: establishing CHILD_SA 'net-0' failed",
+				"control_log": ["initiating IKE_SA gw-gw[3] to 10.0.0.2", "establishing CHILD_SA net-0 failed"]
+			}
+		]
+	}`, recorder.Body.String(), "body not as expected")
+}
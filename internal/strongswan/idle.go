@@ -0,0 +1,267 @@
+package strongswan
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lunarway/strong-duckling/internal/vici"
+	"github.com/prometheus/common/log"
+)
+
+var _ IKESAStatusReceiver = &IdleDetector{}
+var _ CollectionDoneReceiver = &IdleDetector{}
+
+// IdleDirection is the direction of traffic through a child SA.
+type IdleDirection string
+
+const (
+	// IdleDirectionIn is inbound traffic from the remote peer.
+	IdleDirectionIn IdleDirection = "in"
+	// IdleDirectionOut is outbound traffic to the remote peer.
+	IdleDirectionOut IdleDirection = "out"
+)
+
+// IdleStatus is the idle status of one direction of an installed child SA.
+type IdleStatus struct {
+	IKESAName   string
+	ChildSAName string
+	Direction   IdleDirection
+	// Idle is true if no packets were seen in Direction for Threshold.
+	Idle bool
+	// Changed is true if Idle changed since the previous status.
+	Changed bool
+	// Silence is the time since the last packet in Direction or since the
+	// child SA was installed if it has seen no packets.
+	Silence   time.Duration
+	Threshold time.Duration
+	// Removed is true if the direction is no longer checked, eg. because the
+	// child SA is no longer installed or its IKE SA is gone. It is the last
+	// status of the direction and is never idle.
+	Removed bool
+}
+
+// IdleStatusReceiver receives idle statuses of child SAs.
+type IdleStatusReceiver interface {
+	IdleStatus(status IdleStatus)
+}
+
+// IdleRule applies silence thresholds to child SAs matching name patterns.
+// Patterns use the syntax of path.Match and an empty pattern matches all
+// names. A threshold of 0 disables detection in its direction.
+type IdleRule struct {
+	IKESAName   string
+	ChildSAName string
+	In          time.Duration
+	Out         time.Duration
+}
+
+func (r IdleRule) matches(ikeSAName, childSAName string) bool {
+	return matchPattern(r.IKESAName, ikeSAName) && matchPattern(r.ChildSAName, childSAName)
+}
+
+// ParseIdleRule parses a rule of the form
+//
+//	<ike sa pattern>[/<child sa pattern>]?in=<duration>&out=<duration>
+//
+// eg. partner-*/net-*?in=5m&out=10m.
+func ParseIdleRule(s string) (IdleRule, error) {
+	patterns, query, ok := strings.Cut(s, "?")
+	if !ok {
+		return IdleRule{}, fmt.Errorf("could not understand idle rule %s", s)
+	}
+	var r IdleRule
+	r.IKESAName, r.ChildSAName, _ = strings.Cut(patterns, "/")
+	err := validatePatterns(r.IKESAName, r.ChildSAName)
+	if err != nil {
+		return IdleRule{}, err
+	}
+	options, err := url.ParseQuery(query)
+	if err != nil {
+		return IdleRule{}, fmt.Errorf("parse options: %w", err)
+	}
+	for key, values := range options {
+		var d *time.Duration
+		switch key {
+		case "in":
+			d = &r.In
+		case "out":
+			d = &r.Out
+		default:
+			return IdleRule{}, fmt.Errorf("unknown option '%s'", key)
+		}
+		*d, err = time.ParseDuration(values[len(values)-1])
+		if err != nil {
+			return IdleRule{}, fmt.Errorf("could not parse %s: %w", key, err)
+		}
+		if *d < 0 {
+			return IdleRule{}, fmt.Errorf("%s must not be negative", key)
+		}
+	}
+	if r.In == 0 && r.Out == 0 {
+		return IdleRule{}, fmt.Errorf("in or out is required")
+	}
+	return r, nil
+}
+
+// IdleConfiguration specifies how an IdleDetector detects idle child SAs.
+type IdleConfiguration struct {
+	// Rules are matched in order against the names of installed child SAs and
+	// the first matching rule applies. Child SAs matching no rule are not
+	// checked.
+	Rules     []IdleRule
+	Receivers []IdleStatusReceiver
+}
+
+// IdleDetector detects installed child SAs that have seen no inbound or
+// outbound packets for longer than a threshold. Idleness in one direction
+// only, ie. one-way traffic, is a strong signal of a broken remote policy.
+//
+// Idle statuses are sent to the receivers on each IKESAStatus and transitions
+// are logged. When a child SA is no longer installed or its IKE SA is not
+// received in a collection a last status with Removed set is sent.
+type IdleDetector struct {
+	logger log.Logger
+	config IdleConfiguration
+
+	// idle holds the idle state of checked directions of child SAs by IKE SA
+	// name.
+	idle map[string]map[idleKey]IdleStatus
+	// seen holds the IKE SA names received in the current collection.
+	seen map[string]struct{}
+}
+
+type idleKey struct {
+	childSAName string
+	direction   IdleDirection
+}
+
+// NewIdleDetector returns an IdleDetector sending idle statuses to the
+// receivers of config.
+func NewIdleDetector(logger log.Logger, config IdleConfiguration) *IdleDetector {
+	return &IdleDetector{
+		logger: logger,
+		config: config,
+		idle:   make(map[string]map[idleKey]IdleStatus),
+		seen:   make(map[string]struct{}),
+	}
+}
+
+func (d *IdleDetector) IKESAStatus(ikeSAStatus IKESAStatus) {
+	d.seen[ikeSAStatus.Name] = struct{}{}
+	previous := d.idle[ikeSAStatus.Name]
+	current := make(map[idleKey]IdleStatus)
+	for _, childSA := range ikeSAStatus.ChildSA {
+		if childSA.State == nil || childSA.State.State != vici.ChildSAStateInstalled {
+			continue
+		}
+		rule, ok := d.rule(ikeSAStatus.Name, childSA.Name)
+		if !ok {
+			continue
+		}
+		for _, direction := range []struct {
+			direction  IdleDirection
+			threshold  time.Duration
+			lastPacket string
+		}{
+			{IdleDirectionIn, rule.In, childSA.State.LastPacketInSeconds},
+			{IdleDirectionOut, rule.Out, childSA.State.LastPacketOutSeconds},
+		} {
+			if direction.threshold == 0 {
+				continue
+			}
+			silence, ok := silence(direction.lastPacket, childSA.State.InstallTimeSeconds)
+			if !ok {
+				continue
+			}
+			key := idleKey{childSAName: childSA.Name, direction: direction.direction}
+			status := IdleStatus{
+				IKESAName:   ikeSAStatus.Name,
+				ChildSAName: childSA.Name,
+				Direction:   direction.direction,
+				Idle:        silence >= direction.threshold,
+				Silence:     silence,
+				Threshold:   direction.threshold,
+			}
+			status.Changed = status.Idle != previous[key].Idle
+			current[key] = status
+			d.send(status)
+		}
+	}
+	for key, status := range previous {
+		if _, ok := current[key]; ok {
+			continue
+		}
+		d.remove(status)
+	}
+	if len(current) == 0 {
+		delete(d.idle, ikeSAStatus.Name)
+		return
+	}
+	d.idle[ikeSAStatus.Name] = current
+}
+
+// CollectionDone removes the checked directions of IKE SAs that were not
+// received in the collection.
+func (d *IdleDetector) CollectionDone() {
+	for ikeSAName, statuses := range d.idle {
+		if _, ok := d.seen[ikeSAName]; ok {
+			continue
+		}
+		for _, status := range statuses {
+			d.remove(status)
+		}
+		delete(d.idle, ikeSAName)
+	}
+	d.seen = make(map[string]struct{})
+}
+
+// remove sends the last status of a direction that is no longer checked.
+func (d *IdleDetector) remove(status IdleStatus) {
+	d.send(IdleStatus{
+		IKESAName:   status.IKESAName,
+		ChildSAName: status.ChildSAName,
+		Direction:   status.Direction,
+		Changed:     status.Idle,
+		Threshold:   status.Threshold,
+		Removed:     true,
+	})
+}
+
+func (d *IdleDetector) rule(ikeSAName, childSAName string) (IdleRule, bool) {
+	for _, rule := range d.config.Rules {
+		if rule.matches(ikeSAName, childSAName) {
+			return rule, true
+		}
+	}
+	return IdleRule{}, false
+}
+
+func (d *IdleDetector) send(status IdleStatus) {
+	if status.Changed {
+		if status.Idle {
+			d.logger.Infof("Child SA %s.%s is idle: no %sbound packets for %s exceeding %s", status.IKESAName, status.ChildSAName, status.Direction, status.Silence, status.Threshold)
+		} else {
+			d.logger.Infof("Child SA %s.%s is no longer idle for %sbound packets", status.IKESAName, status.ChildSAName, status.Direction)
+		}
+	}
+	for _, receiver := range d.config.Receivers {
+		receiver.IdleStatus(status)
+	}
+}
+
+// silence returns the time since the last packet from the use-in or use-out
+// value lastPacket. If the child SA has seen no packets the time since it was
+// installed is used.
+func silence(lastPacket, installTime string) (time.Duration, bool) {
+	if lastPacket == "" {
+		lastPacket = installTime
+	}
+	seconds, err := strconv.ParseUint(lastPacket, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
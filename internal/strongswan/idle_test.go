@@ -0,0 +1,176 @@
+package strongswan
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lunarway/strong-duckling/internal/test"
+	"github.com/lunarway/strong-duckling/internal/vici"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseIdleRule(t *testing.T) {
+	tt := []struct {
+		name  string
+		input string
+		rule  IdleRule
+		err   string
+	}{
+		{
+			name:  "both directions",
+			input: "partner-*/net-*?in=5m&out=10m",
+			rule:  IdleRule{IKESAName: "partner-*", ChildSAName: "net-*", In: 5 * time.Minute, Out: 10 * time.Minute},
+		},
+		{
+			name:  "all child sas",
+			input: "?in=5m",
+			rule:  IdleRule{In: 5 * time.Minute},
+		},
+		{
+			name:  "missing options",
+			input: "partner1",
+			err:   "could not understand idle rule partner1",
+		},
+		{
+			name:  "no threshold",
+			input: "partner1?",
+			err:   "in or out is required",
+		},
+		{
+			name:  "unknown option",
+			input: "partner1?interval=5m",
+			err:   "unknown option 'interval'",
+		},
+		{
+			name:  "invalid duration",
+			input: "partner1?in=5",
+			err:   `could not parse in: time: missing unit in duration "5"`,
+		},
+		{
+			name:  "negative duration",
+			input: "partner1?out=-5m",
+			err:   "out must not be negative",
+		},
+		{
+			name:  "invalid pattern",
+			input: "partner[?in=5m",
+			err:   "invalid pattern 'partner[': syntax error in pattern",
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			rule, err := ParseIdleRule(tc.input)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err, "error")
+				return
+			}
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tc.rule, rule, "rule")
+		})
+	}
+}
+
+type idleStatuses []IdleStatus
+
+func (s *idleStatuses) IdleStatus(status IdleStatus) {
+	*s = append(*s, status)
+}
+
+func idleStatus(childSAs ...ChildSAStatus) IKESAStatus {
+	return IKESAStatus{
+		Name:    "partner1",
+		ChildSA: childSAs,
+	}
+}
+
+func idleChildSA(name, useIn, useOut string) ChildSAStatus {
+	return ChildSAStatus{
+		Name: name,
+		State: &vici.ChildSA{
+			Name:                 name,
+			State:                vici.ChildSAStateInstalled,
+			InstallTimeSeconds:   "3600",
+			LastPacketInSeconds:  useIn,
+			LastPacketOutSeconds: useOut,
+		},
+	}
+}
+
+func TestIdleDetector(t *testing.T) {
+	var statuses idleStatuses
+	d := NewIdleDetector(test.NewLogger(t), IdleConfiguration{
+		Rules: []IdleRule{
+			{IKESAName: "partner1", ChildSAName: "net-1", In: time.Minute, Out: time.Minute},
+			{IKESAName: "partner1", ChildSAName: "net-2", In: time.Minute},
+		},
+		Receivers: []IdleStatusReceiver{&statuses},
+	})
+	in := func(childSAName string, idle, changed bool, silence time.Duration) IdleStatus {
+		return IdleStatus{IKESAName: "partner1", ChildSAName: childSAName, Direction: IdleDirectionIn, Idle: idle, Changed: changed, Silence: silence, Threshold: time.Minute}
+	}
+	out := func(childSAName string, idle, changed bool, silence time.Duration) IdleStatus {
+		return IdleStatus{IKESAName: "partner1", ChildSAName: childSAName, Direction: IdleDirectionOut, Idle: idle, Changed: changed, Silence: silence, Threshold: time.Minute}
+	}
+
+	// one-way traffic through net-1 and no packets through net-2 since install
+	d.IKESAStatus(idleStatus(
+		idleChildSA("net-1", "120", "5"),
+		idleChildSA("net-2", "", ""),
+		idleChildSA("net-3", "120", "120"),
+	))
+	assert.Equal(t, idleStatuses{
+		in("net-1", true, true, 2*time.Minute),
+		out("net-1", false, false, 5*time.Second),
+		in("net-2", true, true, time.Hour),
+	}, statuses, "statuses not as expected")
+
+	// traffic resumes through net-1 and net-2 is no longer installed
+	statuses = nil
+	d.IKESAStatus(idleStatus(
+		idleChildSA("net-1", "1", "1"),
+		ChildSAStatus{Name: "net-2"},
+	))
+	removed := in("net-2", false, true, 0)
+	removed.Removed = true
+	assert.Equal(t, idleStatuses{
+		in("net-1", false, true, time.Second),
+		out("net-1", false, false, time.Second),
+		removed,
+	}, statuses, "statuses not as expected")
+
+	// net-2 is installed again
+	statuses = nil
+	d.IKESAStatus(idleStatus(
+		idleChildSA("net-1", "1", "1"),
+		idleChildSA("net-2", "", ""),
+	))
+	assert.Equal(t, idleStatuses{
+		in("net-1", false, false, time.Second),
+		out("net-1", false, false, time.Second),
+		in("net-2", true, true, time.Hour),
+	}, statuses, "statuses not as expected")
+}
+
+func TestIdleDetector_gone(t *testing.T) {
+	var statuses idleStatuses
+	d := NewIdleDetector(test.NewLogger(t), IdleConfiguration{
+		Rules: []IdleRule{
+			{In: time.Minute},
+		},
+		Receivers: []IdleStatusReceiver{&statuses},
+	})
+
+	d.IKESAStatus(idleStatus(idleChildSA("net-1", "5", "5")))
+	d.CollectionDone()
+	assert.Equal(t, idleStatuses{
+		{IKESAName: "partner1", ChildSAName: "net-1", Direction: IdleDirectionIn, Silence: 5 * time.Second, Threshold: time.Minute},
+	}, statuses, "statuses not as expected")
+
+	// partner1 is unloaded while net-1 is not idle
+	statuses = nil
+	d.CollectionDone()
+	assert.Equal(t, idleStatuses{
+		{IKESAName: "partner1", ChildSAName: "net-1", Direction: IdleDirectionIn, Threshold: time.Minute, Removed: true},
+	}, statuses, "statuses not as expected")
+	assert.Empty(t, d.idle, "idle state not pruned")
+}
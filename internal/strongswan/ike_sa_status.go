@@ -8,11 +8,26 @@ type IKESAStatusReceiver interface {
 	IKESAStatus(ikeSAStatus IKESAStatus)
 }
 
+// CollectionDoneReceiver is implemented by IKESAStatusReceivers that need to
+// know when the statuses of all IKE SAs of a collection are received, eg. to
+// forget IKE SAs that are gone.
+type CollectionDoneReceiver interface {
+	CollectionDone()
+}
+
 type IKESAStatus struct {
 	Name          string
 	Configuration vici.IKEConf
 	State         *vici.IkeSa
 	ChildSA       []ChildSAStatus
+	// Sessions contains all IKE SAs of the connection. Connections accepting
+	// multiple peers, eg. roadwarriors, can have more than one. State is the
+	// last of them.
+	Sessions []vici.IkeSa
+	// Orphaned is true if the IKE SAs have no configuration, eg. after
+	// unload-conn or a configuration reload. Configuration is then empty and
+	// ChildSA holds the child SAs of State.
+	Orphaned bool
 }
 
 type ChildSAStatus struct {
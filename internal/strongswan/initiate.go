@@ -1,82 +1,568 @@
 package strongswan
 
 import (
+	"context"
 	"fmt"
+	"math/rand"
+	"sync"
 	"time"
 
 	"github.com/lunarway/strong-duckling/internal/vici"
 	"github.com/prometheus/common/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var _ IKESAStatusReceiver = &Reinitiator{}
+var _ Initiator = &vici.ClientConn{}
 
+// Initiator initiates SAs. It is implemented by *vici.ClientConn.
+type Initiator interface {
+	Initiate(child string, ike string, logger func(fields map[string]interface{})) error
+}
+
+// ReinitiatorConfiguration specifies how a Reinitiator initiates missing child
+// SAs.
+type ReinitiatorConfiguration struct {
+	// Backoff specifies the delays between failed initiations of a child SA
+	// that does not match any of Policies.
+	Backoff BackoffConfiguration
+	// Policies are matched in order against the names of missing child SAs and
+	// the first matching rule applies. Child SAs that match no rule are
+	// initiated with ReinitiationInitiateChild and Backoff.
+	Policies []PolicyRule
+	Reporter ReinitiatorReporter
+	// History keeps the latest initiations if set.
+	History *InitiationHistory
+	// Healer skips missing child SAs being healed by it if set, as the heal
+	// initiates them again.
+	Healer *Healer
+	// DryRun logs and reports the decided actions to DecisionReporter instead
+	// of sending them to charon. Decisions do not affect the backoff so they
+	// are repeated as long as child SAs stay missing.
+	DryRun           bool
+	DecisionReporter DecisionReporter
+}
+
+// SkipReason is the reason a missing child SA is not initiated.
+type SkipReason string
+
+const (
+	// SkipReasonBackoff is used when the child SA is backed off after failed
+	// initiations.
+	SkipReasonBackoff SkipReason = "backoff"
+	// SkipReasonGivenUp is used when initiation of the child SA is given up.
+	SkipReasonGivenUp SkipReason = "given_up"
+	// SkipReasonPending is used when the child SA is already queued or being
+	// initiated.
+	SkipReasonPending SkipReason = "pending"
+	// SkipReasonHealing is used when the child SA is being healed by the
+	// Healer.
+	SkipReasonHealing SkipReason = "healing"
+)
+
+// ReinitiatorReporter receives reports on the initiations of a Reinitiator.
+type ReinitiatorReporter interface {
+	// ReportBackoff reports the delay before the next initiation of a child SA.
+	// The delay is 0 when the child SA is not backed off.
+	ReportBackoff(ikeSAName, childSAName string, delay time.Duration)
+	// ReportInitiation reports a completed initiation.
+	ReportInitiation(initiation Initiation)
+	// ReportSkipped reports a missing child SA that is not initiated.
+	ReportSkipped(ikeSAName, childSAName string, reason SkipReason)
+}
+
+// Reinitiator initiates configured child SAs that are missing. Initiations
+// are queued and run by a pool of workers, one per client. Child SAs of the
+// same IKE SA are initiated one at a time while child SAs of different IKE SAs
+// are initiated in parallel.
 type Reinitiator struct {
-	client                *vici.ClientConn
-	logger                log.Logger
-	initiateWorkerChannel chan initiateData
-	loggingTime           map[string]time.Time
-	currentInitiate       initiateData
+	logger log.Logger
+	tracer trace.Tracer
+	config ReinitiatorConfiguration
+	now    func() time.Time
+	// random returns a number in [0, 1) used for backoff jitter.
+	random func() float64
+
+	// mu guards the fields below which are shared with the workers. cond is
+	// signaled when the queue or the set of IKE SAs being initiated changes.
+	mu   sync.Mutex
+	cond *sync.Cond
+	// queue holds the child SAs waiting to be initiated in order of arrival.
+	queue []initiateData
+	// pending holds the full names of child SAs that are queued or being
+	// initiated.
+	pending map[string]struct{}
+	// initiating holds the child SA being initiated by IKE SA name.
+	initiating  map[string]initiateData
+	loggingTime map[string]time.Time
+	// backoffs holds the backoff state of child SAs that failed to initiate
+	// keyed by their full name.
+	backoffs map[string]*backoff
+	// ikeSAUniqueIDs holds the unique ID of the latest IKE SA by IKE SA name.
+	ikeSAUniqueIDs map[string]string
+	// ikeSAActions holds the unique ID of the IKE SA last reauthenticated or
+	// terminated by IKE SA name so missing child SAs of the same IKE SA only
+	// act on it once.
+	ikeSAActions map[string]string
 }
 
-func NewReinitiator(client *vici.ClientConn, logger log.Logger) *Reinitiator {
-	initiateWorkerChannel := make(chan initiateData)
-	go initiateWorker(log.With("type", "initiateWorker"), client, initiateWorkerChannel)
-	return &Reinitiator{
-		client:                client,
-		logger:                logger,
-		initiateWorkerChannel: initiateWorkerChannel,
-		loggingTime:           map[string]time.Time{},
+// NewReinitiator returns a Reinitiator initiating missing child SAs through
+// clients. A worker is started for each client so the number of clients
+// bounds the number of parallel initiations. Each initiation is recorded as a
+// span by tracer.
+func NewReinitiator(clients []Controller, logger log.Logger, tracer trace.Tracer, config ReinitiatorConfiguration) *Reinitiator {
+	config.Backoff.setDefaults()
+	config.Policies = append([]PolicyRule(nil), config.Policies...)
+	for n := range config.Policies {
+		config.Policies[n].Policy.Backoff.setDefaults()
+	}
+	i := &Reinitiator{
+		logger:         logger,
+		tracer:         tracer,
+		config:         config,
+		now:            time.Now,
+		random:         rand.Float64,
+		pending:        map[string]struct{}{},
+		initiating:     map[string]initiateData{},
+		loggingTime:    map[string]time.Time{},
+		backoffs:       map[string]*backoff{},
+		ikeSAUniqueIDs: map[string]string{},
+		ikeSAActions:   map[string]string{},
 	}
+	i.cond = sync.NewCond(&i.mu)
+	for n, client := range clients {
+		go i.initiateWorker(log.With("type", "initiateWorker").With("worker", n), client)
+	}
+	return i
 }
 
 func (i *Reinitiator) IKESAStatus(ikeSAStatus IKESAStatus) {
+	// orphaned IKE SAs cannot be initiated without a configuration
+	if ikeSAStatus.Orphaned {
+		return
+	}
+	now := i.now()
+	i.setIKESAUniqueID(ikeSAStatus)
 	for _, childSA := range ikeSAStatus.ChildSA {
-		if childSA.State != nil {
-			continue
-		}
 		initiate := initiateData{
 			IKEName:   ikeSAStatus.Name,
 			ChildName: childSA.Name,
+			policy:    i.policy(ikeSAStatus.Name, childSA.Name),
+			status:    ikeSAStatus,
 		}
-		select {
-		case i.initiateWorkerChannel <- initiate:
-			// initiation started
-			i.currentInitiate = initiate
-		default:
-			if i.shouldLog(initiate) {
-				log.Infof("Skip initiating Child SA %s, because Child SA %s is being initiated", initiate.getFullName(), i.currentInitiate.getFullName())
-				i.loggingTime[initiate.getFullName()] = time.Now()
-			}
+		if ikeSAStatus.State != nil {
+			initiate.ikeSAUniqueID = ikeSAStatus.State.UniqueID
+		}
+		if childSA.State != nil {
+			i.installed(initiate)
+			continue
+		}
+		if initiate.policy.Action == ReinitiationDisabled {
+			continue
+		}
+		if i.config.Healer != nil && i.config.Healer.Healing(ikeSAStatus.Name, childSA.Name) {
+			i.reportSkipped(initiate, SkipReasonHealing)
+			continue
 		}
+		i.enqueue(initiate, now)
 	}
 }
 
-func (i *Reinitiator) shouldLog(initiate initiateData) bool {
+// setIKESAUniqueID records the unique ID of the latest IKE SA of ikeSAStatus.
+func (i *Reinitiator) setIKESAUniqueID(ikeSAStatus IKESAStatus) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if ikeSAStatus.State == nil {
+		delete(i.ikeSAUniqueIDs, ikeSAStatus.Name)
+		delete(i.ikeSAActions, ikeSAStatus.Name)
+		return
+	}
+	i.ikeSAUniqueIDs[ikeSAStatus.Name] = ikeSAStatus.State.UniqueID
+}
+
+// claimIKESA marks the IKE SA of initiateData as acted on. It returns false if
+// it is no longer the latest IKE SA or it was already acted on for another
+// missing child SA.
+func (i *Reinitiator) claimIKESA(initiateData initiateData) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.ikeSAUniqueIDs[initiateData.IKEName] != initiateData.ikeSAUniqueID {
+		return false
+	}
+	if i.ikeSAActions[initiateData.IKEName] == initiateData.ikeSAUniqueID {
+		return false
+	}
+	i.ikeSAActions[initiateData.IKEName] = initiateData.ikeSAUniqueID
+	return true
+}
+
+// policy returns the policy of the child SA childSAName of the IKE SA
+// ikeSAName.
+func (i *Reinitiator) policy(ikeSAName, childSAName string) ReinitiationPolicy {
+	for _, rule := range i.config.Policies {
+		if rule.matches(ikeSAName, childSAName) {
+			return rule.Policy
+		}
+	}
+	return ReinitiationPolicy{
+		Action:  ReinitiationInitiateChild,
+		Backoff: i.config.Backoff,
+	}
+}
+
+// enqueue queues the child SA of initiate unless it is backed off, already
+// queued or being initiated.
+func (i *Reinitiator) enqueue(initiate initiateData, now time.Time) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if b, ok := i.backoffs[initiate.getFullName()]; ok && !b.ready(initiate.policy.Backoff, now) {
+		if b.givenUp(initiate.policy.Backoff) {
+			i.reportSkipped(initiate, SkipReasonGivenUp)
+		} else {
+			i.reportSkipped(initiate, SkipReasonBackoff)
+		}
+		return
+	}
+	if _, ok := i.pending[initiate.getFullName()]; ok {
+		i.reportSkipped(initiate, SkipReasonPending)
+		if i.shouldLog(initiate, now) {
+			i.logger.Infof("Skip initiating Child SA %s, because it is already queued or being initiated", initiate.getFullName())
+			i.loggingTime[initiate.getFullName()] = now
+		}
+		return
+	}
+	i.pending[initiate.getFullName()] = struct{}{}
+	i.queue = append(i.queue, initiate)
+	i.cond.Signal()
+}
+
+func (i *Reinitiator) shouldLog(initiate initiateData, now time.Time) bool {
 	loggingTime, ok := i.loggingTime[initiate.getFullName()]
-	return !ok || time.Now().Sub(loggingTime) >= 30*time.Second
+	return !ok || now.Sub(loggingTime) >= 30*time.Second
 }
 
-func initiateWorker(logger log.Logger, client *vici.ClientConn, workerChannel chan initiateData) {
+// dequeue blocks until a child SA of an IKE SA that is not being initiated is
+// queued and returns it. The IKE SA is marked as being initiated until done is
+// called.
+func (i *Reinitiator) dequeue() initiateData {
+	i.mu.Lock()
+	defer i.mu.Unlock()
 	for {
-		initiateData := <-workerChannel
+		for n, initiate := range i.queue {
+			if _, ok := i.initiating[initiate.IKEName]; ok {
+				continue
+			}
+			i.queue = append(i.queue[:n], i.queue[n+1:]...)
+			i.initiating[initiate.IKEName] = initiate
+			return initiate
+		}
+		i.cond.Wait()
+	}
+}
 
-		logger.Infof("Initiating a Child SA for %s", initiateData.getFullName())
-		err := client.Initiate(initiateData.ChildName, initiateData.IKEName, func(fields map[string]interface{}) {
-			msg, _ := fields["msg"]
-			logger.With("strongswanFields", fields).Infof("Initiating log for %s: %s", initiateData.getFullName(), msg)
-		})
+// done records the result of an initiation and releases its IKE SA to other
+// workers.
+func (i *Reinitiator) done(initiate initiateData, err error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.initiated(initiate, err)
+	i.releaseLocked(initiate)
+}
+
+// release releases the IKE SA of initiate to other workers without recording
+// a result as no action was sent to charon.
+func (i *Reinitiator) release(initiate initiateData) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.releaseLocked(initiate)
+}
+
+func (i *Reinitiator) releaseLocked(initiate initiateData) {
+	delete(i.initiating, initiate.IKEName)
+	delete(i.pending, initiate.getFullName())
+	// a queued child SA of the same IKE SA may be waiting
+	i.cond.Broadcast()
+}
+
+// installed resets the backoff of the installed child SA of initiate and
+// removes it from the queue so a stale initiation is not run. A child SA being
+// initiated is left to its worker.
+func (i *Reinitiator) installed(initiate initiateData) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.resetBackoffLocked(initiate)
+	for n, queued := range i.queue {
+		if queued.getFullName() != initiate.getFullName() {
+			continue
+		}
+		i.queue = append(i.queue[:n], i.queue[n+1:]...)
+		delete(i.pending, initiate.getFullName())
+		return
+	}
+}
+
+func (i *Reinitiator) resetBackoffLocked(initiate initiateData) {
+	if _, ok := i.backoffs[initiate.getFullName()]; !ok {
+		return
+	}
+	delete(i.backoffs, initiate.getFullName())
+	i.reportBackoff(initiate, 0)
+}
+
+// initiated records the result of an initiation in the backoff state of its
+// child SA. i.mu must be held.
+func (i *Reinitiator) initiated(initiate initiateData, err error) {
+	if err == nil {
+		i.resetBackoffLocked(initiate)
+		return
+	}
+	b, ok := i.backoffs[initiate.getFullName()]
+	if !ok {
+		b = &backoff{}
+		i.backoffs[initiate.getFullName()] = b
+	}
+	b.failure(initiate.policy.Backoff, i.now(), i.random())
+	i.reportBackoff(initiate, b.delay)
+	if b.givenUp(initiate.policy.Backoff) {
+		i.logger.Errorf("Giving up initiating Child SA %s after %d failed attempts", initiate.getFullName(), b.failures)
+		return
+	}
+	i.logger.Infof("Backing off initiating Child SA %s for %s after %d failed attempts", initiate.getFullName(), b.delay, b.failures)
+}
+
+func (i *Reinitiator) reportBackoff(initiate initiateData, delay time.Duration) {
+	if i.config.Reporter == nil {
+		return
+	}
+	i.config.Reporter.ReportBackoff(initiate.IKEName, initiate.ChildName, delay)
+}
+
+func (i *Reinitiator) reportSkipped(initiate initiateData, reason SkipReason) {
+	if i.config.Reporter == nil {
+		return
+	}
+	i.config.Reporter.ReportSkipped(initiate.IKEName, initiate.ChildName, reason)
+}
+
+func (i *Reinitiator) initiateWorker(logger log.Logger, client Controller) {
+	for {
+		i.process(logger, client, i.dequeue())
+	}
+}
+
+// process reinitiates the dequeued child SA of initiateData and releases it.
+//
+// Actions on the IKE SA are only taken once per IKE SA even if several of its
+// child SAs are missing. A reauthentication restores all child SAs so other
+// missing child SAs are skipped, while other missing child SAs are initiated
+// without terminating the IKE SA again.
+func (i *Reinitiator) process(logger log.Logger, client Controller, initiateData initiateData) {
+	if i.config.DryRun {
+		i.decide(logger, initiateData)
+		i.release(initiateData)
+		return
+	}
+	switch initiateData.action() {
+	case ReinitiationReinitiateIKE:
+		if !i.claimIKESA(initiateData) {
+			logger.Infof("Skip reauthenticating IKE SA %s[%s] for missing Child SA %s, because it is already reauthenticated or replaced", initiateData.IKEName, initiateData.ikeSAUniqueID, initiateData.getFullName())
+			i.reportSkipped(initiateData, SkipReasonPending)
+			i.release(initiateData)
+			return
+		}
+	case ReinitiationTerminateInitiate:
+		if !i.claimIKESA(initiateData) {
+			logger.Infof("Skip terminating IKE SA %s[%s] for missing Child SA %s, because it is already terminated or replaced", initiateData.IKEName, initiateData.ikeSAUniqueID, initiateData.getFullName())
+			initiateData.ikeSAUniqueID = ""
+		}
+	}
+	initiation := i.reinitiate(logger, client, initiateData)
+	if i.config.Reporter != nil {
+		i.config.Reporter.ReportInitiation(initiation)
+	}
+	if i.config.History != nil {
+		i.config.History.add(initiation)
+	}
+	i.done(initiateData, initiation.Error)
+}
 
+// reinitiate reinitiates the missing child SA of initiateData according to its
+// policy.
+func (i *Reinitiator) reinitiate(logger log.Logger, client Controller, initiateData initiateData) Initiation {
+	switch initiateData.action() {
+	case ReinitiationReinitiateIKE:
+		logger.Infof("Reauthenticating IKE SA %s[%s] for missing Child SA %s", initiateData.IKEName, initiateData.ikeSAUniqueID, initiateData.getFullName())
+		initiation := Initiation{
+			IKESAName:   initiateData.IKEName,
+			ChildSAName: initiateData.ChildName,
+			Action:      initiateData.action(),
+			Start:       time.Now(),
+		}
+		span := startIKESASpan(i.tracer, "reauthenticate", initiateData)
+		defer span.End()
+		initiation.Error = client.Rekey(&vici.RekeyRequest{
+			Ike_id: initiateData.ikeSAUniqueID,
+			Reauth: "yes",
+		})
+		initiation.Duration = time.Since(initiation.Start)
+		if initiation.Error != nil {
+			logger.Errorf("got error trying to reauthenticate IKE SA %s[%s]: %s", initiateData.IKEName, initiateData.ikeSAUniqueID, initiation.Error)
+			span.RecordError(initiation.Error)
+			span.SetStatus(codes.Error, initiation.Error.Error())
+		}
+		return initiation
+	case ReinitiationTerminateInitiate:
+		logger.Infof("Terminating IKE SA %s[%s] before initiating Child SA %s", initiateData.IKEName, initiateData.ikeSAUniqueID, initiateData.getFullName())
+		start := time.Now()
+		span := startIKESASpan(i.tracer, "terminate", initiateData)
+		err := client.Terminate(&vici.TerminateRequest{
+			Ike_id: initiateData.ikeSAUniqueID,
+		})
 		if err != nil {
-			logger.Errorf("got error trying to initiate Child SA %s: %s", initiateData.getFullName(), err)
+			logger.Errorf("got error trying to terminate IKE SA %s[%s]: %s", initiateData.IKEName, initiateData.ikeSAUniqueID, err)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			span.End()
+			return Initiation{
+				IKESAName:   initiateData.IKEName,
+				ChildSAName: initiateData.ChildName,
+				Action:      initiateData.action(),
+				Start:       start,
+				Duration:    time.Since(start),
+				Error:       fmt.Errorf("terminate: %w", err),
+			}
+		}
+		span.End()
+		initiation := initiate(logger, client, i.tracer, initiateData)
+		initiation.Start = start
+		initiation.Duration = time.Since(start)
+		return initiation
+	}
+	return initiate(logger, client, i.tracer, initiateData)
+}
+
+// decide logs and reports the action that would be taken on the missing child
+// SA of initiateData.
+func (i *Reinitiator) decide(logger log.Logger, initiateData initiateData) {
+	action := initiateData.action()
+	reason := fmt.Sprintf("Child SA %s is missing and policy %s initiates it", initiateData.getFullName(), initiateData.policy.Action)
+	if action != initiateData.policy.Action {
+		reason += fmt.Sprintf(" as IKE SA %s is not established", initiateData.IKEName)
+	}
+	switch action {
+	case ReinitiationReinitiateIKE:
+		reason = fmt.Sprintf("Child SA %s is missing and policy %s reauthenticates IKE SA %s[%s]", initiateData.getFullName(), initiateData.policy.Action, initiateData.IKEName, initiateData.ikeSAUniqueID)
+	case ReinitiationTerminateInitiate:
+		reason = fmt.Sprintf("Child SA %s is missing and policy %s terminates IKE SA %s[%s] before initiating it", initiateData.getFullName(), initiateData.policy.Action, initiateData.IKEName, initiateData.ikeSAUniqueID)
+	}
+	reportDecision(logger, i.config.DecisionReporter, Decision{
+		Component:   "reinitiator",
+		IKESAName:   initiateData.IKEName,
+		ChildSAName: initiateData.ChildName,
+		Action:      string(action),
+		Reason:      reason,
+		IKESAStatus: initiateData.status,
+	})
+}
+
+// Initiation is the record of an initiation of a child SA.
+type Initiation struct {
+	IKESAName   string
+	ChildSAName string
+	// Action is the action taken by the Reinitiator.
+	Action   ReinitiationAction
+	Start    time.Time
+	Duration time.Duration
+	// ControlLog holds the control-log messages received from charon during
+	// the initiation.
+	ControlLog []string
+	// Error is the error of the initiation if it failed.
+	Error error
+}
+
+// initiate initiates the child SA of initiateData. The initiation is recorded
+// as a span with control-log messages from charon as span events.
+func initiate(logger log.Logger, client Initiator, tracer trace.Tracer, initiateData initiateData) Initiation {
+	_, span := tracer.Start(context.Background(), "initiate", trace.WithAttributes(
+		attribute.String("ike_sa_name", initiateData.IKEName),
+		attribute.String("child_sa_name", initiateData.ChildName),
+	))
+	defer span.End()
+
+	initiation := Initiation{
+		IKESAName:   initiateData.IKEName,
+		ChildSAName: initiateData.ChildName,
+		Action:      initiateData.action(),
+		Start:       time.Now(),
+	}
+	logger.Infof("Initiating a Child SA for %s", initiateData.getFullName())
+	err := client.Initiate(initiateData.ChildName, initiateData.IKEName, func(fields map[string]interface{}) {
+		msg, _ := fields["msg"]
+		logger.With("strongswanFields", fields).Infof("Initiating log for %s: %s", initiateData.getFullName(), msg)
+		span.AddEvent("control-log", trace.WithAttributes(controlLogAttributes(fields)...))
+		initiation.ControlLog = append(initiation.ControlLog, fmt.Sprintf("%v", msg))
+	})
+	initiation.Duration = time.Since(initiation.Start)
+	initiation.Error = err
+
+	if err != nil {
+		logger.Errorf("got error trying to initiate Child SA %s: %s", initiateData.getFullName(), err)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return initiation
+	}
+	logger.Infof("Initiated new Child SA %s", initiateData.getFullName())
+	return initiation
+}
+
+// startIKESASpan starts a span named name for an action on the IKE SA of the
+// missing child SA of initiateData.
+func startIKESASpan(tracer trace.Tracer, name string, initiateData initiateData) trace.Span {
+	_, span := tracer.Start(context.Background(), name, trace.WithAttributes(
+		attribute.String("ike_sa_name", initiateData.IKEName),
+		attribute.String("child_sa_name", initiateData.ChildName),
+		attribute.String("ike_sa_unique_id", initiateData.ikeSAUniqueID),
+	))
+	return span
+}
+
+// controlLogAttributes maps the fields of a control-log event to span
+// attributes.
+func controlLogAttributes(fields map[string]interface{}) []attribute.KeyValue {
+	var attributes []attribute.KeyValue
+	for _, key := range []string{"group", "level", "ikesa-name", "ikesa-uniqueid", "msg"} {
+		value, ok := fields[key]
+		if !ok {
 			continue
 		}
-		logger.Infof("Initiated new Child SA %s", initiateData.getFullName())
+		attributes = append(attributes, attribute.String(key, fmt.Sprintf("%v", value)))
 	}
+	return attributes
 }
 
 type initiateData struct {
 	IKEName   string
 	ChildName string
+	policy    ReinitiationPolicy
+	// ikeSAUniqueID is the unique ID of the established IKE SA of the child SA
+	// if any.
+	ikeSAUniqueID string
+	// status is the status of the IKE SA that triggered the initiation.
+	status IKESAStatus
+}
+
+// action returns the action of the policy of the child SA. Actions on the IKE
+// SA fall back to initiating the child SA if there is no IKE SA.
+func (d initiateData) action() ReinitiationAction {
+	switch d.policy.Action {
+	case ReinitiationReinitiateIKE, ReinitiationTerminateInitiate:
+		if d.ikeSAUniqueID == "" {
+			return ReinitiationInitiateChild
+		}
+	}
+	return d.policy.Action
 }
 
 func (i initiateData) getFullName() string {
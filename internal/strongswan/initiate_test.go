@@ -0,0 +1,315 @@
+package strongswan
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lunarway/strong-duckling/internal/test"
+	"github.com/lunarway/strong-duckling/internal/vici"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+type fakeInitiator struct {
+	logs []map[string]interface{}
+	err  error
+}
+
+func (f *fakeInitiator) Initiate(child string, ike string, logger func(fields map[string]interface{})) error {
+	for _, fields := range f.logs {
+		logger(fields)
+	}
+	return f.err
+}
+
+func TestInitiate_span(t *testing.T) {
+	tt := []struct {
+		name   string
+		err    error
+		status codes.Code
+	}{
+		{
+			name:   "successful initiation",
+			status: codes.Unset,
+		},
+		{
+			name:   "failed initiation",
+			err:    errors.New("establishing CHILD_SA 'net-0' failed"),
+			status: codes.Error,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := tracetest.NewSpanRecorder()
+			tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+			client := &fakeInitiator{
+				logs: []map[string]interface{}{
+					{
+						"group":          "IKE",
+						"level":          "1",
+						"ikesa-name":     "gw-gw",
+						"ikesa-uniqueid": "3",
+						"msg":            "initiating IKE_SA gw-gw[3] to 10.0.0.2",
+					},
+				},
+				err: tc.err,
+			}
+
+			initiation := initiate(test.NewLogger(t), client, tracer, initiateData{
+				IKEName:   "gw-gw",
+				ChildName: "net-0",
+			})
+			assert.Equal(t, []string{"initiating IKE_SA gw-gw[3] to 10.0.0.2"}, initiation.ControlLog, "control log not as expected")
+			assert.Equal(t, tc.err, initiation.Error, "error not as expected")
+
+			spans := recorder.Ended()
+			if !assert.Len(t, spans, 1, "number of spans not as expected") {
+				return
+			}
+			span := spans[0]
+			assert.Equal(t, "initiate", span.Name(), "span name not as expected")
+			assert.Equal(t, []attribute.KeyValue{
+				attribute.String("ike_sa_name", "gw-gw"),
+				attribute.String("child_sa_name", "net-0"),
+			}, span.Attributes(), "span attributes not as expected")
+			assert.Equal(t, tc.status, span.Status().Code, "span status not as expected")
+			var controlLogs []sdktrace.Event
+			for _, event := range span.Events() {
+				if event.Name == "control-log" {
+					controlLogs = append(controlLogs, event)
+				}
+			}
+			if !assert.Len(t, controlLogs, 1, "number of control-log events not as expected") {
+				return
+			}
+			assert.Equal(t, []attribute.KeyValue{
+				attribute.String("group", "IKE"),
+				attribute.String("level", "1"),
+				attribute.String("ikesa-name", "gw-gw"),
+				attribute.String("ikesa-uniqueid", "3"),
+				attribute.String("msg", "initiating IKE_SA gw-gw[3] to 10.0.0.2"),
+			}, controlLogs[0].Attributes, "control-log attributes not as expected")
+		})
+	}
+}
+
+type reinitiatorReports struct {
+	mu          sync.Mutex
+	backoffs    map[string]time.Duration
+	initiations []Initiation
+	skipped     map[SkipReason]int
+}
+
+func (r *reinitiatorReports) ReportBackoff(ikeSAName, childSAName string, delay time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.backoffs == nil {
+		r.backoffs = map[string]time.Duration{}
+	}
+	r.backoffs[ikeSAName+"."+childSAName] = delay
+}
+
+func (r *reinitiatorReports) ReportInitiation(initiation Initiation) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.initiations = append(r.initiations, initiation)
+}
+
+func (r *reinitiatorReports) ReportSkipped(ikeSAName, childSAName string, reason SkipReason) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.skipped == nil {
+		r.skipped = map[SkipReason]int{}
+	}
+	r.skipped[reason]++
+}
+
+func TestReinitiator_backoff(t *testing.T) {
+	reports := &reinitiatorReports{}
+	i := NewReinitiator(nil, test.NewLogger(t), noop.NewTracerProvider().Tracer(""), ReinitiatorConfiguration{
+		Backoff: BackoffConfiguration{
+			InitialDelay: time.Second,
+			MaxDelay:     time.Minute,
+			MaxAttempts:  3,
+		},
+		Reporter: reports,
+	})
+	now := time.Now()
+	i.now = func() time.Time {
+		return now
+	}
+	child := initiateData{
+		IKEName:   "gw-gw",
+		ChildName: "net-0",
+		policy:    i.policy("gw-gw", "net-0"),
+	}
+	// queued reports whether the child SA is queued at time at and dequeues it
+	queued := func(at time.Time) bool {
+		i.enqueue(child, at)
+		i.mu.Lock()
+		defer i.mu.Unlock()
+		_, ok := i.pending[child.getFullName()]
+		delete(i.pending, child.getFullName())
+		i.queue = nil
+		return ok
+	}
+
+	assert.True(t, queued(now), "not queued before failures")
+	i.done(child, errors.New("initiate unsuccessful"))
+	i.done(child, errors.New("initiate unsuccessful"))
+	assert.Equal(t, 2*time.Second, reports.backoffs["gw-gw.net-0"], "backoff not as expected")
+	assert.False(t, queued(now.Add(time.Second)), "queued within backoff")
+	assert.True(t, queued(now.Add(2*time.Second)), "not queued after backoff")
+
+	i.done(child, errors.New("initiate unsuccessful"))
+	assert.False(t, queued(now.Add(time.Hour)), "queued after giving up")
+	assert.Equal(t, map[SkipReason]int{SkipReasonBackoff: 1, SkipReasonGivenUp: 1}, reports.skipped, "skipped not as expected")
+
+	// an established child SA resets the backoff
+	i.IKESAStatus(IKESAStatus{
+		Name: "gw-gw",
+		ChildSA: []ChildSAStatus{
+			{
+				Name:  "net-0",
+				State: &vici.ChildSA{},
+			},
+		},
+	})
+	assert.True(t, queued(now), "not queued after reset")
+	assert.Equal(t, time.Duration(0), reports.backoffs["gw-gw.net-0"], "backoff not reset")
+
+	i.done(child, errors.New("initiate unsuccessful"))
+	i.done(child, nil)
+	assert.True(t, queued(now), "not queued after successful initiation")
+	assert.Equal(t, time.Duration(0), reports.backoffs["gw-gw.net-0"], "backoff not reset after success")
+}
+
+// blockingController blocks initiations until released by full name.
+type blockingController struct {
+	started chan string
+	release map[string]chan struct{}
+}
+
+func (b *blockingController) Initiate(child string, ike string, logger func(fields map[string]interface{})) error {
+	b.started <- ike + "." + child
+	<-b.release[ike+"."+child]
+	return nil
+}
+
+func (b *blockingController) Terminate(r *vici.TerminateRequest) error {
+	return nil
+}
+
+func (b *blockingController) Rekey(r *vici.RekeyRequest) error {
+	return nil
+}
+
+func TestReinitiator_parallel(t *testing.T) {
+	initiator := &blockingController{
+		started: make(chan string),
+		release: map[string]chan struct{}{
+			"partner1.net-1": make(chan struct{}),
+			"partner1.net-2": make(chan struct{}),
+			"partner2.net-1": make(chan struct{}),
+		},
+	}
+	i := NewReinitiator([]Controller{initiator, initiator, initiator}, test.NewLogger(t), noop.NewTracerProvider().Tracer(""), ReinitiatorConfiguration{})
+	missing := func(ikeSAName string, childSANames ...string) IKESAStatus {
+		status := IKESAStatus{
+			Name: ikeSAName,
+		}
+		for _, childSAName := range childSANames {
+			status.ChildSA = append(status.ChildSA, ChildSAStatus{
+				Name: childSAName,
+			})
+		}
+		return status
+	}
+	receive := func() string {
+		select {
+		case started := <-initiator.started:
+			return started
+		case <-time.After(5 * time.Second):
+			return "timeout"
+		}
+	}
+
+	i.IKESAStatus(missing("partner1", "net-1", "net-2"))
+	i.IKESAStatus(missing("partner2", "net-1"))
+	started := []string{receive(), receive()}
+	assert.ElementsMatch(t, []string{"partner1.net-1", "partner2.net-1"}, started, "initiations of independent IKE SAs not started in parallel")
+
+	// child SAs being initiated or queued are not queued again
+	i.IKESAStatus(missing("partner1", "net-1", "net-2"))
+	i.mu.Lock()
+	var queued []string
+	for _, initiate := range i.queue {
+		queued = append(queued, initiate.getFullName())
+	}
+	assert.Equal(t, []string{"partner1.net-2"}, queued, "queue not as expected")
+	i.mu.Unlock()
+
+	// the second child SA of partner1 waits for the first to be initiated
+	select {
+	case started := <-initiator.started:
+		t.Fatalf("initiation of %s started while its IKE SA is being initiated", started)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(initiator.release["partner1.net-1"])
+	assert.Equal(t, "partner1.net-2", receive(), "queued child SA not initiated")
+	close(initiator.release["partner1.net-2"])
+	close(initiator.release["partner2.net-1"])
+}
+
+func TestReinitiator_installedWhileQueued(t *testing.T) {
+	initiator := &blockingController{
+		started: make(chan string),
+		release: map[string]chan struct{}{
+			"partner1.net-1": make(chan struct{}),
+			"partner1.net-2": make(chan struct{}),
+		},
+	}
+	i := NewReinitiator([]Controller{initiator}, test.NewLogger(t), noop.NewTracerProvider().Tracer(""), ReinitiatorConfiguration{})
+	status := func(installed bool) IKESAStatus {
+		status := IKESAStatus{
+			Name: "partner1",
+			ChildSA: []ChildSAStatus{
+				{Name: "net-1"},
+				{Name: "net-2"},
+			},
+		}
+		if installed {
+			status.ChildSA[1].State = &vici.ChildSA{State: vici.ChildSAStateInstalled}
+		}
+		return status
+	}
+
+	// net-2 is queued while net-1 of the same IKE SA is being initiated
+	i.IKESAStatus(status(false))
+	select {
+	case started := <-initiator.started:
+		assert.Equal(t, "partner1.net-1", started, "first initiation not as expected")
+	case <-time.After(5 * time.Second):
+		t.Fatal("initiation not started")
+	}
+
+	// net-2 is installed before it is dequeued
+	i.IKESAStatus(status(true))
+	i.mu.Lock()
+	assert.Empty(t, i.queue, "installed child SA still queued")
+	assert.NotContains(t, i.pending, "partner1.net-2", "installed child SA still pending")
+	i.mu.Unlock()
+
+	close(initiator.release["partner1.net-1"])
+	select {
+	case started := <-initiator.started:
+		t.Fatalf("initiation of installed child SA %s started", started)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
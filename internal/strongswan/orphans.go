@@ -0,0 +1,143 @@
+package strongswan
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lunarway/strong-duckling/internal/vici"
+	"github.com/prometheus/common/log"
+)
+
+var _ IKESAStatusReceiver = &OrphanTerminator{}
+var _ CollectionDoneReceiver = &OrphanTerminator{}
+
+const (
+	// DefaultOrphanGracePeriod is the default time an orphaned IKE SA is kept
+	// before it is terminated.
+	DefaultOrphanGracePeriod = 10 * time.Minute
+)
+
+// OrphanTermination is a report of a termination of an orphaned IKE SA.
+type OrphanTermination struct {
+	IKESAName string
+	UniqueID  string
+	// Error is the error of the termination if it failed.
+	Error error
+}
+
+// OrphanReporter receives reports of terminations of orphaned IKE SAs.
+type OrphanReporter interface {
+	ReportOrphanTermination(termination OrphanTermination)
+}
+
+// OrphansConfiguration specifies when an OrphanTerminator terminates orphaned
+// IKE SAs.
+type OrphansConfiguration struct {
+	// GracePeriod is the time an orphaned IKE SA is kept before it is
+	// terminated, eg. to let a configuration reload complete. Defaults to
+	// DefaultOrphanGracePeriod.
+	GracePeriod time.Duration
+	Reporter    OrphanReporter
+	// DryRun logs and reports the decided terminations to DecisionReporter
+	// instead of sending them to charon.
+	DryRun           bool
+	DecisionReporter DecisionReporter
+}
+
+func (c *OrphansConfiguration) setDefaults() {
+	if c.GracePeriod == 0 {
+		c.GracePeriod = DefaultOrphanGracePeriod
+	}
+}
+
+// OrphanTerminator terminates orphaned IKE SAs, ie. IKE SAs without a
+// configuration, so removed partners do not keep live tunnels.
+//
+// Terminations are sent synchronously so the OrphanTerminator should have its
+// own client.
+type OrphanTerminator struct {
+	client Controller
+	logger log.Logger
+	config OrphansConfiguration
+	now    func() time.Time
+
+	// orphans holds the time orphaned IKE SAs were first seen by their unique
+	// ID.
+	orphans map[string]time.Time
+	// seen holds the unique IDs of orphaned IKE SAs seen in the current
+	// collection.
+	seen map[string]struct{}
+}
+
+// NewOrphanTerminator returns an OrphanTerminator terminating orphaned IKE SAs
+// through client.
+func NewOrphanTerminator(client Controller, logger log.Logger, config OrphansConfiguration) *OrphanTerminator {
+	config.setDefaults()
+	return &OrphanTerminator{
+		client:  client,
+		logger:  logger,
+		config:  config,
+		now:     time.Now,
+		orphans: make(map[string]time.Time),
+		seen:    make(map[string]struct{}),
+	}
+}
+
+func (o *OrphanTerminator) IKESAStatus(ikeSAStatus IKESAStatus) {
+	if !ikeSAStatus.Orphaned {
+		return
+	}
+	now := o.now()
+	for _, ikeSA := range ikeSAStatus.Sessions {
+		o.seen[ikeSA.UniqueID] = struct{}{}
+		firstSeen, ok := o.orphans[ikeSA.UniqueID]
+		if !ok {
+			o.logger.Infof("IKE SA %s[%s] has no configuration", ikeSAStatus.Name, ikeSA.UniqueID)
+			o.orphans[ikeSA.UniqueID] = now
+			continue
+		}
+		if now.Sub(firstSeen) < o.config.GracePeriod {
+			continue
+		}
+		// the IKE SA is seen as new if it is still there after the termination
+		delete(o.orphans, ikeSA.UniqueID)
+		o.terminate(ikeSAStatus, ikeSA, now.Sub(firstSeen))
+	}
+}
+
+// CollectionDone forgets orphaned IKE SAs that are gone.
+func (o *OrphanTerminator) CollectionDone() {
+	for uniqueID := range o.orphans {
+		if _, ok := o.seen[uniqueID]; !ok {
+			delete(o.orphans, uniqueID)
+		}
+	}
+	o.seen = make(map[string]struct{})
+}
+
+func (o *OrphanTerminator) terminate(ikeSAStatus IKESAStatus, ikeSA vici.IkeSa, orphaned time.Duration) {
+	if o.config.DryRun {
+		reportDecision(o.logger, o.config.DecisionReporter, Decision{
+			Component:   "orphans",
+			IKESAName:   ikeSAStatus.Name,
+			Action:      "terminate",
+			Reason:      fmt.Sprintf("IKE SA %s[%s] has had no configuration for %s", ikeSAStatus.Name, ikeSA.UniqueID, orphaned),
+			IKESAStatus: ikeSAStatus,
+		})
+		return
+	}
+	o.logger.Infof("Terminating IKE SA %s[%s] without configuration after %s", ikeSAStatus.Name, ikeSA.UniqueID, orphaned)
+	err := o.client.Terminate(&vici.TerminateRequest{
+		Ike_id: ikeSA.UniqueID,
+	})
+	if err != nil {
+		o.logger.Errorf("Failed to terminate IKE SA %s[%s] without configuration: %v", ikeSAStatus.Name, ikeSA.UniqueID, err)
+	}
+	if o.config.Reporter != nil {
+		o.config.Reporter.ReportOrphanTermination(OrphanTermination{
+			IKESAName: ikeSAStatus.Name,
+			UniqueID:  ikeSA.UniqueID,
+			Error:     err,
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package strongswan
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lunarway/strong-duckling/internal/test"
+	"github.com/lunarway/strong-duckling/internal/vici"
+	"github.com/stretchr/testify/assert"
+)
+
+type orphanReports []OrphanTermination
+
+func (r *orphanReports) ReportOrphanTermination(termination OrphanTermination) {
+	*r = append(*r, termination)
+}
+
+func TestOrphanTerminator(t *testing.T) {
+	orphaned := IKESAStatus{
+		Name:     "partner1",
+		Sessions: []vici.IkeSa{{UniqueID: "3"}},
+		Orphaned: true,
+	}
+	tt := []struct {
+		name         string
+		collections  [][]IKESAStatus
+		err          error
+		requests     []string
+		terminations orphanReports
+	}{
+		{
+			name:        "within grace period",
+			collections: [][]IKESAStatus{{orphaned}, {orphaned}},
+		},
+		{
+			name:         "after grace period",
+			collections:  [][]IKESAStatus{{orphaned}, {orphaned}, {orphaned}},
+			requests:     []string{"terminate 3"},
+			terminations: orphanReports{{IKESAName: "partner1", UniqueID: "3"}},
+		},
+		{
+			name: "configured",
+			collections: [][]IKESAStatus{
+				{{Name: "partner1", Sessions: []vici.IkeSa{{UniqueID: "3"}}}},
+				{{Name: "partner1", Sessions: []vici.IkeSa{{UniqueID: "3"}}}},
+				{{Name: "partner1", Sessions: []vici.IkeSa{{UniqueID: "3"}}}},
+			},
+		},
+		{
+			name:        "gone",
+			collections: [][]IKESAStatus{{orphaned}, {}, {orphaned}},
+		},
+		{
+			name:         "failed termination",
+			collections:  [][]IKESAStatus{{orphaned}, {orphaned}, {orphaned}},
+			err:          errors.New("terminate unsuccessful"),
+			requests:     []string{"terminate 3"},
+			terminations: orphanReports{{IKESAName: "partner1", UniqueID: "3", Error: errors.New("terminate unsuccessful")}},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &fakeController{
+				terminateErr: tc.err,
+			}
+			var reports orphanReports
+			o := NewOrphanTerminator(client, test.NewLogger(t), OrphansConfiguration{
+				Reporter: &reports,
+			})
+			now := time.Now()
+			o.now = func() time.Time {
+				return now
+			}
+
+			for _, statuses := range tc.collections {
+				for _, status := range statuses {
+					o.IKESAStatus(status)
+				}
+				o.CollectionDone()
+				now = now.Add(5 * time.Minute)
+			}
+
+			assert.Equal(t, tc.requests, client.requests, "requests not as expected")
+			assert.Equal(t, tc.terminations, reports, "terminations not as expected")
+		})
+	}
+}
+
+func TestOrphanTerminator_dryRun(t *testing.T) {
+	client := &fakeController{}
+	decisions := make(decisionReports, 1)
+	o := NewOrphanTerminator(client, test.NewLogger(t), OrphansConfiguration{
+		GracePeriod:      time.Minute,
+		DryRun:           true,
+		DecisionReporter: decisions,
+	})
+	now := time.Now()
+	o.now = func() time.Time {
+		return now
+	}
+	status := IKESAStatus{
+		Name:     "partner1",
+		Sessions: []vici.IkeSa{{UniqueID: "3"}},
+		Orphaned: true,
+	}
+
+	o.IKESAStatus(status)
+	now = now.Add(time.Minute)
+	o.IKESAStatus(status)
+
+	assert.Empty(t, client.requests, "requests sent in dry run")
+	if assert.Len(t, decisions, 1, "decisions not as expected") {
+		assert.Equal(t, Decision{
+			Component:   "orphans",
+			IKESAName:   "partner1",
+			Action:      "terminate",
+			Reason:      "IKE SA partner1[3] has had no configuration for 1m0s",
+			IKESAStatus: status,
+		}, <-decisions, "decision not as expected")
+	}
+}
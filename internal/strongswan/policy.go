@@ -0,0 +1,202 @@
+package strongswan
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// ReinitiationAction is the action taken by a Reinitiator on a missing child
+// SA.
+type ReinitiationAction string
+
+const (
+	// ReinitiationDisabled never initiates the child SA, eg. for peers that
+	// are always the initiator.
+	ReinitiationDisabled ReinitiationAction = "disabled"
+	// ReinitiationInitiateChild initiates the missing child SA only.
+	ReinitiationInitiateChild ReinitiationAction = "initiate_child"
+	// ReinitiationReinitiateIKE reauthenticates the IKE SA of the missing child
+	// SA, recreating it along with all its child SAs. If there is no IKE SA the
+	// child SA is initiated.
+	ReinitiationReinitiateIKE ReinitiationAction = "reinitiate_ike"
+	// ReinitiationTerminateInitiate terminates the IKE SA of the missing child
+	// SA before initiating it. The IKE SA is terminated once for all its
+	// missing child SAs.
+	ReinitiationTerminateInitiate ReinitiationAction = "terminate_initiate"
+)
+
+// ReinitiationActions holds all reinitiation actions.
+var ReinitiationActions = []ReinitiationAction{
+	ReinitiationDisabled,
+	ReinitiationInitiateChild,
+	ReinitiationReinitiateIKE,
+	ReinitiationTerminateInitiate,
+}
+
+func parseReinitiationAction(s string) (ReinitiationAction, error) {
+	for _, action := range ReinitiationActions {
+		if string(action) == s {
+			return action, nil
+		}
+	}
+	return "", fmt.Errorf("unknown action '%s'", s)
+}
+
+// ReinitiationPolicy specifies how a missing child SA is reinitiated.
+type ReinitiationPolicy struct {
+	Action  ReinitiationAction
+	Backoff BackoffConfiguration
+}
+
+// PolicyRule applies a ReinitiationPolicy to child SAs matching name patterns.
+// Patterns use the syntax of path.Match, eg. partner-*. An empty pattern
+// matches all names.
+type PolicyRule struct {
+	IKESAName   string
+	ChildSAName string
+	Policy      ReinitiationPolicy
+}
+
+func (r PolicyRule) matches(ikeSAName, childSAName string) bool {
+	return matchPattern(r.IKESAName, ikeSAName) && matchPattern(r.ChildSAName, childSAName)
+}
+
+func matchPattern(pattern, name string) bool {
+	if pattern == "" {
+		return true
+	}
+	// patterns are validated when parsed
+	matched, _ := path.Match(pattern, name)
+	return matched
+}
+
+func validatePatterns(patterns ...string) error {
+	for _, pattern := range patterns {
+		_, err := path.Match(pattern, "")
+		if err != nil {
+			return fmt.Errorf("invalid pattern '%s': %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+func (r PolicyRule) validate() error {
+	err := validatePatterns(r.IKESAName, r.ChildSAName)
+	if err != nil {
+		return err
+	}
+	err = r.Policy.Backoff.Validate()
+	if err != nil {
+		return fmt.Errorf("backoff: %w", err)
+	}
+	return nil
+}
+
+// ParsePolicyRule parses a rule of the form
+//
+//	<ike sa pattern>[/<child sa pattern>]=<action>
+//
+// eg. partner-*=disabled. The rule uses defaultBackoff.
+func ParsePolicyRule(s string, defaultBackoff BackoffConfiguration) (PolicyRule, error) {
+	patterns, action, ok := strings.Cut(s, "=")
+	if !ok {
+		return PolicyRule{}, fmt.Errorf("could not understand policy %s", s)
+	}
+	var r PolicyRule
+	r.IKESAName, r.ChildSAName, _ = strings.Cut(patterns, "/")
+	var err error
+	r.Policy.Action, err = parseReinitiationAction(action)
+	if err != nil {
+		return PolicyRule{}, err
+	}
+	r.Policy.Backoff = defaultBackoff
+	err = r.validate()
+	if err != nil {
+		return PolicyRule{}, err
+	}
+	return r, nil
+}
+
+// policiesConfig is the format of a policies configuration file.
+type policiesConfig struct {
+	Policies []struct {
+		IKESAName   string `json:"ike_sa_name"`
+		ChildSAName string `json:"child_sa_name"`
+		Action      string `json:"action"`
+		Backoff     struct {
+			InitialDelay string   `json:"initial_delay"`
+			MaxDelay     string   `json:"max_delay"`
+			Multiplier   *float64 `json:"multiplier"`
+			Jitter       *float64 `json:"jitter"`
+			MaxAttempts  *int     `json:"max_attempts"`
+		} `json:"backoff"`
+	} `json:"policies"`
+}
+
+// ReadPolicies reads policy rules from a JSON configuration file. Backoff
+// options that are omitted are taken from defaultBackoff.
+//
+//	{
+//	  "policies": [
+//	    {"ike_sa_name": "partner1", "action": "disabled"},
+//	    {"ike_sa_name": "partner-*", "child_sa_name": "net-*", "action": "terminate_initiate", "backoff": {"initial_delay": "30s", "max_attempts": 10}}
+//	  ]
+//	}
+func ReadPolicies(r io.Reader, defaultBackoff BackoffConfiguration) ([]PolicyRule, error) {
+	var config policiesConfig
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&config)
+	if err != nil {
+		return nil, fmt.Errorf("decode policies: %w", err)
+	}
+	var rules []PolicyRule
+	for i, c := range config.Policies {
+		rule := PolicyRule{
+			IKESAName:   c.IKESAName,
+			ChildSAName: c.ChildSAName,
+		}
+		rule.Policy.Action, err = parseReinitiationAction(c.Action)
+		if err != nil {
+			return nil, fmt.Errorf("policy %d: %w", i, err)
+		}
+		backoff := defaultBackoff
+		durations := []struct {
+			key   string
+			value string
+			d     *time.Duration
+		}{
+			{"initial_delay", c.Backoff.InitialDelay, &backoff.InitialDelay},
+			{"max_delay", c.Backoff.MaxDelay, &backoff.MaxDelay},
+		}
+		for _, d := range durations {
+			if d.value == "" {
+				continue
+			}
+			*d.d, err = time.ParseDuration(d.value)
+			if err != nil {
+				return nil, fmt.Errorf("policy %d: could not parse %s: %w", i, d.key, err)
+			}
+		}
+		if c.Backoff.Multiplier != nil {
+			backoff.Multiplier = *c.Backoff.Multiplier
+		}
+		if c.Backoff.Jitter != nil {
+			backoff.Jitter = *c.Backoff.Jitter
+		}
+		if c.Backoff.MaxAttempts != nil {
+			backoff.MaxAttempts = *c.Backoff.MaxAttempts
+		}
+		rule.Policy.Backoff = backoff
+		err = rule.validate()
+		if err != nil {
+			return nil, fmt.Errorf("policy %d: %w", i, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
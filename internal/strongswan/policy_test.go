@@ -0,0 +1,379 @@
+package strongswan
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lunarway/strong-duckling/internal/test"
+	"github.com/lunarway/strong-duckling/internal/vici"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestParsePolicyRule(t *testing.T) {
+	backoff := BackoffConfiguration{InitialDelay: time.Second}
+	tt := []struct {
+		name  string
+		input string
+		rule  PolicyRule
+		err   string
+	}{
+		{
+			name:  "ike sa",
+			input: "partner-*=disabled",
+			rule: PolicyRule{
+				IKESAName: "partner-*",
+				Policy:    ReinitiationPolicy{Action: ReinitiationDisabled, Backoff: backoff},
+			},
+		},
+		{
+			name:  "child sa",
+			input: "partner1/net-1=terminate_initiate",
+			rule: PolicyRule{
+				IKESAName:   "partner1",
+				ChildSAName: "net-1",
+				Policy:      ReinitiationPolicy{Action: ReinitiationTerminateInitiate, Backoff: backoff},
+			},
+		},
+		{
+			name:  "all child sas",
+			input: "/net-*=reinitiate_ike",
+			rule: PolicyRule{
+				ChildSAName: "net-*",
+				Policy:      ReinitiationPolicy{Action: ReinitiationReinitiateIKE, Backoff: backoff},
+			},
+		},
+		{
+			name:  "missing action",
+			input: "partner1",
+			err:   "could not understand policy partner1",
+		},
+		{
+			name:  "unknown action",
+			input: "partner1=restart",
+			err:   "unknown action 'restart'",
+		},
+		{
+			name:  "invalid pattern",
+			input: "partner[=disabled",
+			err:   "invalid pattern 'partner[': syntax error in pattern",
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			rule, err := ParsePolicyRule(tc.input, backoff)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err, "error")
+				return
+			}
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tc.rule, rule, "rule")
+		})
+	}
+}
+
+func TestReadPolicies(t *testing.T) {
+	backoff := BackoffConfiguration{InitialDelay: time.Second, MaxDelay: time.Minute, Jitter: 0.2}
+	tt := []struct {
+		name  string
+		input string
+		rules []PolicyRule
+		err   string
+	}{
+		{
+			name: "valid",
+			input: `{"policies": [
+				{"ike_sa_name": "partner1", "action": "disabled"},
+				{"ike_sa_name": "partner-*", "child_sa_name": "net-*", "action": "terminate_initiate", "backoff": {"initial_delay": "30s", "jitter": 0, "max_attempts": 10}}
+			]}`,
+			rules: []PolicyRule{
+				{
+					IKESAName: "partner1",
+					Policy:    ReinitiationPolicy{Action: ReinitiationDisabled, Backoff: backoff},
+				},
+				{
+					IKESAName:   "partner-*",
+					ChildSAName: "net-*",
+					Policy: ReinitiationPolicy{
+						Action: ReinitiationTerminateInitiate,
+						Backoff: BackoffConfiguration{
+							InitialDelay: 30 * time.Second,
+							MaxDelay:     time.Minute,
+							MaxAttempts:  10,
+						},
+					},
+				},
+			},
+		},
+		{
+			name:  "unknown action",
+			input: `{"policies": [{"ike_sa_name": "partner1", "action": "restart"}]}`,
+			err:   "policy 0: unknown action 'restart'",
+		},
+		{
+			name:  "invalid delay",
+			input: `{"policies": [{"action": "initiate_child", "backoff": {"max_delay": "1 minute"}}]}`,
+			err:   `policy 0: could not parse max_delay: time: unknown unit " minute" in duration "1 minute"`,
+		},
+		{
+			name:  "invalid backoff",
+			input: `{"policies": [{"action": "initiate_child", "backoff": {"max_delay": "1ms"}}]}`,
+			err:   "policy 0: backoff: max delay must not be less than initial delay",
+		},
+		{
+			name:  "unknown field",
+			input: `{"policies": [{"action": "disabled", "name": "partner1"}]}`,
+			err:   `decode policies: json: unknown field "name"`,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			rules, err := ReadPolicies(strings.NewReader(tc.input), backoff)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err, "error")
+				return
+			}
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tc.rules, rules, "rules")
+		})
+	}
+}
+
+func TestReinitiator_policy(t *testing.T) {
+	i := NewReinitiator(nil, test.NewLogger(t), noop.NewTracerProvider().Tracer(""), ReinitiatorConfiguration{
+		Backoff: BackoffConfiguration{InitialDelay: time.Second},
+		Policies: []PolicyRule{
+			{IKESAName: "partner1", ChildSAName: "net-1", Policy: ReinitiationPolicy{Action: ReinitiationTerminateInitiate}},
+			{IKESAName: "partner*", Policy: ReinitiationPolicy{Action: ReinitiationDisabled}},
+		},
+	})
+
+	assert.Equal(t, ReinitiationTerminateInitiate, i.policy("partner1", "net-1").Action, "first matching rule not applied")
+	assert.Equal(t, DefaultBackoffInitialDelay, i.policy("partner1", "net-1").Backoff.InitialDelay, "backoff defaults not set")
+	assert.Equal(t, ReinitiationDisabled, i.policy("partner1", "net-2").Action, "pattern not applied")
+	assert.Equal(t, ReinitiationPolicy{
+		Action: ReinitiationInitiateChild,
+		Backoff: BackoffConfiguration{
+			InitialDelay: time.Second,
+			MaxDelay:     DefaultBackoffMaxDelay,
+			Multiplier:   DefaultBackoffMultiplier,
+		},
+	}, i.policy("gw-gw", "net-1"), "default policy not as expected")
+
+	// disabled child SAs are never queued
+	i.IKESAStatus(IKESAStatus{
+		Name: "partner2",
+		ChildSA: []ChildSAStatus{
+			{Name: "net-1"},
+		},
+	})
+	i.mu.Lock()
+	assert.Empty(t, i.queue, "disabled child SA queued")
+	i.mu.Unlock()
+}
+
+func TestReinitiator_reinitiate(t *testing.T) {
+	tt := []struct {
+		name          string
+		action        ReinitiationAction
+		ikeSAUniqueID string
+		err           error
+		terminateErr  error
+		requests      []string
+		// spans are the names of the ended spans with " error" appended if
+		// their status is error.
+		spans []string
+		// taken is the action taken if it differs from action
+		taken ReinitiationAction
+	}{
+		{
+			name:          "initiate child",
+			action:        ReinitiationInitiateChild,
+			ikeSAUniqueID: "3",
+			requests:      []string{"initiate partner1.net-1"},
+			spans:         []string{"initiate"},
+		},
+		{
+			name:          "reinitiate ike",
+			action:        ReinitiationReinitiateIKE,
+			ikeSAUniqueID: "3",
+			requests:      []string{"rekey 3"},
+			spans:         []string{"reauthenticate"},
+		},
+		{
+			name:          "reinitiate ike without ike sa",
+			action:        ReinitiationReinitiateIKE,
+			ikeSAUniqueID: "",
+			requests:      []string{"initiate partner1.net-1"},
+			spans:         []string{"initiate"},
+			taken:         ReinitiationInitiateChild,
+		},
+		{
+			name:          "reinitiate ike failed",
+			action:        ReinitiationReinitiateIKE,
+			ikeSAUniqueID: "3",
+			err:           errors.New("rekey failed"),
+			requests:      []string{"rekey 3"},
+			spans:         []string{"reauthenticate error"},
+		},
+		{
+			name:          "terminate initiate",
+			action:        ReinitiationTerminateInitiate,
+			ikeSAUniqueID: "3",
+			requests:      []string{"terminate 3", "initiate partner1.net-1"},
+			spans:         []string{"terminate", "initiate"},
+		},
+		{
+			name:          "terminate initiate failed",
+			action:        ReinitiationTerminateInitiate,
+			ikeSAUniqueID: "3",
+			terminateErr:  errors.New("terminate failed"),
+			requests:      []string{"terminate 3"},
+			spans:         []string{"terminate error"},
+		},
+		{
+			name:          "terminate initiate without ike sa",
+			action:        ReinitiationTerminateInitiate,
+			ikeSAUniqueID: "",
+			requests:      []string{"initiate partner1.net-1"},
+			spans:         []string{"initiate"},
+			taken:         ReinitiationInitiateChild,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &fakeController{
+				fakeInitiator: fakeInitiator{
+					err: tc.err,
+				},
+				terminateErr: tc.terminateErr,
+			}
+			recorder := tracetest.NewSpanRecorder()
+			tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+			i := NewReinitiator(nil, test.NewLogger(t), tracer, ReinitiatorConfiguration{})
+
+			initiation := i.reinitiate(test.NewLogger(t), client, initiateData{
+				IKEName:       "partner1",
+				ChildName:     "net-1",
+				policy:        ReinitiationPolicy{Action: tc.action},
+				ikeSAUniqueID: tc.ikeSAUniqueID,
+			})
+
+			assert.Equal(t, tc.requests, client.requests, "requests not as expected")
+			taken := tc.action
+			if tc.taken != "" {
+				taken = tc.taken
+			}
+			assert.Equal(t, taken, initiation.Action, "action not as expected")
+			if tc.terminateErr != nil {
+				assert.ErrorIs(t, initiation.Error, tc.terminateErr, "error not as expected")
+			} else {
+				assert.Equal(t, tc.err, initiation.Error, "error not as expected")
+			}
+			var spans []string
+			for _, span := range recorder.Ended() {
+				name := span.Name()
+				if span.Status().Code == codes.Error {
+					name += " error"
+				}
+				spans = append(spans, name)
+			}
+			assert.Equal(t, tc.spans, spans, "spans not as expected")
+		})
+	}
+}
+
+func TestReinitiator_ikeSAActions(t *testing.T) {
+	missing := func(uniqueID string) IKESAStatus {
+		return IKESAStatus{
+			Name:  "partner1",
+			State: &vici.IkeSa{UniqueID: uniqueID, State: vici.IKESAStateEstablished},
+			ChildSA: []ChildSAStatus{
+				{Name: "net-1"},
+				{Name: "net-2"},
+			},
+		}
+	}
+	tt := []struct {
+		name     string
+		action   ReinitiationAction
+		statuses []IKESAStatus
+		requests []string
+	}{
+		{
+			name:     "reinitiate ike once",
+			action:   ReinitiationReinitiateIKE,
+			statuses: []IKESAStatus{missing("3")},
+			requests: []string{"rekey 3"},
+		},
+		{
+			name:     "terminate once and initiate all",
+			action:   ReinitiationTerminateInitiate,
+			statuses: []IKESAStatus{missing("3")},
+			requests: []string{"terminate 3", "initiate partner1.net-1", "initiate partner1.net-2"},
+		},
+		{
+			name:     "replaced ike sa",
+			action:   ReinitiationTerminateInitiate,
+			statuses: []IKESAStatus{missing("3"), missing("4")},
+			requests: []string{"initiate partner1.net-1", "initiate partner1.net-2"},
+		},
+		{
+			name:     "replaced ike sa not reinitiated",
+			action:   ReinitiationReinitiateIKE,
+			statuses: []IKESAStatus{missing("3"), missing("4")},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &fakeController{}
+			i := NewReinitiator(nil, test.NewLogger(t), noop.NewTracerProvider().Tracer(""), ReinitiatorConfiguration{
+				Policies: []PolicyRule{
+					{Policy: ReinitiationPolicy{Action: tc.action}},
+				},
+			})
+
+			for _, status := range tc.statuses {
+				i.IKESAStatus(status)
+			}
+			i.process(test.NewLogger(t), client, i.dequeue())
+			i.process(test.NewLogger(t), client, i.dequeue())
+
+			assert.Equal(t, tc.requests, client.requests, "requests not as expected")
+		})
+	}
+}
+
+func TestReinitiator_skippedReauthenticationKeepsBackoff(t *testing.T) {
+	client := &fakeController{}
+	i := NewReinitiator(nil, test.NewLogger(t), noop.NewTracerProvider().Tracer(""), ReinitiatorConfiguration{
+		Policies: []PolicyRule{
+			{Policy: ReinitiationPolicy{Action: ReinitiationReinitiateIKE}},
+		},
+	})
+	// net-2 failed before and may be initiated again
+	i.backoffs["partner1.net-2"] = &backoff{failures: 1, delay: time.Second}
+
+	i.IKESAStatus(IKESAStatus{
+		Name:  "partner1",
+		State: &vici.IkeSa{UniqueID: "3", State: vici.IKESAStateEstablished},
+		ChildSA: []ChildSAStatus{
+			{Name: "net-1"},
+			{Name: "net-2"},
+		},
+	})
+	i.process(test.NewLogger(t), client, i.dequeue())
+	i.process(test.NewLogger(t), client, i.dequeue())
+
+	assert.Equal(t, []string{"rekey 3"}, client.requests, "requests not as expected")
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	assert.Contains(t, i.backoffs, "partner1.net-2", "backoff of skipped child SA reset")
+	assert.Empty(t, i.pending, "skipped child SA not released")
+	assert.Empty(t, i.initiating, "IKE SA of skipped child SA not released")
+}
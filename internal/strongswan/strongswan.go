@@ -29,15 +29,15 @@ func connections(client *vici.ClientConn) (map[string]vici.IKEConf, error) {
 	return connList, nil
 }
 
-func ikeSas(client *vici.ClientConn) (map[string]vici.IkeSa, error) {
-	sasList, err := client.ListSas("", "")
+func ikeSas(client *vici.ClientConn) (map[string][]vici.IkeSa, error) {
+	sasList, err := client.ListAllSas("", "")
 	if err != nil {
 		return nil, fmt.Errorf("list vici sas: %w", err)
 	}
 	return sasList, nil
 }
 
-func collectSasStats(configs map[string]vici.IKEConf, sas map[string]vici.IkeSa, ikeSAStatusReceivers []IKESAStatusReceiver) {
+func collectSasStats(configs map[string]vici.IKEConf, sas map[string][]vici.IkeSa, ikeSAStatusReceivers []IKESAStatusReceiver) {
 	ikeNames := make(map[string]struct{})
 	for ikeName := range configs {
 		ikeNames[ikeName] = struct{}{}
@@ -49,14 +49,15 @@ func collectSasStats(configs map[string]vici.IKEConf, sas map[string]vici.IkeSa,
 	var ikeSAStatuses []IKESAStatus
 	for ikeName := range ikeNames {
 		config, configFound := configs[ikeName]
-		ikeSA, ikeSAFound := sas[ikeName]
+		ikeSAs := sas[ikeName]
+		ikeSAFound := len(ikeSAs) != 0
 		switch {
 		case configFound && ikeSAFound:
-			ikeSAStatuses = append(ikeSAStatuses, mapToIKESAStatus(ikeName, config, &ikeSA))
+			ikeSAStatuses = append(ikeSAStatuses, mapToIKESAStatus(ikeName, config, ikeSAs))
 		case configFound && !ikeSAFound:
 			ikeSAStatuses = append(ikeSAStatuses, mapToIKESAStatus(ikeName, config, nil))
 		case !configFound && ikeSAFound:
-			log.Errorf("Unexpected IKE_SA Status for IKE Name %s: %#v", ikeName, ikeSA)
+			ikeSAStatuses = append(ikeSAStatuses, mapToOrphanedIKESAStatus(ikeName, ikeSAs))
 		}
 	}
 
@@ -65,13 +66,47 @@ func collectSasStats(configs map[string]vici.IKEConf, sas map[string]vici.IkeSa,
 			reporter.IKESAStatus(ikeSAStatus)
 		}
 	}
+	for _, reporter := range ikeSAStatusReceivers {
+		if receiver, ok := reporter.(CollectionDoneReceiver); ok {
+			receiver.CollectionDone()
+		}
+	}
 }
 
-func mapToIKESAStatus(ikeName string, config vici.IKEConf, ikeSA *vici.IkeSa) IKESAStatus {
+// mapToOrphanedIKESAStatus maps IKE SAs without a configuration.
+func mapToOrphanedIKESAStatus(ikeName string, ikeSAs []vici.IkeSa) IKESAStatus {
+	ikeSA := &ikeSAs[len(ikeSAs)-1]
+	status := IKESAStatus{
+		Name:     ikeName,
+		State:    ikeSA,
+		Sessions: ikeSAs,
+		Orphaned: true,
+	}
+	childNames := make(map[string]struct{})
+	for _, childSA := range ikeSA.ChildSAs {
+		if _, ok := childNames[childSA.Name]; ok {
+			continue
+		}
+		childNames[childSA.Name] = struct{}{}
+		childSA := childSA
+		status.ChildSA = append(status.ChildSA, ChildSAStatus{
+			Name:  childSA.Name,
+			State: &childSA,
+		})
+	}
+	return status
+}
+
+func mapToIKESAStatus(ikeName string, config vici.IKEConf, ikeSAs []vici.IkeSa) IKESAStatus {
+	var ikeSA *vici.IkeSa
+	if len(ikeSAs) != 0 {
+		ikeSA = &ikeSAs[len(ikeSAs)-1]
+	}
 	status := IKESAStatus{
 		Name:          ikeName,
 		Configuration: config,
 		State:         ikeSA,
+		Sessions:      ikeSAs,
 	}
 
 	childNames := make(map[string]struct{})
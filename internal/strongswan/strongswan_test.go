@@ -12,7 +12,7 @@ func TestCollectSasStats(t *testing.T) {
 	tt := []struct {
 		name              string
 		connectionConfigs map[string]vici.IKEConf
-		sas               map[string]vici.IkeSa
+		sas               map[string][]vici.IkeSa
 		expected          []IKESAStatus
 	}{
 		{
@@ -50,11 +50,13 @@ func TestCollectSasStats(t *testing.T) {
 					},
 				},
 			},
-			sas: map[string]vici.IkeSa{
+			sas: map[string][]vici.IkeSa{
 				"gw-gw": {
-					ChildSAs: map[string]vici.ChildSA{
-						"net-net-0-35": {
-							Name: "net-net-0",
+					{
+						ChildSAs: map[string]vici.ChildSA{
+							"net-net-0-35": {
+								Name: "net-net-0",
+							},
 						},
 					},
 				},
@@ -83,6 +85,85 @@ func TestCollectSasStats(t *testing.T) {
 							},
 						},
 					},
+					Sessions: []vici.IkeSa{
+						{
+							ChildSAs: map[string]vici.ChildSA{
+								"net-net-0-35": {
+									Name: "net-net-0",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "multiple sessions",
+			connectionConfigs: map[string]vici.IKEConf{
+				"rw": {},
+			},
+			sas: map[string][]vici.IkeSa{
+				"rw": {
+					{UniqueID: "1", RemoteEAPID: "alice"},
+					{UniqueID: "2", RemoteEAPID: "bob"},
+				},
+			},
+			expected: []IKESAStatus{
+				{
+					Name:          "rw",
+					Configuration: vici.IKEConf{},
+					State:         &vici.IkeSa{UniqueID: "2", RemoteEAPID: "bob"},
+					Sessions: []vici.IkeSa{
+						{UniqueID: "1", RemoteEAPID: "alice"},
+						{UniqueID: "2", RemoteEAPID: "bob"},
+					},
+				},
+			},
+		},
+		{
+			name: "orphaned",
+			sas: map[string][]vici.IkeSa{
+				"gw-gw": {
+					{
+						UniqueID: "3",
+						ChildSAs: map[string]vici.ChildSA{
+							"net-net-0-35": {
+								Name: "net-net-0",
+							},
+						},
+					},
+				},
+			},
+			expected: []IKESAStatus{
+				{
+					Name: "gw-gw",
+					State: &vici.IkeSa{
+						UniqueID: "3",
+						ChildSAs: map[string]vici.ChildSA{
+							"net-net-0-35": {
+								Name: "net-net-0",
+							},
+						},
+					},
+					ChildSA: []ChildSAStatus{
+						{
+							Name: "net-net-0",
+							State: &vici.ChildSA{
+								Name: "net-net-0",
+							},
+						},
+					},
+					Sessions: []vici.IkeSa{
+						{
+							UniqueID: "3",
+							ChildSAs: map[string]vici.ChildSA{
+								"net-net-0-35": {
+									Name: "net-net-0",
+								},
+							},
+						},
+					},
+					Orphaned: true,
 				},
 			},
 		},
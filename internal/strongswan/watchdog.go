@@ -0,0 +1,186 @@
+package strongswan
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/lunarway/strong-duckling/internal/vici"
+	"github.com/prometheus/common/log"
+)
+
+var _ IKESAStatusReceiver = &Watchdog{}
+var _ CollectionDoneReceiver = &Watchdog{}
+
+const (
+	// DefaultStuckThreshold is the default time an IKE SA may stay in
+	// non-final states before it is terminated. It exceeds the default
+	// retransmission timeout of charon of about 165 seconds.
+	DefaultStuckThreshold = 5 * time.Minute
+	// DefaultStuckTerminateTimeout is the default time charon waits for a
+	// graceful termination of a stuck IKE SA before destroying it.
+	DefaultStuckTerminateTimeout = 10 * time.Second
+)
+
+// StuckIKESA is a report of an IKE SA that stayed in non-final states for
+// longer than the threshold of a Watchdog.
+type StuckIKESA struct {
+	IKESAName string
+	UniqueID  string
+	// State is the state of the IKE SA when it was found stuck.
+	State string
+	// Duration is the time the IKE SA stayed in non-final states.
+	Duration time.Duration
+	// DryRun is true if the IKE SA was not terminated as the Watchdog is in
+	// dry-run mode.
+	DryRun bool
+	// Error is the error of the termination if it failed.
+	Error error
+}
+
+// WatchdogReporter receives reports of stuck IKE SAs.
+type WatchdogReporter interface {
+	ReportStuck(stuck StuckIKESA)
+}
+
+// WatchdogConfiguration specifies when a Watchdog terminates stuck IKE SAs.
+type WatchdogConfiguration struct {
+	// Threshold is the time an IKE SA may stay in non-final states before it is
+	// terminated. Defaults to DefaultStuckThreshold.
+	Threshold time.Duration
+	// TerminateTimeout is the time charon waits for a graceful termination
+	// before destroying the IKE SA. Defaults to DefaultStuckTerminateTimeout.
+	TerminateTimeout time.Duration
+	Reporter         WatchdogReporter
+	// DryRun logs and reports the decided terminations to DecisionReporter
+	// instead of sending them to charon.
+	DryRun           bool
+	DecisionReporter DecisionReporter
+}
+
+func (c *WatchdogConfiguration) setDefaults() {
+	if c.Threshold == 0 {
+		c.Threshold = DefaultStuckThreshold
+	}
+	if c.TerminateTimeout == 0 {
+		c.TerminateTimeout = DefaultStuckTerminateTimeout
+	}
+}
+
+// Watchdog terminates IKE SAs that stay in non-final states, eg. CONNECTING,
+// for longer than a threshold. Such IKE SAs block the unique handling of
+// charon and initiations by the Reinitiator, which proceed once the IKE SA is
+// terminated.
+//
+// Terminations are sent synchronously so the Watchdog should have its own
+// client.
+type Watchdog struct {
+	client Controller
+	logger log.Logger
+	config WatchdogConfiguration
+	now    func() time.Time
+
+	// pending holds the IKE SAs in non-final states by their unique ID.
+	pending map[string]*pendingIKESA
+	// seen holds the IKE SA names received in the current collection.
+	seen map[string]struct{}
+}
+
+type pendingIKESA struct {
+	ikeSAName string
+	// since is the time the IKE SA was first seen in a non-final state.
+	since time.Time
+}
+
+// NewWatchdog returns a Watchdog terminating stuck IKE SAs through client.
+func NewWatchdog(client Controller, logger log.Logger, config WatchdogConfiguration) *Watchdog {
+	config.setDefaults()
+	return &Watchdog{
+		client:  client,
+		logger:  logger,
+		config:  config,
+		now:     time.Now,
+		pending: make(map[string]*pendingIKESA),
+		seen:    make(map[string]struct{}),
+	}
+}
+
+// finalIKESAState reports whether state is a state an IKE SA may stay in.
+func finalIKESAState(state string) bool {
+	return state == vici.IKESAStateEstablished || state == vici.IKESAStatePassive
+}
+
+func (w *Watchdog) IKESAStatus(ikeSAStatus IKESAStatus) {
+	now := w.now()
+	w.seen[ikeSAStatus.Name] = struct{}{}
+	seen := make(map[string]struct{})
+	for _, ikeSA := range ikeSAStatus.Sessions {
+		if finalIKESAState(ikeSA.State) {
+			continue
+		}
+		seen[ikeSA.UniqueID] = struct{}{}
+		pending, ok := w.pending[ikeSA.UniqueID]
+		if !ok {
+			w.pending[ikeSA.UniqueID] = &pendingIKESA{
+				ikeSAName: ikeSAStatus.Name,
+				since:     now,
+			}
+			continue
+		}
+		if now.Sub(pending.since) <= w.config.Threshold {
+			continue
+		}
+		// the IKE SA is seen as new if it is still there after the termination
+		delete(w.pending, ikeSA.UniqueID)
+		w.terminate(ikeSAStatus, ikeSA, now.Sub(pending.since))
+	}
+	for uniqueID, pending := range w.pending {
+		if _, ok := seen[uniqueID]; pending.ikeSAName == ikeSAStatus.Name && !ok {
+			delete(w.pending, uniqueID)
+		}
+	}
+}
+
+// CollectionDone forgets the pending IKE SAs of connections that were not
+// received in the collection, eg. after their configuration was unloaded.
+func (w *Watchdog) CollectionDone() {
+	for uniqueID, pending := range w.pending {
+		if _, ok := w.seen[pending.ikeSAName]; !ok {
+			delete(w.pending, uniqueID)
+		}
+	}
+	w.seen = make(map[string]struct{})
+}
+
+// terminate terminates the stuck IKE SA ikeSA.
+func (w *Watchdog) terminate(ikeSAStatus IKESAStatus, ikeSA vici.IkeSa, duration time.Duration) {
+	stuck := StuckIKESA{
+		IKESAName: ikeSAStatus.Name,
+		UniqueID:  ikeSA.UniqueID,
+		State:     ikeSA.State,
+		Duration:  duration,
+		DryRun:    w.config.DryRun,
+	}
+	if w.config.DryRun {
+		reportDecision(w.logger, w.config.DecisionReporter, Decision{
+			Component:   "watchdog",
+			IKESAName:   ikeSAStatus.Name,
+			Action:      "terminate",
+			Reason:      fmt.Sprintf("IKE SA %s[%s] has been in non-final states for %s and is %s", ikeSAStatus.Name, ikeSA.UniqueID, duration, ikeSA.State),
+			IKESAStatus: ikeSAStatus,
+		})
+	} else {
+		w.logger.Infof("Terminating IKE SA %s[%s] stuck in state %s after %s", ikeSAStatus.Name, ikeSA.UniqueID, ikeSA.State, duration)
+		stuck.Error = w.client.Terminate(&vici.TerminateRequest{
+			Ike_id:  ikeSA.UniqueID,
+			Force:   "yes",
+			Timeout: strconv.FormatInt(w.config.TerminateTimeout.Milliseconds(), 10),
+		})
+		if stuck.Error != nil {
+			w.logger.Errorf("Failed to terminate stuck IKE SA %s[%s]: %v", ikeSAStatus.Name, ikeSA.UniqueID, stuck.Error)
+		}
+	}
+	if w.config.Reporter != nil {
+		w.config.Reporter.ReportStuck(stuck)
+	}
+}
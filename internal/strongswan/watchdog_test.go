@@ -0,0 +1,179 @@
+package strongswan
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lunarway/strong-duckling/internal/test"
+	"github.com/lunarway/strong-duckling/internal/vici"
+	"github.com/stretchr/testify/assert"
+)
+
+type stuckReports []StuckIKESA
+
+func (r *stuckReports) ReportStuck(stuck StuckIKESA) {
+	*r = append(*r, stuck)
+}
+
+func watchdogStatus(ikeSAs ...vici.IkeSa) IKESAStatus {
+	return IKESAStatus{
+		Name:     "partner1",
+		Sessions: ikeSAs,
+	}
+}
+
+func TestWatchdog(t *testing.T) {
+	connecting := vici.IkeSa{UniqueID: "3", State: vici.IKESAStateConnecting}
+	established := vici.IkeSa{UniqueID: "3", State: vici.IKESAStateEstablished}
+	tt := []struct {
+		name     string
+		statuses []IKESAStatus
+		err      error
+		requests []string
+		stuck    []StuckIKESA
+	}{
+		{
+			name:     "within threshold",
+			statuses: []IKESAStatus{watchdogStatus(connecting), watchdogStatus(connecting)},
+		},
+		{
+			name:     "stuck",
+			statuses: []IKESAStatus{watchdogStatus(connecting), watchdogStatus(connecting), watchdogStatus(connecting)},
+			requests: []string{"terminate 3"},
+			stuck: []StuckIKESA{
+				{IKESAName: "partner1", UniqueID: "3", State: vici.IKESAStateConnecting, Duration: 6 * time.Minute},
+			},
+		},
+		{
+			name: "stuck in other non-final state",
+			statuses: []IKESAStatus{
+				watchdogStatus(connecting),
+				watchdogStatus(vici.IkeSa{UniqueID: "3", State: vici.IKESAStateCreated}),
+				watchdogStatus(vici.IkeSa{UniqueID: "3", State: vici.IKESAStateDeleting}),
+			},
+			requests: []string{"terminate 3"},
+			stuck: []StuckIKESA{
+				{IKESAName: "partner1", UniqueID: "3", State: vici.IKESAStateDeleting, Duration: 6 * time.Minute},
+			},
+		},
+		{
+			name:     "established",
+			statuses: []IKESAStatus{watchdogStatus(connecting), watchdogStatus(established), watchdogStatus(connecting)},
+		},
+		{
+			name:     "other session established",
+			statuses: []IKESAStatus{watchdogStatus(connecting), watchdogStatus(vici.IkeSa{UniqueID: "4", State: vici.IKESAStateEstablished}), watchdogStatus(connecting)},
+		},
+		{
+			name:     "failed termination",
+			statuses: []IKESAStatus{watchdogStatus(connecting), watchdogStatus(connecting), watchdogStatus(connecting)},
+			err:      errors.New("terminate unsuccessful"),
+			requests: []string{"terminate 3"},
+			stuck: []StuckIKESA{
+				{IKESAName: "partner1", UniqueID: "3", State: vici.IKESAStateConnecting, Duration: 6 * time.Minute, Error: errors.New("terminate unsuccessful")},
+			},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &fakeController{
+				terminateErr: tc.err,
+			}
+			var reports stuckReports
+			w := NewWatchdog(client, test.NewLogger(t), WatchdogConfiguration{
+				Reporter: &reports,
+			})
+			now := time.Now()
+			w.now = func() time.Time {
+				return now
+			}
+
+			for _, status := range tc.statuses {
+				w.IKESAStatus(status)
+				now = now.Add(3 * time.Minute)
+			}
+
+			assert.Equal(t, tc.requests, client.requests, "requests not as expected")
+			assert.Equal(t, tc.stuck, []StuckIKESA(reports), "stuck IKE SAs not as expected")
+		})
+	}
+}
+
+func TestWatchdog_terminateRequest(t *testing.T) {
+	client := &recordingTerminator{}
+	w := NewWatchdog(client, test.NewLogger(t), WatchdogConfiguration{
+		Threshold:        time.Minute,
+		TerminateTimeout: 5 * time.Second,
+	})
+	now := time.Now()
+	w.now = func() time.Time {
+		return now
+	}
+	status := watchdogStatus(vici.IkeSa{UniqueID: "3", State: vici.IKESAStateConnecting})
+
+	w.IKESAStatus(status)
+	now = now.Add(2 * time.Minute)
+	w.IKESAStatus(status)
+
+	assert.Equal(t, []vici.TerminateRequest{
+		{Ike_id: "3", Force: "yes", Timeout: "5000"},
+	}, client.terminations, "terminations not as expected")
+}
+
+func TestWatchdog_gone(t *testing.T) {
+	w := NewWatchdog(&fakeController{}, test.NewLogger(t), WatchdogConfiguration{})
+	w.IKESAStatus(watchdogStatus(vici.IkeSa{UniqueID: "3", State: vici.IKESAStateConnecting}))
+	w.CollectionDone()
+	assert.Len(t, w.pending, 1, "pending IKE SA not tracked")
+
+	// the connection is unloaded and its IKE SA torn down
+	w.CollectionDone()
+	assert.Empty(t, w.pending, "pending IKE SA of gone connection not forgotten")
+}
+
+func TestWatchdog_dryRun(t *testing.T) {
+	client := &fakeController{}
+	decisions := make(decisionReports, 1)
+	var reports stuckReports
+	w := NewWatchdog(client, test.NewLogger(t), WatchdogConfiguration{
+		Threshold:        time.Minute,
+		Reporter:         &reports,
+		DryRun:           true,
+		DecisionReporter: decisions,
+	})
+	now := time.Now()
+	w.now = func() time.Time {
+		return now
+	}
+	status := watchdogStatus(vici.IkeSa{UniqueID: "3", State: vici.IKESAStateConnecting})
+
+	w.IKESAStatus(status)
+	now = now.Add(2 * time.Minute)
+	w.IKESAStatus(status)
+
+	assert.Empty(t, client.requests, "requests sent in dry run")
+	assert.Equal(t, stuckReports{
+		{IKESAName: "partner1", UniqueID: "3", State: vici.IKESAStateConnecting, Duration: 2 * time.Minute, DryRun: true},
+	}, reports, "stuck IKE SAs not as expected")
+	if assert.Len(t, decisions, 1, "decisions not as expected") {
+		assert.Equal(t, Decision{
+			Component:   "watchdog",
+			IKESAName:   "partner1",
+			Action:      "terminate",
+			Reason:      "IKE SA partner1[3] has been in non-final states for 2m0s and is CONNECTING",
+			IKESAStatus: status,
+		}, <-decisions, "decision not as expected")
+	}
+}
+
+// recordingTerminator records terminate requests.
+type recordingTerminator struct {
+	fakeController
+	terminations []vici.TerminateRequest
+}
+
+func (r *recordingTerminator) Terminate(req *vici.TerminateRequest) error {
+	r.terminations = append(r.terminations, *req)
+	return nil
+}
@@ -8,40 +8,68 @@ import (
 
 type logReporter struct {
 	lastReport time.Time
-	lastOpen   bool
+	lastState  portState
 	Logger     log.Logger
 }
 
+// portState is the state of a checked port as logged by the logReporter.
+type portState string
+
+const (
+	portStateOpen               portState = "opened"
+	portStateClosed             portState = "closed"
+	portStateUnexpectedResponse portState = "unexpected response"
+)
+
+func reportState(report Report) portState {
+	switch {
+	case report.UnexpectedResponse():
+		return portStateUnexpectedResponse
+	case report.Open:
+		return portStateOpen
+	default:
+		return portStateClosed
+	}
+}
+
 func (r *logReporter) ReportPortCheck(report Report) {
 	l := r.Logger.With("report", report)
+	state := reportState(report)
 	switch {
-	case report.Open && r.lastOpen:
+	case state == portStateOpen && r.lastState == portStateOpen:
 		// Port is still open - great
-	case !report.Open && (r.lastOpen || r.lastReport == time.Time{}):
-		// Port closed
-		r.lastReport = time.Now()
-		r.lastOpen = report.Open
-		l.
-			With("status", "closed").
-			Infof("TCP connection to %s closed", report.Name)
-	case report.Open && !r.lastOpen:
-		// Port opened
-		r.lastReport = time.Now()
-		r.lastOpen = report.Open
-		l.
-			With("status", "opened").
-			Infof("TCP connection to %s opened", report.Name)
-	case !report.Open && !r.lastOpen:
-		// Port still closed
+	case state == r.lastState:
+		// Port still closed or responding unexpectedly
 		if time.Since(r.lastReport) > 5*time.Minute {
 			r.lastReport = time.Now()
-			r.lastOpen = report.Open
 			l.
-				With("status", "closed").
-				Infof("TCP connection to %s is still closed", report.Name)
+				With("status", state).
+				With("reason", report.Failure).
+				Infof("TCP connection to %s is still %s", report.Name, state)
 		}
+	case state == portStateOpen:
+		// Port opened
+		r.lastReport = time.Now()
+		r.lastState = state
+		l.
+			With("status", state).
+			Infof("TCP connection to %s opened", report.Name)
+	case state == portStateUnexpectedResponse:
+		// Port open but another service responds
+		r.lastReport = time.Now()
+		r.lastState = state
+		l.
+			With("status", state).
+			With("reason", report.Failure).
+			Infof("TCP connection to %s opened but the response is unexpected: %q", report.Name, report.Content)
 	default:
-		panic("This should never happen in LogReporter.ReportPortCheck")
+		// Port closed
+		r.lastReport = time.Now()
+		r.lastState = state
+		l.
+			With("status", state).
+			With("reason", report.Failure).
+			Infof("TCP connection to %s closed", report.Name)
 	}
 }
 
@@ -1,5 +1,9 @@
 package tcpchecker
 
+import (
+	"time"
+)
+
 type Reporter interface {
 	ReportPortCheck(report Report)
 }
@@ -12,4 +16,67 @@ type Report struct {
 	Content string
 	Status  string
 	Error   error
+	// Failure is the reason the check failed. It is empty if the port is open
+	// and the response is as expected. If the port is open but the response
+	// does not match the expectation of the target it is
+	// FailureUnexpectedResponse.
+	Failure FailureReason
+	// ConnectDuration is the time it took to establish the connection or fail
+	// doing so.
+	ConnectDuration time.Duration
+	// FirstByteDuration is the time from the connection was established until
+	// the first byte was received. It is 0 if nothing was received.
+	FirstByteDuration time.Duration
+}
+
+// FailureReason classifies why a check failed.
+type FailureReason string
+
+const (
+	// FailureRefused is reported when the connection is actively refused, ie.
+	// the host is reachable but nothing listens on the port.
+	FailureRefused FailureReason = "refused"
+	// FailureTimeout is reported when no response is received in time, eg.
+	// when packets are dropped.
+	FailureTimeout FailureReason = "timeout"
+	// FailureUnreachable is reported when there is no route to the host or
+	// network.
+	FailureUnreachable FailureReason = "unreachable"
+	// FailureDNS is reported when the address cannot be resolved.
+	FailureDNS FailureReason = "dns"
+	// FailureReset is reported when the connection is reset by the peer.
+	FailureReset FailureReason = "reset"
+	// FailureUnexpectedResponse is reported when the port is open but the
+	// response does not match the expectation, ie. another service than
+	// expected is listening.
+	FailureUnexpectedResponse FailureReason = "unexpected_response"
+	// FailureUnknown is reported for all other errors.
+	FailureUnknown FailureReason = "unknown"
+)
+
+// FailureReasons holds all failure reasons.
+var FailureReasons = []FailureReason{
+	FailureRefused,
+	FailureTimeout,
+	FailureUnreachable,
+	FailureDNS,
+	FailureReset,
+	FailureUnexpectedResponse,
+	FailureUnknown,
+}
+
+// Reason returns the reason the check failed or an empty reason if it
+// succeeded. Reports of closed ports without a failure reason are reported as
+// FailureUnknown.
+func (r Report) Reason() FailureReason {
+	if r.Failure == "" && !r.Open {
+		return FailureUnknown
+	}
+	return r.Failure
+}
+
+// UnexpectedResponse reports whether the port is open but the response does
+// not match the expectation.
+func (r Report) UnexpectedResponse() bool {
+	return r.Open && r.Failure == FailureUnexpectedResponse
 }
@@ -0,0 +1,331 @@
+package tcpchecker
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultInterval is the default interval between checks of a target.
+	DefaultInterval = 1 * time.Second
+	// DefaultConnectTimeout is the default timeout of establishing a
+	// connection.
+	DefaultConnectTimeout = 1 * time.Second
+	// DefaultReadTimeout is the default time to read from an established
+	// connection before closing it.
+	DefaultReadTimeout = 1 * time.Second
+)
+
+// Target is an address to check along with the options of the check.
+type Target struct {
+	Name    string
+	Address string
+	Port    int
+	// Interval is the interval between checks.
+	Interval time.Duration
+	// ConnectTimeout is the timeout of establishing a connection.
+	ConnectTimeout time.Duration
+	// ReadTimeout is the time to read from an established connection before
+	// closing it.
+	ReadTimeout time.Duration
+	// BindAddress is the local IP address to connect from. This allows
+	// checking reachability through a specific child SA by binding to an
+	// address in its local traffic selector. If empty the address is chosen by
+	// the kernel.
+	BindAddress string
+	// Send is a payload written to the connection before reading, eg. a
+	// protocol greeting or health request.
+	Send string
+	// Expect is the pattern the content read from the connection is expected to
+	// match.
+	Expect *regexp.Regexp
+	// ExpectPrefix is the prefix the content read from the connection is
+	// expected to start with.
+	ExpectPrefix string
+	// IKESAName and ChildSAName link the target to the child SA traffic to it
+	// passes through. Both are empty if the target is not linked.
+	IKESAName   string
+	ChildSAName string
+}
+
+// matches reports whether content meets the expectations of t. Without
+// expectations any content matches.
+func (t Target) matches(content string) bool {
+	if t.Expect != nil && !t.Expect.MatchString(content) {
+		return false
+	}
+	return strings.HasPrefix(content, t.ExpectPrefix)
+}
+
+func (t *Target) setDefaults() {
+	if t.Name == "" {
+		t.Name = net.JoinHostPort(t.Address, strconv.Itoa(t.Port))
+	}
+	if t.Interval == 0 {
+		t.Interval = DefaultInterval
+	}
+	if t.ConnectTimeout == 0 {
+		t.ConnectTimeout = DefaultConnectTimeout
+	}
+	if t.ReadTimeout == 0 {
+		t.ReadTimeout = DefaultReadTimeout
+	}
+}
+
+func (t Target) validate() error {
+	if t.Address == "" {
+		return fmt.Errorf("address is required")
+	}
+	if t.Port <= 0 || t.Port > 65535 {
+		return fmt.Errorf("port %d is out of range", t.Port)
+	}
+	if t.BindAddress != "" && net.ParseIP(t.BindAddress) == nil {
+		return fmt.Errorf("bind address '%s' is not an IP address", t.BindAddress)
+	}
+	if (t.IKESAName == "") != (t.ChildSAName == "") {
+		return fmt.Errorf("both ike_sa_name and child_sa_name are required to link a child SA")
+	}
+	return nil
+}
+
+// ParseTarget parses a target in one of the forms
+//
+//	<address>:<port>
+//	<name>:<address>:<port>
+//	tcp://[<name>@]<address>:<port>
+//
+// IPv6 addresses must be enclosed in square brackets, eg. [fd00::1]:443.
+// Options can be set as URL query parameters, eg.
+// tcp://partner1@10.0.0.1:22?interval=5s&timeout=2s.
+//
+// Supported options are interval, connect_timeout (or timeout), read_timeout,
+// bind, send, expect, expect_prefix, ike_sa_name and child_sa_name.
+func ParseTarget(s string) (Target, error) {
+	var t Target
+	var rawOptions string
+	var err error
+	if strings.HasPrefix(s, "tcp://") {
+		t, rawOptions, err = parseURLTarget(s)
+	} else {
+		t, rawOptions, err = parseLegacyTarget(s)
+	}
+	if err != nil {
+		return Target{}, err
+	}
+	err = t.setOptions(rawOptions)
+	if err != nil {
+		return Target{}, err
+	}
+	err = t.validate()
+	if err != nil {
+		return Target{}, err
+	}
+	t.setDefaults()
+	return t, nil
+}
+
+// parseURLTarget parses a target of the form tcp://[<name>@]<address>:<port>
+// and returns it along with its raw options.
+func parseURLTarget(s string) (Target, string, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return Target{}, "", fmt.Errorf("could not parse url: %w", err)
+	}
+	if u.Path != "" || u.Fragment != "" {
+		return Target{}, "", fmt.Errorf("unexpected path in %s", s)
+	}
+	if u.Port() == "" {
+		return Target{}, "", fmt.Errorf("port is required")
+	}
+	// url.Parse accepts IPv6 addresses without brackets making the port
+	// ambiguous
+	address, portStr, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		return Target{}, "", fmt.Errorf("could not understand tcp-checker %s", s)
+	}
+	port, err := parsePort(portStr)
+	if err != nil {
+		return Target{}, "", err
+	}
+	t := Target{
+		Address: address,
+		Port:    port,
+	}
+	if u.User != nil {
+		t.Name = u.User.Username()
+	}
+	return t, u.RawQuery, nil
+}
+
+// parseLegacyTarget parses a target of the form <address>:<port> or
+// <name>:<address>:<port> and returns it along with its raw options.
+func parseLegacyTarget(s string) (Target, string, error) {
+	s, rawOptions, _ := strings.Cut(s, "?")
+	var t Target
+	address, portStr, err := net.SplitHostPort(s)
+	if err != nil {
+		// the first part is a name if the rest is an address and port
+		var rest string
+		var ok bool
+		t.Name, rest, ok = strings.Cut(s, ":")
+		if !ok {
+			return Target{}, "", fmt.Errorf("could not understand tcp-checker %s", s)
+		}
+		address, portStr, err = net.SplitHostPort(rest)
+		if err != nil {
+			return Target{}, "", fmt.Errorf("could not understand tcp-checker %s", s)
+		}
+	}
+	t.Address = address
+	t.Port, err = parsePort(portStr)
+	if err != nil {
+		return Target{}, "", err
+	}
+	return t, rawOptions, nil
+}
+
+func parsePort(s string) (int, error) {
+	port, err := strconv.ParseInt(s, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("could not parse port %s as integer: %w", s, err)
+	}
+	return int(port), nil
+}
+
+// setOptions sets the options encoded as URL query parameters in rawOptions.
+func (t *Target) setOptions(rawOptions string) error {
+	options, err := url.ParseQuery(rawOptions)
+	if err != nil {
+		return fmt.Errorf("could not parse options: %w", err)
+	}
+	for key, values := range options {
+		value := values[len(values)-1]
+		switch key {
+		case "interval":
+			t.Interval, err = parsePositiveDuration(key, value)
+		case "connect_timeout", "timeout":
+			t.ConnectTimeout, err = parsePositiveDuration(key, value)
+		case "read_timeout":
+			t.ReadTimeout, err = parsePositiveDuration(key, value)
+		case "bind":
+			t.BindAddress = value
+		case "send":
+			t.Send = value
+		case "expect_prefix":
+			t.ExpectPrefix = value
+		case "ike_sa_name":
+			t.IKESAName = value
+		case "child_sa_name":
+			t.ChildSAName = value
+		case "expect":
+			t.Expect, err = regexp.Compile(value)
+			if err != nil {
+				err = fmt.Errorf("could not parse expect: %w", err)
+			}
+		default:
+			err = fmt.Errorf("unknown option '%s'", key)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parsePositiveDuration(key, value string) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("could not parse %s: %w", key, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("%s must be positive", key)
+	}
+	return d, nil
+}
+
+// targetsConfig is the format of a targets configuration file.
+type targetsConfig struct {
+	Targets []struct {
+		Name           string `json:"name"`
+		Address        string `json:"address"`
+		Port           int    `json:"port"`
+		Interval       string `json:"interval"`
+		ConnectTimeout string `json:"connect_timeout"`
+		ReadTimeout    string `json:"read_timeout"`
+		BindAddress    string `json:"bind"`
+		Send           string `json:"send"`
+		Expect         string `json:"expect"`
+		ExpectPrefix   string `json:"expect_prefix"`
+		IKESAName      string `json:"ike_sa_name"`
+		ChildSAName    string `json:"child_sa_name"`
+	} `json:"targets"`
+}
+
+// ReadTargets reads targets from a JSON configuration file. Options that are
+// omitted are set to their defaults.
+//
+//	{
+//	  "targets": [
+//	    {"name": "partner1", "address": "10.0.0.1", "port": 22, "interval": "5s", "bind": "10.1.0.1", "expect": "^SSH-2\\.0"}
+//	  ]
+//	}
+func ReadTargets(r io.Reader) ([]Target, error) {
+	var config targetsConfig
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&config)
+	if err != nil {
+		return nil, fmt.Errorf("decode targets: %w", err)
+	}
+	var targets []Target
+	for i, c := range config.Targets {
+		t := Target{
+			Name:         c.Name,
+			Address:      c.Address,
+			Port:         c.Port,
+			BindAddress:  c.BindAddress,
+			Send:         c.Send,
+			ExpectPrefix: c.ExpectPrefix,
+			IKESAName:    c.IKESAName,
+			ChildSAName:  c.ChildSAName,
+		}
+		if c.Expect != "" {
+			t.Expect, err = regexp.Compile(c.Expect)
+			if err != nil {
+				return nil, fmt.Errorf("target %d: could not parse expect: %w", i, err)
+			}
+		}
+		durations := []struct {
+			key   string
+			value string
+			d     *time.Duration
+		}{
+			{"interval", c.Interval, &t.Interval},
+			{"connect_timeout", c.ConnectTimeout, &t.ConnectTimeout},
+			{"read_timeout", c.ReadTimeout, &t.ReadTimeout},
+		}
+		for _, d := range durations {
+			if d.value == "" {
+				continue
+			}
+			*d.d, err = parsePositiveDuration(d.key, d.value)
+			if err != nil {
+				return nil, fmt.Errorf("target %d: %w", i, err)
+			}
+		}
+		err = t.validate()
+		if err != nil {
+			return nil, fmt.Errorf("target %d: %w", i, err)
+		}
+		t.setDefaults()
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
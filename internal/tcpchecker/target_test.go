@@ -0,0 +1,320 @@
+package tcpchecker
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTarget(t *testing.T) {
+	tt := []struct {
+		name   string
+		input  string
+		target Target
+		err    string
+	}{
+		{
+			name:  "address and port",
+			input: "10.0.0.1:22",
+			target: Target{
+				Name:           "10.0.0.1:22",
+				Address:        "10.0.0.1",
+				Port:           22,
+				Interval:       DefaultInterval,
+				ConnectTimeout: DefaultConnectTimeout,
+				ReadTimeout:    DefaultReadTimeout,
+			},
+		},
+		{
+			name:  "name, address and port",
+			input: "partner1:10.0.0.1:22",
+			target: Target{
+				Name:           "partner1",
+				Address:        "10.0.0.1",
+				Port:           22,
+				Interval:       DefaultInterval,
+				ConnectTimeout: DefaultConnectTimeout,
+				ReadTimeout:    DefaultReadTimeout,
+			},
+		},
+		{
+			name:  "options",
+			input: "partner1:10.0.0.1:22?interval=5s&connect_timeout=2s&read_timeout=500ms&bind=10.1.0.1",
+			target: Target{
+				Name:           "partner1",
+				Address:        "10.0.0.1",
+				Port:           22,
+				Interval:       5 * time.Second,
+				ConnectTimeout: 2 * time.Second,
+				ReadTimeout:    500 * time.Millisecond,
+				BindAddress:    "10.1.0.1",
+			},
+		},
+		{
+			name:  "legacy ipv6",
+			input: "[fd00::1]:443",
+			target: Target{
+				Name:           "[fd00::1]:443",
+				Address:        "fd00::1",
+				Port:           443,
+				Interval:       DefaultInterval,
+				ConnectTimeout: DefaultConnectTimeout,
+				ReadTimeout:    DefaultReadTimeout,
+			},
+		},
+		{
+			name:  "legacy name and ipv6",
+			input: "partner1:[fd00::1]:443",
+			target: Target{
+				Name:           "partner1",
+				Address:        "fd00::1",
+				Port:           443,
+				Interval:       DefaultInterval,
+				ConnectTimeout: DefaultConnectTimeout,
+				ReadTimeout:    DefaultReadTimeout,
+			},
+		},
+		{
+			name:  "legacy hostname",
+			input: "partner1:ssh.example.com:22",
+			target: Target{
+				Name:           "partner1",
+				Address:        "ssh.example.com",
+				Port:           22,
+				Interval:       DefaultInterval,
+				ConnectTimeout: DefaultConnectTimeout,
+				ReadTimeout:    DefaultReadTimeout,
+			},
+		},
+		{
+			name:  "url",
+			input: "tcp://10.0.0.1:22",
+			target: Target{
+				Name:           "10.0.0.1:22",
+				Address:        "10.0.0.1",
+				Port:           22,
+				Interval:       DefaultInterval,
+				ConnectTimeout: DefaultConnectTimeout,
+				ReadTimeout:    DefaultReadTimeout,
+			},
+		},
+		{
+			name:  "url with name, ipv6 and options",
+			input: "tcp://name@[fd00::1]:443?interval=5s&timeout=2s&expect=^SSH",
+			target: Target{
+				Name:           "name",
+				Address:        "fd00::1",
+				Port:           443,
+				Interval:       5 * time.Second,
+				ConnectTimeout: 2 * time.Second,
+				ReadTimeout:    DefaultReadTimeout,
+				Expect:         regexp.MustCompile("^SSH"),
+			},
+		},
+		{
+			name:  "url with escaped options",
+			input: "tcp://partner1@ssh.example.com:22?expect=%5ESSH-2%5C.0&bind=fd01::1",
+			target: Target{
+				Name:           "partner1",
+				Address:        "ssh.example.com",
+				Port:           22,
+				Interval:       DefaultInterval,
+				ConnectTimeout: DefaultConnectTimeout,
+				ReadTimeout:    DefaultReadTimeout,
+				BindAddress:    "fd01::1",
+				Expect:         regexp.MustCompile(`^SSH-2\.0`),
+			},
+		},
+		{
+			name:  "url with send and expect_prefix",
+			input: "tcp://smtp@10.0.0.1:25?send=EHLO%20example.com%0D%0A&expect_prefix=250",
+			target: Target{
+				Name:           "smtp",
+				Address:        "10.0.0.1",
+				Port:           25,
+				Interval:       DefaultInterval,
+				ConnectTimeout: DefaultConnectTimeout,
+				ReadTimeout:    DefaultReadTimeout,
+				Send:           "EHLO example.com\r\n",
+				ExpectPrefix:   "250",
+			},
+		},
+		{
+			name:  "url linked to child sa",
+			input: "tcp://partner1@10.2.0.1:22?ike_sa_name=partner1&child_sa_name=net-1",
+			target: Target{
+				Name:           "partner1",
+				Address:        "10.2.0.1",
+				Port:           22,
+				Interval:       DefaultInterval,
+				ConnectTimeout: DefaultConnectTimeout,
+				ReadTimeout:    DefaultReadTimeout,
+				IKESAName:      "partner1",
+				ChildSAName:    "net-1",
+			},
+		},
+		{
+			name:  "url linked to child sa without ike sa",
+			input: "tcp://10.2.0.1:22?child_sa_name=net-1",
+			err:   "both ike_sa_name and child_sa_name are required to link a child SA",
+		},
+		{
+			name:  "url without port",
+			input: "tcp://name@10.0.0.1",
+			err:   "port is required",
+		},
+		{
+			name:  "url with path",
+			input: "tcp://10.0.0.1:22/health",
+			err:   "unexpected path in tcp://10.0.0.1:22/health",
+		},
+		{
+			name:  "url with unbracketed ipv6",
+			input: "tcp://fd00::1:443",
+			err:   "could not understand tcp-checker tcp://fd00::1:443",
+		},
+		{
+			name:  "url without address",
+			input: "tcp://name@:22",
+			err:   "address is required",
+		},
+		{
+			name:  "invalid expect",
+			input: "tcp://10.0.0.1:22?expect=(",
+			err:   "could not parse expect: error parsing regexp: missing closing ): `(`",
+		},
+		{
+			name:  "unbracketed ipv6",
+			input: "fd00::1:443",
+			err:   "could not understand tcp-checker fd00::1:443",
+		},
+		{
+			name:  "too many parts",
+			input: "a:b:c:22",
+			err:   "could not understand tcp-checker a:b:c:22",
+		},
+		{
+			name:  "invalid port",
+			input: "10.0.0.1:ssh",
+			err:   `could not parse port ssh as integer: strconv.ParseInt: parsing "ssh": invalid syntax`,
+		},
+		{
+			name:  "port out of range",
+			input: "10.0.0.1:70000",
+			err:   "port 70000 is out of range",
+		},
+		{
+			name:  "unknown option",
+			input: "10.0.0.1:22?retries=3",
+			err:   "unknown option 'retries'",
+		},
+		{
+			name:  "invalid interval",
+			input: "10.0.0.1:22?interval=often",
+			err:   `could not parse interval: time: invalid duration "often"`,
+		},
+		{
+			name:  "negative timeout",
+			input: "10.0.0.1:22?connect_timeout=-1s",
+			err:   "connect_timeout must be positive",
+		},
+		{
+			name:  "invalid bind address",
+			input: "10.0.0.1:22?bind=localhost",
+			err:   "bind address 'localhost' is not an IP address",
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			target, err := ParseTarget(tc.input)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err, "error not as expected")
+				return
+			}
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tc.target, target, "target not as expected")
+		})
+	}
+}
+
+func TestReadTargets(t *testing.T) {
+	tt := []struct {
+		name    string
+		input   string
+		targets []Target
+		err     string
+	}{
+		{
+			name: "targets with and without options",
+			input: `{"targets": [
+				{"name": "partner1", "address": "10.0.0.1", "port": 22, "interval": "5s", "connect_timeout": "2s", "read_timeout": "3s", "bind": "10.1.0.1"},
+				{"address": "10.0.0.2", "port": 443},
+				{"name": "health", "address": "10.0.0.3", "port": 8000, "send": "HEALTH\n", "expect": "^OK", "expect_prefix": "OK"}
+			]}`,
+			targets: []Target{
+				{
+					Name:           "partner1",
+					Address:        "10.0.0.1",
+					Port:           22,
+					Interval:       5 * time.Second,
+					ConnectTimeout: 2 * time.Second,
+					ReadTimeout:    3 * time.Second,
+					BindAddress:    "10.1.0.1",
+				},
+				{
+					Name:           "10.0.0.2:443",
+					Address:        "10.0.0.2",
+					Port:           443,
+					Interval:       DefaultInterval,
+					ConnectTimeout: DefaultConnectTimeout,
+					ReadTimeout:    DefaultReadTimeout,
+				},
+				{
+					Name:           "health",
+					Address:        "10.0.0.3",
+					Port:           8000,
+					Interval:       DefaultInterval,
+					ConnectTimeout: DefaultConnectTimeout,
+					ReadTimeout:    DefaultReadTimeout,
+					Send:           "HEALTH\n",
+					Expect:         regexp.MustCompile("^OK"),
+					ExpectPrefix:   "OK",
+				},
+			},
+		},
+		{
+			name:  "missing address",
+			input: `{"targets": [{"port": 22}]}`,
+			err:   "target 0: address is required",
+		},
+		{
+			name:  "invalid duration",
+			input: `{"targets": [{"address": "10.0.0.1", "port": 22, "read_timeout": "1"}]}`,
+			err:   `target 0: could not parse read_timeout: time: missing unit in duration "1"`,
+		},
+		{
+			name:  "invalid expect",
+			input: `{"targets": [{"address": "10.0.0.1", "port": 22, "expect": "["}]}`,
+			err:   "target 0: could not parse expect: error parsing regexp: missing closing ]: `[`",
+		},
+		{
+			name:  "unknown field",
+			input: `{"targets": [{"address": "10.0.0.1", "port": 22, "timeout": "1s"}]}`,
+			err:   `decode targets: json: unknown field "timeout"`,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			targets, err := ReadTargets(strings.NewReader(tc.input))
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err, "error not as expected")
+				return
+			}
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tc.targets, targets, "targets not as expected")
+		})
+	}
+}
@@ -4,79 +4,153 @@ import (
 	"bufio"
 	"errors"
 	"fmt"
+	"io"
 	"net"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 )
 
-func Check(name string, address string, port int, reporter Reporter) {
-	conn, err := net.DialTimeout("tcp", fmt.Sprintf("%s:%v", address, port), 1*time.Second)
+// Check connects to target and reports the result to reporter. Options that
+// are not set on target use their defaults.
+func Check(target Target, reporter Reporter) {
+	target.setDefaults()
+	name, address, port := target.Name, target.Address, target.Port
+	dialer := net.Dialer{
+		Timeout: target.ConnectTimeout,
+	}
+	if target.BindAddress != "" {
+		dialer.LocalAddr = &net.TCPAddr{
+			IP: net.ParseIP(target.BindAddress),
+		}
+	}
+	dialStart := time.Now()
+	conn, err := dialer.Dial("tcp", net.JoinHostPort(address, strconv.Itoa(port)))
+	connectDuration := time.Since(dialStart)
 	if err != nil {
 		reporter.ReportPortCheck(Report{
-			Name:    name,
-			Address: address,
-			Port:    port,
-			Open:    false,
-			Status:  "Connect error",
-			Error:   err,
-			Content: "",
+			Name:            name,
+			Address:         address,
+			Port:            port,
+			Open:            false,
+			Status:          "Connect error",
+			Error:           err,
+			Content:         "",
+			Failure:         classifyError(err),
+			ConnectDuration: connectDuration,
 		})
 		return
 	}
+	defer conn.Close()
 
-	err = conn.SetDeadline(time.Now().Add(1 * time.Second))
+	err = conn.SetDeadline(time.Now().Add(target.ReadTimeout))
 	if err != nil {
 		reporter.ReportPortCheck(Report{
-			Name:    name,
-			Address: address,
-			Port:    port,
-			Open:    false,
-			Status:  "Set deadline error",
-			Error:   err,
-			Content: "",
+			Name:            name,
+			Address:         address,
+			Port:            port,
+			Open:            false,
+			Status:          "Set deadline error",
+			Error:           err,
+			Content:         "",
+			Failure:         classifyError(err),
+			ConnectDuration: connectDuration,
 		})
 		return
 	}
 
-	scanner := bufio.NewScanner(conn)
+	if target.Send != "" {
+		_, err = io.WriteString(conn, target.Send)
+		if err != nil {
+			reporter.ReportPortCheck(Report{
+				Name:            name,
+				Address:         address,
+				Port:            port,
+				Open:            false,
+				Status:          "Send error",
+				Error:           err,
+				Content:         "",
+				Failure:         classifyError(err),
+				ConnectDuration: connectDuration,
+			})
+			return
+		}
+	}
+
+	reader := &firstByteReader{
+		reader: conn,
+		start:  time.Now(),
+	}
+	scanner := bufio.NewScanner(reader)
 
 	output := strings.Builder{}
 	for scanner.Scan() {
 		fmt.Fprintf(&output, "%s\n", scanner.Text())
 	}
 
+	report := Report{
+		Name:              name,
+		Address:           address,
+		Port:              port,
+		Open:              true,
+		Status:            "Open (closed by peer)",
+		Error:             nil,
+		Content:           output.String(),
+		ConnectDuration:   connectDuration,
+		FirstByteDuration: reader.firstByte,
+	}
 	if err := scanner.Err(); err != nil {
 		var netError net.Error
 		if errors.As(err, &netError) && netError.Timeout() {
-			reporter.ReportPortCheck(Report{
-				Name:    name,
-				Address: address,
-				Port:    port,
-				Open:    true,
-				Status:  "Open (closed by us)",
-				Error:   nil,
-				Content: output.String(),
-			})
+			report.Status = "Open (closed by us)"
+		} else {
+			report.Open = false
+			report.Status = "Scanner error"
+			report.Error = err
+			report.Failure = classifyError(err)
+			reporter.ReportPortCheck(report)
 			return
 		}
-		reporter.ReportPortCheck(Report{
-			Name:    name,
-			Address: address,
-			Port:    port,
-			Open:    false,
-			Status:  "Scanner error",
-			Error:   err,
-			Content: output.String(),
-		})
-		return
 	}
-	reporter.ReportPortCheck(Report{
-		Name:    name,
-		Address: address,
-		Port:    port,
-		Open:    true,
-		Status:  "Open (closed by peer)",
-		Error:   nil,
-		Content: output.String(),
-	})
+	if !target.matches(report.Content) {
+		report.Status = "Open (unexpected response)"
+		report.Failure = FailureUnexpectedResponse
+	}
+	reporter.ReportPortCheck(report)
+}
+
+// firstByteReader records the time from start until the first byte is read.
+type firstByteReader struct {
+	reader    io.Reader
+	start     time.Time
+	firstByte time.Duration
+}
+
+func (r *firstByteReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 && r.firstByte == 0 {
+		r.firstByte = time.Since(r.start)
+	}
+	return n, err
+}
+
+// classifyError returns the FailureReason of err.
+func classifyError(err error) FailureReason {
+	var dnsError *net.DNSError
+	var netError net.Error
+	switch {
+	case errors.As(err, &dnsError):
+		return FailureDNS
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return FailureRefused
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE):
+		return FailureReset
+	case errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH), errors.Is(err, syscall.EHOSTDOWN), errors.Is(err, syscall.ENETDOWN):
+		return FailureUnreachable
+	case errors.Is(err, syscall.ETIMEDOUT), errors.As(err, &netError) && netError.Timeout():
+		return FailureTimeout
+	default:
+		return FailureUnknown
+	}
 }
@@ -0,0 +1,225 @@
+package tcpchecker
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type reportRecorder struct {
+	reports []Report
+}
+
+func (r *reportRecorder) ReportPortCheck(report Report) {
+	r.reports = append(r.reports, report)
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestClassifyError(t *testing.T) {
+	tt := []struct {
+		name   string
+		err    error
+		reason FailureReason
+	}{
+		{
+			name:   "dns",
+			err:    &net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "unknown.local", IsNotFound: true}},
+			reason: FailureDNS,
+		},
+		{
+			name:   "refused",
+			err:    &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)},
+			reason: FailureRefused,
+		},
+		{
+			name:   "reset",
+			err:    &net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)},
+			reason: FailureReset,
+		},
+		{
+			name:   "host unreachable",
+			err:    &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.EHOSTUNREACH)},
+			reason: FailureUnreachable,
+		},
+		{
+			name:   "network unreachable",
+			err:    &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ENETUNREACH)},
+			reason: FailureUnreachable,
+		},
+		{
+			name:   "dial timeout",
+			err:    &net.OpError{Op: "dial", Err: timeoutError{}},
+			reason: FailureTimeout,
+		},
+		{
+			name:   "kernel timeout",
+			err:    &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ETIMEDOUT)},
+			reason: FailureTimeout,
+		},
+		{
+			name:   "unknown",
+			err:    errors.New("something else"),
+			reason: FailureUnknown,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.reason, classifyError(tc.err), "failure reason not as expected")
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen on tcp: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			fmt.Fprintf(conn, "SSH-2.0-OpenSSH_8.9\n")
+			conn.Close()
+		}
+	}()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	t.Run("open", func(t *testing.T) {
+		recorder := &reportRecorder{}
+		Check(Target{Name: "ssh", Address: "127.0.0.1", Port: port}, recorder)
+
+		if !assert.Len(t, recorder.reports, 1, "number of reports not as expected") {
+			return
+		}
+		report := recorder.reports[0]
+		assert.True(t, report.Open, "port not reported open")
+		assert.Equal(t, FailureReason(""), report.Failure, "failure reason not as expected")
+		assert.Equal(t, "SSH-2.0-OpenSSH_8.9\n", report.Content, "content not as expected")
+		assert.NotZero(t, report.ConnectDuration, "connect duration not set")
+		assert.NotZero(t, report.FirstByteDuration, "first byte duration not set")
+	})
+
+	t.Run("refused", func(t *testing.T) {
+		// reserve a port and close it again to get one nothing listens on
+		closed, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("listen on tcp: %v", err)
+		}
+		closedPort := closed.Addr().(*net.TCPAddr).Port
+		closed.Close()
+
+		recorder := &reportRecorder{}
+		Check(Target{Name: "closed", Address: "127.0.0.1", Port: closedPort}, recorder)
+
+		if !assert.Len(t, recorder.reports, 1, "number of reports not as expected") {
+			return
+		}
+		report := recorder.reports[0]
+		assert.False(t, report.Open, "port reported open")
+		assert.Equal(t, FailureRefused, report.Failure, "failure reason not as expected")
+		assert.Zero(t, report.FirstByteDuration, "first byte duration set")
+	})
+
+	t.Run("expected response", func(t *testing.T) {
+		recorder := &reportRecorder{}
+		Check(Target{Name: "ssh", Address: "127.0.0.1", Port: port, Expect: regexp.MustCompile(`^SSH-2\.0`), ExpectPrefix: "SSH"}, recorder)
+
+		if !assert.Len(t, recorder.reports, 1, "number of reports not as expected") {
+			return
+		}
+		report := recorder.reports[0]
+		assert.True(t, report.Open, "port not reported open")
+		assert.False(t, report.UnexpectedResponse(), "response reported unexpected")
+		assert.Equal(t, FailureReason(""), report.Reason(), "failure reason not as expected")
+	})
+
+	t.Run("unexpected response", func(t *testing.T) {
+		tt := []struct {
+			name   string
+			target Target
+		}{
+			{
+				name:   "regular expression",
+				target: Target{Name: "smtp", Address: "127.0.0.1", Port: port, Expect: regexp.MustCompile(`^220 `)},
+			},
+			{
+				name:   "prefix",
+				target: Target{Name: "smtp", Address: "127.0.0.1", Port: port, ExpectPrefix: "220 "},
+			},
+		}
+		for _, tc := range tt {
+			t.Run(tc.name, func(t *testing.T) {
+				recorder := &reportRecorder{}
+				Check(tc.target, recorder)
+
+				if !assert.Len(t, recorder.reports, 1, "number of reports not as expected") {
+					return
+				}
+				report := recorder.reports[0]
+				assert.True(t, report.Open, "port not reported open")
+				assert.True(t, report.UnexpectedResponse(), "response not reported unexpected")
+				assert.Equal(t, FailureUnexpectedResponse, report.Reason(), "failure reason not as expected")
+				assert.Equal(t, "Open (unexpected response)", report.Status, "status not as expected")
+			})
+		}
+	})
+
+	t.Run("bind address", func(t *testing.T) {
+		recorder := &reportRecorder{}
+		Check(Target{Name: "ssh", Address: "127.0.0.1", Port: port, BindAddress: "127.0.0.1"}, recorder)
+
+		if !assert.Len(t, recorder.reports, 1, "number of reports not as expected") {
+			return
+		}
+		assert.True(t, recorder.reports[0].Open, "port not reported open")
+	})
+}
+
+func TestCheck_send(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen on tcp: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			// respond to a health request only
+			line, _ := bufio.NewReader(conn).ReadString('\n')
+			if line == "HEALTH\n" {
+				fmt.Fprintf(conn, "OK\n")
+			}
+			conn.Close()
+		}
+	}()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	recorder := &reportRecorder{}
+	Check(Target{Name: "health", Address: "127.0.0.1", Port: port, Send: "HEALTH\n", ExpectPrefix: "OK"}, recorder)
+
+	if !assert.Len(t, recorder.reports, 1, "number of reports not as expected") {
+		return
+	}
+	report := recorder.reports[0]
+	assert.True(t, report.Open, "port not reported open")
+	assert.Equal(t, "OK\n", report.Content, "content not as expected")
+	assert.False(t, report.UnexpectedResponse(), "response reported unexpected")
+}
@@ -0,0 +1,220 @@
+package tunnelchecker
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/lunarway/strong-duckling/internal/strongswan"
+	"github.com/lunarway/strong-duckling/internal/tcpchecker"
+)
+
+// Hint annotates which hosts and ports behind the remote traffic selectors of
+// child SAs are expected to accept TCP connections.
+type Hint struct {
+	// IKESAName is the name of the connection the hint applies to. If empty it
+	// applies to all connections.
+	IKESAName string
+	// ChildSAName is the name of the child SA the hint applies to. If empty it
+	// applies to all child SAs of the connection.
+	ChildSAName string
+	// Name is the name of the derived targets. If empty the address and port is
+	// used.
+	Name string
+	// Host is either an IP address or a host number. An IP address is checked
+	// if it is within a remote traffic selector of the child SA. A host number
+	// is added to the network address of each remote traffic selector, eg. host
+	// 1 of 10.2.0.0/16 is 10.2.0.1.
+	Host string
+	Port int
+	// Options are tcpchecker target options encoded as URL query parameters,
+	// eg. interval=5s&expect=^SSH.
+	Options string
+}
+
+func (h Hint) matches(ikeSAName, childSAName string) bool {
+	if h.IKESAName != "" && h.IKESAName != ikeSAName {
+		return false
+	}
+	return h.ChildSAName == "" || h.ChildSAName == childSAName
+}
+
+// address returns the address of the hint within prefix.
+func (h Hint) address(prefix netip.Prefix) (netip.Addr, bool) {
+	return HostAddress(prefix, h.Host)
+}
+
+// HostAddress returns the address of host within prefix. Host is either an IP
+// address within prefix or a host number added to the network address of
+// prefix, eg. host 1 of 10.2.0.0/16 is 10.2.0.1.
+func HostAddress(prefix netip.Prefix, host string) (netip.Addr, bool) {
+	addr, err := netip.ParseAddr(host)
+	if err == nil {
+		return addr, prefix.Contains(addr)
+	}
+	hostNumber, err := strconv.ParseUint(host, 10, 64)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	hostBits := prefix.Addr().BitLen() - prefix.Bits()
+	if hostBits < 64 && hostNumber >= 1<<hostBits {
+		return netip.Addr{}, false
+	}
+	addr = prefix.Masked().Addr()
+	if addr.Is4() {
+		a := addr.As4()
+		n := binary.BigEndian.Uint32(a[:]) + uint32(hostNumber)
+		binary.BigEndian.PutUint32(a[:], n)
+		return netip.AddrFrom4(a), true
+	}
+	a := addr.As16()
+	n := binary.BigEndian.Uint64(a[8:]) + hostNumber
+	binary.BigEndian.PutUint64(a[8:], n)
+	return netip.AddrFrom16(a), true
+}
+
+func (h Hint) target(addr netip.Addr) (tcpchecker.Target, error) {
+	var name string
+	if h.Name != "" {
+		name = h.Name + "@"
+	}
+	return tcpchecker.ParseTarget(fmt.Sprintf("tcp://%s%s?%s", name, net.JoinHostPort(addr.String(), strconv.Itoa(h.Port)), h.Options))
+}
+
+func (h Hint) validate() error {
+	if h.Host == "" {
+		return fmt.Errorf("host is required")
+	}
+	_, addrErr := netip.ParseAddr(h.Host)
+	_, hostNumberErr := strconv.ParseUint(h.Host, 10, 64)
+	if addrErr != nil && hostNumberErr != nil {
+		return fmt.Errorf("host '%s' is neither an IP address nor a host number", h.Host)
+	}
+	// options are validated on a placeholder address as the actual addresses
+	// are not known until traffic selectors are derived
+	_, err := h.target(netip.IPv4Unspecified())
+	return err
+}
+
+// hintsConfig is the format of a hints configuration file.
+type hintsConfig struct {
+	Hints []struct {
+		IKESAName   string `json:"ike_sa_name"`
+		ChildSAName string `json:"child_sa_name"`
+		Name        string `json:"name"`
+		Host        string `json:"host"`
+		Port        int    `json:"port"`
+		Options     string `json:"options"`
+	} `json:"hints"`
+}
+
+// ReadHints reads hints from a JSON configuration file.
+//
+//	{
+//	  "hints": [
+//	    {"ike_sa_name": "partner1", "child_sa_name": "net-1", "name": "ssh", "host": "1", "port": 22, "options": "interval=5s&expect=^SSH"}
+//	  ]
+//	}
+func ReadHints(r io.Reader) ([]Hint, error) {
+	var config hintsConfig
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&config)
+	if err != nil {
+		return nil, fmt.Errorf("decode hints: %w", err)
+	}
+	var hints []Hint
+	for i, c := range config.Hints {
+		h := Hint{
+			IKESAName:   c.IKESAName,
+			ChildSAName: c.ChildSAName,
+			Name:        c.Name,
+			Host:        c.Host,
+			Port:        c.Port,
+			Options:     c.Options,
+		}
+		err = h.validate()
+		if err != nil {
+			return nil, fmt.Errorf("hint %d: %w", i, err)
+		}
+		hints = append(hints, h)
+	}
+	return hints, nil
+}
+
+// Target is a TCP check derived from a hint and the traffic selectors of a
+// child SA. It is linked to the child SA.
+type Target struct {
+	tcpchecker.Target
+}
+
+// key identifies the target among the targets of an IKE SA.
+func (t Target) key() string {
+	return fmt.Sprintf("%s/%s/%s/%s/%d", t.IKESAName, t.ChildSAName, t.Name, t.Address, t.Port)
+}
+
+// Targets derives the targets of the configured child SAs of ikeSAStatus from
+// hints. Traffic selectors that are not subnets, eg. dynamic or address
+// ranges, are skipped.
+func Targets(ikeSAStatus strongswan.IKESAStatus, hints []Hint) []Target {
+	var targets []Target
+	seen := make(map[string]struct{})
+	for _, childSA := range ikeSAStatus.ChildSA {
+		for _, trafficSelector := range childSA.Configuration.RemoteTrafficSelectors {
+			prefix, ok := ParseTrafficSelector(trafficSelector)
+			if !ok {
+				continue
+			}
+			for _, hint := range hints {
+				if !hint.matches(ikeSAStatus.Name, childSA.Name) {
+					continue
+				}
+				addr, ok := hint.address(prefix)
+				if !ok {
+					continue
+				}
+				tcpTarget, err := hint.target(addr)
+				if err != nil {
+					// hints are validated when read so this only happens for
+					// hints constructed otherwise
+					continue
+				}
+				tcpTarget.IKESAName = ikeSAStatus.Name
+				tcpTarget.ChildSAName = childSA.Name
+				target := Target{
+					Target: tcpTarget,
+				}
+				if _, ok := seen[target.key()]; ok {
+					continue
+				}
+				seen[target.key()] = struct{}{}
+				targets = append(targets, target)
+			}
+		}
+	}
+	return targets
+}
+
+// ParseTrafficSelector parses a traffic selector as configured in swanctl or
+// reported for installed child SAs, eg. 10.2.0.0/16, 10.2.0.1 or
+// 10.2.0.0/16[tcp/22]. Dynamic selectors and address ranges are not parsed.
+func ParseTrafficSelector(trafficSelector string) (netip.Prefix, bool) {
+	trafficSelector, _, _ = strings.Cut(strings.TrimSpace(trafficSelector), "[")
+	if strings.Contains(trafficSelector, "/") {
+		prefix, err := netip.ParsePrefix(trafficSelector)
+		if err != nil {
+			return netip.Prefix{}, false
+		}
+		return prefix, true
+	}
+	addr, err := netip.ParseAddr(trafficSelector)
+	if err != nil {
+		return netip.Prefix{}, false
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), true
+}
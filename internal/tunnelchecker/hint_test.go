@@ -0,0 +1,203 @@
+package tunnelchecker
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lunarway/strong-duckling/internal/strongswan"
+	"github.com/lunarway/strong-duckling/internal/vici"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTargets(t *testing.T) {
+	status := func(remoteTrafficSelectors ...string) strongswan.IKESAStatus {
+		return strongswan.IKESAStatus{
+			Name: "partner1",
+			ChildSA: []strongswan.ChildSAStatus{
+				{
+					Name: "net-1",
+					Configuration: vici.ChildSAConf{
+						RemoteTrafficSelectors: remoteTrafficSelectors,
+					},
+				},
+			},
+		}
+	}
+	type address struct {
+		name    string
+		address string
+		port    int
+	}
+	tt := []struct {
+		name    string
+		status  strongswan.IKESAStatus
+		hints   []Hint
+		targets []address
+	}{
+		{
+			name:   "host number",
+			status: status("10.2.0.0/16"),
+			hints: []Hint{
+				{Host: "1", Port: 22},
+			},
+			targets: []address{
+				{"10.2.0.1:22", "10.2.0.1", 22},
+			},
+		},
+		{
+			name:   "host number in each traffic selector",
+			status: status("10.2.0.0/16", "10.3.0.0/24[tcp/22]"),
+			hints: []Hint{
+				{Name: "ssh", Host: "10", Port: 22},
+			},
+			targets: []address{
+				{"ssh", "10.2.0.10", 22},
+				{"ssh", "10.3.0.10", 22},
+			},
+		},
+		{
+			name:   "host number outside traffic selector",
+			status: status("10.2.0.0/30"),
+			hints: []Hint{
+				{Host: "4", Port: 22},
+			},
+		},
+		{
+			name:   "ipv6 host number",
+			status: status("fd00:2::/64"),
+			hints: []Hint{
+				{Host: "1", Port: 443},
+			},
+			targets: []address{
+				{"[fd00:2::1]:443", "fd00:2::1", 443},
+			},
+		},
+		{
+			name:   "address within traffic selector",
+			status: status("10.2.0.0/16", "10.3.0.0/16"),
+			hints: []Hint{
+				{Host: "10.3.1.1", Port: 22},
+				{Host: "10.4.1.1", Port: 22},
+			},
+			targets: []address{
+				{"10.3.1.1:22", "10.3.1.1", 22},
+			},
+		},
+		{
+			name:   "single address traffic selector",
+			status: status("10.2.0.5"),
+			hints: []Hint{
+				{Host: "10.2.0.5", Port: 22},
+			},
+			targets: []address{
+				{"10.2.0.5:22", "10.2.0.5", 22},
+			},
+		},
+		{
+			name:   "dynamic and range traffic selectors",
+			status: status("dynamic", "10.2.0.1-10.2.0.9"),
+			hints: []Hint{
+				{Host: "1", Port: 22},
+			},
+		},
+		{
+			name:   "other ike sa",
+			status: status("10.2.0.0/16"),
+			hints: []Hint{
+				{IKESAName: "partner2", Host: "1", Port: 22},
+				{IKESAName: "partner1", ChildSAName: "net-2", Host: "1", Port: 22},
+				{IKESAName: "partner1", ChildSAName: "net-1", Host: "2", Port: 22},
+			},
+			targets: []address{
+				{"10.2.0.2:22", "10.2.0.2", 22},
+			},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			targets := Targets(tc.status, tc.hints)
+			var addresses []address
+			for _, target := range targets {
+				assert.Equal(t, "partner1", target.IKESAName, "ike sa name")
+				assert.Equal(t, "net-1", target.ChildSAName, "child sa name")
+				addresses = append(addresses, address{target.Name, target.Address, target.Port})
+			}
+			assert.Equal(t, tc.targets, addresses, "targets")
+		})
+	}
+}
+
+func TestReadHints(t *testing.T) {
+	tt := []struct {
+		name  string
+		input string
+		hints []Hint
+		err   string
+	}{
+		{
+			name:  "valid",
+			input: `{"hints": [{"ike_sa_name": "partner1", "child_sa_name": "net-1", "name": "ssh", "host": "1", "port": 22, "options": "interval=5s"}]}`,
+			hints: []Hint{
+				{IKESAName: "partner1", ChildSAName: "net-1", Name: "ssh", Host: "1", Port: 22, Options: "interval=5s"},
+			},
+		},
+		{
+			name:  "missing host",
+			input: `{"hints": [{"port": 22}]}`,
+			err:   "hint 0: host is required",
+		},
+		{
+			name:  "invalid host",
+			input: `{"hints": [{"host": "gateway", "port": 22}]}`,
+			err:   "hint 0: host 'gateway' is neither an IP address nor a host number",
+		},
+		{
+			name:  "invalid port",
+			input: `{"hints": [{"host": "1", "port": 0}]}`,
+			err:   "hint 0: port 0 is out of range",
+		},
+		{
+			name:  "invalid options",
+			input: `{"hints": [{"host": "1", "port": 22, "options": "interval=-1s"}]}`,
+			err:   "hint 0: interval must be positive",
+		},
+		{
+			name:  "unknown field",
+			input: `{"hints": [{"host": "1", "port": 22, "address": "10.0.0.1"}]}`,
+			err:   `decode hints: json: unknown field "address"`,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			hints, err := ReadHints(strings.NewReader(tc.input))
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err, "error")
+				return
+			}
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tc.hints, hints, "hints")
+		})
+	}
+}
+
+func TestTargets_options(t *testing.T) {
+	targets := Targets(strongswan.IKESAStatus{
+		Name: "partner1",
+		ChildSA: []strongswan.ChildSAStatus{
+			{
+				Name: "net-1",
+				Configuration: vici.ChildSAConf{
+					RemoteTrafficSelectors: []string{"10.2.0.0/16"},
+				},
+			},
+		},
+	}, []Hint{
+		{Host: "1", Port: 22, Options: "interval=5s&bind=10.1.0.1"},
+	})
+	if !assert.Len(t, targets, 1, "targets") {
+		return
+	}
+	assert.Equal(t, 5*time.Second, targets[0].Interval, "interval")
+	assert.Equal(t, "10.1.0.1", targets[0].BindAddress, "bind address")
+}
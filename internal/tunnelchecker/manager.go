@@ -0,0 +1,109 @@
+package tunnelchecker
+
+import (
+	"sync"
+
+	"github.com/lunarway/strong-duckling/internal/strongswan"
+	"github.com/prometheus/common/log"
+)
+
+var _ strongswan.IKESAStatusReceiver = &Manager{}
+var _ strongswan.CollectionDoneReceiver = &Manager{}
+
+// StartFunc starts checking target. The returned function stops the checks
+// and must block until they are stopped.
+type StartFunc func(target Target) (stop func())
+
+// Manager is a strongswan.IKESAStatusReceiver that keeps the checks derived
+// from hints in sync with the configured child SAs. Checks are started when
+// their child SA is configured and stopped when it is removed or its traffic
+// selectors change. Checks of IKE SAs that are no longer reported are stopped
+// when a collection is done.
+type Manager struct {
+	logger log.Logger
+	hints  []Hint
+	start  StartFunc
+
+	mu      sync.Mutex
+	stopped bool
+	// running holds the stop functions of the running checks by IKE SA name and
+	// target key.
+	running map[string]map[string]func()
+	// seen holds the IKE SA names received in the current collection.
+	seen map[string]struct{}
+}
+
+func NewManager(logger log.Logger, hints []Hint, start StartFunc) *Manager {
+	return &Manager{
+		logger:  logger,
+		hints:   hints,
+		start:   start,
+		running: make(map[string]map[string]func()),
+		seen:    make(map[string]struct{}),
+	}
+}
+
+func (m *Manager) IKESAStatus(ikeSAStatus strongswan.IKESAStatus) {
+	targets := Targets(ikeSAStatus, m.hints)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stopped {
+		return
+	}
+	m.seen[ikeSAStatus.Name] = struct{}{}
+	running := m.running[ikeSAStatus.Name]
+	if running == nil {
+		running = make(map[string]func())
+		m.running[ikeSAStatus.Name] = running
+	}
+	desired := make(map[string]struct{}, len(targets))
+	for _, target := range targets {
+		key := target.key()
+		desired[key] = struct{}{}
+		if _, ok := running[key]; ok {
+			continue
+		}
+		m.logger.Debugf("Starting derived check %s of child SA %s of IKE SA %s", target.Name, target.ChildSAName, target.IKESAName)
+		running[key] = m.start(target)
+	}
+	for key, stop := range running {
+		if _, ok := desired[key]; ok {
+			continue
+		}
+		m.logger.Debugf("Stopping derived check %s of IKE SA %s", key, ikeSAStatus.Name)
+		stop()
+		delete(running, key)
+	}
+}
+
+// CollectionDone stops the checks of IKE SAs that were not received in the
+// collection.
+func (m *Manager) CollectionDone() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for ikeSAName, running := range m.running {
+		if _, ok := m.seen[ikeSAName]; ok {
+			continue
+		}
+		for key, stop := range running {
+			m.logger.Debugf("Stopping derived check %s of gone IKE SA %s", key, ikeSAName)
+			stop()
+		}
+		delete(m.running, ikeSAName)
+	}
+	m.seen = make(map[string]struct{})
+}
+
+// Stop stops all running checks. Statuses received after Stop are ignored.
+func (m *Manager) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stopped = true
+	for ikeSAName, running := range m.running {
+		for _, stop := range running {
+			stop()
+		}
+		delete(m.running, ikeSAName)
+	}
+}
@@ -0,0 +1,81 @@
+package tunnelchecker
+
+import (
+	"testing"
+
+	"github.com/lunarway/strong-duckling/internal/strongswan"
+	"github.com/lunarway/strong-duckling/internal/test"
+	"github.com/lunarway/strong-duckling/internal/vici"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManager(t *testing.T) {
+	running := make(map[string]bool)
+	m := NewManager(test.NewLogger(t), []Hint{{Host: "1", Port: 22}}, func(target Target) func() {
+		running[target.Address] = true
+		return func() {
+			running[target.Address] = false
+		}
+	})
+	status := func(remoteTrafficSelectors ...string) strongswan.IKESAStatus {
+		return strongswan.IKESAStatus{
+			Name: "partner1",
+			ChildSA: []strongswan.ChildSAStatus{
+				{
+					Name: "net-1",
+					Configuration: vici.ChildSAConf{
+						RemoteTrafficSelectors: remoteTrafficSelectors,
+					},
+				},
+			},
+		}
+	}
+
+	m.IKESAStatus(status("10.2.0.0/16"))
+	assert.Equal(t, map[string]bool{"10.2.0.1": true}, running, "initial checks")
+
+	m.IKESAStatus(status("10.2.0.0/16"))
+	assert.Equal(t, map[string]bool{"10.2.0.1": true}, running, "unchanged checks")
+
+	m.IKESAStatus(status("10.3.0.0/16"))
+	assert.Equal(t, map[string]bool{"10.2.0.1": false, "10.3.0.1": true}, running, "changed traffic selectors")
+
+	m.Stop()
+	assert.Equal(t, map[string]bool{"10.2.0.1": false, "10.3.0.1": false}, running, "stopped checks")
+
+	m.IKESAStatus(status("10.4.0.0/16"))
+	assert.NotContains(t, running, "10.4.0.1", "checks started after stop")
+}
+
+func TestManager_gone(t *testing.T) {
+	running := make(map[string]bool)
+	m := NewManager(test.NewLogger(t), []Hint{{Host: "1", Port: 22}}, func(target Target) func() {
+		running[target.IKESAName] = true
+		return func() {
+			running[target.IKESAName] = false
+		}
+	})
+	status := func(name string) strongswan.IKESAStatus {
+		return strongswan.IKESAStatus{
+			Name: name,
+			ChildSA: []strongswan.ChildSAStatus{
+				{
+					Name: "net-1",
+					Configuration: vici.ChildSAConf{
+						RemoteTrafficSelectors: []string{"10.2.0.0/16"},
+					},
+				},
+			},
+		}
+	}
+
+	m.IKESAStatus(status("partner1"))
+	m.IKESAStatus(status("partner2"))
+	m.CollectionDone()
+	assert.Equal(t, map[string]bool{"partner1": true, "partner2": true}, running, "initial checks")
+
+	// partner2 is unloaded
+	m.IKESAStatus(status("partner1"))
+	m.CollectionDone()
+	assert.Equal(t, map[string]bool{"partner1": true, "partner2": false}, running, "checks of gone IKE SA not stopped")
+}
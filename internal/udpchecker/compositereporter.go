@@ -0,0 +1,17 @@
+package udpchecker
+
+type compositeReporter struct {
+	reporters []Reporter
+}
+
+func (r compositeReporter) ReportUDPCheck(report Report) {
+	for _, reporter := range r.reporters {
+		reporter.ReportUDPCheck(report)
+	}
+}
+
+func CompositeReporter(reporters ...Reporter) Reporter {
+	return &compositeReporter{
+		reporters: reporters,
+	}
+}
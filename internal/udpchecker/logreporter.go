@@ -0,0 +1,51 @@
+package udpchecker
+
+import (
+	"time"
+
+	"github.com/prometheus/common/log"
+)
+
+type logReporter struct {
+	lastReport    time.Time
+	lastReachable bool
+	Logger        log.Logger
+}
+
+func (r *logReporter) ReportUDPCheck(report Report) {
+	l := r.Logger.With("report", report)
+	switch {
+	case report.Reachable && r.lastReachable:
+		// Peer still replies - great
+	case !report.Reachable && (r.lastReachable || r.lastReport == time.Time{}):
+		// Peer stopped replying
+		r.lastReport = time.Now()
+		r.lastReachable = report.Reachable
+		l.
+			With("status", "unreachable").
+			With("reason", report.Failure).
+			Infof("UDP peer %s is unreachable", report.Name)
+	case report.Reachable && !r.lastReachable:
+		// Peer started replying
+		r.lastReport = time.Now()
+		r.lastReachable = report.Reachable
+		l.
+			With("status", "reachable").
+			Infof("UDP peer %s is reachable", report.Name)
+	case !report.Reachable && !r.lastReachable:
+		// Peer still not replying
+		if time.Since(r.lastReport) > 5*time.Minute {
+			r.lastReport = time.Now()
+			l.
+				With("status", "unreachable").
+				With("reason", report.Failure).
+				Infof("UDP peer %s is still unreachable", report.Name)
+		}
+	default:
+		panic("This should never happen in LogReporter.ReportUDPCheck")
+	}
+}
+
+func LogReporter(logger log.Logger) Reporter {
+	return &logReporter{Logger: logger}
+}
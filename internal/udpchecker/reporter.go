@@ -0,0 +1,47 @@
+package udpchecker
+
+import (
+	"time"
+)
+
+type Reporter interface {
+	ReportUDPCheck(report Report)
+}
+
+type Report struct {
+	Name    string
+	Address string
+	Port    int
+	// Reachable is true if a reply matching the expectation of the target was
+	// received in time.
+	Reachable bool
+	Content   string
+	Status    string
+	Error     error
+	// Failure is the reason the check failed. It is empty if Reachable is true.
+	Failure FailureReason
+	// RoundTripTime is the time from the payload was sent until the reply was
+	// received. It is 0 if no reply was received.
+	RoundTripTime time.Duration
+}
+
+// FailureReason classifies why a check failed.
+type FailureReason string
+
+const (
+	// FailureRefused is reported when the peer responds with an ICMP port
+	// unreachable message, ie. nothing listens on the port.
+	FailureRefused FailureReason = "refused"
+	// FailureTimeout is reported when no reply is received in time.
+	FailureTimeout FailureReason = "timeout"
+	// FailureUnreachable is reported when there is no route to the host or
+	// network.
+	FailureUnreachable FailureReason = "unreachable"
+	// FailureDNS is reported when the address cannot be resolved.
+	FailureDNS FailureReason = "dns"
+	// FailureUnexpectedResponse is reported when a reply is received but it
+	// does not match the expectation.
+	FailureUnexpectedResponse FailureReason = "unexpected_response"
+	// FailureUnknown is reported for all other errors.
+	FailureUnknown FailureReason = "unknown"
+)
@@ -0,0 +1,120 @@
+package udpchecker
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+const (
+	// DefaultInterval is the default interval between checks of a target.
+	DefaultInterval = 5 * time.Second
+	// DefaultTimeout is the default time to wait for a reply.
+	DefaultTimeout = 1 * time.Second
+)
+
+// Target is a UDP address to send a payload to along with the expectation of
+// the reply.
+type Target struct {
+	Name    string
+	Address string
+	Port    int
+	// Send is the payload sent to the target. Most services only reply to
+	// valid requests so it is usually a protocol specific request.
+	Send string
+	// Expect is the pattern the reply is expected to match. If nil any reply
+	// is accepted.
+	Expect *regexp.Regexp
+	// Interval is the interval between checks.
+	Interval time.Duration
+	// Timeout is the time to wait for a reply.
+	Timeout time.Duration
+}
+
+func (t *Target) setDefaults() {
+	if t.Name == "" {
+		t.Name = net.JoinHostPort(t.Address, strconv.Itoa(t.Port))
+	}
+	if t.Interval == 0 {
+		t.Interval = DefaultInterval
+	}
+	if t.Timeout == 0 {
+		t.Timeout = DefaultTimeout
+	}
+}
+
+// ParseTarget parses a target of the form udp://[<name>@]<address>:<port>.
+// IPv6 addresses must be enclosed in square brackets. Options can be set as
+// URL query parameters, eg. udp://dns@10.0.0.53:53?send=...&timeout=2s.
+//
+// Supported options are interval, timeout, send and expect.
+func ParseTarget(s string) (Target, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return Target{}, fmt.Errorf("could not parse url: %w", err)
+	}
+	if u.Scheme != "udp" {
+		return Target{}, fmt.Errorf("url scheme must be udp")
+	}
+	if u.Path != "" || u.Fragment != "" {
+		return Target{}, fmt.Errorf("unexpected path in %s", s)
+	}
+	address, portStr, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		return Target{}, fmt.Errorf("could not understand udp-checker %s", s)
+	}
+	if address == "" {
+		return Target{}, fmt.Errorf("address is required")
+	}
+	port, err := strconv.ParseInt(portStr, 10, 32)
+	if err != nil {
+		return Target{}, fmt.Errorf("could not parse port %s as integer: %w", portStr, err)
+	}
+	if port <= 0 || port > 65535 {
+		return Target{}, fmt.Errorf("port %d is out of range", port)
+	}
+	t := Target{
+		Address: address,
+		Port:    int(port),
+	}
+	if u.User != nil {
+		t.Name = u.User.Username()
+	}
+	for key, values := range u.Query() {
+		value := values[len(values)-1]
+		switch key {
+		case "interval":
+			t.Interval, err = parsePositiveDuration(key, value)
+		case "timeout":
+			t.Timeout, err = parsePositiveDuration(key, value)
+		case "send":
+			t.Send = value
+		case "expect":
+			t.Expect, err = regexp.Compile(value)
+			if err != nil {
+				err = fmt.Errorf("could not parse expect: %w", err)
+			}
+		default:
+			err = fmt.Errorf("unknown option '%s'", key)
+		}
+		if err != nil {
+			return Target{}, err
+		}
+	}
+	t.setDefaults()
+	return t, nil
+}
+
+func parsePositiveDuration(key, value string) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("could not parse %s: %w", key, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("%s must be positive", key)
+	}
+	return d, nil
+}
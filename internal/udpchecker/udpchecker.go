@@ -0,0 +1,94 @@
+package udpchecker
+
+import (
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// maxReplySize is the maximum size of a reply matched against the
+// expectation.
+const maxReplySize = 65535
+
+// Check sends the payload of target and waits for a reply. The result is
+// reported to reporter. Options that are not set on target use their
+// defaults.
+//
+// The socket is connected so ICMP port unreachable messages from the peer are
+// reported as FailureRefused.
+func Check(target Target, reporter Reporter) {
+	target.setDefaults()
+	report := Report{
+		Name:    target.Name,
+		Address: target.Address,
+		Port:    target.Port,
+	}
+	conn, err := net.DialTimeout("udp", net.JoinHostPort(target.Address, strconv.Itoa(target.Port)), target.Timeout)
+	if err != nil {
+		report.Status = "Dial error"
+		report.Error = err
+		report.Failure = classifyError(err)
+		reporter.ReportUDPCheck(report)
+		return
+	}
+	defer conn.Close()
+
+	err = conn.SetDeadline(time.Now().Add(target.Timeout))
+	if err != nil {
+		report.Status = "Set deadline error"
+		report.Error = err
+		report.Failure = classifyError(err)
+		reporter.ReportUDPCheck(report)
+		return
+	}
+	start := time.Now()
+	_, err = io.WriteString(conn, target.Send)
+	if err != nil {
+		report.Status = "Send error"
+		report.Error = err
+		report.Failure = classifyError(err)
+		reporter.ReportUDPCheck(report)
+		return
+	}
+	buf := make([]byte, maxReplySize)
+	n, err := conn.Read(buf)
+	if err != nil {
+		report.Status = "Receive error"
+		report.Error = err
+		report.Failure = classifyError(err)
+		reporter.ReportUDPCheck(report)
+		return
+	}
+	report.RoundTripTime = time.Since(start)
+	report.Content = string(buf[:n])
+	if target.Expect != nil && !target.Expect.MatchString(report.Content) {
+		report.Status = "Unexpected response"
+		report.Failure = FailureUnexpectedResponse
+		reporter.ReportUDPCheck(report)
+		return
+	}
+	report.Status = "Reachable"
+	report.Reachable = true
+	reporter.ReportUDPCheck(report)
+}
+
+// classifyError returns the FailureReason of err.
+func classifyError(err error) FailureReason {
+	var dnsError *net.DNSError
+	var netError net.Error
+	switch {
+	case errors.As(err, &dnsError):
+		return FailureDNS
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return FailureRefused
+	case errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH), errors.Is(err, syscall.EHOSTDOWN), errors.Is(err, syscall.ENETDOWN):
+		return FailureUnreachable
+	case errors.As(err, &netError) && netError.Timeout():
+		return FailureTimeout
+	default:
+		return FailureUnknown
+	}
+}
@@ -0,0 +1,158 @@
+package udpchecker
+
+import (
+	"net"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type reportRecorder struct {
+	reports []Report
+}
+
+func (r *reportRecorder) ReportUDPCheck(report Report) {
+	r.reports = append(r.reports, report)
+}
+
+func TestCheck(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen on udp: %v", err)
+	}
+	defer conn.Close()
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			// echo pings and ignore everything else
+			if string(buf[:n]) == "ping" {
+				conn.WriteTo([]byte("pong"), addr)
+			}
+		}
+	}()
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+
+	// reserve a port and close it again to get one nothing listens on
+	closed, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen on udp: %v", err)
+	}
+	closedPort := closed.LocalAddr().(*net.UDPAddr).Port
+	closed.Close()
+
+	tt := []struct {
+		name      string
+		target    Target
+		reachable bool
+		failure   FailureReason
+	}{
+		{
+			name:      "reply",
+			target:    Target{Address: "127.0.0.1", Port: port, Send: "ping"},
+			reachable: true,
+		},
+		{
+			name:      "expected reply",
+			target:    Target{Address: "127.0.0.1", Port: port, Send: "ping", Expect: regexp.MustCompile("^pong$")},
+			reachable: true,
+		},
+		{
+			name:    "unexpected reply",
+			target:  Target{Address: "127.0.0.1", Port: port, Send: "ping", Expect: regexp.MustCompile("^PONG$")},
+			failure: FailureUnexpectedResponse,
+		},
+		{
+			name:    "no reply",
+			target:  Target{Address: "127.0.0.1", Port: port, Send: "hello", Timeout: 50 * time.Millisecond},
+			failure: FailureTimeout,
+		},
+		{
+			name:    "port unreachable",
+			target:  Target{Address: "127.0.0.1", Port: closedPort, Send: "ping", Timeout: time.Second},
+			failure: FailureRefused,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := &reportRecorder{}
+			Check(tc.target, recorder)
+
+			if !assert.Len(t, recorder.reports, 1, "number of reports not as expected") {
+				return
+			}
+			report := recorder.reports[0]
+			assert.Equal(t, tc.reachable, report.Reachable, "reachable not as expected")
+			assert.Equal(t, tc.failure, report.Failure, "failure reason not as expected")
+			if tc.reachable {
+				assert.NotZero(t, report.RoundTripTime, "round trip time not set")
+				assert.Equal(t, "pong", report.Content, "content not as expected")
+			}
+		})
+	}
+}
+
+func TestParseTarget(t *testing.T) {
+	tt := []struct {
+		name   string
+		input  string
+		target Target
+		err    string
+	}{
+		{
+			name:  "address and port",
+			input: "udp://10.0.0.53:53",
+			target: Target{
+				Name:     "10.0.0.53:53",
+				Address:  "10.0.0.53",
+				Port:     53,
+				Interval: DefaultInterval,
+				Timeout:  DefaultTimeout,
+			},
+		},
+		{
+			name:  "name, ipv6 and options",
+			input: "udp://radius@[fd00::1]:1812?interval=10s&timeout=2s&send=ping%0A&expect=^pong",
+			target: Target{
+				Name:     "radius",
+				Address:  "fd00::1",
+				Port:     1812,
+				Send:     "ping\n",
+				Expect:   regexp.MustCompile("^pong"),
+				Interval: 10 * time.Second,
+				Timeout:  2 * time.Second,
+			},
+		},
+		{
+			name:  "tcp scheme",
+			input: "tcp://10.0.0.1:53",
+			err:   "url scheme must be udp",
+		},
+		{
+			name:  "missing port",
+			input: "udp://10.0.0.1",
+			err:   "could not understand udp-checker udp://10.0.0.1",
+		},
+		{
+			name:  "unknown option",
+			input: "udp://10.0.0.1:53?retries=1",
+			err:   "unknown option 'retries'",
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			target, err := ParseTarget(tc.input)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err, "error not as expected")
+				return
+			}
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tc.target, target, "target not as expected")
+		})
+	}
+}
@@ -5,6 +5,60 @@ import (
 	"strconv"
 )
 
+// IKE SA states as reported by charon.
+const (
+	IKESAStateCreated     = "CREATED"
+	IKESAStateConnecting  = "CONNECTING"
+	IKESAStateEstablished = "ESTABLISHED"
+	IKESAStatePassive     = "PASSIVE"
+	IKESAStateRekeying    = "REKEYING"
+	IKESAStateRekeyed     = "REKEYED"
+	IKESAStateDeleting    = "DELETING"
+	IKESAStateDestroying  = "DESTROYING"
+)
+
+// IKESAStates lists all possible IKE SA states.
+var IKESAStates = []string{
+	IKESAStateCreated,
+	IKESAStateConnecting,
+	IKESAStateEstablished,
+	IKESAStatePassive,
+	IKESAStateRekeying,
+	IKESAStateRekeyed,
+	IKESAStateDeleting,
+	IKESAStateDestroying,
+}
+
+// Child SA states as reported by charon.
+const (
+	ChildSAStateCreated    = "CREATED"
+	ChildSAStateRouted     = "ROUTED"
+	ChildSAStateInstalling = "INSTALLING"
+	ChildSAStateInstalled  = "INSTALLED"
+	ChildSAStateUpdating   = "UPDATING"
+	ChildSAStateRekeying   = "REKEYING"
+	ChildSAStateRekeyed    = "REKEYED"
+	ChildSAStateRetrying   = "RETRYING"
+	ChildSAStateDeleting   = "DELETING"
+	ChildSAStateDeleted    = "DELETED"
+	ChildSAStateDestroying = "DESTROYING"
+)
+
+// ChildSAStates lists all possible child SA states.
+var ChildSAStates = []string{
+	ChildSAStateCreated,
+	ChildSAStateRouted,
+	ChildSAStateInstalling,
+	ChildSAStateInstalled,
+	ChildSAStateUpdating,
+	ChildSAStateRekeying,
+	ChildSAStateRekeyed,
+	ChildSAStateRetrying,
+	ChildSAStateDeleting,
+	ChildSAStateDeleted,
+	ChildSAStateDestroying,
+}
+
 // IkeSa is an IKE Security Associasion from a list-sa event.
 type IkeSa struct {
 	UniqueID   string `json:"uniqueid"` //called ike_id in terminate() argument.
@@ -21,6 +75,14 @@ type IkeSa struct {
 	RemoteEAPID   string `json:"remote-eap-id"`
 	// Initiator indicates if this SA is the initiator.
 	Initiator string `json:"initiator"`
+	// NATLocal is "yes" if the local host is behind NAT.
+	NATLocal string `json:"nat-local"`
+	// NATRemote is "yes" if the remote host is behind NAT.
+	NATRemote string `json:"nat-remote"`
+	// NATFake is "yes" if NAT situation has been faked as responder.
+	NATFake string `json:"nat-fake"`
+	// NATAny is "yes" if any of the peers is behind NAT.
+	NATAny string `json:"nat-any"`
 	// InitiatorSPI contains a hex encoded initiator SPI / cookie
 	InitiatorSPI string `json:"initiator-spi"`
 	// ResponderSPI contains a hex encoded responder SPI / cookie
@@ -134,8 +196,25 @@ func (s *ChildSA) GetBytesOut() uint64 {
 // To be simple, list all clients that are connecting to this server .
 // A client is a sa.
 // Lists currently active IKE_SAs
-func (c *ClientConn) ListSas(ike string, ike_id string) (sas map[string]IkeSa, err error) {
-	sas = map[string]IkeSa{}
+//
+// Only the last IKE SA of each connection is returned. Use ListAllSas to get
+// all IKE SAs of connections with multiple SAs, eg. roadwarrior connections.
+func (c *ClientConn) ListSas(ike string, ike_id string) (map[string]IkeSa, error) {
+	allSas, err := c.ListAllSas(ike, ike_id)
+	if err != nil {
+		return nil, err
+	}
+	sas := map[string]IkeSa{}
+	for ikeName, ikeSAs := range allSas {
+		sas[ikeName] = ikeSAs[len(ikeSAs)-1]
+	}
+	return sas, nil
+}
+
+// ListAllSas lists currently active IKE_SAs grouped by their connection name in
+// the order they are reported by charon.
+func (c *ClientConn) ListAllSas(ike string, ike_id string) (sas map[string][]IkeSa, err error) {
+	sas = map[string][]IkeSa{}
 	var eventErr error
 	//register event
 	err = c.RegisterEvent("list-sa", func(response map[string]interface{}) {
@@ -146,7 +225,7 @@ func (c *ClientConn) ListSas(ike string, ike_id string) (sas map[string]IkeSa, e
 			return
 		}
 		for ikeName, ikeSA := range sa {
-			sas[ikeName] = ikeSA
+			sas[ikeName] = append(sas[ikeName], ikeSA)
 		}
 	})
 	if err != nil {
@@ -164,10 +243,6 @@ func (c *ClientConn) ListSas(ike string, ike_id string) (sas map[string]IkeSa, e
 		}
 	}()
 
-	if eventErr != nil {
-		return nil, eventErr
-	}
-
 	inMap := map[string]interface{}{}
 	if ike != "" {
 		inMap["ike"] = ike
@@ -179,5 +254,8 @@ func (c *ClientConn) ListSas(ike string, ike_id string) (sas map[string]IkeSa, e
 	if err != nil {
 		return nil, err
 	}
+	if eventErr != nil {
+		return nil, eventErr
+	}
 	return sas, nil
 }
@@ -0,0 +1,28 @@
+package vici
+
+import (
+	"fmt"
+)
+
+type RekeyRequest struct {
+	Child    string `json:"child,omitempty"`
+	Ike      string `json:"ike,omitempty"`
+	Child_id string `json:"child-id,omitempty"`
+	Ike_id   string `json:"ike-id,omitempty"`
+	// Reauth reauthenticates an IKE SA instead of rekeying it. Set to "yes" to
+	// enable.
+	Reauth string `json:"reauth,omitempty"`
+}
+
+// Rekey initiates the rekeying of an SA. This is the equivalent of
+// `swanctl --rekey`.
+func (c *ClientConn) Rekey(r *RekeyRequest) error {
+	msg, err := c.Request("rekey", r)
+	if err != nil {
+		return err
+	}
+	if msg["success"] != "yes" {
+		return fmt.Errorf("rekey unsuccessful: %v", msg["errmsg"])
+	}
+	return nil
+}
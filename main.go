@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"os"
@@ -13,13 +14,21 @@ import (
 
 	"github.com/lunarway/strong-duckling/internal/daemon"
 	"github.com/lunarway/strong-duckling/internal/http"
+	"github.com/lunarway/strong-duckling/internal/httpchecker"
+	"github.com/lunarway/strong-duckling/internal/icmpchecker"
+	"github.com/lunarway/strong-duckling/internal/keepalive"
 	"github.com/lunarway/strong-duckling/internal/metrics"
+	"github.com/lunarway/strong-duckling/internal/otlp"
+	"github.com/lunarway/strong-duckling/internal/statsd"
 	"github.com/lunarway/strong-duckling/internal/strongswan"
 	"github.com/lunarway/strong-duckling/internal/tcpchecker"
+	"github.com/lunarway/strong-duckling/internal/tunnelchecker"
+	"github.com/lunarway/strong-duckling/internal/udpchecker"
 	"github.com/lunarway/strong-duckling/internal/vici"
 	"github.com/lunarway/strong-duckling/internal/whooping"
-	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/log"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 	kingpin "gopkg.in/alecthomas/kingpin.v2"
 )
 
@@ -35,8 +44,50 @@ func main() {
 	flags := kingpin.New("strong-duckling", "A small sidekick to strongswan VPN")
 	listenAddress := flags.Flag("listen", "Address on which to expose metrics.").String()
 	whoopingAddress := flags.Flag("whooping", "Address on which to start whooping.").String()
-	tcpCheckerAddresses := flags.Flag("tcp-checker", "TCP address to check. Supports <address>:<port> or <name>:<address>:<port>").Strings()
+	tcpCheckerAddresses := flags.Flag("tcp-checker", "TCP address to check. Supports <address>:<port>, <name>:<address>:<port> or tcp://<name>@<address>:<port> optionally followed by options, e.g. ?interval=5s&timeout=2s. IPv6 addresses must be enclosed in brackets").Strings()
+	tcpCheckerConfig := flags.Flag("tcp-checker-config", "JSON file with tcp-checker targets and their options").String()
+	httpCheckerAddresses := flags.Flag("http-checker", "HTTP or HTTPS URL to check with a GET request expecting a 2xx status code. Supports <url> or <name>=<url>").Strings()
+	httpCheckerConfig := flags.Flag("http-checker-config", "JSON file with http-checker targets and their expectations").String()
+	udpCheckerAddresses := flags.Flag("udp-checker", "UDP address to send a payload to expecting a reply. Supports udp://<name>@<address>:<port>?send=<payload>&expect=<regexp>").Strings()
+	icmpCheckerAddresses := flags.Flag("icmp-checker", "Host to send ICMP echo requests to with unprivileged ping sockets. Supports icmp://<name>@<address>?interval=<duration>&window=<probes>").Strings()
+	tcpCheckerHealAction := flags.Flag("tcp-checker-heal-action", "Action taken on the child SA linked to a tcp-checker target with ike_sa_name and child_sa_name after consecutive failed checks while it is installed").Enum(string(strongswan.HealActionRekey), string(strongswan.HealActionReinitiate))
+	tcpCheckerHealAfter := flags.Flag("tcp-checker-heal-after", "Number of consecutive failed checks before the linked child SA is healed").Default(strconv.Itoa(strongswan.DefaultHealThreshold)).Int()
+	tcpCheckerHealCooldown := flags.Flag("tcp-checker-heal-cooldown", "Minimum time between actions on the same child SA").Default(strongswan.DefaultHealCooldown.String()).Duration()
+	tunnelCheckerConfig := flags.Flag("tunnel-checker-config", "JSON file with host and port hints from which tcp checks are derived for the remote traffic selectors of configured child SAs").String()
+	tcpCheckerFlapWindow := flags.Flag("tcp-checker-flap-window", "Sliding window over which the flap rate of tcp-checker targets is calculated").Default(metrics.DefaultFlapWindow.String()).Duration()
 	enableReinitiator := flags.Flag("enable-reinitiator", "Enables re-initiation of connections when expected Security Associations are missing").Bool()
+	reinitiatorWorkers := flags.Flag("reinitiator-workers", "Maximum number of IKE SAs initiated in parallel by the reinitiator. Each worker uses its own VICI connection").Default("4").Int()
+	reinitiatorHistorySize := flags.Flag("reinitiator-history-size", "Number of latest initiations served as JSON on /reinitiator/history").Default(strconv.Itoa(strongswan.DefaultHistorySize)).Int()
+	reinitiatorBackoffInitialDelay := flags.Flag("reinitiator-backoff-initial-delay", "Delay before initiating a child SA again after its first failed initiation").Default(strongswan.DefaultBackoffInitialDelay.String()).Duration()
+	reinitiatorBackoffMaxDelay := flags.Flag("reinitiator-backoff-max-delay", "Maximum delay between initiations of a child SA").Default(strongswan.DefaultBackoffMaxDelay.String()).Duration()
+	reinitiatorBackoffMultiplier := flags.Flag("reinitiator-backoff-multiplier", "Factor the delay is multiplied by after each failed initiation of a child SA").Default(strconv.FormatFloat(strongswan.DefaultBackoffMultiplier, 'g', -1, 64)).Float64()
+	reinitiatorBackoffJitter := flags.Flag("reinitiator-backoff-jitter", "Fraction of the delay between 0 and 1 that is randomly subtracted from it").Default(strconv.FormatFloat(strongswan.DefaultBackoffJitter, 'g', -1, 64)).Float64()
+	reinitiatorMaxAttempts := flags.Flag("reinitiator-max-attempts", "Number of consecutive failed initiations of a child SA after which initiation is given up until it is established by other means. 0 never gives up").Default("0").Int()
+	reinitiatorPolicies := flags.Flag("reinitiator-policy", "Reinitiation policy of child SAs matching name patterns. Supports <ike sa pattern>[/<child sa pattern>]=<action> with the actions "+strings.Join(reinitiationActions(), ", ")+" and can be repeated. The first matching policy applies").Strings()
+	reinitiatorPolicyConfig := flags.Flag("reinitiator-policy-config", "JSON file with reinitiation policies and their backoff. Policies given with --reinitiator-policy are matched first").String()
+	terminateDuplicates := flags.Flag("terminate-duplicate-child-sas", "Terminates installed child SAs with the same name and traffic selectors as a newer child SA, e.g. after rekey collisions").Bool()
+	duplicatesGracePeriod := flags.Flag("duplicate-child-sa-grace-period", "Time a stale duplicate child SA is left for charon to remove before it is terminated").Default(strongswan.DefaultDuplicateGracePeriod.String()).Duration()
+	terminateStuckIKESAs := flags.Flag("terminate-stuck-ike-sas", "Terminates IKE SAs that stay in non-final states such as CONNECTING so initiations can proceed").Bool()
+	stuckIKESAThreshold := flags.Flag("stuck-ike-sa-threshold", "Time an IKE SA may stay in non-final states before it is terminated").Default(strongswan.DefaultStuckThreshold.String()).Duration()
+	stuckIKESATerminateTimeout := flags.Flag("stuck-ike-sa-terminate-timeout", "Time charon waits for a graceful termination of a stuck IKE SA before destroying it").Default(strongswan.DefaultStuckTerminateTimeout.String()).Duration()
+	terminateOrphanedIKESAs := flags.Flag("terminate-orphaned-ike-sas", "Terminates IKE SAs without a configuration, e.g. after unload-conn or a configuration reload, so removed partners do not keep live tunnels").Bool()
+	orphanedIKESAGracePeriod := flags.Flag("orphaned-ike-sa-grace-period", "Time an IKE SA without a configuration is kept before it is terminated").Default(strongswan.DefaultOrphanGracePeriod.String()).Duration()
+	idleThresholds := flags.Flag("idle-threshold", "Silence thresholds of child SAs matching name patterns after which they are idle. Supports <ike sa pattern>[/<child sa pattern>]?in=<duration>&out=<duration> and can be repeated. The first matching threshold applies").Strings()
+	keepaliveRules := flags.Flag("keepalive", "Sends keepalive probes from the local into the remote traffic selector of child SAs matching name patterns whose use-out exceeds a threshold. Supports <ike sa pattern>[/<child sa pattern>]?threshold=<duration>&protocol=<udp|tcp>&host=<host>&port=<port>&timeout=<duration> and can be repeated. The first matching rule applies").Strings()
+	keepaliveInterval := flags.Flag("keepalive-interval", "Interval between checks of the use-out of child SAs kept alive").Default(keepalive.DefaultInterval.String()).Duration()
+	dryRun := flags.Flag("dry-run", "Log and count the actions of the reinitiator, healer, duplicate terminator, stuck IKE SA watchdog and orphan terminator with their reason and the triggering IKE SA status without sending them to charon").Bool()
+	enableRemoteAccessMetrics := flags.Flag("enable-remote-access-metrics", "Enables metrics on individual remote access sessions labeled by their identity").Bool()
+	metricsLabels := flags.Flag("metrics-label", "Label added to all metrics. Supports <name>=<value> and can be repeated").StringMap()
+	enableProcessMetrics := flags.Flag("enable-process-metrics", "Enables metrics on the strong-duckling process such as CPU and memory usage").Bool()
+	enableGoMetrics := flags.Flag("enable-go-metrics", "Enables metrics on the Go runtime of strong-duckling").Bool()
+	statsdAddress := flags.Flag("statsd-address", "UDP address of a StatsD server to push metrics to, e.g. localhost:8125").String()
+	statsdFlushInterval := flags.Flag("statsd-flush-interval", "Interval between pushes of buffered StatsD metrics").Default(statsd.DefaultFlushInterval.String()).Duration()
+	statsdTags := flags.Flag("statsd-tag", "DogStatsD tag added to all StatsD metrics. Supports <name>:<value> and can be repeated").Strings()
+	otlpEndpoint := flags.Flag("otlp-endpoint", "Host and port of an OTLP receiver to export metrics and traces to, e.g. localhost:4317").String()
+	otlpProtocol := flags.Flag("otlp-protocol", "OTLP transport protocol to use").Default(otlp.ProtocolGRPC).Enum(otlp.ProtocolGRPC, otlp.ProtocolHTTP)
+	otlpInsecure := flags.Flag("otlp-insecure", "Disables TLS towards the OTLP receiver").Bool()
+	otlpExportInterval := flags.Flag("otlp-export-interval", "Interval between exports of OTLP metrics").Default(otlp.DefaultExportInterval.String()).Duration()
+	remoteAccessMaxIdentities := flags.Flag("remote-access-max-identities", "Maximum number of identities exposed as labels in remote access metrics. Additional identities are reported as 'other'. 0 disables the limit").Default("100").Int()
 	log.AddFlags(flags)
 	flags.HelpFlag.Short('h')
 	flags.Version(version)
@@ -47,20 +98,142 @@ func main() {
 		log.Errorf("--enable-reinitiator requires --vici-socket to be set up")
 		os.Exit(1)
 	}
+	if *terminateDuplicates && len(*socket) == 0 {
+		log.Errorf("--terminate-duplicate-child-sas requires --vici-socket to be set up")
+		os.Exit(1)
+	}
+	if *terminateStuckIKESAs && len(*socket) == 0 {
+		log.Errorf("--terminate-stuck-ike-sas requires --vici-socket to be set up")
+		os.Exit(1)
+	}
+	if *terminateOrphanedIKESAs && len(*socket) == 0 {
+		log.Errorf("--terminate-orphaned-ike-sas requires --vici-socket to be set up")
+		os.Exit(1)
+	}
+	var idleRules []strongswan.IdleRule
+	for _, threshold := range *idleThresholds {
+		rule, err := strongswan.ParseIdleRule(threshold)
+		if err != nil {
+			log.Errorf("Invalid idle-threshold %s: %v", threshold, err)
+			os.Exit(1)
+		}
+		idleRules = append(idleRules, rule)
+	}
+	if len(idleRules) != 0 && len(*socket) == 0 {
+		log.Errorf("--idle-threshold requires --vici-socket to be set up")
+		os.Exit(1)
+	}
+	var parsedKeepaliveRules []keepalive.Rule
+	for _, rule := range *keepaliveRules {
+		r, err := keepalive.ParseRule(rule)
+		if err != nil {
+			log.Errorf("Invalid keepalive %s: %v", rule, err)
+			os.Exit(1)
+		}
+		parsedKeepaliveRules = append(parsedKeepaliveRules, r)
+	}
+	if len(parsedKeepaliveRules) != 0 && len(*socket) == 0 {
+		log.Errorf("--keepalive requires --vici-socket to be set up")
+		os.Exit(1)
+	}
+	if *reinitiatorHistorySize < 1 {
+		log.Errorf("--reinitiator-history-size must be at least 1")
+		os.Exit(1)
+	}
+	if *reinitiatorWorkers < 1 {
+		log.Errorf("--reinitiator-workers must be at least 1")
+		os.Exit(1)
+	}
+	reinitiatorBackoff := strongswan.BackoffConfiguration{
+		InitialDelay: *reinitiatorBackoffInitialDelay,
+		MaxDelay:     *reinitiatorBackoffMaxDelay,
+		Multiplier:   *reinitiatorBackoffMultiplier,
+		Jitter:       *reinitiatorBackoffJitter,
+		MaxAttempts:  *reinitiatorMaxAttempts,
+	}
+	if err := reinitiatorBackoff.Validate(); err != nil {
+		log.Errorf("Invalid reinitiator backoff: %v", err)
+		os.Exit(1)
+	}
+	var reinitiatorPolicyRules []strongswan.PolicyRule
+	for _, policy := range *reinitiatorPolicies {
+		rule, err := strongswan.ParsePolicyRule(policy, reinitiatorBackoff)
+		if err != nil {
+			log.Errorf("Invalid reinitiator-policy %s: %v", policy, err)
+			os.Exit(1)
+		}
+		reinitiatorPolicyRules = append(reinitiatorPolicyRules, rule)
+	}
+	if *reinitiatorPolicyConfig != "" {
+		rules, err := readReinitiatorPolicies(*reinitiatorPolicyConfig, reinitiatorBackoff)
+		if err != nil {
+			log.Errorf("Could not read reinitiator-policy-config %s: %v", *reinitiatorPolicyConfig, err)
+			os.Exit(1)
+		}
+		reinitiatorPolicyRules = append(reinitiatorPolicyRules, rules...)
+	}
+	if *tcpCheckerHealAction != "" && len(*socket) == 0 {
+		log.Errorf("--tcp-checker-heal-action requires --vici-socket to be set up")
+		os.Exit(1)
+	}
+	if *tunnelCheckerConfig != "" && len(*socket) == 0 {
+		log.Errorf("--tunnel-checker-config requires --vici-socket to be set up")
+		os.Exit(1)
+	}
 
 	whooper := whooping.Whooper{}
 
-	httpServer := http.Define()
-	if *listenAddress != "" {
-		whooper.RegisterListener(httpServer, fmt.Sprintf("http://localhost%s", *listenAddress))
-		metrics.Register(httpServer)
-	}
-	prometheusReporter, err := metrics.NewPrometheusReporter(prometheus.DefaultRegisterer, log.Base().With("name", "prometheusReporter"))
+	prometheusReporter, err := metrics.NewPrometheusReporter(log.Base().With("name", "prometheusReporter"), metrics.Configuration{
+		ConstLabels:               *metricsLabels,
+		ProcessCollector:          *enableProcessMetrics,
+		GoCollector:               *enableGoMetrics,
+		TcpCheckerFlapWindow:      *tcpCheckerFlapWindow,
+		RemoteAccessMaxIdentities: *remoteAccessMaxIdentities,
+	})
 	if err != nil {
 		log.Errorf("Failed to register metrics: %v", err)
 		os.Exit(1)
 	}
 
+	reporters := reporters{
+		prometheus: prometheusReporter,
+	}
+	if *statsdAddress != "" {
+		statsdReporter, err := statsd.New(log.Base().With("name", "statsdReporter"), statsd.Configuration{
+			Address:       *statsdAddress,
+			Tags:          *statsdTags,
+			FlushInterval: *statsdFlushInterval,
+		})
+		if err != nil {
+			log.Errorf("Failed to set up StatsD reporter: %v", err)
+			os.Exit(1)
+		}
+		reporters.statsd = statsdReporter
+	}
+	var tracer trace.Tracer = noop.NewTracerProvider().Tracer("")
+	var otlpExporter *otlp.Exporter
+	if *otlpEndpoint != "" {
+		otlpExporter, err = otlp.New(context.Background(), log.Base().With("name", "otlpReporter"), otlp.Configuration{
+			Endpoint:       *otlpEndpoint,
+			Protocol:       *otlpProtocol,
+			Insecure:       *otlpInsecure,
+			ExportInterval: *otlpExportInterval,
+			ServiceVersion: version,
+		})
+		if err != nil {
+			log.Errorf("Failed to set up OTLP exporter: %v", err)
+			os.Exit(1)
+		}
+		reporters.otlp = otlpExporter.Reporter()
+		tracer = otlpExporter.Tracer("github.com/lunarway/strong-duckling/internal/strongswan")
+	}
+
+	httpServer := http.Define()
+	if *listenAddress != "" {
+		whooper.RegisterListener(httpServer, fmt.Sprintf("http://localhost%s", *listenAddress))
+		httpServer.Handle("/metrics", prometheusReporter.Handler())
+	}
+
 	componentDone := make(chan error)
 	shutdown := make(chan struct{})
 	var shutdownWg sync.WaitGroup
@@ -68,7 +241,7 @@ func main() {
 	if whoopingAddress != nil && *whoopingAddress != "" {
 		logger := log.With("name", "whooper")
 		whoopDaemon := daemon.New(daemon.Configuration{
-			Reporter: prometheusReporter.Daemon(logger, "whopper"),
+			Reporter: reporters.daemon(logger, "whopper"),
 			Interval: 1 * time.Second,
 			Tick: func() {
 				whooper.Whoop(*whoopingAddress, fmt.Sprintf("http://localhost%s", *listenAddress))
@@ -82,39 +255,56 @@ func main() {
 		}()
 	}
 
+	var healer *strongswan.Healer
+	if *tcpCheckerHealAction != "" {
+		healerClient := viciClient(&shutdownWg, shutdown, componentDone, log.With("viciClient", "healer"), *socket)
+		healerClient.ReadTimeout = 5 * time.Minute
+
+		healer = strongswan.NewHealer(healerClient, log.Base().With("name", "healer"), tracer, strongswan.HealerConfiguration{
+			Action:           strongswan.HealAction(*tcpCheckerHealAction),
+			Threshold:        *tcpCheckerHealAfter,
+			Cooldown:         *tcpCheckerHealCooldown,
+			Reporter:         prometheusReporter.Healer(),
+			DryRun:           *dryRun,
+			DecisionReporter: prometheusReporter.DryRun(),
+		})
+	}
+
+	var tcpCheckerTargets []tcpchecker.Target
 	for _, tcpCheckerAddress := range *tcpCheckerAddresses {
-		values := strings.Split(tcpCheckerAddress, ":")
-		var name, address, portStr string
-
-		if len(values) == 3 {
-			name = values[0]
-			address = values[1]
-			portStr = values[2]
-		} else if len(values) == 2 {
-			address = values[0]
-			portStr = values[1]
-			name = fmt.Sprintf("%s:%s", address, portStr)
-		} else {
-			log.Errorf("Could not understand tcp-checker %s", tcpCheckerAddress)
+		target, err := tcpchecker.ParseTarget(tcpCheckerAddress)
+		if err != nil {
+			log.Errorf("Could not parse tcp-checker %s: %v", tcpCheckerAddress, err)
 			os.Exit(1)
 		}
-		port, err := strconv.ParseInt(portStr, 10, 32)
+		tcpCheckerTargets = append(tcpCheckerTargets, target)
+	}
+	if *tcpCheckerConfig != "" {
+		targets, err := readTcpCheckerTargets(*tcpCheckerConfig)
 		if err != nil {
-			log.Errorf("Could not parse port %s as integer in tcp-checker %s. Error: %s", portStr, tcpCheckerAddress, err)
+			log.Errorf("Could not read tcp-checker-config %s: %v", *tcpCheckerConfig, err)
 			os.Exit(1)
 		}
+		tcpCheckerTargets = append(tcpCheckerTargets, targets...)
+	}
 
+	for _, target := range tcpCheckerTargets {
+		target := target
 		logger := log.
 			With("type", "tcpchecker").
-			With("name", name).
-			With("address", address).
-			With("port", port)
-		logger.Infof("Start checking address %s:%v", address, port)
+			With("name", target.Name).
+			With("address", target.Address).
+			With("port", target.Port)
+		logger.Infof("Start checking address %s:%v every %s", target.Address, target.Port, target.Interval)
+		tcpCheckerReporter := reporters.tcpChecker(logger)
+		if healer != nil && target.ChildSAName != "" {
+			tcpCheckerReporter = tcpchecker.CompositeReporter(tcpCheckerReporter, healer.Reporter(target.IKESAName, target.ChildSAName))
+		}
 		tcpCheckerDaemon := daemon.New(daemon.Configuration{
-			Reporter: prometheusReporter.Daemon(logger, "tcpchecker"),
-			Interval: 1 * time.Second,
+			Reporter: reporters.daemon(logger, "tcpchecker"),
+			Interval: target.Interval,
 			Tick: func() {
-				tcpchecker.Check(name, address, int(port), tcpchecker.CompositeReporter(tcpchecker.LogReporter(logger), prometheusReporter.TcpChecker()))
+				tcpchecker.Check(target, tcpCheckerReporter)
 			},
 		})
 
@@ -125,6 +315,103 @@ func main() {
 		}()
 	}
 
+	var httpCheckerTargets []httpchecker.Target
+	for _, httpCheckerAddress := range *httpCheckerAddresses {
+		target, err := httpchecker.ParseTarget(httpCheckerAddress)
+		if err != nil {
+			log.Errorf("Could not parse http-checker %s: %v", httpCheckerAddress, err)
+			os.Exit(1)
+		}
+		httpCheckerTargets = append(httpCheckerTargets, target)
+	}
+	if *httpCheckerConfig != "" {
+		targets, err := readHttpCheckerTargets(*httpCheckerConfig)
+		if err != nil {
+			log.Errorf("Could not read http-checker-config %s: %v", *httpCheckerConfig, err)
+			os.Exit(1)
+		}
+		httpCheckerTargets = append(httpCheckerTargets, targets...)
+	}
+
+	for _, target := range httpCheckerTargets {
+		target := target
+		logger := log.
+			With("type", "httpchecker").
+			With("name", target.Name).
+			With("url", target.URL)
+		logger.Infof("Start checking %s %s every %s", target.Method, target.URL, target.Interval)
+		httpCheckerReporter := reporters.httpChecker(logger)
+		httpCheckerDaemon := daemon.New(daemon.Configuration{
+			Reporter: reporters.daemon(logger, "httpchecker"),
+			Interval: target.Interval,
+			Tick: func() {
+				httpchecker.Check(target, httpCheckerReporter)
+			},
+		})
+
+		shutdownWg.Add(1)
+		go func() {
+			defer shutdownWg.Done()
+			httpCheckerDaemon.Loop(shutdown)
+		}()
+	}
+
+	for _, udpCheckerAddress := range *udpCheckerAddresses {
+		target, err := udpchecker.ParseTarget(udpCheckerAddress)
+		if err != nil {
+			log.Errorf("Could not parse udp-checker %s: %v", udpCheckerAddress, err)
+			os.Exit(1)
+		}
+		logger := log.
+			With("type", "udpchecker").
+			With("name", target.Name).
+			With("address", target.Address).
+			With("port", target.Port)
+		logger.Infof("Start checking address %s:%v every %s", target.Address, target.Port, target.Interval)
+		udpCheckerReporter := reporters.udpChecker(logger)
+		udpCheckerDaemon := daemon.New(daemon.Configuration{
+			Reporter: reporters.daemon(logger, "udpchecker"),
+			Interval: target.Interval,
+			Tick: func() {
+				udpchecker.Check(target, udpCheckerReporter)
+			},
+		})
+
+		shutdownWg.Add(1)
+		go func() {
+			defer shutdownWg.Done()
+			udpCheckerDaemon.Loop(shutdown)
+		}()
+	}
+
+	for _, icmpCheckerAddress := range *icmpCheckerAddresses {
+		target, err := icmpchecker.ParseTarget(icmpCheckerAddress)
+		if err != nil {
+			log.Errorf("Could not parse icmp-checker %s: %v", icmpCheckerAddress, err)
+			os.Exit(1)
+		}
+		logger := log.
+			With("type", "icmpchecker").
+			With("name", target.Name).
+			With("address", target.Address)
+		logger.Infof("Start pinging address %s every %s", target.Address, target.Interval)
+		icmpCheckerReporter := reporters.icmpChecker(logger)
+		icmpChecker := icmpchecker.NewChecker(target)
+		icmpCheckerDaemon := daemon.New(daemon.Configuration{
+			Reporter: reporters.daemon(logger, "icmpchecker"),
+			Interval: target.Interval,
+			Tick: func() {
+				icmpChecker.Check(icmpCheckerReporter)
+			},
+		})
+
+		shutdownWg.Add(1)
+		go func() {
+			defer shutdownWg.Done()
+			icmpCheckerDaemon.Loop(shutdown)
+		}()
+	}
+
 	if *listenAddress != "" {
 		go func() {
 			// no shutdown mechanism in place for the HTTP server
@@ -146,22 +433,158 @@ func main() {
 	}()
 
 	if len(*socket) != 0 {
-		ikeSAStatusReceivers := []strongswan.IKESAStatusReceiver{
-			prometheusReporter.StrongSwan(),
+		ikeSAStatusReceivers := reporters.strongSwan()
+
+		if *enableRemoteAccessMetrics {
+			ikeSAStatusReceivers = append(ikeSAStatusReceivers, prometheusReporter.RemoteAccess())
+		}
+
+		if *tunnelCheckerConfig != "" {
+			hints, err := readTunnelCheckerHints(*tunnelCheckerConfig)
+			if err != nil {
+				log.Errorf("Could not read tunnel-checker-config %s: %v", *tunnelCheckerConfig, err)
+				os.Exit(1)
+			}
+			tunnelCheckerManager := tunnelchecker.NewManager(log.Base().With("name", "tunnelchecker"), hints, func(target tunnelchecker.Target) func() {
+				logger := log.
+					With("type", "tunnelchecker").
+					With("ike_sa_name", target.IKESAName).
+					With("child_sa_name", target.ChildSAName).
+					With("name", target.Name).
+					With("address", target.Address).
+					With("port", target.Port)
+				logger.Infof("Start checking address %s:%v every %s", target.Address, target.Port, target.Interval)
+				tunnelCheckerReporter := reporters.tunnelChecker(logger, target.IKESAName, target.ChildSAName)
+				if healer != nil {
+					tunnelCheckerReporter = tcpchecker.CompositeReporter(tunnelCheckerReporter, healer.Reporter(target.IKESAName, target.ChildSAName))
+				}
+				tunnelCheckerDaemon := daemon.New(daemon.Configuration{
+					Reporter: reporters.daemon(logger, "tunnelchecker"),
+					Interval: target.Interval,
+					Tick: func() {
+						tcpchecker.Check(target.Target, tunnelCheckerReporter)
+					},
+				})
+				stop := make(chan struct{})
+				stopped := make(chan struct{})
+				go func() {
+					defer close(stopped)
+					tunnelCheckerDaemon.Loop(stop)
+				}()
+				return func() {
+					close(stop)
+					<-stopped
+					prometheusReporter.DeleteTunnelChecker(target)
+					logger.Infof("Stopped checking address %s:%v", target.Address, target.Port)
+				}
+			})
+			ikeSAStatusReceivers = append(ikeSAStatusReceivers, tunnelCheckerManager)
+
+			shutdownWg.Add(1)
+			go func() {
+				defer shutdownWg.Done()
+				<-shutdown
+				tunnelCheckerManager.Stop()
+			}()
+		}
+
+		if healer != nil {
+			ikeSAStatusReceivers = append(ikeSAStatusReceivers, healer)
+		}
+
+		if len(idleRules) != 0 {
+			ikeSAStatusReceivers = append(ikeSAStatusReceivers, strongswan.NewIdleDetector(log.Base().With("name", "idle"), strongswan.IdleConfiguration{
+				Rules: idleRules,
+				Receivers: []strongswan.IdleStatusReceiver{
+					prometheusReporter.Idle(),
+				},
+			}))
+		}
+
+		if len(parsedKeepaliveRules) != 0 {
+			logger := log.Base().With("name", "keepalive")
+			keepaliver := keepalive.New(logger, keepalive.Configuration{
+				Rules:    parsedKeepaliveRules,
+				Reporter: prometheusReporter.Keepalive(),
+			})
+			ikeSAStatusReceivers = append(ikeSAStatusReceivers, keepaliver)
+			keepaliveDaemon := daemon.New(daemon.Configuration{
+				Reporter: reporters.daemon(logger, "keepalive"),
+				Interval: *keepaliveInterval,
+				Tick:     keepaliver.Tick,
+			})
+
+			shutdownWg.Add(1)
+			go func() {
+				defer shutdownWg.Done()
+				keepaliveDaemon.Loop(shutdown)
+			}()
+		}
+
+		if *terminateDuplicates {
+			duplicatesClient := viciClient(&shutdownWg, shutdown, componentDone, log.With("viciClient", "duplicates"), *socket)
+			duplicatesClient.ReadTimeout = 5 * time.Minute
+
+			ikeSAStatusReceivers = append(ikeSAStatusReceivers, strongswan.NewDuplicateTerminator(duplicatesClient, log.Base().With("name", "duplicates"), strongswan.DuplicatesConfiguration{
+				GracePeriod:      *duplicatesGracePeriod,
+				Reporter:         prometheusReporter.Duplicates(),
+				DryRun:           *dryRun,
+				DecisionReporter: prometheusReporter.DryRun(),
+			}))
+		}
+
+		if *terminateStuckIKESAs {
+			watchdogClient := viciClient(&shutdownWg, shutdown, componentDone, log.With("viciClient", "watchdog"), *socket)
+			watchdogClient.ReadTimeout = 5 * time.Minute
+
+			ikeSAStatusReceivers = append(ikeSAStatusReceivers, strongswan.NewWatchdog(watchdogClient, log.Base().With("name", "watchdog"), strongswan.WatchdogConfiguration{
+				Threshold:        *stuckIKESAThreshold,
+				TerminateTimeout: *stuckIKESATerminateTimeout,
+				Reporter:         prometheusReporter.Watchdog(),
+				DryRun:           *dryRun,
+				DecisionReporter: prometheusReporter.DryRun(),
+			}))
+		}
+
+		if *terminateOrphanedIKESAs {
+			orphansClient := viciClient(&shutdownWg, shutdown, componentDone, log.With("viciClient", "orphans"), *socket)
+			orphansClient.ReadTimeout = 5 * time.Minute
+
+			ikeSAStatusReceivers = append(ikeSAStatusReceivers, strongswan.NewOrphanTerminator(orphansClient, log.Base().With("name", "orphans"), strongswan.OrphansConfiguration{
+				GracePeriod:      *orphanedIKESAGracePeriod,
+				Reporter:         prometheusReporter.Orphans(),
+				DryRun:           *dryRun,
+				DecisionReporter: prometheusReporter.DryRun(),
+			}))
 		}
 
 		if *enableReinitiator {
-			reinitiatorClient := viciClient(&shutdownWg, shutdown, componentDone, log.With("viciClient", "reinitiator"), *socket)
-			reinitiatorClient.ReadTimeout = 5 * time.Minute
+			var reinitiatorClients []strongswan.Controller
+			for n := 0; n < *reinitiatorWorkers; n++ {
+				reinitiatorClient := viciClient(&shutdownWg, shutdown, componentDone, log.With("viciClient", fmt.Sprintf("reinitiator-%d", n)), *socket)
+				reinitiatorClient.ReadTimeout = 5 * time.Minute
+				reinitiatorClients = append(reinitiatorClients, reinitiatorClient)
+			}
 
-			ikeSAStatusReceivers = append(ikeSAStatusReceivers, strongswan.NewReinitiator(reinitiatorClient, log.Base().With("name", "reinitiator")))
+			reinitiatorHistory := strongswan.NewInitiationHistory(*reinitiatorHistorySize)
+			reinitiatorHistory.RegisterHandler(httpServer)
+
+			ikeSAStatusReceivers = append(ikeSAStatusReceivers, strongswan.NewReinitiator(reinitiatorClients, log.Base().With("name", "reinitiator"), tracer, strongswan.ReinitiatorConfiguration{
+				Backoff:          reinitiatorBackoff,
+				Policies:         reinitiatorPolicyRules,
+				Reporter:         prometheusReporter.Reinitiator(),
+				History:          reinitiatorHistory,
+				Healer:           healer,
+				DryRun:           *dryRun,
+				DecisionReporter: prometheusReporter.DryRun(),
+			}))
 		}
 
 		client := viciClient(&shutdownWg, shutdown, componentDone, log.With("viciClient", "collector"), *socket)
 		client.ReadTimeout = 60 * time.Second
 
 		d := daemon.New(daemon.Configuration{
-			Reporter: prometheusReporter.Daemon(log.Base().With("name", "strongswan"), "strongswan"),
+			Reporter: reporters.daemon(log.Base().With("name", "strongswan"), "strongswan"),
 			Interval: 2 * time.Second,
 			Tick: func() {
 				strongswan.Collect(client, ikeSAStatusReceivers)
@@ -176,8 +599,27 @@ func main() {
 		}()
 	}
 
+	if reporters.statsd != nil {
+		logger := log.With("name", "statsd")
+		statsdDaemon := daemon.New(daemon.Configuration{
+			Reporter: reporters.daemon(logger, "statsd"),
+			Interval: reporters.statsd.FlushInterval(),
+			Tick:     reporters.statsd.Flush,
+		})
+
+		shutdownWg.Add(1)
+		go func() {
+			defer shutdownWg.Done()
+			statsdDaemon.Loop(shutdown)
+			err := reporters.statsd.Close()
+			if err != nil {
+				logger.Errorf("Failed to close StatsD reporter: %v", err)
+			}
+		}()
+	}
+
 	log.Infof("Strong duckling version %s", version)
-	prometheusReporter.Info(version)
+	reporters.info(version)
 
 	// this is blocking until some component fails of a signal is received
 	reason := <-componentDone
@@ -185,6 +627,14 @@ func main() {
 	close(shutdown)
 	log.Info("waiting for all components to shutdown")
 	shutdownWg.Wait()
+	if otlpExporter != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err := otlpExporter.Shutdown(ctx)
+		cancel()
+		if err != nil {
+			log.Errorf("Failed to shut down OTLP exporter: %v", err)
+		}
+	}
 	if reason != nil {
 		log.Errorf("exited due to error: %v", reason)
 		exitCode = 1
@@ -193,6 +643,175 @@ func main() {
 	}
 }
 
+// reporters combines the enabled metric reporters. The Prometheus reporter is
+// always enabled while the StatsD and OTLP reporters are optional.
+type reporters struct {
+	prometheus *metrics.PrometheusReporter
+	statsd     *statsd.Reporter
+	otlp       *otlp.Reporter
+}
+
+func (r reporters) tcpChecker(logger log.Logger) tcpchecker.Reporter {
+	tcpCheckerReporters := []tcpchecker.Reporter{
+		tcpchecker.LogReporter(logger),
+		r.prometheus.TcpChecker(),
+	}
+	if r.statsd != nil {
+		tcpCheckerReporters = append(tcpCheckerReporters, r.statsd.TcpChecker())
+	}
+	if r.otlp != nil {
+		tcpCheckerReporters = append(tcpCheckerReporters, r.otlp.TcpChecker())
+	}
+	return tcpchecker.CompositeReporter(tcpCheckerReporters...)
+}
+
+func (r reporters) tunnelChecker(logger log.Logger, ikeSAName, childSAName string) tcpchecker.Reporter {
+	tunnelCheckerReporters := []tcpchecker.Reporter{
+		tcpchecker.LogReporter(logger),
+		r.prometheus.TunnelChecker(ikeSAName, childSAName),
+	}
+	if r.statsd != nil {
+		tunnelCheckerReporters = append(tunnelCheckerReporters, r.statsd.TunnelChecker(ikeSAName, childSAName))
+	}
+	if r.otlp != nil {
+		tunnelCheckerReporters = append(tunnelCheckerReporters, r.otlp.TunnelChecker(ikeSAName, childSAName))
+	}
+	return tcpchecker.CompositeReporter(tunnelCheckerReporters...)
+}
+
+func (r reporters) httpChecker(logger log.Logger) httpchecker.Reporter {
+	httpCheckerReporters := []httpchecker.Reporter{
+		httpchecker.LogReporter(logger),
+		r.prometheus.HttpChecker(),
+	}
+	if r.statsd != nil {
+		httpCheckerReporters = append(httpCheckerReporters, r.statsd.HttpChecker())
+	}
+	if r.otlp != nil {
+		httpCheckerReporters = append(httpCheckerReporters, r.otlp.HttpChecker())
+	}
+	return httpchecker.CompositeReporter(httpCheckerReporters...)
+}
+
+func (r reporters) udpChecker(logger log.Logger) udpchecker.Reporter {
+	udpCheckerReporters := []udpchecker.Reporter{
+		udpchecker.LogReporter(logger),
+		r.prometheus.UdpChecker(),
+	}
+	if r.statsd != nil {
+		udpCheckerReporters = append(udpCheckerReporters, r.statsd.UdpChecker())
+	}
+	if r.otlp != nil {
+		udpCheckerReporters = append(udpCheckerReporters, r.otlp.UdpChecker())
+	}
+	return udpchecker.CompositeReporter(udpCheckerReporters...)
+}
+
+func (r reporters) icmpChecker(logger log.Logger) icmpchecker.Reporter {
+	icmpCheckerReporters := []icmpchecker.Reporter{
+		icmpchecker.LogReporter(logger),
+		r.prometheus.IcmpChecker(),
+	}
+	if r.statsd != nil {
+		icmpCheckerReporters = append(icmpCheckerReporters, r.statsd.IcmpChecker())
+	}
+	if r.otlp != nil {
+		icmpCheckerReporters = append(icmpCheckerReporters, r.otlp.IcmpChecker())
+	}
+	return icmpchecker.CompositeReporter(icmpCheckerReporters...)
+}
+
+func (r reporters) strongSwan() []strongswan.IKESAStatusReceiver {
+	ikeSAStatusReceivers := []strongswan.IKESAStatusReceiver{
+		r.prometheus.StrongSwan(),
+		r.prometheus.OrphanedIKESAs(),
+	}
+	if r.statsd != nil {
+		ikeSAStatusReceivers = append(ikeSAStatusReceivers, r.statsd.StrongSwan())
+	}
+	if r.otlp != nil {
+		ikeSAStatusReceivers = append(ikeSAStatusReceivers, r.otlp.StrongSwan())
+	}
+	return ikeSAStatusReceivers
+}
+
+func (r reporters) daemon(logger log.Logger, name string) *daemon.Reporter {
+	daemonReporters := []*daemon.Reporter{
+		r.prometheus.Daemon(logger, name),
+	}
+	if r.statsd != nil {
+		daemonReporters = append(daemonReporters, r.statsd.Daemon(name))
+	}
+	if r.otlp != nil {
+		daemonReporters = append(daemonReporters, r.otlp.Daemon(name))
+	}
+	if len(daemonReporters) == 1 {
+		return daemonReporters[0]
+	}
+	return daemon.CompositeReporter(daemonReporters...)
+}
+
+func (r reporters) info(version string) {
+	r.prometheus.Info(version)
+	if r.statsd != nil {
+		r.statsd.Info(version)
+	}
+	if r.otlp != nil {
+		r.otlp.Info(version)
+	}
+}
+
+// readTcpCheckerTargets reads tcp checker targets from the JSON file at path.
+func readTcpCheckerTargets(path string) ([]tcpchecker.Target, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return tcpchecker.ReadTargets(f)
+}
+
+// readTunnelCheckerHints reads tunnel checker hints from the JSON file at path.
+func readTunnelCheckerHints(path string) ([]tunnelchecker.Hint, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return tunnelchecker.ReadHints(f)
+}
+
+// readReinitiatorPolicies reads reinitiation policies from the JSON file at
+// path. Omitted backoff options are taken from defaultBackoff.
+func readReinitiatorPolicies(path string, defaultBackoff strongswan.BackoffConfiguration) ([]strongswan.PolicyRule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return strongswan.ReadPolicies(f, defaultBackoff)
+}
+
+// reinitiationActions returns the names of all reinitiation actions.
+func reinitiationActions() []string {
+	var actions []string
+	for _, action := range strongswan.ReinitiationActions {
+		actions = append(actions, string(action))
+	}
+	return actions
+}
+
+// readHttpCheckerTargets reads http checker targets from the JSON file at
+// path.
+func readHttpCheckerTargets(path string) ([]httpchecker.Target, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return httpchecker.ReadTargets(f)
+}
+
 // viciClient returns a listening vici.ClientConn controlled by provided life
 // cycle channels.
 func viciClient(shutdownWg *sync.WaitGroup, shutdown chan struct{}, componentDone chan error, log log.Logger, socket string) *vici.ClientConn {